@@ -11,6 +11,7 @@ import (
 
 	"clarity/internal/api"
 	"clarity/internal/config"
+	"clarity/internal/models"
 	"clarity/internal/repository"
 	"clarity/internal/service"
 
@@ -32,7 +33,40 @@ func main() {
 
 	repo := repository.New(db)
 	yandexGPT := service.NewYandexGPTClient(cfg.YandexGPTAPIKey, cfg.YandexGPTFolderID, cfg.YandexGPTModelURI)
-	router := api.NewRouter(repo, cfg.JWTSecret, cfg.MLServiceURL, yandexGPT)
+	categorizer, err := service.NewCategorizer(cfg.CategorizerChain, service.CategorizerDeps{
+		DB:    db,
+		ML:    service.NewMLClient(cfg.MLServiceURL),
+		Rules: service.NewRuleEngine(db),
+	})
+	if err != nil {
+		log.Fatal("Invalid CATEGORIZER_CHAIN: %v", err)
+	}
+	log.Info("Categorizer chain: %v", categorizer.Name())
+
+	// Задания перекатегоризации, прерванные остановкой сервера, продолжаются с места остановки
+	recategorizer := service.NewRecategorizationService(db, categorizer)
+	if resumed, err := recategorizer.ResumeInterrupted(); err != nil {
+		log.Warning("Failed to resume recategorization jobs: %v", err)
+	} else if resumed > 0 {
+		log.Info("Resumed %d recategorization jobs", resumed)
+	}
+
+	blobStore, err := service.NewBlobStore(cfg.BlobStore, cfg.AttachmentsDir, service.S3Config{
+		Endpoint:  cfg.S3Endpoint,
+		Bucket:    cfg.S3Bucket,
+		Region:    cfg.S3Region,
+		AccessKey: cfg.S3AccessKey,
+		SecretKey: cfg.S3SecretKey,
+	})
+	if err != nil {
+		log.Fatal("Invalid BLOB_STORE: %v", err)
+	}
+	attachments := service.NewAttachmentService(db, blobStore, cfg.AttachmentMaxSize, cfg.AttachmentQuota)
+
+	// Один FXService на процесс: SaveRates сбрасывает кэш курсов у всех, кто конвертирует суммы
+	fxService := service.NewFXService(db)
+
+	router := api.NewRouter(repo, cfg, yandexGPT, categorizer, recategorizer, attachments, fxService)
 
 	addr := ":" + cfg.Port
 	server := &http.Server{
@@ -55,6 +89,13 @@ func main() {
 		}
 	}()
 
+	recurring := service.NewRecurringService(db, fxService)
+	go runRecurringScheduler(ctx, recurring, cfg.RecurringInterval, log)
+	if cfg.TrashRetention > 0 {
+		go runTrashPurger(ctx, repo, attachments, cfg.TrashRetention, log)
+	}
+	go runIdempotencyKeyPurger(ctx, repo, log)
+
 	<-ctx.Done()
 	log.Info("Shutting down gracefully...")
 
@@ -67,3 +108,85 @@ func main() {
 		log.Info("Server stopped")
 	}
 }
+
+// runRecurringScheduler - создает транзакции по наступившим регулярным операциям
+// сразу после старта и далее раз в interval
+func runRecurringScheduler(ctx context.Context, recurring *service.RecurringService, interval time.Duration, log *logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		created, err := recurring.MaterializeDue(time.Now())
+		if err != nil {
+			log.Warning("Recurring scheduler: %v", err)
+		}
+		if created > 0 {
+			log.Info("Recurring scheduler: created %d transactions", created)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runTrashPurger - раз в час окончательно удаляет записи, пролежавшие в корзине дольше
+// retention (с записью в журнал изменений их владельцев), и оставшиеся без владельца вложения
+func runTrashPurger(ctx context.Context, repo *repository.Repository, attachments *service.AttachmentService, retention time.Duration, log *logger.Logger) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		var purged int
+		err := repo.Transaction(func(repo *repository.Repository) error {
+			count, records, err := repo.PurgeTrashBefore(time.Now().Add(-retention))
+			if err != nil {
+				return err
+			}
+			purged = count
+			for _, record := range records {
+				actor := service.SystemActor(record.UserID, models.AuditSourceRetention)
+				if err := service.RecordAudit(repo.DB(), actor, record.EntityType, record.ID, models.AuditActionPurge, nil, nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Warning("Trash purger: %v", err)
+		}
+		if purged > 0 {
+			log.Info("Trash purger: purged %d items", purged)
+			if _, err := attachments.PurgeAllOrphans(ctx); err != nil {
+				log.Warning("Trash purger: attachments: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runIdempotencyKeyPurger - раз в час удаляет истекшие ключи Idempotency-Key вместе с
+// сохраненными ответами
+func runIdempotencyKeyPurger(ctx context.Context, repo *repository.Repository, log *logger.Logger) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if _, err := repo.PurgeExpiredIdempotencyKeys(time.Now()); err != nil {
+			log.Warning("Idempotency key purger: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	repo *repository.Repository
+	fx   *service.FXService
+}
+
+func NewAccountHandler(repo *repository.Repository, fx *service.FXService) *AccountHandler {
+	return &AccountHandler{repo: repo, fx: fx}
+}
+
+type CreateAccountRequest struct {
+	Name           string       `json:"name" binding:"required"`
+	Type           string       `json:"type" binding:"required,oneof=card current cash savings"`
+	Currency       string       `json:"currency"` // ISO 4217, по умолчанию базовая валюта пользователя
+	OpeningBalance models.Money `json:"opening_balance"`
+}
+
+type UpdateAccountRequest struct {
+	Name           *string       `json:"name,omitempty"`
+	Type           *string       `json:"type,omitempty" binding:"omitempty,oneof=card current cash savings"`
+	OpeningBalance *models.Money `json:"opening_balance,omitempty"`
+}
+
+type CreateTransferRequest struct {
+	FromAccountID uint         `json:"from_account_id" binding:"required"`
+	ToAccountID   uint         `json:"to_account_id" binding:"required"`
+	Amount        models.Money `json:"amount" binding:"required,gt=0"` // В валюте счета-источника
+	ToAmount      models.Money `json:"to_amount"`                      // Зачислено на счет-получатель (по умолчанию по курсу на дату)
+	Description   string       `json:"description"`
+	Date          string       `json:"date"`
+}
+
+// TransferResponse - перевод вместе с обеими ногами
+type TransferResponse struct {
+	Transfer *models.Transfer    `json:"transfer"`
+	Outgoing *models.Transaction `json:"outgoing"`
+	Incoming *models.Transaction `json:"incoming"`
+}
+
+func (h *AccountHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req CreateAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency := service.BaseCurrency(h.repo.DB(), userID)
+	if req.Currency != "" {
+		currency = service.NormalizeCurrency(req.Currency)
+	}
+	if !service.ValidCurrency(currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency code"})
+		return
+	}
+
+	acc := &models.Account{
+		UserID:         userID,
+		Name:           req.Name,
+		Type:           req.Type,
+		Currency:       currency,
+		OpeningBalance: req.OpeningBalance,
+	}
+	if err := h.repo.CreateAccount(acc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, acc)
+}
+
+func (h *AccountHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	accounts, err := h.repo.GetAccounts(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get accounts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, accounts)
+}
+
+func (h *AccountHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return
+	}
+
+	acc, err := h.repo.GetAccountByID(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+
+	var req UpdateAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name != nil {
+		acc.Name = *req.Name
+	}
+	if req.Type != nil {
+		acc.Type = *req.Type
+	}
+	if req.OpeningBalance != nil {
+		acc.OpeningBalance = *req.OpeningBalance
+	}
+
+	if err := h.repo.UpdateAccount(acc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, acc)
+}
+
+func (h *AccountHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return
+	}
+
+	if _, err := h.repo.GetAccountByID(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+
+	if err := h.repo.DeleteAccount(uint(id), userID); err != nil {
+		if errors.Is(err, repository.ErrAccountInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Account has transactions or recurring rules and cannot be deleted"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+}
+
+// CreateTransfer - перевод между счетами: создает расходную ногу на счете-источнике
+// и доходную ногу на счете-получателе. Ноги не учитываются в доходах и расходах.
+func (h *AccountHandler) CreateTransfer(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req CreateTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.FromAccountID == req.ToAccountID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Source and destination accounts must differ"})
+		return
+	}
+	fromAccount, err := h.repo.GetAccountByID(req.FromAccountID, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Source account not found"})
+		return
+	}
+	toAccount, err := h.repo.GetAccountByID(req.ToAccountID, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Destination account not found"})
+		return
+	}
+
+	date := time.Now()
+	if req.Date != "" {
+		parsed, err := time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+		date = parsed
+	}
+
+	// Сумма зачисления: явно указанная или по курсу на дату перевода
+	toAmount := req.ToAmount
+	if toAmount <= 0 {
+		toAmount, err = h.fx.Convert(userID, req.Amount, fromAccount.Currency, toAccount.Currency, date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	outBase, err := h.fx.ToBase(userID, req.Amount, fromAccount.Currency, date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	inBase, err := h.fx.ToBase(userID, toAmount, toAccount.Currency, date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transfer := &models.Transfer{
+		UserID:        userID,
+		FromAccountID: req.FromAccountID,
+		ToAccountID:   req.ToAccountID,
+		Amount:        req.Amount,
+		Currency:      fromAccount.Currency,
+		ToAmount:      toAmount,
+		ToCurrency:    toAccount.Currency,
+		Description:   req.Description,
+		Date:          date,
+	}
+	outLeg := &models.Transaction{
+		UserID:      userID,
+		AccountID:   req.FromAccountID,
+		Amount:      req.Amount,
+		Currency:    fromAccount.Currency,
+		BaseAmount:  outBase,
+		Description: req.Description,
+		Category:    models.TransferCategory,
+		Date:        date,
+		Type:        "expense",
+	}
+	inLeg := &models.Transaction{
+		UserID:      userID,
+		AccountID:   req.ToAccountID,
+		Amount:      toAmount,
+		Currency:    toAccount.Currency,
+		BaseAmount:  inBase,
+		Description: req.Description,
+		Category:    models.TransferCategory,
+		Date:        date,
+		Type:        "income",
+	}
+	// Расходная нога хранится отрицательной, как и любой расход
+	outLeg.NormalizeSign()
+	inLeg.NormalizeSign()
+	// Категория ног перевода фиксирована и проверки не требует
+	outLeg.SetCategorySource(models.CategorySourceManual, 1)
+	inLeg.SetCategorySource(models.CategorySourceManual, 1)
+
+	err = h.repo.Transaction(func(repo *repository.Repository) error {
+		if err := repo.CreateTransfer(transfer, outLeg, inLeg); err != nil {
+			return err
+		}
+		for _, leg := range []*models.Transaction{outLeg, inLeg} {
+			if err := service.RecordAudit(repo.DB(), service.UserActor(userID, models.AuditSourceAPI), models.AuditEntityTransaction, leg.ID,
+				models.AuditActionCreate, nil, service.AuditSnapshot(leg)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transfer"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, TransferResponse{
+		Transfer: transfer,
+		Outgoing: outLeg,
+		Incoming: inLeg,
+	})
+}
+
+// DeleteTransfer - переносит перевод в корзину вместе с обеими ногами
+func (h *AccountHandler) DeleteTransfer(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+		return
+	}
+
+	if _, err := h.repo.GetTransferByID(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return
+	}
+	legs, err := h.repo.GetTransferLegs(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transfer"})
+		return
+	}
+
+	err = h.repo.Transaction(func(repo *repository.Repository) error {
+		if err := repo.DeleteTransfer(uint(id), userID); err != nil {
+			return err
+		}
+		for i := range legs {
+			if err := service.RecordAudit(repo.DB(), service.UserActor(userID, models.AuditSourceAPI), models.AuditEntityTransaction, legs[i].ID,
+				models.AuditActionDelete, service.AuditSnapshot(&legs[i]), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transfer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer deleted"})
+}
@@ -4,120 +4,89 @@ import (
 	"clarity/internal/api/middleware"
 	"clarity/internal/models"
 	"clarity/internal/repository"
+	"clarity/internal/service"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type AnalyticsHandler struct {
 	repo *repository.Repository
+	fx   *service.FXService
 }
 
-func NewAnalyticsHandler(repo *repository.Repository) *AnalyticsHandler {
-	return &AnalyticsHandler{repo: repo}
+func NewAnalyticsHandler(repo *repository.Repository, fx *service.FXService) *AnalyticsHandler {
+	return &AnalyticsHandler{repo: repo, fx: fx}
 }
 
 type SummaryResponse struct {
-	Month               string             `json:"month"`
-	TotalIncome         float64            `json:"total_income"`
-	TotalExpense        float64            `json:"total_expense"`
-	Balance             float64            `json:"balance"`             // Баланс за месяц
-	TotalBalance        float64            `json:"total_balance"`      // Общий баланс (сумма всех транзакций)
-	SavingsRate         float64            `json:"savings_rate"`
-	ByCategory          map[string]float64 `json:"by_category"`
-	EssentialExpense    float64            `json:"essential_expense"`
-	NonEssentialExpense float64            `json:"non_essential_expense"`
+	Currency            string                   `json:"currency"` // Базовая валюта, в которой посчитаны суммы
+	Month               string                   `json:"month"`
+	TotalIncome         models.Money             `json:"total_income"`
+	TotalExpense        models.Money             `json:"total_expense"`
+	Balance             models.Money             `json:"balance"`       // Баланс за месяц
+	TotalBalance        models.Money             `json:"total_balance"` // Общий баланс (сумма остатков по всем счетам)
+	Accounts            []service.AccountBalance `json:"accounts"`      // Остатки по счетам
+	SavingsRate         float64                  `json:"savings_rate"`
+	ByCategory          map[string]models.Money  `json:"by_category"`
+	EssentialExpense    models.Money             `json:"essential_expense"`
+	NonEssentialExpense models.Money             `json:"non_essential_expense"`
+	UnreviewedExpense   models.Money             `json:"unreviewed_expense"` // Расходы с непроверенной категорией
+	UnreviewedCount     int                      `json:"unreviewed_count"`   // Транзакции в очереди проверки за месяц
 }
 
 type TrendData struct {
-	Month   string  `json:"month"`
-	Income  float64 `json:"income"`
-	Expense float64 `json:"expense"`
-	Balance float64 `json:"balance"`
+	Month   string       `json:"month"`
+	Income  models.Money `json:"income"`
+	Expense models.Money `json:"expense"`
+	Balance models.Money `json:"balance"`
 }
 
 type TrendsResponse struct {
-	Months []TrendData `json:"months"`
+	Currency string      `json:"currency"`
+	Months   []TrendData `json:"months"`
 }
 
 func (h *AnalyticsHandler) Summary(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	month := c.DefaultQuery("month", time.Now().Format("2006-01"))
 
-	startDate := month + "-01"
-	// Получаем последний день месяца
-	monthTime, _ := time.Parse("2006-01", month)
-	lastDay := monthTime.AddDate(0, 1, 0).AddDate(0, 0, -1)
-	endDate := lastDay.Format("2006-01-02")
+	startDate, endDate := service.MonthRange(month)
 
 	db := h.repo.DB()
 
-	var totalIncome, totalExpense, essentialExpense float64
+	period := service.PeriodQuery(db, userID, startDate, endDate)
+	totals := service.SumTotals(period)
 
-	// Доходы
-	db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'income' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&totalIncome)
-
-	// Расходы (amount уже отрицательный, берем абсолютное значение для отображения)
-	db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(ABS(amount)), 0)").
-		Scan(&totalExpense)
-
-	// Обязательные расходы (amount уже отрицательный, берем абсолютное значение)
-	db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND is_essential = true AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(ABS(amount)), 0)").
-		Scan(&essentialExpense)
-
-	nonEssentialExpense := totalExpense - essentialExpense
-
-	// По категориям
-	var categoryData []struct {
-		Category string
-		Amount   float64
-	}
-	db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("category, COALESCE(SUM(ABS(amount)), 0) as amount").
-		Group("category").
-		Find(&categoryData)
-
-	byCategory := make(map[string]float64)
-	for _, item := range categoryData {
-		byCategory[item.Category] = item.Amount
+	byCategory := make(map[string]models.Money)
+	for _, item := range h.expenseByCategory(c, userID, period) {
+		byCategory[item.Category] += item.Amount
 	}
 
-	// balance = доходы - расходы (расходы уже положительные после ABS)
-	balance := totalIncome - totalExpense
-	savingsRate := 0.0
-	if totalIncome > 0 {
-		savingsRate = (balance / totalIncome) * 100
+	// Общий баланс - сумма остатков по всем счетам (начальный остаток + движения)
+	accounts := service.AccountBalances(db, h.fx, userID, "")
+	var totalBalance models.Money
+	for _, acc := range accounts {
+		totalBalance += acc.BaseBalance
 	}
 
-	// Общий баланс (сумма всех транзакций за все время)
-	// Расходы хранятся как отрицательные числа (-350), доходы - положительные (3506)
-	// Поэтому просто суммируем все amount: 3506 + (-350) = 3156
-	var totalBalance float64
-	db.Model(&models.Transaction{}).
-		Where("user_id = ?", userID).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&totalBalance)
-
 	c.JSON(http.StatusOK, SummaryResponse{
+		Currency:            service.BaseCurrency(db, userID),
 		Month:               month,
-		TotalIncome:         totalIncome,
-		TotalExpense:        totalExpense,
-		Balance:             balance,
+		TotalIncome:         totals.Income,
+		TotalExpense:        totals.Expense,
+		Balance:             totals.Net(),
 		TotalBalance:        totalBalance,
-		SavingsRate:         savingsRate,
+		Accounts:            accounts,
+		SavingsRate:         totals.SavingsRate(),
 		ByCategory:          byCategory,
-		EssentialExpense:    essentialExpense,
-		NonEssentialExpense: nonEssentialExpense,
+		EssentialExpense:    totals.Essential,
+		NonEssentialExpense: totals.NonEssential(),
+		UnreviewedExpense:   totals.Unreviewed,
+		UnreviewedCount:     totals.UnreviewedCount,
 	})
 }
 
@@ -144,38 +113,66 @@ func (h *AnalyticsHandler) Trends(c *gin.Context) {
 	for i := monthsCount - 1; i >= 0; i-- {
 		monthDate := now.AddDate(0, -i, 0)
 		month := monthDate.Format("2006-01")
-		startDate := month + "-01"
-		lastDay := monthDate.AddDate(0, 1, 0).AddDate(0, 0, -1)
-		endDate := lastDay.Format("2006-01-02")
-
-		var income, expense float64
+		startDate, endDate := service.MonthRange(month)
 
-		db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'income' AND date >= ? AND date <= ?", userID, startDate, endDate).
-			Select("COALESCE(SUM(amount), 0)").
-			Scan(&income)
-
-		db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, startDate, endDate).
-			Select("COALESCE(SUM(ABS(amount)), 0)").
-			Scan(&expense)
+		totals := service.PeriodTotals(db, userID, startDate, endDate)
 
 		trends = append(trends, TrendData{
 			Month:   month,
-			Income:  income,
-			Expense: expense,
-			Balance: income - expense,
+			Income:  totals.Income,
+			Expense: totals.Expense,
+			Balance: totals.Net(),
 		})
 	}
 
-	c.JSON(http.StatusOK, TrendsResponse{Months: trends})
+	c.JSON(http.StatusOK, TrendsResponse{Currency: service.BaseCurrency(db, userID), Months: trends})
 }
 
 // CategoryDistributionResponse - ответ с распределением по категориям в процентах
 type CategoryDistributionResponse struct {
 	Month        string             `json:"month"`
-	TotalExpense float64            `json:"total_expense"` // Общая сумма расходов
-	Distribution map[string]float64  `json:"distribution"`  // Категория -> процент (0-100)
+	TotalExpense models.Money       `json:"total_expense"` // Общая сумма расходов
+	Distribution map[string]float64 `json:"distribution"`  // Категория -> процент (0-100)
+}
+
+// TagDistributionResponse - суммы по меткам за период
+type TagDistributionResponse struct {
+	Currency     string              `json:"currency"`
+	StartDate    string              `json:"start_date"`
+	EndDate      string              `json:"end_date"`
+	TotalExpense models.Money        `json:"total_expense"`    // Все расходы периода
+	Untagged     models.Money        `json:"untagged_expense"` // Расходы без меток
+	Tags         []service.TagAmount `json:"tags"`
+}
+
+// TagDistribution - расходы, доходы, инвестиции и вклады по меткам за период.
+// Период - month (по умолчанию текущий) или start_date/end_date.
+func (h *AnalyticsHandler) TagDistribution(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	startDate, endDate, _, ok := analyticsPeriod(c)
+	if !ok {
+		return
+	}
+
+	db := h.repo.DB()
+	period := service.PeriodQuery(db, userID, startDate, endDate)
+	totalExpense := service.SumTotals(period).Expense
+	untagged := service.SumTotals(period.Where("NOT EXISTS (SELECT 1 FROM transaction_tags WHERE transaction_tags.transaction_id = lines.id)")).Expense
+
+	tags, err := service.TagBreakdown(db, userID, startDate, endDate, totalExpense)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tag analytics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TagDistributionResponse{
+		Currency:     service.BaseCurrency(db, userID),
+		StartDate:    startDate,
+		EndDate:      endDate,
+		TotalExpense: totalExpense,
+		Untagged:     untagged,
+		Tags:         tags,
+	})
 }
 
 // CategoryDistribution - возвращает распределение расходов по категориям в процентах для круговой диаграммы
@@ -183,19 +180,11 @@ func (h *AnalyticsHandler) CategoryDistribution(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	month := c.DefaultQuery("month", time.Now().Format("2006-01"))
 
-	startDate := month + "-01"
-	monthTime, _ := time.Parse("2006-01", month)
-	lastDay := monthTime.AddDate(0, 1, 0).AddDate(0, 0, -1)
-	endDate := lastDay.Format("2006-01-02")
+	startDate, endDate := service.MonthRange(month)
+	period := service.PeriodQuery(h.repo.DB(), userID, startDate, endDate)
 
-	db := h.repo.DB()
-
-	// Получаем общую сумму расходов за месяц
-	var totalExpense float64
-	db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(ABS(amount)), 0)").
-		Scan(&totalExpense)
+	// Общая сумма расходов за месяц
+	totalExpense := service.SumTotals(period).Expense
 
 	// Если нет расходов, возвращаем пустое распределение
 	if totalExpense == 0 {
@@ -207,47 +196,30 @@ func (h *AnalyticsHandler) CategoryDistribution(c *gin.Context) {
 		return
 	}
 
-	// Получаем суммы по категориям
-	var categoryData []struct {
-		Category string
-		Amount   float64
-	}
-	db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("category, COALESCE(SUM(ABS(amount)), 0) as amount").
-		Group("category").
-		Find(&categoryData)
-
-	// Вычисляем проценты
-	distribution := make(map[string]float64)
-	var totalPercentage float64
-	
+	categoryData := h.expenseByCategory(c, userID, period)
+
+	// Суммы без категории попадают в категорию по умолчанию
+	var categories []string
+	var amounts []models.Money
+	index := make(map[string]int)
 	for _, item := range categoryData {
 		if item.Category == "" {
-			item.Category = "Другое"
+			item.Category = models.DefaultCategory
+		}
+		if i, ok := index[item.Category]; ok {
+			amounts[i] += item.Amount
+			continue
 		}
-		percentage := (item.Amount / totalExpense) * 100
-		distribution[item.Category] = percentage
-		totalPercentage += percentage
+		index[item.Category] = len(categories)
+		categories = append(categories, item.Category)
+		amounts = append(amounts, item.Amount)
 	}
 
-	// Нормализация: если сумма не равна 100 (из-за округления), корректируем
-	// Находим категорию с максимальным процентом и корректируем её
-	if len(distribution) > 0 && totalPercentage != 100.0 {
-		diff := 100.0 - totalPercentage
-		// Находим категорию с максимальным процентом
-		maxCategory := ""
-		maxValue := 0.0
-		for cat, val := range distribution {
-			if val > maxValue {
-				maxValue = val
-				maxCategory = cat
-			}
-		}
-		// Корректируем самую большую категорию
-		if maxCategory != "" {
-			distribution[maxCategory] += diff
-		}
+	// Проценты считаются в целых сотых долях и в сумме дают ровно 100
+	shares := models.PercentShares(amounts)
+	distribution := make(map[string]float64, len(categories))
+	for i, category := range categories {
+		distribution[category] = shares[i]
 	}
 
 	c.JSON(http.StatusOK, CategoryDistributionResponse{
@@ -256,3 +228,203 @@ func (h *AnalyticsHandler) CategoryDistribution(c *gin.Context) {
 		Distribution: distribution,
 	})
 }
+
+// expenseByCategory - расходы по категориям; при rollup=true суммы подкатегорий
+// сворачиваются в категории верхнего уровня
+func (h *AnalyticsHandler) expenseByCategory(c *gin.Context, userID uint, period *gorm.DB) []service.CategoryAmount {
+	amounts := service.ExpenseByCategory(period)
+	if c.Query("rollup") != "true" {
+		return amounts
+	}
+	categories, err := h.repo.GetCategories(userID, "expense")
+	if err != nil {
+		return amounts
+	}
+	return service.RollupByRoot(categories, amounts)
+}
+
+// CategoryTreeResponse - дерево категорий с суммами за месяц
+type CategoryTreeResponse struct {
+	Currency   string                  `json:"currency"`
+	Month      string                  `json:"month"`
+	Type       string                  `json:"type"`
+	Total      models.Money            `json:"total"`
+	Categories []*service.CategoryNode `json:"categories"`
+}
+
+// CategoryTree - суммы за месяц по дереву категорий: у каждой категории своя сумма
+// и сумма вместе с подкатегориями
+func (h *AnalyticsHandler) CategoryTree(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	month := c.DefaultQuery("month", time.Now().Format("2006-01"))
+	categoryType := c.DefaultQuery("type", "expense")
+	if categoryType != "expense" && categoryType != "income" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be income or expense"})
+		return
+	}
+
+	startDate, endDate := service.MonthRange(month)
+	db := h.repo.DB()
+	period := service.PeriodQuery(db, userID, startDate, endDate)
+
+	var amounts []service.CategoryAmount
+	var total models.Money
+	if categoryType == "expense" {
+		amounts = service.ExpenseByCategory(period)
+		total = service.SumTotals(period).Expense
+	} else {
+		amounts = service.IncomeByCategory(period)
+		total = service.SumTotals(period).Income
+	}
+
+	categories, err := h.repo.GetCategories(userID, categoryType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, CategoryTreeResponse{
+		Currency:   service.BaseCurrency(db, userID),
+		Month:      month,
+		Type:       categoryType,
+		Total:      total,
+		Categories: service.CategoryTree(categories, amounts),
+	})
+}
+
+// AccountBalancesResponse - остатки по счетам
+type AccountBalancesResponse struct {
+	Currency     string                   `json:"currency"`
+	TotalBalance models.Money             `json:"total_balance"`
+	Accounts     []service.AccountBalance `json:"accounts"`
+}
+
+// AccountBalances - текущие остатки по всем счетам пользователя
+func (h *AnalyticsHandler) AccountBalances(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	asOf := c.Query("date")
+	if asOf != "" {
+		if _, err := time.Parse("2006-01-02", asOf); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+	}
+
+	accounts := service.AccountBalances(h.repo.DB(), h.fx, userID, asOf)
+	var total models.Money
+	for _, acc := range accounts {
+		total += acc.BaseBalance
+	}
+
+	c.JSON(http.StatusOK, AccountBalancesResponse{
+		Currency:     service.BaseCurrency(h.repo.DB(), userID),
+		TotalBalance: total,
+		Accounts:     accounts,
+	})
+}
+
+// AccountBalanceHistory - история остатка по счету на конец каждого месяца
+func (h *AnalyticsHandler) AccountBalanceHistory(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return
+	}
+
+	account, err := h.repo.GetAccountByID(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+
+	monthsCount := 6
+	if m := c.Query("months"); m != "" {
+		if parsed, err := strconv.Atoi(m); err == nil {
+			monthsCount = parsed
+			if monthsCount < 1 {
+				monthsCount = 1
+			}
+			if monthsCount > 24 {
+				monthsCount = 24
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"account_id": account.ID,
+		"currency":   account.Currency,
+		"history":    service.AccountBalanceHistory(h.repo.DB(), account, monthsCount),
+	})
+}
+
+// analyticsPeriod - период из month (по умолчанию текущий месяц) или start_date/end_date
+// (без end_date - по сегодня) и его длина в днях; при ошибке ответ уже отправлен
+func analyticsPeriod(c *gin.Context) (string, string, int, bool) {
+	startDate, endDate := service.MonthRange(c.DefaultQuery("month", time.Now().Format("2006-01")))
+	if c.Query("start_date") != "" || c.Query("end_date") != "" {
+		startDate, endDate = c.Query("start_date"), c.DefaultQuery("end_date", time.Now().Format("2006-01-02"))
+	}
+	start, startErr := time.Parse("2006-01-02", startDate)
+	end, endErr := time.Parse("2006-01-02", endDate)
+	if startErr != nil || endErr != nil || end.Before(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period. Use month=YYYY-MM or start_date and end_date as YYYY-MM-DD"})
+		return "", "", 0, false
+	}
+	return startDate, endDate, int(end.Sub(start).Hours()/24) + 1, true
+}
+
+// MerchantsResponse - траты по продавцам за период
+type MerchantsResponse struct {
+	Currency  string                 `json:"currency"`
+	StartDate string                 `json:"start_date"`
+	EndDate   string                 `json:"end_date"`
+	Type      string                 `json:"type"`
+	Total     models.Money           `json:"total"` // Сумма по всем продавцам периода
+	Merchants []service.MerchantStat `json:"merchants"`
+}
+
+// Merchants - топ продавцов за период: сумма, частота посещений и средний чек.
+// Период - month (по умолчанию текущий) или start_date/end_date.
+func (h *AnalyticsHandler) Merchants(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	txType := c.DefaultQuery("type", "expense")
+	if txType != "expense" && txType != "income" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be income or expense"})
+		return
+	}
+
+	startDate, endDate, days, ok := analyticsPeriod(c)
+	if !ok {
+		return
+	}
+
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil {
+			limit = parsed
+			if limit < 1 {
+				limit = 1
+			}
+			if limit > 100 {
+				limit = 100
+			}
+		}
+	}
+
+	db := h.repo.DB()
+	merchants, total, err := service.TopMerchants(db, userID, txType, startDate, endDate, days, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get merchant analytics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MerchantsResponse{
+		Currency:  service.BaseCurrency(db, userID),
+		StartDate: startDate,
+		EndDate:   endDate,
+		Type:      txType,
+		Total:     total,
+		Merchants: merchants,
+	})
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MultipartOverhead - запас на заголовки multipart сверх предельного размера файла
+const MultipartOverhead = 1 << 20
+
+type AttachmentHandler struct {
+	repo        *repository.Repository
+	attachments *service.AttachmentService
+}
+
+func NewAttachmentHandler(repo *repository.Repository, attachments *service.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{repo: repo, attachments: attachments}
+}
+
+func (h *AttachmentHandler) UploadToTransaction(c *gin.Context) {
+	h.upload(c, models.AttachmentOwnerTransaction)
+}
+
+func (h *AttachmentHandler) UploadToDeposit(c *gin.Context) {
+	h.upload(c, models.AttachmentOwnerDeposit)
+}
+
+func (h *AttachmentHandler) UploadToInvestment(c *gin.Context) {
+	h.upload(c, models.AttachmentOwnerInvestment)
+}
+
+func (h *AttachmentHandler) ListForTransaction(c *gin.Context) {
+	h.list(c, models.AttachmentOwnerTransaction)
+}
+
+func (h *AttachmentHandler) ListForDeposit(c *gin.Context) {
+	h.list(c, models.AttachmentOwnerDeposit)
+}
+
+func (h *AttachmentHandler) ListForInvestment(c *gin.Context) {
+	h.list(c, models.AttachmentOwnerInvestment)
+}
+
+// upload - multipart-загрузка файла (поле file) к записи из пути
+func (h *AttachmentHandler) upload(c *gin.Context, ownerType string) {
+	userID := middleware.GetUserID(c)
+	ownerID, ok := h.owner(c, ownerType, userID)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.attachments.MaxSize()+MultipartOverhead)
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File is larger than %d bytes", h.attachments.MaxSize())})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+		return
+	}
+	if file.Size > h.attachments.MaxSize() {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File is larger than %d bytes", h.attachments.MaxSize())})
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to open file"})
+		return
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, h.attachments.MaxSize()+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	attachment, err := h.attachments.Upload(c.Request.Context(), userID, ownerType, ownerID, file.Filename, data)
+	switch {
+	case errors.Is(err, service.ErrAttachmentTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File is larger than %d bytes", h.attachments.MaxSize())})
+	case errors.Is(err, service.ErrAttachmentQuota):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Attachment storage quota exceeded"})
+	case errors.Is(err, service.ErrAttachmentType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Only JPEG, PNG, GIF, WebP images and PDF documents are allowed"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+	default:
+		c.JSON(http.StatusCreated, attachment)
+	}
+}
+
+func (h *AttachmentHandler) list(c *gin.Context, ownerType string) {
+	userID := middleware.GetUserID(c)
+	ownerID, ok := h.owner(c, ownerType, userID)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.repo.GetAttachments(userID, ownerType, ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attachments"})
+		return
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// owner - id записи из пути, если она принадлежит пользователю; иначе ответ 400/404 уже отправлен
+func (h *AttachmentHandler) owner(c *gin.Context, ownerType string, userID uint) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + ownerType + " ID"})
+		return 0, false
+	}
+	switch ownerType {
+	case models.AttachmentOwnerTransaction:
+		_, err = h.repo.GetTransactionByID(uint(id), userID)
+	case models.AttachmentOwnerDeposit:
+		_, err = h.repo.GetDepositByID(uint(id), userID)
+	case models.AttachmentOwnerInvestment:
+		_, err = h.repo.GetInvestmentByID(uint(id), userID)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// Usage - занятое вложениями место и квота
+func (h *AttachmentHandler) Usage(c *gin.Context) {
+	usage, err := h.attachments.Usage(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attachment usage"})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+// Get - метаданные вложения
+func (h *AttachmentHandler) Get(c *gin.Context) {
+	attachment, ok := h.attachment(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, attachment)
+}
+
+// Download - содержимое вложения; ?inline=true - для показа в браузере
+func (h *AttachmentHandler) Download(c *gin.Context) {
+	attachment, ok := h.attachment(c)
+	if !ok {
+		return
+	}
+	disposition := "attachment"
+	if c.Query("inline") == "true" {
+		disposition = "inline"
+	}
+	h.send(c, attachment, false, attachment.ContentType, attachment.Size, disposition)
+}
+
+// Thumbnail - миниатюра изображения в JPEG
+func (h *AttachmentHandler) Thumbnail(c *gin.Context) {
+	attachment, ok := h.attachment(c)
+	if !ok {
+		return
+	}
+	if !attachment.HasThumbnail {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment has no thumbnail"})
+		return
+	}
+	h.send(c, attachment, true, "image/jpeg", attachment.ThumbnailSize, "inline")
+}
+
+func (h *AttachmentHandler) send(c *gin.Context, attachment *models.Attachment, thumbnail bool, contentType string, size int64, disposition string) {
+	content, err := h.attachments.Open(c.Request.Context(), attachment, thumbnail)
+	if errors.Is(err, service.ErrBlobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment content not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to read attachment"})
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, size, contentType, content, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}),
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, max-age=3600",
+	})
+}
+
+func (h *AttachmentHandler) Delete(c *gin.Context) {
+	attachment, ok := h.attachment(c)
+	if !ok {
+		return
+	}
+	if err := h.attachments.Delete(c.Request.Context(), attachment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted"})
+}
+
+// attachment - вложение из пути, если оно принадлежит пользователю; иначе ответ уже отправлен
+func (h *AttachmentHandler) attachment(c *gin.Context) (*models.Attachment, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return nil, false
+	}
+	attachment, err := h.repo.GetAttachmentByID(uint(id), middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return nil, false
+	}
+	return attachment, true
+}
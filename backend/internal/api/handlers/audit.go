@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	repo *repository.Repository
+}
+
+func NewAuditHandler(repo *repository.Repository) *AuditHandler {
+	return &AuditHandler{repo: repo}
+}
+
+func (h *AuditHandler) TransactionHistory(c *gin.Context) {
+	h.history(c, models.AuditEntityTransaction)
+}
+
+func (h *AuditHandler) DepositHistory(c *gin.Context) {
+	h.history(c, models.AuditEntityDeposit)
+}
+
+func (h *AuditHandler) InvestmentHistory(c *gin.Context) {
+	h.history(c, models.AuditEntityInvestment)
+}
+
+// history - история изменений записи, новые первыми. История остается доступной и после
+// удаления записи.
+func (h *AuditHandler) history(c *gin.Context, entityType string) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + entityType + " ID"})
+		return
+	}
+
+	entries, err := h.repo.GetAuditHistory(userID, entityType, uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get history"})
+		return
+	}
+	if len(entries) == 0 {
+		// Записи, созданные до появления журнала, истории не имеют
+		switch entityType {
+		case models.AuditEntityTransaction:
+			_, err = h.repo.GetTransactionByID(uint(id), userID)
+		case models.AuditEntityDeposit:
+			_, err = h.repo.GetDepositByID(uint(id), userID)
+		case models.AuditEntityInvestment:
+			_, err = h.repo.GetInvestmentByID(uint(id), userID)
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// ActivityResponse - страница ленты изменений
+type ActivityResponse struct {
+	Entries  []models.AuditEntry `json:"entries"`
+	BeforeID uint                `json:"before_id,omitempty"` // Передать в before_id для следующей страницы; пусто - последняя страница
+}
+
+// Activity - лента изменений всех записей пользователя, новые первыми
+func (h *AuditHandler) Activity(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	filter := repository.ActivityFilter{
+		EntityType: c.Query("entity_type"),
+		Action:     c.Query("action"),
+		Source:     c.Query("source"),
+		Limit:      50,
+	}
+	if l := c.Query("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		filter.Limit = min(limit, 200)
+	}
+	if before := c.Query("before_id"); before != "" {
+		id, err := strconv.ParseUint(before, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before_id"})
+			return
+		}
+		filter.BeforeID = uint(id)
+	}
+
+	// Лишняя запись показывает, есть ли следующая страница
+	limit := filter.Limit
+	filter.Limit++
+	entries, err := h.repo.GetActivity(userID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get activity"})
+		return
+	}
+
+	response := ActivityResponse{Entries: entries}
+	if len(entries) > limit {
+		response.Entries = entries[:limit]
+		response.BeforeID = entries[limit-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// colorPattern - цвет категории в формате #RRGGBB
+var colorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+type CategoryHandler struct {
+	repo *repository.Repository
+}
+
+func NewCategoryHandler(repo *repository.Repository) *CategoryHandler {
+	return &CategoryHandler{repo: repo}
+}
+
+type CreateCategoryRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Type        string `json:"type" binding:"required,oneof=income expense"`
+	ParentID    *uint  `json:"parent_id"`
+	Icon        string `json:"icon"`
+	Color       string `json:"color"`
+	IsEssential bool   `json:"is_essential"`
+}
+
+type UpdateCategoryRequest struct {
+	ParentID    *uint   `json:"parent_id,omitempty"` // 0 - перенести на верхний уровень
+	Icon        *string `json:"icon,omitempty"`
+	Color       *string `json:"color,omitempty"`
+	IsEssential *bool   `json:"is_essential,omitempty"`
+}
+
+type RenameCategoryRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type MergeCategoryRequest struct {
+	TargetID uint `json:"target_id" binding:"required"`
+}
+
+// categoryID - ID категории из пути запроса
+func categoryID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// categoryErrorResponse - ответ на ошибку репозитория категорий
+func categoryErrorResponse(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, repository.ErrCategoryExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Category with this name already exists; use merge to combine categories"})
+	case errors.Is(err, repository.ErrInvalidParent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent category must exist, have the same type and not be a subcategory of this category"})
+	case errors.Is(err, repository.ErrCategoryInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "Category has transactions, recurring rules or subcategories; merge it into another category instead"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " category"})
+	}
+}
+
+// Create - новая категория в справочнике пользователя
+func (h *CategoryHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req CreateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category name is required"})
+		return
+	}
+	if req.Color != "" && !colorPattern.MatchString(req.Color) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Color must be in #RRGGBB format"})
+		return
+	}
+
+	cat := &models.Category{
+		UserID:      userID,
+		Type:        req.Type,
+		Name:        name,
+		ParentID:    req.ParentID,
+		Icon:        req.Icon,
+		Color:       req.Color,
+		IsEssential: req.IsEssential,
+	}
+	if cat.ParentID != nil && *cat.ParentID == 0 {
+		cat.ParentID = nil
+	}
+
+	if err := h.repo.CreateCategory(cat); err != nil {
+		categoryErrorResponse(c, err, "create")
+		return
+	}
+
+	c.JSON(http.StatusCreated, cat)
+}
+
+// List - справочник категорий (type=income|expense - только одного типа)
+func (h *CategoryHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	categories, err := h.repo.GetCategories(userID, c.Query("type"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+// Update - иконка, цвет, признак обязательности по умолчанию и родитель категории
+func (h *CategoryHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, ok := categoryID(c)
+	if !ok {
+		return
+	}
+
+	cat, err := h.repo.GetCategoryByID(id, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	var req UpdateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			cat.ParentID = nil
+		} else {
+			cat.ParentID = req.ParentID
+		}
+	}
+	if req.Icon != nil {
+		cat.Icon = *req.Icon
+	}
+	if req.Color != nil {
+		if *req.Color != "" && !colorPattern.MatchString(*req.Color) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Color must be in #RRGGBB format"})
+			return
+		}
+		cat.Color = *req.Color
+	}
+	if req.IsEssential != nil {
+		cat.IsEssential = *req.IsEssential
+	}
+
+	if err := h.repo.UpdateCategory(cat); err != nil {
+		categoryErrorResponse(c, err, "update")
+		return
+	}
+
+	c.JSON(http.StatusOK, cat)
+}
+
+// Delete - удаление неиспользуемой категории без подкатегорий
+func (h *CategoryHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, ok := categoryID(c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.repo.GetCategoryByID(id, userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	if err := h.repo.DeleteCategory(id, userID); err != nil {
+		categoryErrorResponse(c, err, "delete")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted"})
+}
+
+// rewriteHistory - выполняет rewrite, переписывающий категорию cat в истории, и записывает
+// изменения затронутых транзакций в журнал в той же транзакции БД
+func (h *CategoryHandler) rewriteHistory(cat *models.Category, rewrite func(repo *repository.Repository) error) error {
+	return h.repo.Transaction(func(repo *repository.Repository) error {
+		ids, err := repo.CategoryTransactionIDs(cat.UserID, cat.Type, cat.Name)
+		if err != nil {
+			return err
+		}
+		return service.RecordTransactionUpdates(repo.DB(), service.UserActor(cat.UserID, models.AuditSourceAPI), ids, func() error {
+			return rewrite(repo)
+		})
+	})
+}
+
+// Rename - переименование категории с переписыванием истории транзакций
+func (h *CategoryHandler) Rename(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, ok := categoryID(c)
+	if !ok {
+		return
+	}
+
+	cat, err := h.repo.GetCategoryByID(id, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	var req RenameCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category name is required"})
+		return
+	}
+	if name == cat.Name {
+		c.JSON(http.StatusOK, cat)
+		return
+	}
+
+	err = h.rewriteHistory(cat, func(repo *repository.Repository) error {
+		return repo.RenameCategory(cat, name)
+	})
+	if err != nil {
+		categoryErrorResponse(c, err, "rename")
+		return
+	}
+
+	c.JSON(http.StatusOK, cat)
+}
+
+// Merge - слияние категории с другой категорией того же типа: транзакции, части разбивки
+// и регулярные операции переходят в target, подкатегории - тоже, сама категория удаляется
+func (h *CategoryHandler) Merge(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, ok := categoryID(c)
+	if !ok {
+		return
+	}
+
+	source, err := h.repo.GetCategoryByID(id, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	var req MergeCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target, err := h.repo.GetCategoryByID(req.TargetID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target category not found"})
+		return
+	}
+	if target.ID == source.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge a category into itself"})
+		return
+	}
+	if target.Type != source.Type {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Categories must have the same type"})
+		return
+	}
+	// Подкатегории source переходят в target, поэтому target не может быть потомком source
+	if descendant, err := h.repo.IsDescendant(target.ID, source.ID); err != nil || descendant {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge a category into its own subcategory"})
+		return
+	}
+
+	err = h.rewriteHistory(source, func(repo *repository.Repository) error {
+		return repo.MergeCategory(source, target)
+	})
+	if err != nil {
+		categoryErrorResponse(c, err, "merge")
+		return
+	}
+
+	c.JSON(http.StatusOK, target)
+}
@@ -17,14 +17,16 @@ import (
 type ChatHandler struct {
 	repo          *repository.Repository
 	yandexGPT     *service.YandexGPTClient
+	fx            *service.FXService
 	healthService *service.HealthScoreService
 }
 
-func NewChatHandler(repo *repository.Repository, yandexGPT *service.YandexGPTClient) *ChatHandler {
+func NewChatHandler(repo *repository.Repository, yandexGPT *service.YandexGPTClient, fx *service.FXService) *ChatHandler {
 	return &ChatHandler{
 		repo:          repo,
 		yandexGPT:     yandexGPT,
-		healthService: service.NewHealthScoreService(repo.DB()),
+		fx:            fx,
+		healthService: service.NewHealthScoreService(repo.DB(), fx),
 	}
 }
 
@@ -80,7 +82,7 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 	}
 
 	// Отправляем в YandexGPT
-	response, err := h.yandexGPT.Chat(messages)
+	response, err := h.yandexGPT.Chat(c.Request.Context(), messages)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("AI service error: %v", err)})
 		return
@@ -123,6 +125,10 @@ func (h *ChatHandler) GetHistory(c *gin.Context) {
 func (h *ChatHandler) buildFinancialContext(userID uint) string {
 	var context strings.Builder
 
+	// Все суммы в контексте приводятся к базовой валюте пользователя
+	baseCurrency := service.BaseCurrency(h.repo.DB(), userID)
+	sym := service.CurrencySymbol(baseCurrency)
+
 	// Health Score
 	month := time.Now().Format("2006-01")
 	healthScore, err := h.healthService.Calculate(userID, month)
@@ -136,59 +142,35 @@ func (h *ChatHandler) buildFinancialContext(userID uint) string {
 
 	// Аналитика за текущий месяц
 	db := h.repo.DB()
-	startDate := month + "-01"
 	monthTime, _ := time.Parse("2006-01", month)
-	lastDay := monthTime.AddDate(0, 1, 0).AddDate(0, 0, -1)
-	endDate := lastDay.Format("2006-01-02")
-
-	var totalIncome, totalExpense float64
-	db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'income' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&totalIncome)
-
-	db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&totalExpense)
+	startDate, endDate := service.MonthRange(month)
+	period := service.PeriodQuery(db, userID, startDate, endDate)
 
+	totals := service.SumTotals(period)
+	totalIncome, totalExpense := totals.Income, totals.Expense
 	balance := totalIncome - totalExpense
 
-	// Общий баланс (свободные средства) - сумма всех доходов минус расходы
-	var totalBalance float64
-	db.Model(&models.Transaction{}).
-		Where("user_id = ?", userID).
-		Select("COALESCE(SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END), 0)").
-		Scan(&totalBalance)
+	// Общий баланс (свободные средства) - суммарный остаток по всем счетам
+	totalBalance := service.TotalBalance(db, h.fx, userID, "")
 
-	context.WriteString(fmt.Sprintf("Финансы за %s:\n", month))
-	context.WriteString(fmt.Sprintf("- Доходы: %.2f₽\n", totalIncome))
-	context.WriteString(fmt.Sprintf("- Расходы: %.2f₽\n", totalExpense))
-	context.WriteString(fmt.Sprintf("- Баланс за месяц: %.2f₽\n", balance))
-	context.WriteString(fmt.Sprintf("- Свободные средства (общий баланс): %.2f₽\n\n", totalBalance))
+	context.WriteString(fmt.Sprintf("Финансы за %s (валюта: %s):\n", month, baseCurrency))
+	context.WriteString(fmt.Sprintf("- Доходы: %s%s\n", totalIncome, sym))
+	context.WriteString(fmt.Sprintf("- Расходы: %s%s\n", totalExpense, sym))
+	context.WriteString(fmt.Sprintf("- Баланс за месяц: %s%s\n", balance, sym))
+	context.WriteString(fmt.Sprintf("- Свободные средства (общий баланс): %s%s\n\n", totalBalance, sym))
 
 	// Разбивка расходов по категориям за месяц
-	var categoryData []struct {
-		Category string
-		Amount   float64
-		Count    int
-	}
-	db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("category, COALESCE(SUM(amount), 0) as amount, COUNT(*) as count").
-		Group("category").
-		Order("amount desc").
-		Find(&categoryData)
+	categoryData := service.ExpenseByCategory(period)
 
 	if len(categoryData) > 0 {
 		context.WriteString("Расходы по категориям за месяц:\n")
 		for _, item := range categoryData {
 			percent := 0.0
 			if totalExpense > 0 {
-				percent = (item.Amount / totalExpense) * 100
+				percent = item.Amount.Ratio(totalExpense) * 100
 			}
-			context.WriteString(fmt.Sprintf("- %s: %.2f₽ (%.1f%%, %d транзакций)\n",
-				item.Category, item.Amount, percent, item.Count))
+			context.WriteString(fmt.Sprintf("- %s: %s%s (%.1f%%, %d транзакций)\n",
+				item.Category, item.Amount, sym, percent, item.Count))
 		}
 		context.WriteString("\n")
 	}
@@ -196,26 +178,15 @@ func (h *ChatHandler) buildFinancialContext(userID uint) string {
 	// Сравнение с предыдущим месяцем
 	prevMonthTime := monthTime.AddDate(0, -1, 0)
 	prevMonth := prevMonthTime.Format("2006-01")
-	prevStartDate := prevMonth + "-01"
-	prevLastDay := prevMonthTime.AddDate(0, 1, 0).AddDate(0, 0, -1)
-	prevEndDate := prevLastDay.Format("2006-01-02")
-
-	var prevIncome, prevExpense float64
-	db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'income' AND date >= ? AND date <= ?", userID, prevStartDate, prevEndDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&prevIncome)
-
-	db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, prevStartDate, prevEndDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&prevExpense)
+	prevStartDate, prevEndDate := service.MonthRange(prevMonth)
+	prevTotals := service.PeriodTotals(db, userID, prevStartDate, prevEndDate)
+	prevIncome, prevExpense := prevTotals.Income, prevTotals.Expense
 
 	if prevIncome > 0 || prevExpense > 0 {
 		context.WriteString(fmt.Sprintf("Сравнение с предыдущим месяцем (%s):\n", prevMonth))
 		if prevIncome > 0 {
-			change := ((totalIncome - prevIncome) / prevIncome) * 100
-			context.WriteString(fmt.Sprintf("- Доходы: %.2f₽ → %.2f₽ (", prevIncome, totalIncome))
+			change := (totalIncome - prevIncome).Ratio(prevIncome) * 100
+			context.WriteString(fmt.Sprintf("- Доходы: %s%s → %s%s (", prevIncome, sym, totalIncome, sym))
 			if change > 0 {
 				context.WriteString(fmt.Sprintf("+%.1f%%)\n", change))
 			} else {
@@ -223,8 +194,8 @@ func (h *ChatHandler) buildFinancialContext(userID uint) string {
 			}
 		}
 		if prevExpense > 0 {
-			change := ((totalExpense - prevExpense) / prevExpense) * 100
-			context.WriteString(fmt.Sprintf("- Расходы: %.2f₽ → %.2f₽ (", prevExpense, totalExpense))
+			change := (totalExpense - prevExpense).Ratio(prevExpense) * 100
+			context.WriteString(fmt.Sprintf("- Расходы: %s%s → %s%s (", prevExpense, sym, totalExpense, sym))
 			if change > 0 {
 				context.WriteString(fmt.Sprintf("+%.1f%%)\n", change))
 			} else {
@@ -236,8 +207,8 @@ func (h *ChatHandler) buildFinancialContext(userID uint) string {
 
 	// Самая дорогая транзакция по расходам (для быстрого ответа на частые вопросы)
 	var maxExpenseTx models.Transaction
-	result := db.Where("user_id = ? AND type = 'expense'", userID).
-		Order("amount desc").
+	result := db.Where("user_id = ? AND type = 'expense' AND transfer_id IS NULL", userID).
+		Order("base_amount asc").
 		First(&maxExpenseTx)
 	if result.Error == nil && maxExpenseTx.ID > 0 {
 		essential := ""
@@ -246,8 +217,8 @@ func (h *ChatHandler) buildFinancialContext(userID uint) string {
 		} else {
 			essential = " [необязательное]"
 		}
-		context.WriteString(fmt.Sprintf("Самая дорогая расходная транзакция (одна конкретная транзакция): %.2f₽ | Категория: %s%s | Описание: %s | Дата: %s\n\n",
-			maxExpenseTx.Amount, maxExpenseTx.Category, essential, maxExpenseTx.Description, maxExpenseTx.Date.Format("02.01.2006")))
+		context.WriteString(fmt.Sprintf("Самая дорогая расходная транзакция (одна конкретная транзакция): %s%s | Категория: %s%s | Описание: %s | Дата: %s\n\n",
+			maxExpenseTx.Amount, service.CurrencySymbol(maxExpenseTx.Currency), maxExpenseTx.Category, essential, maxExpenseTx.Description, maxExpenseTx.Date.Format("02.01.2006")))
 	}
 
 	// Последние транзакции (топ-20 для лучшего контекста)
@@ -271,8 +242,8 @@ func (h *ChatHandler) buildFinancialContext(userID uint) string {
 				essential = " [необязательное]"
 			}
 			// Четко разделяем доходы и расходы для AI
-			context.WriteString(fmt.Sprintf("- [%s] %s: %.2f₽ | Категория: %s%s | Описание: %s\n",
-				txType, tx.Date.Format("02.01.2006"), tx.Amount, tx.Category, essential, tx.Description))
+			context.WriteString(fmt.Sprintf("- [%s] %s: %s%s | Категория: %s%s | Описание: %s\n",
+				txType, tx.Date.Format("02.01.2006"), tx.Amount, service.CurrencySymbol(tx.Currency), tx.Category, essential, tx.Description))
 		}
 		context.WriteString("\n")
 	}
@@ -281,27 +252,27 @@ func (h *ChatHandler) buildFinancialContext(userID uint) string {
 	var investments []models.Investment
 	db.Where("user_id = ?", userID).Find(&investments)
 	if len(investments) > 0 {
-		var totalInv, totalValue float64
+		var totalInv, totalValue models.Money
 		for _, inv := range investments {
-			totalInv += inv.Amount
-			if inv.CurrentValue > 0 {
-				totalValue += inv.CurrentValue
+			totalInv += inv.BaseAmount
+			if inv.BaseCurrentValue > 0 {
+				totalValue += inv.BaseCurrentValue
 			} else {
-				totalValue += inv.Amount
+				totalValue += inv.BaseAmount
 			}
 		}
-		context.WriteString(fmt.Sprintf("Инвестиции: %.2f₽ (текущая стоимость: %.2f₽)\n\n", totalInv, totalValue))
+		context.WriteString(fmt.Sprintf("Инвестиции: %s%s (текущая стоимость: %s%s)\n\n", totalInv, sym, totalValue, sym))
 	}
 
 	// Вклады
 	var deposits []models.Deposit
 	db.Where("user_id = ? AND close_date IS NULL", userID).Find(&deposits)
 	if len(deposits) > 0 {
-		var totalDep float64
+		var totalDep models.Money
 		for _, dep := range deposits {
-			totalDep += dep.Amount
+			totalDep += dep.BaseAmount
 		}
-		context.WriteString(fmt.Sprintf("Активные вклады: %.2f₽\n\n", totalDep))
+		context.WriteString(fmt.Sprintf("Активные вклады: %s%s\n\n", totalDep, sym))
 	}
 
 	return context.String()
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trainingHeaders - колонки обучающей выборки ML сервиса (как в ml/ci_data.csv)
+var trainingHeaders = []string{"Date", "Category", "RefNo", "Date", "Withdrawal", "Deposit", "Balance"}
+
+type CorrectionHandler struct {
+	repo *repository.Repository
+}
+
+func NewCorrectionHandler(repo *repository.Repository) *CorrectionHandler {
+	return &CorrectionHandler{repo: repo}
+}
+
+// List - последние ручные исправления категорий
+func (h *CorrectionHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	corrections, err := h.repo.GetCategoryCorrections(userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get corrections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, corrections)
+}
+
+// Stats - статистика исправлений: сколько, какие категории и на что исправляли
+func (h *CorrectionHandler) Stats(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	stats, err := h.repo.GetCorrectionStats(userID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get correction stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// ExportTrainingData - CSV в формате обучающей выборки ML сервиса. По умолчанию только
+// исправленные пользователем транзакции, all=true - все транзакции с категориями.
+func (h *CorrectionHandler) ExportTrainingData(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	all := c.Query("all") == "true"
+
+	txs, err := h.repo.GetTrainingTransactions(userID, all)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transactions"})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=training_%s.csv", time.Now().Format("20060102_150405")))
+
+	writer := csv.NewWriter(c.Writer)
+	defer writer.Flush()
+
+	if err := writer.Write(trainingHeaders); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write CSV header"})
+		return
+	}
+
+	for _, tx := range txs {
+		if err := writer.Write(trainingRecord(tx)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write CSV record"})
+			return
+		}
+	}
+}
+
+// trainingRecord - строка обучающей выборки. ref_no подставляется так же, как при запросе
+// к ML сервису (если его нет - описание); дата в формате D/M/YYYY, расход - в Withdrawal,
+// доход - в Deposit. Остаток ноутбук обучения не использует, колонка остается пустой.
+func trainingRecord(tx models.Transaction) []string {
+	refNo := tx.RefNo
+	if refNo == "" {
+		refNo = tx.Description
+	}
+	date := tx.Date.Format("2/1/2006")
+	withdrawal, deposit := models.Money(0), models.Money(0)
+	if tx.Amount < 0 {
+		withdrawal = tx.Amount.Abs()
+	} else {
+		deposit = tx.Amount
+	}
+	return []string{date, tx.Category, refNo, date, withdrawal.String(), deposit.String(), ""}
+}
+
+// ListMerchants - выученные категории продавцов
+func (h *CorrectionHandler) ListMerchants(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	memory, err := h.repo.GetMerchantCategories(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get merchant categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, memory)
+}
+
+// DeleteMerchant - забыть категорию продавца; новые транзакции снова категоризируют правила и ML
+func (h *CorrectionHandler) DeleteMerchant(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant category ID"})
+		return
+	}
+
+	if err := h.repo.DeleteMerchantCategory(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merchant category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Merchant category deleted"})
+}
@@ -4,6 +4,7 @@ import (
 	"clarity/internal/api/middleware"
 	"clarity/internal/models"
 	"clarity/internal/repository"
+	"clarity/internal/service"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,27 +14,31 @@ import (
 
 type DepositHandler struct {
 	repo *repository.Repository
+	fx   *service.FXService
 }
 
-func NewDepositHandler(repo *repository.Repository) *DepositHandler {
-	return &DepositHandler{repo: repo}
+func NewDepositHandler(repo *repository.Repository, fx *service.FXService) *DepositHandler {
+	return &DepositHandler{repo: repo, fx: fx}
 }
 
 type CreateDepositRequest struct {
-	Amount       float64 `json:"amount" binding:"required"`
-	InterestRate float64 `json:"interest_rate"`
-	Description  string  `json:"description"`
-	OpenDate     string  `json:"open_date"`
-	TermMonths   int     `json:"term_months"`
+	Amount       models.Money `json:"amount" binding:"required"`
+	Currency     string       `json:"currency"` // ISO 4217, по умолчанию базовая валюта пользователя
+	InterestRate float64      `json:"interest_rate"`
+	Description  string       `json:"description"`
+	OpenDate     string       `json:"open_date"`
+	TermMonths   int          `json:"term_months"`
+	Tags         []string     `json:"tags"` // Имена меток; отсутствующие создаются
 }
 
 type UpdateDepositRequest struct {
-	Amount       float64 `json:"amount"`
-	InterestRate float64 `json:"interest_rate"`
-	Description  string  `json:"description"`
-	OpenDate     string  `json:"open_date"`
-	CloseDate    string  `json:"close_date"`
-	TermMonths   int     `json:"term_months"`
+	Amount       models.Money `json:"amount"`
+	InterestRate float64      `json:"interest_rate"`
+	Description  string       `json:"description"`
+	OpenDate     string       `json:"open_date"`
+	CloseDate    string       `json:"close_date"`
+	TermMonths   int          `json:"term_months"`
+	Tags         *[]string    `json:"tags,omitempty"` // Заменяют прежние метки
 }
 
 func (h *DepositHandler) Create(c *gin.Context) {
@@ -52,6 +57,7 @@ func (h *DepositHandler) Create(c *gin.Context) {
 	dep := &models.Deposit{
 		UserID:       userID,
 		Amount:       req.Amount,
+		Currency:     service.NormalizeCurrency(req.Currency),
 		InterestRate: req.InterestRate,
 		Description:  req.Description,
 		TermMonths:   req.TermMonths,
@@ -69,7 +75,29 @@ func (h *DepositHandler) Create(c *gin.Context) {
 		dep.OpenDate = time.Now()
 	}
 
-	if err := h.repo.CreateDeposit(dep); err != nil {
+	if req.Currency == "" {
+		dep.Currency = service.BaseCurrency(h.repo.DB(), userID)
+	}
+	if !service.ValidCurrency(dep.Currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency code"})
+		return
+	}
+	if err := h.convertDeposit(userID, dep); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err := h.repo.Transaction(func(repo *repository.Repository) error {
+		var err error
+		if dep.Tags, err = service.EnsureTags(repo.DB(), userID, req.Tags); err != nil {
+			return err
+		}
+		if err := repo.CreateDeposit(dep); err != nil {
+			return err
+		}
+		return service.RecordAudit(repo.DB(), service.UserActor(userID, models.AuditSourceAPI), models.AuditEntityDeposit, dep.ID,
+			models.AuditActionCreate, nil, service.AuditSnapshot(dep))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create deposit"})
 		return
 	}
@@ -84,17 +112,19 @@ func (h *DepositHandler) List(c *gin.Context) {
 		return
 	}
 
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	filter, ok := listFilter(c)
+	if !ok {
+		return
+	}
 	activeOnly := c.DefaultQuery("active_only", "false") == "true"
 
-	deps, err := h.repo.GetDeposits(userID, limit, offset, activeOnly)
+	page, err := h.repo.ListDeposits(userID, activeOnly, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deposits"})
+		listError(c, err, "Failed to fetch deposits")
 		return
 	}
 
-	c.JSON(http.StatusOK, deps)
+	c.JSON(http.StatusOK, page)
 }
 
 func (h *DepositHandler) Update(c *gin.Context) {
@@ -115,6 +145,7 @@ func (h *DepositHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Deposit not found"})
 		return
 	}
+	before := service.AuditSnapshot(dep)
 
 	var req UpdateDepositRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -154,7 +185,24 @@ func (h *DepositHandler) Update(c *gin.Context) {
 		dep.CloseDate = nil
 	}
 
-	if err := h.repo.UpdateDeposit(dep); err != nil {
+	if err := h.convertDeposit(userID, dep); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.repo.Transaction(func(repo *repository.Repository) error {
+		if req.Tags != nil {
+			var err error
+			if dep.Tags, err = service.EnsureTags(repo.DB(), userID, *req.Tags); err != nil {
+				return err
+			}
+		}
+		if err := repo.UpdateDeposit(dep); err != nil {
+			return err
+		}
+		return service.RecordAudit(repo.DB(), service.UserActor(userID, models.AuditSourceAPI), models.AuditEntityDeposit, dep.ID,
+			models.AuditActionUpdate, before, service.AuditSnapshot(dep))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update deposit"})
 		return
 	}
@@ -175,10 +223,33 @@ func (h *DepositHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.DeleteDeposit(uint(id), userID); err != nil {
+	dep, err := h.repo.GetDepositByID(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deposit not found"})
+		return
+	}
+
+	err = h.repo.Transaction(func(repo *repository.Repository) error {
+		if err := repo.DeleteDeposit(uint(id), userID); err != nil {
+			return err
+		}
+		return service.RecordAudit(repo.DB(), service.UserActor(userID, models.AuditSourceAPI), models.AuditEntityDeposit, dep.ID,
+			models.AuditActionDelete, service.AuditSnapshot(dep), nil)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete deposit"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Deposit deleted"})
 }
+
+// convertDeposit - сумма вклада в базовой валюте по курсу на дату открытия
+func (h *DepositHandler) convertDeposit(userID uint, dep *models.Deposit) error {
+	base, err := h.fx.ToBase(userID, dep.Amount, dep.Currency, dep.OpenDate)
+	if err != nil {
+		return err
+	}
+	dep.BaseAmount = base
+	return nil
+}
@@ -27,7 +27,7 @@ func (h *ForecastHandler) Analyze(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
 	// Получаем все транзакции пользователя
-	transactions, err := h.repo.GetTransactions(userID, 1000, 0, "", "", "")
+	transactions, err := h.repo.GetTransactions(userID, 1000, 0, "", "", "", nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transactions"})
 		return
@@ -41,16 +41,20 @@ func (h *ForecastHandler) Analyze(c *gin.Context) {
 	// Преобразуем в формат для ML сервиса
 	analyzeTransactions := make([]service.AnalyzeTransaction, 0, len(transactions))
 	for _, tx := range transactions {
+		// Переводы между счетами не влияют на доходы и расходы
+		if tx.TransferID != nil {
+			continue
+		}
 		analyzeTransactions = append(analyzeTransactions, service.AnalyzeTransaction{
 			Date:        tx.Date.Format(time.RFC3339),
-			Amount:      tx.Amount,
+			Amount:      tx.BaseAmount,
 			Category:    tx.Category,
 			IsEssential: tx.IsEssential,
 		})
 	}
 
 	// Вызываем ML сервис
-	result, err := h.forecastClient.Analyze(analyzeTransactions)
+	result, err := h.forecastClient.Analyze(c.Request.Context(), analyzeTransactions)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "ML service error: " + err.Error()})
 		return
@@ -64,7 +68,7 @@ func (h *ForecastHandler) Predict(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
 	// Получаем все транзакции пользователя
-	transactions, err := h.repo.GetTransactions(userID, 1000, 0, "", "", "")
+	transactions, err := h.repo.GetTransactions(userID, 1000, 0, "", "", "", nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transactions"})
 		return
@@ -78,16 +82,20 @@ func (h *ForecastHandler) Predict(c *gin.Context) {
 	// Преобразуем в формат для ML сервиса
 	analyzeTransactions := make([]service.AnalyzeTransaction, 0, len(transactions))
 	for _, tx := range transactions {
+		// Переводы между счетами не влияют на доходы и расходы
+		if tx.TransferID != nil {
+			continue
+		}
 		analyzeTransactions = append(analyzeTransactions, service.AnalyzeTransaction{
 			Date:        tx.Date.Format(time.RFC3339),
-			Amount:      tx.Amount,
+			Amount:      tx.BaseAmount,
 			Category:    tx.Category,
 			IsEssential: tx.IsEssential,
 		})
 	}
 
 	// Вызываем ML сервис
-	result, err := h.forecastClient.Analyze(analyzeTransactions)
+	result, err := h.forecastClient.Analyze(c.Request.Context(), analyzeTransactions)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "ML service error: " + err.Error()})
 		return
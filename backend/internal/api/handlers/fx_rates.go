@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FXRateHandler struct {
+	repo   *repository.Repository
+	fx     *service.FXService
+	client *service.FXRatesClient
+}
+
+func NewFXRateHandler(repo *repository.Repository, fx *service.FXService, client *service.FXRatesClient) *FXRateHandler {
+	return &FXRateHandler{repo: repo, fx: fx, client: client}
+}
+
+// FXRatesUpdateResponse - результат загрузки курсов и пересчета сумм в базовой валюте
+type FXRatesUpdateResponse struct {
+	Saved      int      `json:"saved"`      // Сохранено курсов
+	Recomputed int      `json:"recomputed"` // Записей пересчитано в базовую валюту
+	Missing    int      `json:"missing"`    // Записей без курса (сумма в базовой валюте не обновлена)
+	Errors     []string `json:"errors"`     // Ошибки разбора строк
+}
+
+// List - курсы валют пользователя (последние сначала)
+func (h *FXRateHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	query := h.repo.DB().Where("user_id = ?", userID)
+	if currency := c.Query("currency"); currency != "" {
+		currency = service.NormalizeCurrency(currency)
+		query = query.Where("currency = ? OR quote_currency = ?", currency, currency)
+	}
+
+	var rates []models.FXRate
+	if err := query.Order("date desc, currency asc").Limit(limit).Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get FX rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// Import - загрузка курсов из CSV с колонками date, currency, quote_currency, rate
+func (h *FXRateHandler) Import(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to open file"})
+		return
+	}
+	defer src.Close()
+
+	reader := csv.NewReader(src)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	headers, err := reader.Read()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read CSV header: " + err.Error()})
+		return
+	}
+	headerMap := make(map[string]int)
+	for i, h := range headers {
+		headerMap[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	for _, field := range []string{"date", "currency", "rate"} {
+		if _, ok := headerMap[field]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Missing required field: %s", field)})
+			return
+		}
+	}
+
+	baseCurrency := service.BaseCurrency(h.repo.DB(), userID)
+	var rates []models.FXRate
+	var errors []string
+	rowNum := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		rowNum++
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("Row %d: %v", rowNum, err))
+			continue
+		}
+
+		rate, err := parseFXRateRecord(record, headerMap, baseCurrency)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("Row %d: %v", rowNum, err))
+			continue
+		}
+		rate.UserID = userID
+		rate.Source = "csv"
+		rates = append(rates, rate)
+	}
+
+	h.saveAndRecalculate(c, userID, rates, errors)
+}
+
+// Sync - загрузка курсов на дату из сервиса курсов (FX_RATES_URL)
+func (h *FXRateHandler) Sync(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	if !h.client.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "FX rates endpoint is not configured"})
+		return
+	}
+
+	date := time.Now()
+	if dateStr := c.Query("date"); dateStr != "" {
+		parsed, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+		date = parsed
+	}
+
+	resp, err := h.client.Fetch(c.Request.Context(), service.BaseCurrency(h.repo.DB(), userID), date)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	rateDate, err := time.Parse("2006-01-02", resp.Date)
+	if err != nil {
+		rateDate = date
+	}
+
+	// 1 единица Base = Rates[code] единиц code
+	base := service.NormalizeCurrency(resp.Base)
+	var rates []models.FXRate
+	for code, value := range resp.Rates {
+		code = service.NormalizeCurrency(code)
+		if code == base || value <= 0 || !service.ValidCurrency(code) {
+			continue
+		}
+		rates = append(rates, models.FXRate{
+			UserID:        userID,
+			Date:          rateDate,
+			Currency:      base,
+			QuoteCurrency: code,
+			Rate:          value,
+			Source:        "endpoint",
+		})
+	}
+
+	h.saveAndRecalculate(c, userID, rates, nil)
+}
+
+func (h *FXRateHandler) saveAndRecalculate(c *gin.Context, userID uint, rates []models.FXRate, errors []string) {
+	if err := h.fx.SaveRates(rates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save FX rates"})
+		return
+	}
+
+	updated, missing, err := h.fx.RecalculateBaseAmounts(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recalculate base amounts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, FXRatesUpdateResponse{
+		Saved:      len(rates),
+		Recomputed: updated,
+		Missing:    missing,
+		Errors:     errors,
+	})
+}
+
+// parseFXRateRecord - разбор строки CSV с курсом; quote_currency по умолчанию - базовая валюта
+func parseFXRateRecord(record []string, headerMap map[string]int, baseCurrency string) (models.FXRate, error) {
+	field := func(name string) string {
+		if idx, ok := headerMap[name]; ok && idx < len(record) {
+			return strings.TrimSpace(record[idx])
+		}
+		return ""
+	}
+
+	date, err := time.Parse("2006-01-02", field("date"))
+	if err != nil {
+		return models.FXRate{}, fmt.Errorf("invalid date format (expected YYYY-MM-DD)")
+	}
+
+	currency := service.NormalizeCurrency(field("currency"))
+	quote := baseCurrency
+	if q := field("quote_currency"); q != "" {
+		quote = service.NormalizeCurrency(q)
+	}
+	if !service.ValidCurrency(currency) || !service.ValidCurrency(quote) || currency == quote {
+		return models.FXRate{}, fmt.Errorf("invalid currency pair %s/%s", currency, quote)
+	}
+
+	rate, err := strconv.ParseFloat(strings.ReplaceAll(field("rate"), ",", "."), 64)
+	if err != nil || rate <= 0 {
+		return models.FXRate{}, fmt.Errorf("invalid rate: %s", field("rate"))
+	}
+
+	return models.FXRate{
+		Date:          date,
+		Currency:      currency,
+		QuoteCurrency: quote,
+		Rate:          rate,
+	}, nil
+}
@@ -14,9 +14,9 @@ type HealthScoreHandler struct {
 	healthService *service.HealthScoreService
 }
 
-func NewHealthScoreHandler(repo *repository.Repository) *HealthScoreHandler {
+func NewHealthScoreHandler(repo *repository.Repository, fx *service.FXService) *HealthScoreHandler {
 	return &HealthScoreHandler{
-		healthService: service.NewHealthScoreService(repo.DB()),
+		healthService: service.NewHealthScoreService(repo.DB(), fx),
 	}
 }
 
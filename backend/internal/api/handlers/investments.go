@@ -4,6 +4,7 @@ import (
 	"clarity/internal/api/middleware"
 	"clarity/internal/models"
 	"clarity/internal/repository"
+	"clarity/internal/service"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,26 +14,30 @@ import (
 
 type InvestmentHandler struct {
 	repo *repository.Repository
+	fx   *service.FXService
 }
 
-func NewInvestmentHandler(repo *repository.Repository) *InvestmentHandler {
-	return &InvestmentHandler{repo: repo}
+func NewInvestmentHandler(repo *repository.Repository, fx *service.FXService) *InvestmentHandler {
+	return &InvestmentHandler{repo: repo, fx: fx}
 }
 
 type CreateInvestmentRequest struct {
-	Amount       float64 `json:"amount" binding:"required"`
-	Type         string  `json:"type"`
-	Description  string  `json:"description"`
-	CurrentValue float64 `json:"current_value"`
-	Date         string  `json:"date"`
+	Amount       models.Money `json:"amount" binding:"required"`
+	Currency     string       `json:"currency"` // ISO 4217, по умолчанию базовая валюта пользователя
+	Type         string       `json:"type"`
+	Description  string       `json:"description"`
+	CurrentValue models.Money `json:"current_value"`
+	Date         string       `json:"date"`
+	Tags         []string     `json:"tags"` // Имена меток; отсутствующие создаются
 }
 
 type UpdateInvestmentRequest struct {
-	Amount       float64 `json:"amount"`
-	Type         string  `json:"type"`
-	Description  string  `json:"description"`
-	CurrentValue float64 `json:"current_value"`
-	Date         string  `json:"date"`
+	Amount       models.Money `json:"amount"`
+	Type         string       `json:"type"`
+	Description  string       `json:"description"`
+	CurrentValue models.Money `json:"current_value"`
+	Date         string       `json:"date"`
+	Tags         *[]string    `json:"tags,omitempty"` // Заменяют прежние метки
 }
 
 func (h *InvestmentHandler) Create(c *gin.Context) {
@@ -51,6 +56,7 @@ func (h *InvestmentHandler) Create(c *gin.Context) {
 	inv := &models.Investment{
 		UserID:       userID,
 		Amount:       req.Amount,
+		Currency:     service.NormalizeCurrency(req.Currency),
 		Type:         req.Type,
 		Description:  req.Description,
 		CurrentValue: req.CurrentValue,
@@ -68,7 +74,29 @@ func (h *InvestmentHandler) Create(c *gin.Context) {
 		inv.Date = time.Now()
 	}
 
-	if err := h.repo.CreateInvestment(inv); err != nil {
+	if req.Currency == "" {
+		inv.Currency = service.BaseCurrency(h.repo.DB(), userID)
+	}
+	if !service.ValidCurrency(inv.Currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency code"})
+		return
+	}
+	if err := h.convertInvestment(userID, inv); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err := h.repo.Transaction(func(repo *repository.Repository) error {
+		var err error
+		if inv.Tags, err = service.EnsureTags(repo.DB(), userID, req.Tags); err != nil {
+			return err
+		}
+		if err := repo.CreateInvestment(inv); err != nil {
+			return err
+		}
+		return service.RecordAudit(repo.DB(), service.UserActor(userID, models.AuditSourceAPI), models.AuditEntityInvestment, inv.ID,
+			models.AuditActionCreate, nil, service.AuditSnapshot(inv))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create investment"})
 		return
 	}
@@ -83,16 +111,18 @@ func (h *InvestmentHandler) List(c *gin.Context) {
 		return
 	}
 
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	filter, ok := listFilter(c)
+	if !ok {
+		return
+	}
 
-	invs, err := h.repo.GetInvestments(userID, limit, offset)
+	page, err := h.repo.ListInvestments(userID, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch investments"})
+		listError(c, err, "Failed to fetch investments")
 		return
 	}
 
-	c.JSON(http.StatusOK, invs)
+	c.JSON(http.StatusOK, page)
 }
 
 func (h *InvestmentHandler) Update(c *gin.Context) {
@@ -113,6 +143,7 @@ func (h *InvestmentHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Investment not found"})
 		return
 	}
+	before := service.AuditSnapshot(inv)
 
 	var req UpdateInvestmentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -141,7 +172,24 @@ func (h *InvestmentHandler) Update(c *gin.Context) {
 		inv.Date = date
 	}
 
-	if err := h.repo.UpdateInvestment(inv); err != nil {
+	if err := h.convertInvestment(userID, inv); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.repo.Transaction(func(repo *repository.Repository) error {
+		if req.Tags != nil {
+			var err error
+			if inv.Tags, err = service.EnsureTags(repo.DB(), userID, *req.Tags); err != nil {
+				return err
+			}
+		}
+		if err := repo.UpdateInvestment(inv); err != nil {
+			return err
+		}
+		return service.RecordAudit(repo.DB(), service.UserActor(userID, models.AuditSourceAPI), models.AuditEntityInvestment, inv.ID,
+			models.AuditActionUpdate, before, service.AuditSnapshot(inv))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update investment"})
 		return
 	}
@@ -162,10 +210,38 @@ func (h *InvestmentHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.DeleteInvestment(uint(id), userID); err != nil {
+	inv, err := h.repo.GetInvestmentByID(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Investment not found"})
+		return
+	}
+
+	err = h.repo.Transaction(func(repo *repository.Repository) error {
+		if err := repo.DeleteInvestment(uint(id), userID); err != nil {
+			return err
+		}
+		return service.RecordAudit(repo.DB(), service.UserActor(userID, models.AuditSourceAPI), models.AuditEntityInvestment, inv.ID,
+			models.AuditActionDelete, service.AuditSnapshot(inv), nil)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete investment"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Investment deleted"})
 }
+
+// convertInvestment - суммы в базовой валюте: вложение по курсу на дату, текущая стоимость по текущему курсу
+func (h *InvestmentHandler) convertInvestment(userID uint, inv *models.Investment) error {
+	base, err := h.fx.ToBase(userID, inv.Amount, inv.Currency, inv.Date)
+	if err != nil {
+		return err
+	}
+	current, err := h.fx.ToBase(userID, inv.CurrentValue, inv.Currency, time.Now())
+	if err != nil {
+		return err
+	}
+	inv.BaseAmount = base
+	inv.BaseCurrentValue = current
+	return nil
+}
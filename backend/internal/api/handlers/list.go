@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listFilter - фильтры, порядок и страница списка из query; при ошибке отвечает 400
+func listFilter(c *gin.Context) (repository.ListFilter, bool) {
+	filter := repository.ListFilter{
+		Type:     c.Query("type"),
+		Category: c.Query("category"),
+		Tags:     c.QueryArray("tag"),
+		Sort:     c.Query("sort"),
+		Cursor:   c.Query("cursor"),
+		Limit:    50,
+	}
+	if l := c.Query("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return filter, false
+		}
+		filter.Limit = min(limit, 200)
+	}
+	if total := c.Query("include_total"); total != "" {
+		value, err := strconv.ParseBool(total)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "include_total must be true or false"})
+			return filter, false
+		}
+		filter.WithTotal = value
+	}
+	if essential := c.Query("is_essential"); essential != "" {
+		value, err := strconv.ParseBool(essential)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "is_essential must be true or false"})
+			return filter, false
+		}
+		filter.IsEssential = &value
+	}
+	for param, target := range map[string]**models.Money{"min_amount": &filter.MinAmount, "max_amount": &filter.MaxAmount} {
+		if value := c.Query(param); value != "" {
+			amount, err := models.ParseMoney(value)
+			if err != nil || amount < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return filter, false
+			}
+			*target = &amount
+		}
+	}
+
+	if month := c.Query("month"); month != "" {
+		if _, err := time.Parse("2006-01", month); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month. Use YYYY-MM"})
+			return filter, false
+		}
+		filter.StartDate, filter.EndDate = service.MonthRange(month)
+	} else {
+		for param, target := range map[string]*string{"start_date": &filter.StartDate, "end_date": &filter.EndDate} {
+			if value := c.Query(param); value != "" {
+				if _, err := time.Parse("2006-01-02", value); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ". Use YYYY-MM-DD"})
+					return filter, false
+				}
+				*target = value
+			}
+		}
+	}
+	return filter, true
+}
+
+// listError - ответ на ошибку получения страницы списка
+func listError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, repository.ErrInvalidSort):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be date_desc, date_asc, amount_desc or amount_asc"})
+	case errors.Is(err, repository.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+	case errors.Is(err, repository.ErrUnsupportedFilter):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MerchantHandler struct {
+	repo *repository.Repository
+}
+
+func NewMerchantHandler(repo *repository.Repository) *MerchantHandler {
+	return &MerchantHandler{repo: repo}
+}
+
+type CreateMerchantRequest struct {
+	Name     string   `json:"name" binding:"required"`
+	Patterns []string `json:"patterns"` // Варианты написания в выписке ("YANDEX*TAXI", "Яндекс Go")
+	Category string   `json:"category"` // Категория расходов по умолчанию
+}
+
+type UpdateMerchantRequest struct {
+	Name     *string   `json:"name,omitempty"`
+	Patterns *[]string `json:"patterns,omitempty"` // Заменяют прежние шаблоны
+	Category *string   `json:"category,omitempty"` // Пустая строка - без категории по умолчанию
+}
+
+// Create - новый продавец в справочнике; транзакции, подходящие под шаблоны, связываются с ним
+func (h *MerchantHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req CreateMerchantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	merchant := &models.Merchant{
+		UserID:   userID,
+		Name:     strings.TrimSpace(req.Name),
+		Patterns: service.NormalizePatterns(req.Patterns),
+		Category: strings.TrimSpace(req.Category),
+	}
+	h.save(c, merchant, http.StatusCreated)
+}
+
+func (h *MerchantHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	merchants, err := h.repo.GetMerchants(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get merchants"})
+		return
+	}
+
+	c.JSON(http.StatusOK, merchants)
+}
+
+// Update - имя, шаблоны и категория по умолчанию продавца
+func (h *MerchantHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID"})
+		return
+	}
+
+	merchant, err := h.repo.GetMerchantByID(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merchant not found"})
+		return
+	}
+
+	var req UpdateMerchantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name != nil {
+		merchant.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Patterns != nil {
+		merchant.Patterns = service.NormalizePatterns(*req.Patterns)
+	}
+	if req.Category != nil {
+		merchant.Category = strings.TrimSpace(*req.Category)
+	}
+	h.save(c, merchant, http.StatusOK)
+}
+
+// Delete - удаление продавца; транзакции сохраняют нормализованный ключ продавца
+func (h *MerchantHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID"})
+		return
+	}
+
+	if err := h.repo.DeleteMerchant(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merchant not found"})
+		return
+	}
+	// Транзакции удаленного продавца могут подойти под шаблоны других
+	if err := service.RelinkMerchants(h.repo.DB(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to relink transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Merchant deleted"})
+}
+
+// save - проверяет и сохраняет продавца, затем заново связывает транзакции со справочником
+func (h *MerchantHandler) save(c *gin.Context, merchant *models.Merchant, status int) {
+	if merchant.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Merchant name is required"})
+		return
+	}
+	if models.NormalizeMerchant(merchant.Name) == "" && len(merchant.Patterns) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Merchant needs a name with letters or at least one pattern"})
+		return
+	}
+	if merchant.Category != "" {
+		if _, err := h.repo.EnsureCategory(merchant.UserID, "expense", merchant.Category); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save category"})
+			return
+		}
+	}
+
+	if err := h.repo.SaveMerchant(merchant); err != nil {
+		if errors.Is(err, repository.ErrMerchantExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Merchant with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save merchant"})
+		return
+	}
+	if err := service.RelinkMerchants(h.repo.DB(), merchant.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to relink transactions"})
+		return
+	}
+
+	c.JSON(status, merchant)
+}
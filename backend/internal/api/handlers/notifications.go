@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"clarity/internal/api/middleware"
+	"clarity/internal/models"
 	"clarity/internal/repository"
+	"clarity/internal/service"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type NotificationHandler struct {
@@ -21,20 +24,19 @@ func NewNotificationHandler(repo *repository.Repository) *NotificationHandler {
 func (h *NotificationHandler) GetNotifications(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
-	unreadOnly := c.Query("unread_only") == "true"
-
-	if limit > 100 {
-		limit = 100
+	filter, ok := listFilter(c)
+	if !ok {
+		return
 	}
+	unreadOnly := c.Query("unread_only") == "true"
 
-	notifications, err := h.repo.GetNotifications(userID, limit, unreadOnly)
+	page, err := h.repo.ListNotifications(userID, unreadOnly, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications"})
+		listError(c, err, "Failed to get notifications")
 		return
 	}
 
-	c.JSON(http.StatusOK, notifications)
+	c.JSON(http.StatusOK, page)
 }
 
 // MarkAsRead - пометить уведомление как прочитанное
@@ -46,7 +48,14 @@ func (h *NotificationHandler) MarkAsRead(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.MarkNotificationAsRead(userID, uint(id)); err != nil {
+	err = h.repo.Transaction(func(repo *repository.Repository) error {
+		marked, err := repo.MarkNotificationAsRead(userID, uint(id))
+		if err != nil || !marked {
+			return err
+		}
+		return recordNotificationRead(repo.DB(), userID, uint(id))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notification as read"})
 		return
 	}
@@ -58,10 +67,28 @@ func (h *NotificationHandler) MarkAsRead(c *gin.Context) {
 func (h *NotificationHandler) MarkAllAsRead(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	if err := h.repo.MarkAllNotificationsAsRead(userID); err != nil {
+	err := h.repo.Transaction(func(repo *repository.Repository) error {
+		ids, err := repo.MarkAllNotificationsAsRead(userID)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := recordNotificationRead(repo.DB(), userID, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark all notifications as read"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "All notifications marked as read"})
 }
+
+// recordNotificationRead - запись в журнал о прочтении уведомления пользователем
+func recordNotificationRead(db *gorm.DB, userID, id uint) error {
+	return service.RecordAudit(db, service.UserActor(userID, models.AuditSourceAPI), models.AuditEntityNotification, id,
+		models.AuditActionUpdate, map[string]interface{}{"is_read": false}, map[string]interface{}{"is_read": true})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProfileHandler struct {
+	repo *repository.Repository
+	fx   *service.FXService
+}
+
+func NewProfileHandler(repo *repository.Repository, fx *service.FXService) *ProfileHandler {
+	return &ProfileHandler{repo: repo, fx: fx}
+}
+
+type UpdateProfileRequest struct {
+	BaseCurrency    *string  `json:"base_currency,omitempty"`
+	ReviewThreshold *float64 `json:"review_threshold,omitempty"` // 0-1, действует на новые транзакции
+}
+
+// ProfileResponse - профиль пользователя
+type ProfileResponse struct {
+	ID              uint    `json:"id"`
+	Email           string  `json:"email"`
+	BaseCurrency    string  `json:"base_currency"`
+	ReviewThreshold float64 `json:"review_threshold"` // Порог уверенности категоризации для очереди проверки
+}
+
+func profileResponse(user *models.User) ProfileResponse {
+	return ProfileResponse{ID: user.ID, Email: user.Email, BaseCurrency: user.BaseCurrency, ReviewThreshold: user.ReviewThreshold}
+}
+
+// Get - профиль текущего пользователя
+func (h *ProfileHandler) Get(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	user, err := h.repo.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profileResponse(user))
+}
+
+// Update - изменение профиля. При смене базовой валюты все суммы пересчитываются
+// по сохраненным курсам; записи без курса попадают в счетчик missing.
+func (h *ProfileHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	user, err := h.repo.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ReviewThreshold != nil && (*req.ReviewThreshold < 0 || *req.ReviewThreshold > 1) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "review_threshold must be between 0 and 1"})
+		return
+	}
+
+	response := gin.H{}
+	if req.ReviewThreshold != nil {
+		if err := h.repo.DB().Model(user).Update("review_threshold", *req.ReviewThreshold).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+		user.ReviewThreshold = *req.ReviewThreshold
+	}
+	if req.BaseCurrency != nil {
+		currency := service.NormalizeCurrency(*req.BaseCurrency)
+		if !service.ValidCurrency(currency) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency code"})
+			return
+		}
+		if currency != user.BaseCurrency {
+			if err := h.repo.DB().Model(user).Update("base_currency", currency).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+				return
+			}
+			user.BaseCurrency = currency
+			updated, missing, err := h.fx.RecalculateBaseAmounts(userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recalculate base amounts"})
+				return
+			}
+			response["recomputed"] = updated
+			response["missing"] = missing
+		}
+	}
+
+	response["profile"] = profileResponse(user)
+	c.JSON(http.StatusOK, response)
+}
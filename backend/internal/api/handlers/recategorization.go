@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RecategorizationHandler struct {
+	repo    *repository.Repository
+	service *service.RecategorizationService
+}
+
+func NewRecategorizationHandler(repo *repository.Repository, service *service.RecategorizationService) *RecategorizationHandler {
+	return &RecategorizationHandler{repo: repo, service: service}
+}
+
+// RecategorizationJobRequest - запуск перекатегоризации истории
+type RecategorizationJobRequest struct {
+	StartDate string   `json:"start_date"` // YYYY-MM-DD
+	EndDate   string   `json:"end_date"`   // YYYY-MM-DD
+	Category  string   `json:"category"`   // Только транзакции с этой категорией
+	Sources   []string `json:"sources"`    // Только с этими источниками категории (по умолчанию - все автоматические)
+	Apply     bool     `json:"apply"`      // false - только отчет о различиях
+}
+
+// RecategorizationChangesResponse - различия, найденные заданием
+type RecategorizationChangesResponse struct {
+	Total   int64                           `json:"total"`
+	Changes []models.RecategorizationChange `json:"changes"`
+}
+
+// Create - создает задание и запускает его в фоне
+func (h *RecategorizationHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req RecategorizationJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job := models.RecategorizationJob{
+		UserID:   userID,
+		Status:   models.RecategorizationPending,
+		Apply:    req.Apply,
+		Category: strings.TrimSpace(req.Category),
+	}
+	var err error
+	if job.StartDate, err = parseOptionalDate(req.StartDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if job.EndDate, err = parseOptionalDate(req.EndDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if job.StartDate != nil && job.EndDate != nil && job.EndDate.Before(*job.StartDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+		return
+	}
+	for _, source := range req.Sources {
+		if !slices.Contains(service.RecategorizableSources, source) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid source %q: use one of %s", source, strings.Join(service.RecategorizableSources, ", "))})
+			return
+		}
+	}
+	job.Sources = req.Sources
+
+	active, err := h.repo.HasActiveRecategorizationJob(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check recategorization jobs"})
+		return
+	}
+	if active {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another recategorization job is already running"})
+		return
+	}
+
+	if err := h.repo.CreateRecategorizationJob(&job); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recategorization job"})
+		return
+	}
+	h.service.Start(job.ID)
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (h *RecategorizationHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	jobs, err := h.repo.GetRecategorizationJobs(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recategorization jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+// Get - задание с прогрессом
+func (h *RecategorizationHandler) Get(c *gin.Context) {
+	job, ok := h.job(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// Changes - отчет о различиях: прежняя и новая категория транзакций
+func (h *RecategorizationHandler) Changes(c *gin.Context) {
+	job, ok := h.job(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	changes, total, err := h.repo.GetRecategorizationChanges(job.ID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recategorization changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RecategorizationChangesResponse{Total: total, Changes: changes})
+}
+
+// Cancel - останавливает задание; уже сохраненные страницы остаются
+func (h *RecategorizationHandler) Cancel(c *gin.Context) {
+	job, ok := h.job(c)
+	if !ok {
+		return
+	}
+	if job.Status != models.RecategorizationPending && job.Status != models.RecategorizationRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Job is already %s", job.Status)})
+		return
+	}
+
+	if err := h.service.Cancel(job); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel recategorization job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// Resume - продолжает отмененное или упавшее задание с места остановки
+func (h *RecategorizationHandler) Resume(c *gin.Context) {
+	job, ok := h.job(c)
+	if !ok {
+		return
+	}
+	if job.Status != models.RecategorizationCancelled && job.Status != models.RecategorizationFailed {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Only cancelled or failed jobs can be resumed, job is %s", job.Status)})
+		return
+	}
+
+	active, err := h.repo.HasActiveRecategorizationJob(job.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check recategorization jobs"})
+		return
+	}
+	if active {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another recategorization job is already running"})
+		return
+	}
+
+	if err := h.repo.ResetRecategorizationJob(job); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resume recategorization job"})
+		return
+	}
+	h.service.Start(job.ID)
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// job - задание пользователя из параметра :id; при ошибке ответ уже отправлен
+func (h *RecategorizationHandler) job(c *gin.Context) (*models.RecategorizationJob, bool) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return nil, false
+	}
+
+	job, err := h.repo.GetRecategorizationJob(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recategorization job not found"})
+		return nil, false
+	}
+	return job, true
+}
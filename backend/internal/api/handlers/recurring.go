@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RecurringHandler struct {
+	repo      *repository.Repository
+	recurring *service.RecurringService
+}
+
+func NewRecurringHandler(repo *repository.Repository, recurring *service.RecurringService) *RecurringHandler {
+	return &RecurringHandler{repo: repo, recurring: recurring}
+}
+
+// CreateRecurringRuleRequest - расписание задается полями frequency/interval/by_month_day/count/end_date
+// или строкой rrule ("FREQ=MONTHLY;BYMONTHDAY=5;COUNT=12")
+type CreateRecurringRuleRequest struct {
+	AccountID   uint         `json:"account_id" binding:"required"`
+	Amount      models.Money `json:"amount" binding:"required"`
+	Type        string       `json:"type" binding:"required,oneof=income expense"`
+	Category    string       `json:"category"`
+	Description string       `json:"description"`
+	IsEssential *bool        `json:"is_essential"` // По умолчанию - признак категории из справочника
+	Frequency   string       `json:"frequency"`
+	Interval    int          `json:"interval"`
+	ByMonthDay  int          `json:"by_month_day"`
+	StartDate   string       `json:"start_date"` // YYYY-MM-DD, по умолчанию сегодня
+	EndDate     string       `json:"end_date"`   // YYYY-MM-DD
+	Count       int          `json:"count"`
+	RRule       string       `json:"rrule"`
+}
+
+type UpdateRecurringRuleRequest struct {
+	Amount      *models.Money `json:"amount,omitempty"`
+	Category    *string       `json:"category,omitempty"`
+	Description *string       `json:"description,omitempty"`
+	IsEssential *bool         `json:"is_essential,omitempty"`
+	Active      *bool         `json:"active,omitempty"`
+	Frequency   *string       `json:"frequency,omitempty"`
+	Interval    *int          `json:"interval,omitempty"`
+	ByMonthDay  *int          `json:"by_month_day,omitempty"`
+	StartDate   *string       `json:"start_date,omitempty"`
+	EndDate     *string       `json:"end_date,omitempty"` // Пустая строка убирает дату окончания
+	Count       *int          `json:"count,omitempty"`
+	RRule       *string       `json:"rrule,omitempty"`
+}
+
+// RecurringRuleResponse - правило вместе с расписанием в формате RRULE
+type RecurringRuleResponse struct {
+	models.RecurringRule
+	RRule string `json:"rrule"`
+}
+
+// UpcomingResponse - предстоящие платежи и поступления по регулярным операциям
+type UpcomingResponse struct {
+	Currency     string                       `json:"currency"` // Валюта итогов (базовая)
+	Days         int                          `json:"days"`
+	TotalExpense models.Money                 `json:"total_expense"`
+	TotalIncome  models.Money                 `json:"total_income"`
+	Items        []service.UpcomingOccurrence `json:"items"`
+}
+
+func toRecurringResponse(rule *models.RecurringRule) RecurringRuleResponse {
+	return RecurringRuleResponse{RecurringRule: *rule, RRule: service.FormatRRule(rule)}
+}
+
+func parseOptionalDate(value string) (*time.Time, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, errors.New("Invalid date format. Use YYYY-MM-DD")
+	}
+	return &date, nil
+}
+
+// Create - создание регулярной операции. Если start_date в прошлом, наступившие
+// повторения будут созданы планировщиком при ближайшем запуске.
+func (h *RecurringHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req CreateRecurringRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := h.repo.GetAccountByID(req.AccountID, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Account not found"})
+		return
+	}
+
+	rule := &models.RecurringRule{
+		UserID:      userID,
+		AccountID:   account.ID,
+		Amount:      models.SignedByType(req.Type, req.Amount),
+		Currency:    account.Currency,
+		Type:        req.Type,
+		Category:    strings.TrimSpace(req.Category),
+		Description: req.Description,
+		Frequency:   strings.ToLower(req.Frequency),
+		Interval:    req.Interval,
+		ByMonthDay:  req.ByMonthDay,
+		Count:       req.Count,
+		Active:      true,
+	}
+	if rule.Category == "" {
+		rule.Category = models.DefaultCategory
+	}
+	if category, err := h.repo.EnsureCategory(userID, rule.Type, rule.Category); err == nil {
+		rule.IsEssential = category.IsEssential
+	}
+	if req.IsEssential != nil {
+		rule.IsEssential = *req.IsEssential
+	}
+
+	startDate, err := parseOptionalDate(req.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if startDate != nil {
+		rule.StartDate = *startDate
+	} else {
+		now := time.Now()
+		rule.StartDate = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	if rule.EndDate, err = parseOptionalDate(req.EndDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.RRule != "" {
+		if err := service.ParseRRule(req.RRule, rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if rule.Interval == 0 {
+		rule.Interval = 1
+	}
+	if err := service.ValidateRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.recurring.Reschedule(rule)
+	if err := h.repo.CreateRecurringRule(rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recurring rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, toRecurringResponse(rule))
+}
+
+// List - регулярные операции пользователя (ближайшие первыми)
+func (h *RecurringHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	rules, err := h.repo.GetRecurringRules(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recurring rules"})
+		return
+	}
+
+	response := make([]RecurringRuleResponse, 0, len(rules))
+	for i := range rules {
+		response = append(response, toRecurringResponse(&rules[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Get - одна регулярная операция
+func (h *RecurringHandler) Get(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recurring rule ID"})
+		return
+	}
+
+	rule, err := h.repo.GetRecurringRuleByID(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recurring rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toRecurringResponse(rule))
+}
+
+// Update - изменение правила. Уже созданные транзакции не меняются; при изменении расписания
+// next_date пересчитывается, при возобновлении (active=true) пропущенные за паузу даты не создаются.
+func (h *RecurringHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recurring rule ID"})
+		return
+	}
+
+	rule, err := h.repo.GetRecurringRuleByID(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recurring rule not found"})
+		return
+	}
+
+	var req UpdateRecurringRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Amount != nil {
+		rule.Amount = models.SignedByType(rule.Type, *req.Amount)
+	}
+	if req.Category != nil {
+		rule.Category = strings.TrimSpace(*req.Category)
+		if rule.Category == "" {
+			rule.Category = models.DefaultCategory
+		}
+		category, err := h.repo.EnsureCategory(userID, rule.Type, rule.Category)
+		if err == nil && req.IsEssential == nil {
+			rule.IsEssential = category.IsEssential
+		}
+	}
+	if req.Description != nil {
+		rule.Description = *req.Description
+	}
+	if req.IsEssential != nil {
+		rule.IsEssential = *req.IsEssential
+	}
+
+	// Изменение расписания
+	scheduleChanged := false
+	if req.Frequency != nil {
+		rule.Frequency = strings.ToLower(*req.Frequency)
+		scheduleChanged = true
+	}
+	if req.Interval != nil {
+		rule.Interval = *req.Interval
+		scheduleChanged = true
+	}
+	if req.ByMonthDay != nil {
+		rule.ByMonthDay = *req.ByMonthDay
+		scheduleChanged = true
+	}
+	if req.Count != nil {
+		rule.Count = *req.Count
+		scheduleChanged = true
+	}
+	if req.StartDate != nil {
+		startDate, err := parseOptionalDate(*req.StartDate)
+		if err != nil || startDate == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+		rule.StartDate = *startDate
+		scheduleChanged = true
+	}
+	if req.EndDate != nil {
+		if rule.EndDate, err = parseOptionalDate(*req.EndDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		scheduleChanged = true
+	}
+	if req.RRule != nil {
+		rule.EndDate, rule.Count, rule.ByMonthDay, rule.Interval = nil, 0, 0, 1
+		if err := service.ParseRRule(*req.RRule, rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		scheduleChanged = true
+	}
+	if err := service.ValidateRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if scheduleChanged {
+		h.recurring.Reschedule(rule)
+	}
+
+	if req.Active != nil {
+		if *req.Active && !rule.Active {
+			service.SkipPast(rule, time.Now())
+		}
+		rule.Active = *req.Active
+	}
+
+	if err := h.repo.UpdateRecurringRule(rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recurring rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toRecurringResponse(rule))
+}
+
+// Delete - удаление правила; созданные по нему транзакции сохраняются
+func (h *RecurringHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recurring rule ID"})
+		return
+	}
+
+	if err := h.repo.DeleteRecurringRule(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recurring rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recurring rule deleted"})
+}
+
+// Upcoming - предстоящие платежи на ближайшие days дней (по умолчанию 30, максимум 366)
+func (h *RecurringHandler) Upcoming(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive integer"})
+		return
+	}
+	if days > 366 {
+		days = 366
+	}
+
+	items := h.recurring.Upcoming(userID, days, time.Now())
+	if c.Query("type") != "" {
+		filtered := items[:0]
+		for _, item := range items {
+			if item.Type == c.Query("type") {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+
+	response := UpcomingResponse{
+		Currency: service.BaseCurrency(h.repo.DB(), userID),
+		Days:     days,
+		Items:    items,
+	}
+	for _, item := range items {
+		if item.Type == "expense" {
+			response.TotalExpense += item.BaseAmount.Abs()
+		} else {
+			response.TotalIncome += item.BaseAmount
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
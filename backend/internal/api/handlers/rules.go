@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RuleHandler struct {
+	repo   *repository.Repository
+	engine *service.RuleEngine
+}
+
+func NewRuleHandler(repo *repository.Repository, engine *service.RuleEngine) *RuleHandler {
+	return &RuleHandler{repo: repo, engine: engine}
+}
+
+// CategoryRuleRequest - правило целиком (создание и предпросмотр)
+type CategoryRuleRequest struct {
+	Name           string `json:"name"`
+	Priority       *int   `json:"priority"` // По умолчанию 100 - раньше правил по умолчанию (1000+)
+	Enabled        *bool  `json:"enabled"`  // По умолчанию true
+	StopProcessing bool   `json:"stop_processing"`
+
+	DescriptionPattern string        `json:"description_pattern"`
+	RefNoPattern       string        `json:"ref_no_pattern"`
+	MinAmount          *models.Money `json:"min_amount"`
+	MaxAmount          *models.Money `json:"max_amount"`
+	Type               string        `json:"type"`
+	Weekdays           []int         `json:"weekdays"`
+	AccountID          *uint         `json:"account_id"`
+
+	SetCategory    string   `json:"set_category"`
+	SetEssential   *bool    `json:"set_essential"`
+	AddTags        []string `json:"add_tags"`
+	SetDescription string   `json:"set_description"`
+}
+
+// UpdateCategoryRuleRequest - изменение переданных полей. Пустая строка, пустой список
+// и 0 в min_amount/max_amount/account_id убирают условие или действие.
+type UpdateCategoryRuleRequest struct {
+	Name           *string `json:"name,omitempty"`
+	Priority       *int    `json:"priority,omitempty"`
+	Enabled        *bool   `json:"enabled,omitempty"`
+	StopProcessing *bool   `json:"stop_processing,omitempty"`
+
+	DescriptionPattern *string       `json:"description_pattern,omitempty"`
+	RefNoPattern       *string       `json:"ref_no_pattern,omitempty"`
+	MinAmount          *models.Money `json:"min_amount,omitempty"`
+	MaxAmount          *models.Money `json:"max_amount,omitempty"`
+	Type               *string       `json:"type,omitempty"`
+	Weekdays           *[]int        `json:"weekdays,omitempty"`
+	AccountID          *uint         `json:"account_id,omitempty"`
+
+	SetCategory    *string   `json:"set_category,omitempty"`
+	SetEssential   *bool     `json:"set_essential,omitempty"`
+	ClearEssential bool      `json:"clear_essential,omitempty"` // Правило перестает менять is_essential
+	AddTags        *[]string `json:"add_tags,omitempty"`
+	SetDescription *string   `json:"set_description,omitempty"`
+}
+
+// ApplyRulesRequest - повторное применение правил к истории
+type ApplyRulesRequest struct {
+	RuleIDs   []uint `json:"rule_ids"`   // Пусто - все включенные правила
+	StartDate string `json:"start_date"` // YYYY-MM-DD
+	EndDate   string `json:"end_date"`   // YYYY-MM-DD
+}
+
+// RulePreviewResponse - прошлые транзакции, которые подошли бы под правило
+type RulePreviewResponse struct {
+	Total        int                       `json:"total"` // Всего совпадений
+	Transactions []service.RulePreviewItem `json:"transactions"`
+}
+
+func (req CategoryRuleRequest) toRule(userID uint) models.CategoryRule {
+	rule := models.CategoryRule{
+		UserID:             userID,
+		Name:               strings.TrimSpace(req.Name),
+		Priority:           100,
+		Enabled:            true,
+		StopProcessing:     req.StopProcessing,
+		DescriptionPattern: req.DescriptionPattern,
+		RefNoPattern:       req.RefNoPattern,
+		MinAmount:          req.MinAmount,
+		MaxAmount:          req.MaxAmount,
+		Type:               req.Type,
+		Weekdays:           req.Weekdays,
+		AccountID:          req.AccountID,
+		SetCategory:        strings.TrimSpace(req.SetCategory),
+		SetEssential:       req.SetEssential,
+		AddTags:            service.NormalizeTags(req.AddTags),
+		SetDescription:     req.SetDescription,
+	}
+	if req.Priority != nil {
+		rule.Priority = *req.Priority
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	return rule
+}
+
+// validateRule - проверка правила и принадлежности счета из условия
+func (h *RuleHandler) validateRule(c *gin.Context, rule *models.CategoryRule) bool {
+	if err := service.ValidateCategoryRule(*rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if rule.AccountID != nil {
+		if _, err := h.repo.GetAccountByID(*rule.AccountID, rule.UserID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Account not found"})
+			return false
+		}
+	}
+	return true
+}
+
+// ensureRuleCategory - категория из действия правила добавляется в справочник
+func (h *RuleHandler) ensureRuleCategory(rule *models.CategoryRule) {
+	if rule.SetCategory == "" {
+		return
+	}
+	types := []string{rule.Type}
+	if rule.Type == "" {
+		types = []string{"expense", "income"}
+	}
+	for _, categoryType := range types {
+		h.repo.EnsureCategory(rule.UserID, categoryType, rule.SetCategory)
+	}
+}
+
+// Create - новое правило
+func (h *RuleHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req CategoryRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := req.toRule(userID)
+	if !h.validateRule(c, &rule) {
+		return
+	}
+	h.ensureRuleCategory(&rule)
+
+	if err := h.repo.CreateCategoryRule(&rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// List - правила пользователя в порядке проверки
+func (h *RuleHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	rules, err := h.repo.GetCategoryRules(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// Update - изменение правила (уже обработанные транзакции не меняются, см. Apply)
+func (h *RuleHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	rule, err := h.repo.GetCategoryRuleByID(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return
+	}
+
+	var req UpdateCategoryRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name != nil {
+		rule.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Priority != nil {
+		rule.Priority = *req.Priority
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.StopProcessing != nil {
+		rule.StopProcessing = *req.StopProcessing
+	}
+	if req.DescriptionPattern != nil {
+		rule.DescriptionPattern = *req.DescriptionPattern
+	}
+	if req.RefNoPattern != nil {
+		rule.RefNoPattern = *req.RefNoPattern
+	}
+	if req.MinAmount != nil {
+		rule.MinAmount = req.MinAmount
+		if *req.MinAmount == 0 {
+			rule.MinAmount = nil
+		}
+	}
+	if req.MaxAmount != nil {
+		rule.MaxAmount = req.MaxAmount
+		if *req.MaxAmount == 0 {
+			rule.MaxAmount = nil
+		}
+	}
+	if req.Type != nil {
+		rule.Type = *req.Type
+	}
+	if req.Weekdays != nil {
+		rule.Weekdays = *req.Weekdays
+	}
+	if req.AccountID != nil {
+		rule.AccountID = req.AccountID
+		if *req.AccountID == 0 {
+			rule.AccountID = nil
+		}
+	}
+	if req.SetCategory != nil {
+		rule.SetCategory = strings.TrimSpace(*req.SetCategory)
+	}
+	if req.SetEssential != nil {
+		rule.SetEssential = req.SetEssential
+	}
+	if req.ClearEssential {
+		rule.SetEssential = nil
+	}
+	if req.AddTags != nil {
+		rule.AddTags = service.NormalizeTags(*req.AddTags)
+	}
+	if req.SetDescription != nil {
+		rule.SetDescription = *req.SetDescription
+	}
+
+	if !h.validateRule(c, rule) {
+		return
+	}
+	h.ensureRuleCategory(rule)
+
+	if err := h.repo.UpdateCategoryRule(rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// Delete - удаление правила; обработанные им транзакции не меняются
+func (h *RuleHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	if err := h.repo.DeleteCategoryRule(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Rule deleted"})
+}
+
+// Preview - какие прошлые транзакции подошли бы под еще не сохраненное правило
+func (h *RuleHandler) Preview(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req CategoryRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := req.toRule(userID)
+	if !h.validateRule(c, &rule) {
+		return
+	}
+	h.preview(c, rule)
+}
+
+// PreviewSaved - какие прошлые транзакции подходят под сохраненное правило
+func (h *RuleHandler) PreviewSaved(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	rule, err := h.repo.GetCategoryRuleByID(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return
+	}
+	h.preview(c, *rule)
+}
+
+func (h *RuleHandler) preview(c *gin.Context, rule models.CategoryRule) {
+	compiled, err := service.CompileRule(rule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, total, err := h.engine.Preview(rule.UserID, compiled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to preview rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RulePreviewResponse{Total: total, Transactions: items})
+}
+
+// Apply - повторно применяет правила к прошлым транзакциям
+func (h *RuleHandler) Apply(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req ApplyRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, date := range []string{req.StartDate, req.EndDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
+	}
+
+	result, err := h.engine.ApplyToHistory(userID, req.RuleIDs, req.StartDate, req.EndDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SubscriptionHandler struct {
+	repo     *repository.Repository
+	detector *service.SubscriptionDetector
+}
+
+func NewSubscriptionHandler(repo *repository.Repository, detector *service.SubscriptionDetector) *SubscriptionHandler {
+	return &SubscriptionHandler{repo: repo, detector: detector}
+}
+
+// SubscriptionsResponse - найденные подписки и их суммарная стоимость
+type SubscriptionsResponse struct {
+	Currency         string                 `json:"currency"`           // Валюта итогов (базовая)
+	TotalAnnualCost  models.Money           `json:"total_annual_cost"`  // По активным подпискам
+	TotalMonthlyCost models.Money           `json:"total_monthly_cost"` // Годовая стоимость / 12
+	ActiveCount      int                    `json:"active_count"`
+	Subscriptions    []service.Subscription `json:"subscriptions"`
+}
+
+// List - подписки и регулярные платежи, найденные по истории расходов.
+// active_only=true скрывает подписки, ожидаемое списание по которым давно не приходило.
+func (h *SubscriptionHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	activeOnly := c.Query("active_only") == "true"
+
+	subscriptions := h.detector.Detect(userID, time.Now())
+
+	response := SubscriptionsResponse{
+		Currency:      service.BaseCurrency(h.repo.DB(), userID),
+		Subscriptions: []service.Subscription{},
+	}
+	for _, sub := range subscriptions {
+		if sub.Active {
+			response.ActiveCount++
+			response.TotalAnnualCost += sub.AnnualCost
+		} else if activeOnly {
+			continue
+		}
+		response.Subscriptions = append(response.Subscriptions, sub)
+	}
+	response.TotalMonthlyCost = response.TotalAnnualCost.MulRate(1.0 / 12)
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TagHandler struct {
+	repo *repository.Repository
+}
+
+func NewTagHandler(repo *repository.Repository) *TagHandler {
+	return &TagHandler{repo: repo}
+}
+
+// TagRequest - создание или переименование метки
+type TagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// Create - новая метка (метки также создаются автоматически при указании в транзакциях,
+// инвестициях и вкладах)
+func (h *TagHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag := &models.Tag{UserID: userID}
+	h.save(c, tag, req.Name, http.StatusCreated)
+}
+
+// Update - переименование метки; записи с меткой сохраняют ее
+func (h *TagHandler) Update(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	tag, err := h.repo.GetTagByID(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.save(c, tag, req.Name, http.StatusOK)
+}
+
+// List - метки пользователя с числом транзакций, инвестиций и вкладов
+func (h *TagHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	tags, err := h.repo.GetTags(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// Delete - удаление метки; она снимается со всех транзакций, инвестиций и вкладов
+func (h *TagHandler) Delete(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	if err := h.repo.DeleteTag(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted"})
+}
+
+func (h *TagHandler) save(c *gin.Context, tag *models.Tag, name string, status int) {
+	tag.Name = strings.TrimSpace(name)
+	if tag.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name is required"})
+		return
+	}
+
+	if err := h.repo.SaveTag(tag); err != nil {
+		if errors.Is(err, repository.ErrTagExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Tag with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tag"})
+		return
+	}
+
+	c.JSON(status, tag)
+}
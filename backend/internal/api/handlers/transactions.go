@@ -5,8 +5,9 @@ import (
 	"clarity/internal/models"
 	"clarity/internal/repository"
 	"clarity/internal/service"
+	"context"
 	"fmt"
-	"math"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -17,33 +18,96 @@ import (
 
 type TransactionHandler struct {
 	repo            *repository.Repository
-	mlClient        *service.MLClient
+	categorizer     service.Categorizer
 	anomalyDetector *service.AnomalyDetector
+	fx              *service.FXService
+	rules           *service.RuleEngine
+	bulk            *service.BulkService
 }
 
-func NewTransactionHandler(repo *repository.Repository, mlClient *service.MLClient, anomalyDetector *service.AnomalyDetector) *TransactionHandler {
+func NewTransactionHandler(repo *repository.Repository, categorizer service.Categorizer, anomalyDetector *service.AnomalyDetector, fx *service.FXService, rules *service.RuleEngine, bulk *service.BulkService) *TransactionHandler {
 	return &TransactionHandler{
 		repo:            repo,
-		mlClient:        mlClient,
+		categorizer:     categorizer,
 		anomalyDetector: anomalyDetector,
+		fx:              fx,
+		rules:           rules,
+		bulk:            bulk,
 	}
 }
 
 type CreateTransactionRequest struct {
-	Amount      float64 `json:"amount" binding:"required"`
-	Description string  `json:"description"`
-	RefNo       string  `json:"ref_no"` // Референсный номер транзакции
-	Date        string  `json:"date"`
-	Type        string  `json:"type" binding:"required,oneof=income expense"`
-	IsEssential bool    `json:"is_essential"`
+	AccountID   uint         `json:"account_id"` // Не указан - счет по умолчанию
+	Amount      models.Money `json:"amount" binding:"required"`
+	Currency    string       `json:"currency"` // Валюта операции (по умолчанию валюта счета)
+	Description string       `json:"description"`
+	RefNo       string       `json:"ref_no"` // Референсный номер транзакции
+	Date        string       `json:"date"`
+	Type        string       `json:"type" binding:"required,oneof=income expense"`
+	IsEssential *bool        `json:"is_essential"`     // По умолчанию - признак категории из справочника
+	Splits      []SplitLine  `json:"splits,omitempty"` // Разбивка по категориям (опционально)
+	Tags        []string     `json:"tags,omitempty"`
 }
 
 type UpdateTransactionRequest struct {
-	Amount      *float64 `json:"amount,omitempty"`
-	Description *string  `json:"description,omitempty"`
-	Category    *string  `json:"category,omitempty"`
-	Date        *string  `json:"date,omitempty"`
-	IsEssential *bool   `json:"is_essential,omitempty"`
+	Amount      *models.Money `json:"amount,omitempty"`
+	Description *string       `json:"description,omitempty"`
+	Category    *string       `json:"category,omitempty"`
+	Date        *string       `json:"date,omitempty"`
+	IsEssential *bool         `json:"is_essential,omitempty"`
+	Splits      *[]SplitLine  `json:"splits,omitempty"` // Новая разбивка целиком; пустой список убирает разбивку
+	Tags        *[]string     `json:"tags,omitempty"`   // Новый набор меток целиком; пустой список снимает метки
+}
+
+// SplitLine - часть транзакции в запросе
+type SplitLine struct {
+	Amount      models.Money `json:"amount"`
+	Category    string       `json:"category"`
+	IsEssential *bool        `json:"is_essential"` // По умолчанию - признак категории из справочника
+	Description string       `json:"description"`
+}
+
+// toSplits - части транзакции из запроса
+func toSplits(lines []SplitLine) []models.TransactionSplit {
+	splits := make([]models.TransactionSplit, 0, len(lines))
+	for _, line := range lines {
+		split := models.TransactionSplit{
+			Amount:      line.Amount,
+			Category:    strings.TrimSpace(line.Category),
+			Description: line.Description,
+		}
+		if line.IsEssential != nil {
+			split.IsEssential = *line.IsEssential
+		}
+		splits = append(splits, split)
+	}
+	return splits
+}
+
+// applyCategoryDefaults - добавляет категории транзакции и ее частей в справочник пользователя
+// и проставляет is_essential из справочника там, где он не передан явно (isEssential и
+// IsEssential строк lines равны nil). Ноги переводов в справочнике не учитываются.
+func (h *TransactionHandler) applyCategoryDefaults(repo *repository.Repository, tx *models.Transaction, isEssential *bool, lines []SplitLine) error {
+	if tx.TransferID != nil {
+		return nil
+	}
+	category, err := repo.EnsureCategory(tx.UserID, tx.Type, tx.Category)
+	if err != nil {
+		return err
+	}
+	if isEssential == nil {
+		tx.IsEssential = category.IsEssential
+	}
+	for i := range tx.Splits {
+		category, err := repo.EnsureCategory(tx.UserID, tx.Type, tx.Splits[i].Category)
+		if err != nil {
+			return err
+		}
+		if i < len(lines) && lines[i].IsEssential == nil {
+			tx.Splits[i].IsEssential = category.IsEssential
+		}
+	}
+	return nil
 }
 
 func (h *TransactionHandler) Create(c *gin.Context) {
@@ -55,13 +119,36 @@ func (h *TransactionHandler) Create(c *gin.Context) {
 		return
 	}
 
+	var account *models.Account
+	var err error
+	if req.AccountID == 0 {
+		if account, err = h.repo.DefaultAccount(userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get default account"})
+			return
+		}
+	} else if account, err = h.repo.GetAccountByID(req.AccountID, userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Account not found"})
+		return
+	}
+	// Операции по счету ведутся в валюте счета
+	if req.Currency != "" && service.NormalizeCurrency(req.Currency) != account.Currency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Currency must match account currency %s", account.Currency)})
+		return
+	}
+
 	tx := &models.Transaction{
 		UserID:      userID,
+		AccountID:   account.ID,
 		Amount:      req.Amount,
+		Currency:    account.Currency,
 		Description: req.Description,
 		RefNo:       req.RefNo,
 		Type:        req.Type,
-		IsEssential: req.IsEssential,
+		Splits:      toSplits(req.Splits),
+	}
+	if err := tx.ValidateSplits(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	if req.Date != "" {
@@ -75,48 +162,61 @@ func (h *TransactionHandler) Create(c *gin.Context) {
 		tx.Date = time.Now()
 	}
 
-	// ML категоризация (только для расходов)
-	if req.Type == "expense" {
-		// Используем ref_no, если есть, иначе description
-		refNo := req.RefNo
-		if refNo == "" && req.Description != "" {
-			refNo = req.Description
-		}
+	// Сумма в базовой валюте по курсу на дату транзакции
+	tx.BaseAmount, err = h.fx.ToBase(userID, tx.Amount, tx.Currency, tx.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Знак суммы определяется типом: расход всегда отрицательный, доход - положительный
+	tx.NormalizeSign()
 
-		// Пытаемся классифицировать через ML сервис (основной метод)
-		result, err := h.mlClient.CategorizeWithDate(refNo, req.Amount, tx.Date)
-		if err == nil {
-			// ML сервис доступен
-			// Сначала проверяем description - если содержит явные ключевые слова, используем их
-			if req.Description != "" {
-				descCategory := h.classifyByDescription(req.Description, req.Amount)
-				// Если description дал конкретную категорию (не Misc), используем её
-				// Это важно для случаев типа "Обед в кафе" (должно быть Food, а не Shopping по сумме)
-				if descCategory != "Misc" && descCategory != "" {
-					tx.Category = descCategory
-				} else {
-					// Если description не помог, используем результат ML
-					tx.Category = result.Category
-				}
-			} else {
-				// Если description пустой, используем результат ML
-				tx.Category = result.Category
-			}
-		} else {
-			// ML сервис недоступен - используем fallback по description
-			if req.Description != "" {
-				tx.Category = h.classifyByDescription(req.Description, req.Amount)
-			} else {
-				tx.Category = "Misc"
-			}
-		}
+	// У разбитой транзакции категории заданы частями, остальным категорию дает цепочка категоризаторов
+	if len(tx.Splits) > 0 {
+		tx.Category = tx.MainSplitCategory()
+		tx.SetCategorySource(models.CategorySourceManual, 1)
+		tx.Explanation = manualExplanation(tx, "категория самой крупной части разбивки")
+	} else {
+		h.categorize(c.Request.Context(), tx)
+	}
+	// Правила пользователя задают признак обязательности, описание и метки (категорию - в цепочке).
+	// Явно переданный is_essential правила не меняют.
+	outcome := h.applyRules(tx, req.IsEssential != nil)
+
+	// Неуверенная категоризация попадает в очередь проверки
+	tx.NeedsReview = service.NeedsReview(tx, service.ReviewThreshold(h.repo.DB(), userID))
+
+	// Признак обязательности: явно переданный, затем из правил, затем из справочника категорий
+	isEssential := req.IsEssential
+	if isEssential != nil {
+		tx.IsEssential = *isEssential
+	} else if outcome.IsEssential != nil {
+		isEssential = outcome.IsEssential
 	}
 
-	if tx.Category == "" {
-		tx.Category = "Другое"
+	// Продавец - по описанию после правил (правило может его заменить)
+	merchants, err := service.LoadMerchants(h.repo.DB(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get merchants"})
+		return
 	}
+	service.AssignMerchant(tx, merchants)
 
-	if err := h.repo.CreateTransaction(tx); err != nil {
+	// Новые категории и метки создаются в той же транзакции БД, что и сама транзакция
+	err = h.repo.Transaction(func(repo *repository.Repository) error {
+		if err := h.applyCategoryDefaults(repo, tx, isEssential, req.Splits); err != nil {
+			return err
+		}
+		if err := h.attachTags(repo, tx, append(req.Tags, outcome.Tags...)); err != nil {
+			return err
+		}
+		if err := repo.CreateTransaction(tx); err != nil {
+			return err
+		}
+		return service.RecordAudit(repo.DB(), service.UserActor(userID, models.AuditSourceAPI), models.AuditEntityTransaction, tx.ID,
+			models.AuditActionCreate, nil, service.AuditSnapshot(tx))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction"})
 		return
 	}
@@ -142,32 +242,44 @@ func (h *TransactionHandler) checkAndNotifyAnomalies(userID uint, tx *models.Tra
 			UserID:  userID,
 			Type:    "anomaly",
 			Title:   "⚠️ Аномальная транзакция обнаружена",
-			Message: fmt.Sprintf("%s. Сумма: %.2f₽, Категория: %s. %s", anomaly.Reason, tx.Amount, tx.Category, severityText),
+			Message: fmt.Sprintf("%s. Сумма: %s%s, Категория: %s. %s", anomaly.Reason, tx.Amount, service.CurrencySymbol(tx.Currency), tx.Category, severityText),
 		}
-		h.repo.CreateNotification(notification)
+		h.notify(notification)
 	}
 
-	// 2. Проверка лимита по категории (только для расходов)
-	if tx.Type == "expense" && tx.Category != "" {
+	// Лимиты и подушка считаются в базовой валюте
+	sym := service.CurrencySymbol(service.BaseCurrency(h.repo.DB(), userID))
+
+	// 2. Проверка лимита по категории (только для расходов; у разбитой транзакции - по каждой категории частей)
+	if tx.Type == "expense" {
 		month := tx.Date.Format("2006-01")
-		exceeded, current, limit := h.anomalyDetector.CheckCategoryLimit(userID, tx.Category, math.Abs(tx.Amount), month)
-		if exceeded {
-			notification := &models.Notification{
-				UserID:  userID,
-				Type:    "category_limit",
-				Title:   "📊 Превышен лимит по категории",
-				Message: fmt.Sprintf("Категория '%s': потрачено %.2f₽ из лимита %.2f₽ (%.0f%%)", tx.Category, current, limit, (current/limit)*100),
+		var categories []string
+		byCategory := make(map[string]models.Money)
+		for _, line := range tx.Lines() {
+			if line.Category == "" {
+				continue
+			}
+			if _, ok := byCategory[line.Category]; !ok {
+				categories = append(categories, line.Category)
+			}
+			byCategory[line.Category] += line.BaseAmount.Abs()
+		}
+		for _, category := range categories {
+			exceeded, current, limit := h.anomalyDetector.CheckCategoryLimit(userID, category, byCategory[category], month)
+			if exceeded {
+				notification := &models.Notification{
+					UserID:  userID,
+					Type:    "category_limit",
+					Title:   "📊 Превышен лимит по категории",
+					Message: fmt.Sprintf("Категория '%s': потрачено %s%s из лимита %s%s (%.0f%%)", category, current, sym, limit, sym, current.Ratio(limit)*100),
+				}
+				h.notify(notification)
 			}
-			h.repo.CreateNotification(notification)
 		}
 	}
 
-	// 3. Проверка снижения финансовой подушки
-	var currentBalance float64
-	h.repo.DB().Model(&models.Transaction{}).
-		Where("user_id = ?", userID).
-		Select("COALESCE(SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END), 0)").
-		Scan(&currentBalance)
+	// 3. Проверка снижения финансовой подушки (суммарный остаток по всем счетам)
+	currentBalance := service.TotalBalance(h.repo.DB(), h.fx, userID, "")
 
 	decreased, current, previous := h.anomalyDetector.CheckCushionDecrease(userID, currentBalance)
 	if decreased {
@@ -175,32 +287,31 @@ func (h *TransactionHandler) checkAndNotifyAnomalies(userID uint, tx *models.Tra
 			UserID:  userID,
 			Type:    "cushion",
 			Title:   "💰 Снижение финансовой подушки",
-			Message: fmt.Sprintf("Ваша финансовая подушка снизилась с %.2f₽ до %.2f₽ (на %.0f%%)", previous, current, ((previous-current)/previous)*100),
+			Message: fmt.Sprintf("Ваша финансовая подушка снизилась с %s%s до %s%s (на %.0f%%)", previous, sym, current, sym, (previous-current).Ratio(previous)*100),
 		}
-		h.repo.CreateNotification(notification)
+		h.notify(notification)
 	}
 }
 
 func (h *TransactionHandler) List(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
-	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
-	month := c.Query("month")
-	startDate := c.Query("start_date")
-	endDate := c.Query("end_date")
-
-	if limit > 1000 {
-		limit = 1000
+	filter, ok := listFilter(c)
+	if !ok {
+		return
+	}
+	if filter.Type != "" && filter.Type != "income" && filter.Type != "expense" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be income or expense"})
+		return
 	}
 
-	txs, err := h.repo.GetTransactions(userID, limit, offset, month, startDate, endDate)
+	page, err := h.repo.ListTransactions(userID, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transactions"})
+		listError(c, err, "Failed to get transactions")
 		return
 	}
 
-	c.JSON(http.StatusOK, txs)
+	c.JSON(http.StatusOK, page)
 }
 
 func (h *TransactionHandler) Update(c *gin.Context) {
@@ -216,6 +327,8 @@ func (h *TransactionHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
 		return
 	}
+	previousCategory := tx.Category
+	before := service.AuditSnapshot(tx)
 
 	var req UpdateTransactionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -223,15 +336,39 @@ func (h *TransactionHandler) Update(c *gin.Context) {
 		return
 	}
 
+	// Сумму и дату ноги перевода нельзя менять отдельно от второй ноги
+	if tx.TransferID != nil && (req.Amount != nil || req.Date != nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transfer legs cannot change amount or date; recreate the transfer"})
+		return
+	}
+	if tx.TransferID != nil && req.Splits != nil && len(*req.Splits) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transfer legs cannot be split"})
+		return
+	}
+
 	// Обновляем только переданные поля
 	if req.Amount != nil {
 		tx.Amount = *req.Amount
 	}
 	if req.Description != nil {
 		tx.Description = *req.Description
+		merchants, err := service.LoadMerchants(h.repo.DB(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get merchants"})
+			return
+		}
+		service.AssignMerchant(tx, merchants)
 	}
 	if req.Category != nil {
-		tx.Category = *req.Category
+		tx.Category = strings.TrimSpace(*req.Category)
+		if tx.Category == "" {
+			tx.Category = models.DefaultCategory
+		}
+	}
+	// Категория, заданная пользователем, считается проверенной
+	if req.Category != nil || req.Splits != nil {
+		tx.SetCategorySource(models.CategorySourceManual, 1)
+		tx.NeedsReview = false
 	}
 	if req.Date != nil {
 		date, err := time.Parse("2006-01-02", *req.Date)
@@ -244,12 +381,64 @@ func (h *TransactionHandler) Update(c *gin.Context) {
 	if req.IsEssential != nil {
 		tx.IsEssential = *req.IsEssential
 	}
+	if req.Splits != nil {
+		tx.Splits = toSplits(*req.Splits)
+		if len(tx.Splits) > 0 {
+			tx.Category = tx.MainSplitCategory()
+		}
+	}
+	// Сумма частей должна совпадать с суммой транзакции, в том числе после ее изменения
+	if err := tx.ValidateSplits(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Пересчитываем сумму в базовой валюте, если изменились сумма или дата
+	if req.Amount != nil || req.Date != nil {
+		tx.BaseAmount, err = h.fx.ToBase(userID, tx.Amount, tx.Currency, tx.Date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	tx.NormalizeSign()
 
-	if err := h.repo.UpdateTransaction(tx); err != nil {
+	// При смене категории без явного is_essential берется признак новой категории
+	isEssential := req.IsEssential
+	if isEssential == nil && req.Category == nil {
+		isEssential = &tx.IsEssential
+	}
+	var lines []SplitLine
+	if req.Splits != nil {
+		lines = *req.Splits
+	}
+
+	err = h.repo.Transaction(func(repo *repository.Repository) error {
+		if err := h.applyCategoryDefaults(repo, tx, isEssential, lines); err != nil {
+			return err
+		}
+		if req.Tags != nil {
+			tx.Tags = nil
+			if err := h.attachTags(repo, tx, *req.Tags); err != nil {
+				return err
+			}
+		}
+		if err := repo.UpdateTransaction(tx); err != nil {
+			return err
+		}
+		return service.RecordAudit(repo.DB(), service.UserActor(userID, models.AuditSourceAPI), models.AuditEntityTransaction, tx.ID,
+			models.AuditActionUpdate, before, service.AuditSnapshot(tx))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transaction"})
 		return
 	}
 
+	// Ручная смена категории запоминается для следующих транзакций того же продавца
+	if req.Category != nil && tx.TransferID == nil && len(tx.Splits) == 0 && tx.Category != previousCategory {
+		h.recordCorrection(tx, previousCategory)
+	}
+
 	c.JSON(http.StatusOK, tx)
 }
 
@@ -261,100 +450,157 @@ func (h *TransactionHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.DeleteTransaction(uint(id), userID); err != nil {
+	tx, err := h.repo.GetTransactionByID(uint(id), userID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
 		return
 	}
+	// Нога перевода уходит в корзину вместе со второй ногой
+	removed := []models.Transaction{*tx}
+	if tx.TransferID != nil {
+		if removed, err = h.repo.GetTransferLegs(*tx.TransferID, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transaction"})
+			return
+		}
+	}
+
+	err = h.repo.Transaction(func(repo *repository.Repository) error {
+		if err := repo.DeleteTransaction(uint(id), userID); err != nil {
+			return err
+		}
+		for i := range removed {
+			if err := service.RecordAudit(repo.DB(), service.UserActor(userID, models.AuditSourceAPI), models.AuditEntityTransaction, removed[i].ID,
+				models.AuditActionDelete, service.AuditSnapshot(&removed[i]), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transaction"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Transaction deleted"})
 }
 
-// classifyByDescription - классификация по ключевым словам в description
-// Используется как приоритетный метод, если description содержит явные ключевые слова
-func (h *TransactionHandler) classifyByDescription(description string, amount float64) string {
-	if description == "" {
-		return "Misc"
+// notify - сохраняет автоматическое уведомление вместе с записью о его создании в журнале
+func (h *TransactionHandler) notify(notification *models.Notification) {
+	err := h.repo.Transaction(func(repo *repository.Repository) error {
+		if err := repo.CreateNotification(notification); err != nil {
+			return err
+		}
+		return service.RecordAudit(repo.DB(), service.SystemActor(notification.UserID, models.AuditSourceSystem), models.AuditEntityNotification,
+			notification.ID, models.AuditActionCreate, nil, service.AuditSnapshot(notification))
+	})
+	if err != nil {
+		log.Printf("Failed to create notification: %v", err)
 	}
+}
 
-	desc := strings.ToLower(description)
+// applyRules - применяет к новой транзакции действия правил пользователя (см. service.Evaluate),
+// кроме категории: ее правила задают в цепочке категоризаторов (service.RuleCategorizer).
+// Явно заданный is_essential (keepEssential) правила не меняют; метки из результата нужно
+// добавить через attachTags.
+func (h *TransactionHandler) applyRules(tx *models.Transaction, keepEssential bool) service.RuleOutcome {
+	rules, err := h.rules.Rules(tx.UserID)
+	if err != nil {
+		return service.RuleOutcome{}
+	}
+	outcome := service.Evaluate(rules, tx)
+	service.ApplyOutcome(tx, outcome, true, keepEssential)
+	return outcome
+}
 
-	// Проверяем в порядке приоритета (более специфичные сначала)
+// recordCorrection - сохраняет ручное исправление категории; ошибка не мешает обновлению транзакции
+func (h *TransactionHandler) recordCorrection(tx *models.Transaction, previousCategory string) {
+	correction := models.CategoryCorrection{
+		UserID:        tx.UserID,
+		TransactionID: tx.ID,
+		Type:          tx.Type,
+		Merchant:      models.MerchantKey(tx.Description, tx.RefNo),
+		FromCategory:  previousCategory,
+		ToCategory:    tx.Category,
+	}
+	if err := h.repo.RecordCategoryCorrection(&correction); err != nil {
+		log.Printf("Failed to record category correction for transaction %d: %v", tx.ID, err)
+	}
+}
 
-	// Аренда/Жилье
-	if strings.Contains(desc, "аренд") || strings.Contains(desc, "квартир") || strings.Contains(desc, "жилье") ||
-		strings.Contains(desc, "коммунал") || strings.Contains(desc, "жкх") || strings.Contains(desc, "управляющ") ||
-		strings.Contains(desc, "домофон") || strings.Contains(desc, "консьерж") || strings.Contains(desc, "ипотек") {
-		return "Rent"
+// categorize - категория транзакции без категории и разбивки от цепочки категоризаторов;
+// если ни одна стратегия не ответила - DefaultCategory
+func (h *TransactionHandler) categorize(ctx context.Context, tx *models.Transaction) {
+	if tx.Category != "" || len(tx.Splits) > 0 {
+		return
 	}
+	result, err := h.categorizer.Categorize(ctx, tx)
+	setCategorization(tx, result, err)
+}
 
-	// Еда - проверяем, что это не "магазин продуктов" (это Shopping)
-	foodKeywords := []string{"еда", "обед", "ужин", "кафе", "ресторан", "столовая", "завтрак", "ланч",
-		"пицц", "суши", "бургер", "шашлык", "кофе", "чай", "напиток", "десерт", "морожен", "кондитерск",
-		"пекарн", "доставк", "еды", "заказ еды", "едапорт", "delivery", "food", "cafe", "restaurant"}
-	for _, keyword := range foodKeywords {
-		if strings.Contains(desc, keyword) && !strings.Contains(desc, "магазин") {
-			return "Food"
+// categorizeAll - как categorize, но для пакета транзакций: каждая стратегия цепочки
+// опрашивается один раз на весь пакет
+func (h *TransactionHandler) categorizeAll(ctx context.Context, txs []*models.Transaction) {
+	var pending []*models.Transaction
+	for _, tx := range txs {
+		if tx.Category == "" && len(tx.Splits) == 0 {
+			pending = append(pending, tx)
 		}
 	}
-	// Продукты - только если явно указано и не магазин
-	if (strings.Contains(desc, "продукт") || strings.Contains(desc, "мясо") || strings.Contains(desc, "рыба") ||
-		strings.Contains(desc, "овощ") || strings.Contains(desc, "фрукт") || strings.Contains(desc, "молок") ||
-		strings.Contains(desc, "хлеб") || strings.Contains(desc, "бакалея")) && !strings.Contains(desc, "магазин") {
-		return "Food"
-	}
-
-	// Транспорт
-	transportKeywords := []string{"такси", "uber", "yandex", "ситимобил", "gett", "транспорт", "метро",
-		"автобус", "троллейбус", "трамвай", "поезд", "электричк", "билет", "проезд", "транспортная карта",
-		"транспортная", "каршеринг", "каршеринг", "бензин", "заправк", "азс", "газ", "парковк", "стоянк",
-		"штраф", "гибдд", "дпс", "транспортный налог"}
-	for _, keyword := range transportKeywords {
-		if strings.Contains(desc, keyword) {
-			return "Transport"
-		}
+	if len(pending) == 0 {
+		return
 	}
-
-	// Shopping
-	shoppingKeywords := []string{"одежд", "шоппинг", "магазин", "покупк", "торгов", "торговый центр",
-		"молл", "бутик", "ателье", "обув", "аксессуар", "электроник", "телефон", "ноутбук", "планшет",
-		"техник", "бытов", "мебель", "интерьер", "косметик", "парфюм", "аптек", "лекарств", "медицин",
-		"здоровье", "спорт", "фитнес", "тренажер", "абонемент", "книг", "канцтовар", "игрушк", "подарок"}
-	for _, keyword := range shoppingKeywords {
-		if strings.Contains(desc, keyword) {
-			// Исключения для продуктовых магазинов
-			if (keyword == "магазин" || keyword == "покупк") &&
-				(strings.Contains(desc, "продукт") || strings.Contains(desc, "еда") || strings.Contains(desc, "продуктов")) {
-				continue
-			}
-			return "Shopping"
-		}
+	results, errs := service.CategorizeAll(ctx, h.categorizer, pending)
+	for i, tx := range pending {
+		setCategorization(tx, results[i], errs[i])
 	}
+}
 
-	// Здоровье/Медицина (может быть отдельной категорией, но пока Shopping)
-	if strings.Contains(desc, "больниц") || strings.Contains(desc, "поликлиник") || strings.Contains(desc, "врач") ||
-		strings.Contains(desc, "стоматолог") || strings.Contains(desc, "лечение") || strings.Contains(desc, "анализ") {
-		return "Shopping" // Или можно добавить отдельную категорию "Health"
+// setCategorization - категория из ответа цепочки (или DefaultCategory) и ее объяснение
+func setCategorization(tx *models.Transaction, result *service.Categorization, err error) {
+	explanation := &models.CategoryExplanation{}
+	if result != nil {
+		explanation.Explanation = result.Explanation
+		explanation.Decision = result.Decision
+		explanation.RuleID = result.RuleID
+		explanation.Steps = result.Steps
+	}
+	if err == nil && result != nil && result.Category != "" {
+		tx.Category = result.Category
+		tx.SetCategorySource(result.Source, result.Confidence)
+	} else {
+		tx.Category = models.DefaultCategory
+		tx.SetCategorySource(models.CategorySourceDefault, 0)
+		explanation.Explanation = "категория по умолчанию"
+		if err != nil {
+			explanation.Decision = fmt.Sprintf("цепочка категоризаторов недоступна: %v", err)
+		}
 	}
+	explanation.Category = tx.Category
+	explanation.Source = tx.CategorySource
+	explanation.Confidence = tx.CategoryConfidence
+	tx.Explanation = explanation
+}
 
-	// Образование
-	if strings.Contains(desc, "образован") || strings.Contains(desc, "университет") || strings.Contains(desc, "школ") ||
-		strings.Contains(desc, "курс") || strings.Contains(desc, "обучен") || strings.Contains(desc, "репетитор") {
-		return "Shopping" // Или можно добавить отдельную категорию "Education"
+// manualExplanation - объяснение категории, заданной пользователем без цепочки категоризаторов
+func manualExplanation(tx *models.Transaction, decision string) *models.CategoryExplanation {
+	return &models.CategoryExplanation{
+		Category:   tx.Category,
+		Source:     tx.CategorySource,
+		Confidence: tx.CategoryConfidence,
+		Decision:   decision,
 	}
+}
 
-	// Развлечения
-	if strings.Contains(desc, "кино") || strings.Contains(desc, "театр") || strings.Contains(desc, "концерт") ||
-		strings.Contains(desc, "клуб") || strings.Contains(desc, "бар") || strings.Contains(desc, "развлечен") ||
-		strings.Contains(desc, "игра") || strings.Contains(desc, "казино") || strings.Contains(desc, "билет") {
-		return "Shopping" // Или можно добавить отдельную категорию "Entertainment"
+// attachTags - добавляет транзакции метки по именам (отсутствующие метки создаются)
+func (h *TransactionHandler) attachTags(repo *repository.Repository, tx *models.Transaction, names []string) error {
+	names = service.NormalizeTags(append(service.TagNames(tx.Tags), names...))
+	if len(names) == 0 {
+		return nil
 	}
-
-	// Зарплата - по сумме и ключевым словам
-	if amount > 5000 || strings.Contains(desc, "зарплат") || strings.Contains(desc, "заработн") ||
-		strings.Contains(desc, "доход") || strings.Contains(desc, "выплат") || strings.Contains(desc, "перевод") {
-		return "Salary"
+	tags, err := service.EnsureTags(repo.DB(), tx.UserID, names)
+	if err != nil {
+		return err
 	}
-
-	// Если ничего не подошло - Misc
-	return "Misc"
+	tx.Tags = tags
+	return nil
 }
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BulkTransactionsRequest - одна операция над списком транзакций или над транзакциями под фильтром
+type BulkTransactionsRequest struct {
+	Operation   string      `json:"operation" binding:"required"`
+	IDs         []uint      `json:"ids"`
+	Filter      *BulkFilter `json:"filter"`       // Вместо ids: фильтры как у поиска транзакций
+	Category    string      `json:"category"`     // set_category
+	IsEssential *bool       `json:"is_essential"` // set_essential; для set_category - вместо признака категории из справочника
+	Tag         string      `json:"tag"`          // add_tag, remove_tag
+	Days        int         `json:"days"`         // shift_date
+	DryRun      bool        `json:"dry_run"`      // Только посчитать, ничего не меняя
+}
+
+// BulkFilter - фильтры выбора транзакций (см. GET /transactions/search); нужен хотя бы один
+type BulkFilter struct {
+	Query       string        `json:"q"`
+	Type        string        `json:"type"`
+	Category    string        `json:"category"`
+	IsEssential *bool         `json:"is_essential"`
+	Tags        []string      `json:"tags"`
+	AccountID   uint          `json:"account_id"`
+	MinAmount   *models.Money `json:"min_amount"`
+	MaxAmount   *models.Money `json:"max_amount"`
+	Month       string        `json:"month"`
+	StartDate   string        `json:"start_date"`
+	EndDate     string        `json:"end_date"`
+}
+
+// search - запрос поиска по фильтру
+func (f *BulkFilter) search() (repository.TransactionSearch, error) {
+	search := repository.TransactionSearch{
+		Query:       f.Query,
+		Type:        f.Type,
+		Category:    f.Category,
+		IsEssential: f.IsEssential,
+		Tags:        f.Tags,
+		AccountID:   f.AccountID,
+		MinAmount:   f.MinAmount,
+		MaxAmount:   f.MaxAmount,
+		StartDate:   f.StartDate,
+		EndDate:     f.EndDate,
+	}
+	if search.Type != "" && search.Type != "income" && search.Type != "expense" {
+		return search, errors.New("filter.type must be income or expense")
+	}
+	if f.Month != "" {
+		if _, err := time.Parse("2006-01", f.Month); err != nil {
+			return search, errors.New("Invalid filter.month. Use YYYY-MM")
+		}
+		search.StartDate, search.EndDate = service.MonthRange(f.Month)
+	}
+	for param, value := range map[string]string{"start_date": search.StartDate, "end_date": search.EndDate} {
+		if _, err := time.Parse("2006-01-02", value); value != "" && err != nil {
+			return search, fmt.Errorf("Invalid filter.%s. Use YYYY-MM-DD", param)
+		}
+	}
+	if f.Query == "" && f.Type == "" && f.Category == "" && f.IsEssential == nil && len(f.Tags) == 0 && f.AccountID == 0 &&
+		f.MinAmount == nil && f.MaxAmount == nil && search.StartDate == "" && search.EndDate == "" {
+		return search, errors.New("filter must have at least one condition")
+	}
+	return search, nil
+}
+
+// Bulk - применяет одну операцию к транзакциям из ids или под фильтром одной транзакцией БД.
+// dry_run выполняет операцию и откатывает ее: в ответе те же счетчики, но ничего не меняется.
+func (h *TransactionHandler) Bulk(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req BulkTransactionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	op := service.BulkOperation{
+		Operation:   req.Operation,
+		Category:    req.Category,
+		IsEssential: req.IsEssential,
+		Tag:         req.Tag,
+		Days:        req.Days,
+	}
+	if err := op.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (len(req.IDs) == 0) == (req.Filter == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pass either ids or filter"})
+		return
+	}
+
+	ids := req.IDs
+	if req.Filter != nil {
+		search, err := req.Filter.search()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// Лишний id показывает, что под фильтр попало больше допустимого
+		if ids, err = h.repo.MatchTransactionIDs(userID, search, service.MaxBulkTransactions+1); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transactions"})
+			return
+		}
+	}
+	if len(ids) > service.MaxBulkTransactions {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many transactions: at most %d per operation, narrow the filter", service.MaxBulkTransactions)})
+		return
+	}
+
+	result, err := h.bulk.Apply(userID, ids, op, req.DryRun)
+	switch {
+	case errors.Is(err, service.ErrRateNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply bulk operation"})
+		return
+	}
+
+	// Как и при PATCH, ручная смена категории запоминается для продавца
+	for i := range result.Corrections {
+		if err := h.repo.RecordCategoryCorrection(&result.Corrections[i]); err != nil {
+			log.Printf("Failed to record category correction for transaction %d: %v", result.Corrections[i].TransactionID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
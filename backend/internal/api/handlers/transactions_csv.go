@@ -3,7 +3,11 @@ package handlers
 import (
 	"clarity/internal/api/middleware"
 	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -24,9 +28,10 @@ func (h *TransactionHandler) ExportTransactions(c *gin.Context) {
 	month := c.Query("month")
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
+	tags := c.QueryArray("tag")
 
 	// Получаем транзакции с учетом фильтров
-	transactions, err := h.repo.GetTransactions(userID, 10000, 0, month, startDate, endDate)
+	transactions, err := h.repo.GetTransactions(userID, 10000, 0, month, startDate, endDate, tags)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transactions"})
 		return
@@ -41,7 +46,7 @@ func (h *TransactionHandler) ExportTransactions(c *gin.Context) {
 	defer writer.Flush()
 
 	// Записываем заголовки
-	headers := []string{"date", "amount", "type", "description", "ref_no", "category", "is_essential"}
+	headers := []string{"date", "amount", "currency", "base_amount", "type", "description", "ref_no", "category", "is_essential", "account_id", "tags"}
 	if err := writer.Write(headers); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write CSV header"})
 		return
@@ -51,12 +56,16 @@ func (h *TransactionHandler) ExportTransactions(c *gin.Context) {
 	for _, tx := range transactions {
 		record := []string{
 			tx.Date.Format("2006-01-02"),
-			fmt.Sprintf("%.2f", tx.Amount),
+			tx.Amount.String(),
+			tx.Currency,
+			tx.BaseAmount.String(),
 			tx.Type,
 			tx.Description,
 			tx.RefNo,
 			tx.Category,
 			strconv.FormatBool(tx.IsEssential),
+			strconv.FormatUint(uint64(tx.AccountID), 10),
+			strings.Join(service.TagNames(tx.Tags), csvTagSeparator),
 		}
 		if err := writer.Write(record); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write CSV record"})
@@ -75,24 +84,18 @@ func (h *TransactionHandler) ExportReport(c *gin.Context) {
 	endDate := c.Query("end_date")
 
 	// Получаем транзакции с учетом фильтров
-	transactions, err := h.repo.GetTransactions(userID, 10000, 0, month, startDate, endDate)
+	transactions, err := h.repo.GetTransactions(userID, 10000, 0, month, startDate, endDate, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transactions"})
 		return
 	}
 
-	// Вычисляем аналитику
-	var totalIncome, totalExpense, essentialExpense float64
-	var categoryData []struct {
-		Category string
-		Amount   float64
-	}
-
 	db := h.repo.DB()
 
 	// Строим базовый запрос с фильтрами по дате
 	buildQuery := func(query *gorm.DB) *gorm.DB {
-		query = query.Where("user_id = ?", userID)
+		// Ноги переводов между счетами не являются доходом или расходом
+		query = query.Where("user_id = ? AND transfer_id IS NULL", userID)
 		if month != "" {
 			query = query.Where("DATE_TRUNC('month', date) = ?", month)
 		} else if startDate != "" && endDate != "" {
@@ -105,30 +108,10 @@ func (h *TransactionHandler) ExportReport(c *gin.Context) {
 		return query
 	}
 
-	// Доходы
-	incomeQuery := buildQuery(db.Model(&models.Transaction{}).Where("type = ?", "income"))
-	incomeQuery.Select("COALESCE(SUM(amount), 0)").Scan(&totalIncome)
-
-	// Расходы
-	expenseQuery := buildQuery(db.Model(&models.Transaction{}).Where("type = ?", "expense"))
-	expenseQuery.Select("COALESCE(SUM(ABS(amount)), 0)").Scan(&totalExpense)
-
-	// Обязательные расходы
-	essentialQuery := buildQuery(db.Model(&models.Transaction{}).Where("type = ? AND is_essential = ?", "expense", true))
-	essentialQuery.Select("COALESCE(SUM(ABS(amount)), 0)").Scan(&essentialExpense)
-
-	// По категориям
-	categoryQuery := buildQuery(db.Model(&models.Transaction{}).Where("type = ?", "expense"))
-	categoryQuery.Select("category, COALESCE(SUM(ABS(amount)), 0) as amount").
-		Group("category").
-		Find(&categoryData)
-
-	balance := totalIncome - totalExpense
-	savingsRate := 0.0
-	if totalIncome > 0 {
-		savingsRate = (balance / totalIncome) * 100
-	}
-	nonEssentialExpense := totalExpense - essentialExpense
+	// Вычисляем аналитику (категории - по частям разбитых транзакций)
+	period := buildQuery(service.TransactionLines(db)).Session(&gorm.Session{})
+	totals := service.SumTotals(period)
+	categoryData := service.ExpenseByCategory(period)
 
 	// Устанавливаем заголовки для скачивания файла
 	c.Header("Content-Type", "text/csv; charset=utf-8")
@@ -141,6 +124,7 @@ func (h *TransactionHandler) ExportReport(c *gin.Context) {
 	// Записываем метаданные
 	writer.Write([]string{"#", "ФИНАНСОВЫЙ ОТЧЕТ"})
 	writer.Write([]string{"#", "Дата создания:", time.Now().Format("2006-01-02 15:04:05")})
+	writer.Write([]string{"#", "Валюта отчета:", service.BaseCurrency(db, userID)})
 	if month != "" {
 		writer.Write([]string{"#", "Период:", month})
 	} else if startDate != "" && endDate != "" {
@@ -152,12 +136,12 @@ func (h *TransactionHandler) ExportReport(c *gin.Context) {
 
 	// Записываем сводку
 	writer.Write([]string{"#", "СВОДКА"})
-	writer.Write([]string{"Доходы", fmt.Sprintf("%.2f", totalIncome)})
-	writer.Write([]string{"Расходы", fmt.Sprintf("%.2f", totalExpense)})
-	writer.Write([]string{"Обязательные расходы", fmt.Sprintf("%.2f", essentialExpense)})
-	writer.Write([]string{"Необязательные расходы", fmt.Sprintf("%.2f", nonEssentialExpense)})
-	writer.Write([]string{"Баланс", fmt.Sprintf("%.2f", balance)})
-	writer.Write([]string{"Норма сбережений (%)", fmt.Sprintf("%.2f", savingsRate)})
+	writer.Write([]string{"Доходы", totals.Income.String()})
+	writer.Write([]string{"Расходы", totals.Expense.String()})
+	writer.Write([]string{"Обязательные расходы", totals.Essential.String()})
+	writer.Write([]string{"Необязательные расходы", totals.NonEssential().String()})
+	writer.Write([]string{"Баланс", totals.Net().String()})
+	writer.Write([]string{"Норма сбережений (%)", fmt.Sprintf("%.2f", totals.SavingsRate())})
 	writer.Write([]string{""})
 
 	// Записываем расходы по категориям
@@ -167,28 +151,31 @@ func (h *TransactionHandler) ExportReport(c *gin.Context) {
 		for _, item := range categoryData {
 			category := item.Category
 			if category == "" {
-				category = "Другое"
+				category = models.DefaultCategory
 			}
-			writer.Write([]string{category, fmt.Sprintf("%.2f", item.Amount)})
+			writer.Write([]string{category, item.Amount.String()})
 		}
 		writer.Write([]string{""})
 	}
 
 	// Записываем заголовки транзакций
 	writer.Write([]string{"#", "ДЕТАЛЬНЫЕ ТРАНЗАКЦИИ"})
-	headers := []string{"Дата", "Сумма", "Тип", "Описание", "Номер операции", "Категория", "Обязательный"}
+	headers := []string{"Дата", "Сумма", "Валюта", "Сумма в валюте отчета", "Тип", "Описание", "Номер операции", "Категория", "Обязательный", "Метки"}
 	writer.Write(headers)
 
 	// Записываем транзакции
 	for _, tx := range transactions {
 		record := []string{
 			tx.Date.Format("2006-01-02"),
-			fmt.Sprintf("%.2f", tx.Amount),
+			tx.Amount.String(),
+			tx.Currency,
+			tx.BaseAmount.String(),
 			tx.Type,
 			tx.Description,
 			tx.RefNo,
 			tx.Category,
 			strconv.FormatBool(tx.IsEssential),
+			strings.Join(service.TagNames(tx.Tags), csvTagSeparator),
 		}
 		if err := writer.Write(record); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write CSV record"})
@@ -197,6 +184,20 @@ func (h *TransactionHandler) ExportReport(c *gin.Context) {
 	}
 }
 
+// csvTagSeparator - разделитель меток в колонке tags при экспорте и импорте
+const csvTagSeparator = ";"
+
+// importPageSize - строк CSV, которые категоризируются одним проходом цепочки категоризаторов
+const importPageSize = 500
+
+// importRow - разобранная строка импорта, ожидающая категоризации и сохранения
+type importRow struct {
+	row          int // Номер строки для сообщений об ошибках
+	tx           *models.Transaction
+	csvEssential bool     // is_essential задан в CSV
+	tags         []string // Метки из колонки tags
+}
+
 // ImportTransactionsRequest - запрос на импорт транзакций
 type ImportTransactionsRequest struct {
 	SkipErrors bool `json:"skip_errors"` // Пропускать ошибки и продолжать импорт
@@ -204,10 +205,10 @@ type ImportTransactionsRequest struct {
 
 // ImportTransactionsResponse - ответ на импорт
 type ImportTransactionsResponse struct {
-	Total      int `json:"total"`       // Всего строк в CSV
-	Imported   int `json:"imported"`    // Успешно импортировано
-	Failed     int `json:"failed"`      // Не удалось импортировать
-	Errors     []string `json:"errors"` // Список ошибок
+	Total    int      `json:"total"`    // Всего строк в CSV
+	Imported int      `json:"imported"` // Успешно импортировано
+	Failed   int      `json:"failed"`   // Не удалось импортировать
+	Errors   []string `json:"errors"`   // Список ошибок
 }
 
 // ImportTransactions - импорт транзакций из CSV
@@ -232,6 +233,21 @@ func (h *TransactionHandler) ImportTransactions(c *gin.Context) {
 	// Парсим параметры
 	skipErrors := c.PostForm("skip_errors") == "true"
 
+	// Счет для строк без колонки account_id: из формы, иначе счет пользователя по умолчанию
+	var defaultAccountID uint
+	if accountParam := c.PostForm("account_id"); accountParam != "" {
+		id, err := strconv.ParseUint(accountParam, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+			return
+		}
+		if _, err := h.repo.GetAccountByID(uint(id), userID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Account not found"})
+			return
+		}
+		defaultAccountID = uint(id)
+	}
+
 	// Читаем CSV (обрабатываем BOM если есть)
 	// CSV reader в Go не обрабатывает BOM автоматически, поэтому делаем это вручную
 	bomReader := src
@@ -274,10 +290,36 @@ func (h *TransactionHandler) ImportTransactions(c *gin.Context) {
 			return
 		}
 	}
+	// Без account_id в форме строки без счета идут на счет пользователя по умолчанию
+	if defaultAccountID == 0 {
+		account, err := h.repo.DefaultAccount(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get default account"})
+			return
+		}
+		defaultAccountID = account.ID
+	}
+
+	// Кэш проверенных счетов пользователя: id счета -> валюта
+	ownedAccounts := make(map[uint]string)
+	if defaultAccountID != 0 {
+		acc, _ := h.repo.GetAccountByID(defaultAccountID, userID)
+		ownedAccounts[defaultAccountID] = acc.Currency
+	}
 
 	response := ImportTransactionsResponse{
 		Errors: []string{},
 	}
+	reviewThreshold := service.ReviewThreshold(h.repo.DB(), userID)
+
+	// Строки копятся страницами по importPageSize: категоризация страницы - один проход
+	// цепочки (ML сервис получает пакет вместо запроса на каждую строку)
+	var page []importRow
+	flush := func() error {
+		err := h.importPage(c.Request.Context(), page, reviewThreshold, skipErrors, &response)
+		page = page[:0]
+		return err
+	}
 
 	// Читаем и обрабатываем строки
 	for {
@@ -301,6 +343,31 @@ func (h *TransactionHandler) ImportTransactions(c *gin.Context) {
 
 		// Парсим транзакцию
 		tx, parseErr := h.parseCSVRecord(record, headerMap, userID)
+		if parseErr == nil {
+			if tx.AccountID == 0 {
+				tx.AccountID = defaultAccountID
+			}
+			accountCurrency, ok := ownedAccounts[tx.AccountID]
+			if !ok {
+				if acc, err := h.repo.GetAccountByID(tx.AccountID, userID); err != nil {
+					parseErr = fmt.Errorf("account not found: %d", tx.AccountID)
+				} else {
+					accountCurrency = acc.Currency
+					ownedAccounts[tx.AccountID] = acc.Currency
+				}
+			}
+			if parseErr == nil {
+				if tx.Currency == "" {
+					tx.Currency = accountCurrency
+				}
+				if tx.Currency != accountCurrency {
+					parseErr = fmt.Errorf("currency %s does not match account currency %s", tx.Currency, accountCurrency)
+				}
+			}
+			if parseErr == nil {
+				tx.BaseAmount, parseErr = h.fx.ToBase(userID, tx.Amount, tx.Currency, tx.Date)
+			}
+		}
 		if parseErr != nil {
 			response.Failed++
 			errorMsg := fmt.Sprintf("Row %d: %v", response.Total, parseErr)
@@ -311,53 +378,100 @@ func (h *TransactionHandler) ImportTransactions(c *gin.Context) {
 			log.Printf("[CSV Import] ERROR Row %d: %s", response.Total, errorMsg)
 			response.Errors = append(response.Errors, errorMsg)
 			if !skipErrors {
+				// Строки до ошибочной импортируются, как и без страниц
+				if err := flush(); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
 				c.JSON(http.StatusBadRequest, gin.H{"error": errorMsg})
 				return
 			}
 			continue
 		}
+		tx.NormalizeSign()
 
-		log.Printf("[CSV Import] Row %d: parsed successfully - Date: %s, Amount: %.2f, Type: %s, Description: %s", 
+		log.Printf("[CSV Import] Row %d: parsed successfully - Date: %s, Amount: %s, Type: %s, Description: %s",
 			response.Total, tx.Date.Format("2006-01-02"), tx.Amount, tx.Type, tx.Description)
 
-		// ML категоризация для расходов (только если категория не указана в CSV)
-		if tx.Type == "expense" && (tx.Category == "" || strings.TrimSpace(tx.Category) == "") {
-			refNo := tx.RefNo
-			if refNo == "" && tx.Description != "" {
-				refNo = tx.Description
+		csvEssential := false
+		if essIdx, ok := headerMap["is_essential"]; ok && essIdx < len(record) {
+			csvEssential = strings.TrimSpace(record[essIdx]) != ""
+		}
+		var tags []string
+		if tagsIdx, ok := headerMap["tags"]; ok && tagsIdx < len(record) {
+			tags = strings.Split(record[tagsIdx], csvTagSeparator)
+		}
+		page = append(page, importRow{row: response.Total, tx: tx, csvEssential: csvEssential, tags: tags})
+		if len(page) >= importPageSize {
+			if err := flush(); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
 			}
+		}
+	}
+	if err := flush(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-			result, err := h.mlClient.CategorizeWithDate(refNo, tx.Amount, tx.Date)
-			if err == nil {
-				// ML сервис доступен
-				if tx.Description != "" {
-					descCategory := h.classifyByDescription(tx.Description, tx.Amount)
-					if descCategory != "Misc" && descCategory != "" {
-						tx.Category = descCategory
-					} else {
-						tx.Category = result.Category
-					}
-				} else {
-					tx.Category = result.Category
-				}
-			} else {
-				// Fallback на описание
-				if tx.Description != "" {
-					tx.Category = h.classifyByDescription(tx.Description, tx.Amount)
-				} else {
-					tx.Category = "Другое"
-				}
-			}
+	c.JSON(http.StatusOK, response)
+}
+
+// importPage - категоризирует страницу импорта одним проходом цепочки и сохраняет транзакции.
+// Без skipErrors первая ошибка сохранения прерывает импорт.
+func (h *TransactionHandler) importPage(ctx context.Context, page []importRow, reviewThreshold float64, skipErrors bool, response *ImportTransactionsResponse) error {
+	if len(page) == 0 {
+		return nil
+	}
+	txs := make([]*models.Transaction, len(page))
+	for i, row := range page {
+		txs[i] = row.tx
+	}
+	// Категория из CSV важнее цепочки категоризаторов
+	h.categorizeAll(ctx, txs)
+
+	merchants, err := service.LoadMerchants(h.repo.DB(), page[0].tx.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get merchants: %w", err)
+	}
+
+	for _, row := range page {
+		tx := row.tx
+
+		// is_essential из CSV важнее правил. Правила задают признак обязательности, описание и метки.
+		var isEssential *bool
+		if row.csvEssential {
+			isEssential = &tx.IsEssential
+		}
+		outcome := h.applyRules(tx, isEssential != nil)
+		if isEssential == nil && outcome.IsEssential != nil {
+			isEssential = outcome.IsEssential
 		}
+		tx.NeedsReview = service.NeedsReview(tx, reviewThreshold)
 
-		// Создаем транзакцию
-		if err := h.repo.CreateTransaction(tx); err != nil {
+		service.AssignMerchant(tx, merchants)
+
+		// Создаем транзакцию вместе с ее новыми категориями, метками и записью в журнале
+		err := h.repo.Transaction(func(repo *repository.Repository) error {
+			// Пустая колонка is_essential - признак из правил или из справочника категорий
+			if err := h.applyCategoryDefaults(repo, tx, isEssential, nil); err != nil {
+				return err
+			}
+			if err := h.attachTags(repo, tx, append(row.tags, outcome.Tags...)); err != nil {
+				return err
+			}
+			if err := repo.CreateTransaction(tx); err != nil {
+				return err
+			}
+			return service.RecordAudit(repo.DB(), service.UserActor(tx.UserID, models.AuditSourceCSVImport), models.AuditEntityTransaction, tx.ID,
+				models.AuditActionCreate, nil, service.AuditSnapshot(tx))
+		})
+		if err != nil {
 			response.Failed++
-			errorMsg := fmt.Sprintf("Row %d: Failed to create transaction: %v", response.Total, err)
+			errorMsg := fmt.Sprintf("Row %d: Failed to create transaction: %v", row.row, err)
 			response.Errors = append(response.Errors, errorMsg)
 			if !skipErrors {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": errorMsg})
-				return
+				return errors.New(errorMsg)
 			}
 			continue
 		}
@@ -365,10 +479,9 @@ func (h *TransactionHandler) ImportTransactions(c *gin.Context) {
 		response.Imported++
 
 		// Асинхронная детекция аномалий (не блокируем импорт)
-		go h.checkAndNotifyAnomalies(userID, tx)
+		go h.checkAndNotifyAnomalies(tx.UserID, tx)
 	}
-
-	c.JSON(http.StatusOK, response)
+	return nil
 }
 
 // parseCSVRecord - парсит строку CSV в транзакцию
@@ -388,11 +501,11 @@ func (h *TransactionHandler) parseCSVRecord(record []string, headerMap map[strin
 	if err != nil {
 		// Пробуем другие форматы
 		formats := []string{
-			"2006/01/02",      // YYYY/MM/DD
-			"02.01.2006",      // DD.MM.YYYY
-			"02-01-2006",      // DD-MM-YYYY
-			"01-02-2006",      // MM-DD-YYYY
-			"01-02-06",        // MM-DD-YY (12-07-25 = 12-07-2025)
+			"2006/01/02",           // YYYY/MM/DD
+			"02.01.2006",           // DD.MM.YYYY
+			"02-01-2006",           // DD-MM-YYYY
+			"01-02-2006",           // MM-DD-YYYY
+			"01-02-06",             // MM-DD-YY (12-07-25 = 12-07-2025)
 			"2006-01-02T15:04:05Z", // ISO format
 		}
 		parsed := false
@@ -436,11 +549,11 @@ func (h *TransactionHandler) parseCSVRecord(record []string, headerMap map[strin
 	log.Printf("[CSV Parse] Parsing amount: '%s'", amountStr)
 	// Убираем пробелы
 	amountStr = strings.ReplaceAll(amountStr, " ", "")
-	
+
 	// Определяем, какая запятая используется как разделитель тысяч, а какая как десятичный
 	hasComma := strings.Contains(amountStr, ",")
 	hasDot := strings.Contains(amountStr, ".")
-	
+
 	originalAmountStr := amountStr
 	if hasComma && hasDot {
 		// Оба разделителя: запятая = тысячи, точка = десятичные (1,234.56)
@@ -472,13 +585,13 @@ func (h *TransactionHandler) parseCSVRecord(record []string, headerMap map[strin
 		}
 		// Иначе точка уже правильный десятичный разделитель
 	}
-	
-	amount, err := strconv.ParseFloat(amountStr, 64)
+
+	amount, err := models.ParseMoney(amountStr)
 	if err != nil {
 		log.Printf("[CSV Parse] ERROR parsing amount: original='%s', processed='%s', error=%v", originalAmountStr, amountStr, err)
 		return nil, fmt.Errorf("invalid amount: %s (parsed as: %s)", record[amountIdx], amountStr)
 	}
-	log.Printf("[CSV Parse] Amount parsed successfully: %.2f (from '%s')", amount, originalAmountStr)
+	log.Printf("[CSV Parse] Amount parsed successfully: %s (from '%s')", amount, originalAmountStr)
 	tx.Amount = amount
 
 	// Type (обязательное)
@@ -491,6 +604,8 @@ func (h *TransactionHandler) parseCSVRecord(record []string, headerMap map[strin
 		return nil, fmt.Errorf("invalid type: %s (must be 'income' or 'expense')", typeStr)
 	}
 	tx.Type = typeStr
+	// В выгрузках банков расход бывает и со знаком, и без: знак определяется только типом
+	tx.NormalizeSign()
 
 	// Description (опциональное)
 	if descIdx, ok := headerMap["description"]; ok && descIdx < len(record) {
@@ -505,6 +620,10 @@ func (h *TransactionHandler) parseCSVRecord(record []string, headerMap map[strin
 	// Category (опциональное, если не указана - будет определена ML)
 	if catIdx, ok := headerMap["category"]; ok && catIdx < len(record) {
 		tx.Category = strings.TrimSpace(record[catIdx])
+		if tx.Category != "" {
+			tx.SetCategorySource(models.CategorySourceManual, 1)
+			tx.Explanation = manualExplanation(tx, "категория указана в CSV")
+		}
 	}
 
 	// IsEssential (опциональное)
@@ -514,6 +633,23 @@ func (h *TransactionHandler) parseCSVRecord(record []string, headerMap map[strin
 		tx.IsEssential = essStr == "true" || essStr == "1" || essStr == "yes" || essStr == "да"
 	}
 
+	// Currency (опциональное, по умолчанию валюта счета)
+	if curIdx, ok := headerMap["currency"]; ok && curIdx < len(record) {
+		if curStr := strings.TrimSpace(record[curIdx]); curStr != "" {
+			tx.Currency = service.NormalizeCurrency(curStr)
+		}
+	}
+
+	// AccountID (опциональное, если не указан - берется счет из формы импорта)
+	if accIdx, ok := headerMap["account_id"]; ok && accIdx < len(record) {
+		if accStr := strings.TrimSpace(record[accIdx]); accStr != "" {
+			accountID, err := strconv.ParseUint(accStr, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid account_id: %s", accStr)
+			}
+			tx.AccountID = uint(accountID)
+		}
+	}
+
 	return tx, nil
 }
-
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExplainResponse - почему транзакция получила свою категорию
+type ExplainResponse struct {
+	TransactionID      uint                        `json:"transaction_id"`
+	Category           string                      `json:"category"` // Текущая категория транзакции
+	CategorySource     string                      `json:"category_source"`
+	CategoryConfidence float64                     `json:"category_confidence"`
+	Stored             *models.CategoryExplanation `json:"stored"`  // Как категория выбрана при создании
+	DryRun             *service.Categorization     `json:"dry_run"` // Что цепочка категоризаторов выбрала бы сейчас
+	Matches            bool                        `json:"matches"` // Пробный прогон выбрал текущую категорию
+}
+
+// Explain - объяснение категории: сохраненное при создании и пробный прогон цепочки
+// категоризаторов без изменения транзакции
+func (h *TransactionHandler) Explain(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	tx, err := h.repo.GetTransactionByID(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+	if tx.TransferID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transfer legs are not categorized"})
+		return
+	}
+
+	stored, err := h.repo.GetCategoryExplanation(tx.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get category explanation"})
+		return
+	}
+
+	dryRun, err := h.dryRun(c, tx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run categorizers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ExplainResponse{
+		TransactionID:      tx.ID,
+		Category:           tx.Category,
+		CategorySource:     tx.CategorySource,
+		CategoryConfidence: tx.CategoryConfidence,
+		Stored:             stored,
+		DryRun:             dryRun,
+		Matches:            dryRun.Category == tx.Category,
+	})
+}
+
+// dryRun - категоризация копии транзакции без категории, как при создании; если ни одна
+// стратегия не ответила - DefaultCategory
+func (h *TransactionHandler) dryRun(c *gin.Context, tx *models.Transaction) (*service.Categorization, error) {
+	probe := *tx
+	probe.Category = ""
+	probe.Splits = nil
+	probe.Tags = nil
+
+	var result *service.Categorization
+	var err error
+	if explainer, ok := h.categorizer.(service.Explainer); ok {
+		result, err = explainer.Explain(c.Request.Context(), &probe)
+	} else {
+		result, err = h.categorizer.Categorize(c.Request.Context(), &probe)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = &service.Categorization{}
+	}
+	if result.Category == "" {
+		result.Category = models.DefaultCategory
+		result.Source = models.CategorySourceDefault
+		result.Explanation = "категория по умолчанию"
+	}
+	return result, nil
+}
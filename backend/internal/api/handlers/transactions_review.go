@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReviewQueueResponse - очередь проверки категорий
+type ReviewQueueResponse struct {
+	Currency          string               `json:"currency"`
+	Total             int64                `json:"total"`              // Всего транзакций в очереди
+	UnreviewedExpense models.Money         `json:"unreviewed_expense"` // Расходы в очереди в базовой валюте
+	Transactions      []models.Transaction `json:"transactions"`
+}
+
+// ConfirmReviewRequest - подтверждение категорий из очереди проверки
+type ConfirmReviewRequest struct {
+	TransactionIDs []uint `json:"transaction_ids"`
+	All            bool   `json:"all"` // Подтвердить всю очередь
+}
+
+// ReassignReviewRequest - новая категория для нескольких транзакций
+type ReassignReviewRequest struct {
+	TransactionIDs []uint `json:"transaction_ids" binding:"required,min=1"`
+	Category       string `json:"category" binding:"required"`
+	IsEssential    *bool  `json:"is_essential"` // По умолчанию - признак категории из справочника
+}
+
+// ReassignReviewResponse - итог переназначения категорий
+type ReassignReviewResponse struct {
+	Updated int    `json:"updated"`
+	Skipped []uint `json:"skipped"` // Не найдены, ноги переводов или транзакции с разбивкой
+}
+
+// ReviewQueue - транзакции, уверенность категоризации которых ниже порога пользователя
+func (h *TransactionHandler) ReviewQueue(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	txs, total, err := h.repo.GetReviewQueue(userID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get review queue"})
+		return
+	}
+
+	db := h.repo.DB()
+	c.JSON(http.StatusOK, ReviewQueueResponse{
+		Currency:          service.BaseCurrency(db, userID),
+		Total:             total,
+		UnreviewedExpense: service.SumTotals(service.CashflowQuery(db, userID)).Unreviewed,
+		Transactions:      txs,
+	})
+}
+
+// ConfirmReview - категории верны: транзакции уходят из очереди проверки
+func (h *TransactionHandler) ConfirmReview(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req ConfirmReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.TransactionIDs) == 0 && !req.All {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pass transaction_ids or all=true"})
+		return
+	}
+
+	ids := req.TransactionIDs
+	if req.All {
+		ids = nil
+	}
+	var confirmed int64
+	err := h.repo.Transaction(func(repo *repository.Repository) error {
+		queued, err := repo.ReviewQueueIDs(userID, ids)
+		if err != nil {
+			return err
+		}
+		return service.RecordTransactionUpdates(repo.DB(), service.UserActor(userID, models.AuditSourceAPI), queued, func() error {
+			confirmed, err = repo.ConfirmReview(userID, queued)
+			return err
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"confirmed": confirmed})
+}
+
+// ReassignReview - задает транзакциям новую категорию. Как и PATCH транзакции, смена
+// категории сохраняется как исправление и запоминается для продавца.
+func (h *TransactionHandler) ReassignReview(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req ReassignReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	category := strings.TrimSpace(req.Category)
+	if category == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category is required"})
+		return
+	}
+
+	txs, err := h.repo.GetTransactionsByIDs(userID, req.TransactionIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transactions"})
+		return
+	}
+
+	response := ReassignReviewResponse{Skipped: []uint{}}
+	found := make(map[uint]bool, len(txs))
+	for i := range txs {
+		tx := &txs[i]
+		found[tx.ID] = true
+		if tx.TransferID != nil || len(tx.Splits) > 0 {
+			response.Skipped = append(response.Skipped, tx.ID)
+			continue
+		}
+
+		previousCategory := tx.Category
+		before := service.AuditSnapshot(tx)
+		tx.Category = category
+		tx.SetCategorySource(models.CategorySourceManual, 1)
+		tx.NeedsReview = false
+		if req.IsEssential != nil {
+			tx.IsEssential = *req.IsEssential
+		}
+
+		err := h.repo.Transaction(func(repo *repository.Repository) error {
+			if err := h.applyCategoryDefaults(repo, tx, req.IsEssential, nil); err != nil {
+				return err
+			}
+			if err := repo.UpdateTransactionCategory(tx); err != nil {
+				return err
+			}
+			return service.RecordAudit(repo.DB(), service.UserActor(userID, models.AuditSourceAPI), models.AuditEntityTransaction, tx.ID,
+				models.AuditActionUpdate, before, service.AuditSnapshot(tx))
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transactions"})
+			return
+		}
+		if tx.Category != previousCategory {
+			h.recordCorrection(tx, previousCategory)
+		}
+		response.Updated++
+	}
+	for _, id := range req.TransactionIDs {
+		if !found[id] {
+			response.Skipped = append(response.Skipped, id)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
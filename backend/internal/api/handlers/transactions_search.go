@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchTransactionsResponse - страница результатов поиска
+type SearchTransactionsResponse struct {
+	Currency   string                 `json:"currency"`
+	Results    []repository.SearchHit `json:"results"`
+	NextCursor string                 `json:"next_cursor,omitempty"` // Пусто - последняя страница
+}
+
+// Search - полнотекстовый поиск транзакций со структурными фильтрами и страницами по курсору
+func (h *TransactionHandler) Search(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	search := repository.TransactionSearch{
+		Query:    c.Query("q"),
+		Type:     c.Query("type"),
+		Category: c.Query("category"),
+		Tags:     c.QueryArray("tag"),
+		Sort:     c.Query("sort"),
+		Cursor:   c.Query("cursor"),
+		Limit:    50,
+	}
+	if search.Type != "" && search.Type != "income" && search.Type != "expense" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be income or expense"})
+		return
+	}
+	if l := c.Query("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		search.Limit = min(limit, 200)
+	}
+	if essential := c.Query("is_essential"); essential != "" {
+		value, err := strconv.ParseBool(essential)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "is_essential must be true or false"})
+			return
+		}
+		search.IsEssential = &value
+	}
+	if account := c.Query("account_id"); account != "" {
+		id, err := strconv.ParseUint(account, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+			return
+		}
+		search.AccountID = uint(id)
+	}
+	for param, target := range map[string]**models.Money{"min_amount": &search.MinAmount, "max_amount": &search.MaxAmount} {
+		if value := c.Query(param); value != "" {
+			amount, err := models.ParseMoney(value)
+			if err != nil || amount < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return
+			}
+			*target = &amount
+		}
+	}
+
+	// Период необязателен: без month и дат поиск идет по всей истории
+	if month := c.Query("month"); month != "" {
+		if _, err := time.Parse("2006-01", month); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month. Use YYYY-MM"})
+			return
+		}
+		search.StartDate, search.EndDate = service.MonthRange(month)
+	} else {
+		for param, target := range map[string]*string{"start_date": &search.StartDate, "end_date": &search.EndDate} {
+			if value := c.Query(param); value != "" {
+				if _, err := time.Parse("2006-01-02", value); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ". Use YYYY-MM-DD"})
+					return
+				}
+				*target = value
+			}
+		}
+	}
+
+	hits, nextCursor, err := h.repo.SearchTransactions(userID, search)
+	switch {
+	case errors.Is(err, repository.ErrInvalidSort):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be relevance, date_desc, date_asc, amount_desc or amount_asc"})
+		return
+	case errors.Is(err, repository.ErrSortNeedsText):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort=relevance requires q"})
+		return
+	case errors.Is(err, repository.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SearchTransactionsResponse{
+		Currency:   service.BaseCurrency(h.repo.DB(), userID),
+		Results:    hits,
+		NextCursor: nextCursor,
+	})
+}
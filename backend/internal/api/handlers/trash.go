@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"clarity/internal/api/middleware"
+	"clarity/internal/models"
+	"clarity/internal/repository"
+	"clarity/internal/service"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TrashHandler struct {
+	repo        *repository.Repository
+	attachments *service.AttachmentService
+	retention   time.Duration // 0 - плановой очистки нет
+}
+
+func NewTrashHandler(repo *repository.Repository, attachments *service.AttachmentService, retention time.Duration) *TrashHandler {
+	return &TrashHandler{repo: repo, attachments: attachments, retention: retention}
+}
+
+// TrashEntry - запись корзины с датой плановой очистки
+type TrashEntry struct {
+	repository.TrashItem
+	PurgeAt *time.Time `json:"purge_at,omitempty"`
+}
+
+// TrashResponse - содержимое корзины
+type TrashResponse struct {
+	RetentionDays int          `json:"retention_days"` // 0 - записи хранятся до ручной очистки
+	Items         []TrashEntry `json:"items"`
+}
+
+// List - корзина пользователя; ?type= - только транзакции, переводы, инвестиции или вклады
+func (h *TrashHandler) List(c *gin.Context) {
+	items, err := h.repo.GetTrash(middleware.GetUserID(c), c.Query("type"))
+	if errors.Is(err, repository.ErrUnknownTrashType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be transaction, transfer, investment or deposit"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get trash"})
+		return
+	}
+
+	response := TrashResponse{RetentionDays: int(h.retention / (24 * time.Hour)), Items: make([]TrashEntry, len(items))}
+	for i, item := range items {
+		response.Items[i].TrashItem = item
+		if h.retention > 0 {
+			purgeAt := item.DeletedAt.Add(h.retention)
+			response.Items[i].PurgeAt = &purgeAt
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Restore - возвращает запись из корзины
+func (h *TrashHandler) Restore(c *gin.Context) {
+	id, ok := trashItemID(c)
+	if !ok {
+		return
+	}
+	userID := middleware.GetUserID(c)
+	itemType := c.Param("type")
+	err := h.repo.Transaction(func(repo *repository.Repository) error {
+		if err := repo.RestoreTrashItem(userID, itemType, id); err != nil {
+			return err
+		}
+		return recordRestore(repo, userID, itemType, id)
+	})
+	if !h.handleItemError(c, err, "Failed to restore item") {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Item restored"})
+}
+
+// recordRestore - запись в журнал о возврате из корзины; перевод записывается по ногам
+func recordRestore(repo *repository.Repository, userID uint, itemType string, id uint) error {
+	actor := service.UserActor(userID, models.AuditSourceAPI)
+	switch itemType {
+	case repository.TrashTransaction:
+		return service.RecordAudit(repo.DB(), actor, models.AuditEntityTransaction, id, models.AuditActionRestore, nil, nil)
+	case repository.TrashTransfer:
+		legs, err := repo.GetTransferLegs(id, userID)
+		if err != nil {
+			return err
+		}
+		for _, leg := range legs {
+			if err := service.RecordAudit(repo.DB(), actor, models.AuditEntityTransaction, leg.ID, models.AuditActionRestore, nil, nil); err != nil {
+				return err
+			}
+		}
+	case repository.TrashInvestment:
+		return service.RecordAudit(repo.DB(), actor, models.AuditEntityInvestment, id, models.AuditActionRestore, nil, nil)
+	case repository.TrashDeposit:
+		return service.RecordAudit(repo.DB(), actor, models.AuditEntityDeposit, id, models.AuditActionRestore, nil, nil)
+	}
+	return nil
+}
+
+// recordPurge - записи в журнал об окончательном удалении из корзины; перевод записывается по ногам
+func recordPurge(repo *repository.Repository, userID uint, records []repository.PurgedRecord) error {
+	actor := service.UserActor(userID, models.AuditSourceAPI)
+	for _, record := range records {
+		if err := service.RecordAudit(repo.DB(), actor, record.EntityType, record.ID, models.AuditActionPurge, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Purge - окончательно удаляет запись из корзины вместе с ее вложениями
+func (h *TrashHandler) Purge(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	id, ok := trashItemID(c)
+	if !ok {
+		return
+	}
+	err := h.repo.Transaction(func(repo *repository.Repository) error {
+		purged, err := repo.PurgeTrashItem(userID, c.Param("type"), id)
+		if err != nil {
+			return err
+		}
+		return recordPurge(repo, userID, purged)
+	})
+	if !h.handleItemError(c, err, "Failed to purge item") {
+		return
+	}
+	if _, err := h.attachments.PurgeOrphans(c.Request.Context(), userID); err != nil {
+		log.Printf("Failed to purge attachments of user %d: %v", userID, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Item purged"})
+}
+
+// Empty - окончательно удаляет всю корзину пользователя
+func (h *TrashHandler) Empty(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	var purged int
+	err := h.repo.Transaction(func(repo *repository.Repository) error {
+		count, records, err := repo.EmptyTrash(userID)
+		if err != nil {
+			return err
+		}
+		purged = count
+		return recordPurge(repo, userID, records)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to empty trash"})
+		return
+	}
+	if _, err := h.attachments.PurgeOrphans(c.Request.Context(), userID); err != nil {
+		log.Printf("Failed to purge attachments of user %d: %v", userID, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+func trashItemID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// handleItemError - ответ на ошибку операции с записью корзины; true, если ошибки нет
+func (h *TrashHandler) handleItemError(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, repository.ErrUnknownTrashType):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be transaction, transfer, investment or deposit"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found in trash"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return false
+}
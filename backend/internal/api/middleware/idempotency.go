@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"bytes"
+	"clarity/internal/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxIdempotencyKeyLength - предельная длина заголовка Idempotency-Key
+const maxIdempotencyKeyLength = 255
+
+// idempotencyHeartbeat - как часто отмечается ключ выполняемого запроса
+var idempotencyHeartbeat = models.IdempotencyLockTimeout / 3
+
+// IdempotencyStore - хранилище ключей идемпотентности (repository.Repository)
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(key *models.IdempotencyKey) (existing *models.IdempotencyKey, reserved bool, err error)
+	TouchIdempotencyKey(id uint) error
+	CompleteIdempotencyKey(key *models.IdempotencyKey) error
+	ReleaseIdempotencyKey(id uint) error
+}
+
+// Idempotency - повтор запроса с тем же заголовком Idempotency-Key не выполняется снова, а
+// получает сохраненный ответ первого (с заголовком Idempotency-Replayed: true). Тот же ключ
+// с другим запросом или пока первый еще выполняется - 409. Ответы 5xx не сохраняются:
+// повтор после ошибки сервера выполняется заново. Без заголовка запрос выполняется как
+// обычно. Ставится после AuthMiddleware: ключи у каждого пользователя свои. Тело запроса
+// с ключом читается в память целиком, поэтому оно ограничено maxBody байт (больше - 413).
+func Idempotency(repo IdempotencyStore, ttl time.Duration, maxBody int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Idempotency-Key")
+		if header == "" {
+			c.Next()
+			return
+		}
+		if len(header) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Request body with Idempotency-Key must be at most %d MB", maxBody>>20)})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		key := &models.IdempotencyKey{
+			UserID:      GetUserID(c),
+			Key:         header,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			RequestHash: requestHash(c.Request, body),
+			Status:      models.IdempotencyProcessing,
+			ExpiresAt:   time.Now().Add(ttl),
+		}
+		existing, reserved, err := repo.ReserveIdempotencyKey(key)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Idempotency-Key"})
+			return
+		}
+		if !reserved {
+			switch {
+			case existing.RequestHash != key.RequestHash:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "Idempotency-Key was already used with a different request"})
+			case existing.Status != models.IdempotencyCompleted:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+			default:
+				c.Header("Idempotency-Replayed", "true")
+				c.Data(existing.ResponseCode, existing.ContentType, existing.ResponseBody)
+				c.Abort()
+			}
+			return
+		}
+
+		// Если обработчик упал, ключ освобождается, чтобы повтор выполнился заново
+		completed := false
+		defer func() {
+			if !completed {
+				if err := repo.ReleaseIdempotencyKey(key.ID); err != nil {
+					log.Printf("Failed to release Idempotency-Key %d: %v", key.ID, err)
+				}
+			}
+		}()
+
+		// Пока запрос выполняется, ключ отмечается: без отметок его займет следующий повтор
+		done := make(chan struct{})
+		defer close(done)
+		ticker := time.NewTicker(idempotencyHeartbeat)
+		go func() {
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					if err := repo.TouchIdempotencyKey(key.ID); err != nil {
+						log.Printf("Failed to touch Idempotency-Key %d: %v", key.ID, err)
+					}
+				}
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		key.ResponseCode = recorder.Status()
+		key.ContentType = recorder.Header().Get("Content-Type")
+		key.ResponseBody = recorder.body.Bytes()
+		if err := repo.CompleteIdempotencyKey(key); err != nil {
+			log.Printf("Failed to save response for Idempotency-Key %d: %v", key.ID, err)
+			return
+		}
+		completed = true
+	}
+}
+
+// requestHash - SHA-256 метода, пути, query и тела запроса. У multipart/form-data хэшируются
+// поля и файлы, а не тело целиком: при повторе клиент может выбрать другой boundary.
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+"\n")
+
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && mediaType == "multipart/form-data" && params["boundary"] != "" {
+		parts := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := parts.NextPart()
+			if err != nil {
+				if err != io.EOF {
+					hash.Write(body) // Поврежденное тело - как есть
+				}
+				break
+			}
+			io.WriteString(hash, part.FormName()+"\x00"+part.FileName()+"\x00")
+			io.Copy(hash, part)
+			hash.Write([]byte{0})
+		}
+	} else {
+		hash.Write(body)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder - копирует тело ответа для сохранения под ключом идемпотентности
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
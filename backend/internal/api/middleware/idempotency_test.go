@@ -0,0 +1,326 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"clarity/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryIdempotencyStore - хранилище ключей в памяти с теми же правилами занятия ключа,
+// что у repository.ReserveIdempotencyKey, и управляемыми часами
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	now     time.Time
+	nextID  uint
+	keys    map[string]*models.IdempotencyKey
+	touches int
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{now: time.Now(), keys: make(map[string]*models.IdempotencyKey)}
+}
+
+func storeKey(userID uint, key string) string {
+	return fmt.Sprintf("%d/%s", userID, key)
+}
+
+func (s *memoryIdempotencyStore) advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+}
+
+func (s *memoryIdempotencyStore) get(userID uint, key string) *models.IdempotencyKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.keys[storeKey(userID, key)]; ok {
+		copied := *record
+		return &copied
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) put(record *models.IdempotencyKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	record.ID = s.nextID
+	s.keys[storeKey(record.UserID, record.Key)] = record
+}
+
+func (s *memoryIdempotencyStore) ReserveIdempotencyKey(key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := storeKey(key.UserID, key.Key)
+	if existing, ok := s.keys[id]; ok {
+		expired := !existing.ExpiresAt.After(s.now)
+		abandoned := existing.Status == models.IdempotencyProcessing && existing.UpdatedAt.Before(s.now.Add(-models.IdempotencyLockTimeout))
+		if !expired && !abandoned {
+			copied := *existing
+			return &copied, false, nil
+		}
+		delete(s.keys, id)
+	}
+	s.nextID++
+	key.ID = s.nextID
+	key.CreatedAt, key.UpdatedAt = s.now, s.now
+	copied := *key
+	s.keys[id] = &copied
+	return nil, true, nil
+}
+
+func (s *memoryIdempotencyStore) TouchIdempotencyKey(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range s.keys {
+		if record.ID == id && record.Status == models.IdempotencyProcessing {
+			record.UpdatedAt = s.now
+			s.touches++
+		}
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) CompleteIdempotencyKey(key *models.IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key.Status = models.IdempotencyCompleted
+	for _, record := range s.keys {
+		if record.ID == key.ID {
+			record.Status = key.Status
+			record.ResponseCode = key.ResponseCode
+			record.ContentType = key.ContentType
+			record.ResponseBody = append([]byte(nil), key.ResponseBody...)
+			record.UpdatedAt = s.now
+		}
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) ReleaseIdempotencyKey(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, record := range s.keys {
+		if record.ID == id {
+			delete(s.keys, name)
+		}
+	}
+	return nil
+}
+
+// idempotencyRouter - POST /items за Idempotency от имени пользователя 1
+func idempotencyRouter(store IdempotencyStore, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", uint(1)) })
+	r.POST("/items", Idempotency(store, 24*time.Hour, 1<<20), handler)
+	return r
+}
+
+func postItem(r http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	calls := 0
+	r := idempotencyRouter(store, func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"id": calls})
+	})
+
+	first := postItem(r, "key-1", `{"amount": 500}`)
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotency-Replayed") != "" {
+		t.Fatalf("first response = %d %v", first.Code, first.Header())
+	}
+	replay := postItem(r, "key-1", `{"amount": 500}`)
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", replay.Code, replay.Body, first.Code, first.Body)
+	}
+	if replay.Header().Get("Idempotency-Replayed") != "true" {
+		t.Errorf("replay has no Idempotency-Replayed header")
+	}
+	if got := replay.Header().Get("Content-Type"); got != first.Header().Get("Content-Type") {
+		t.Errorf("replay Content-Type = %q", got)
+	}
+
+	// Другой ключ и запрос без ключа выполняются
+	postItem(r, "key-2", `{"amount": 500}`)
+	postItem(r, "", `{"amount": 500}`)
+	if calls != 3 {
+		t.Errorf("handler called %d times, want 3", calls)
+	}
+}
+
+func TestIdempotencyConflictOnDifferentRequest(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	calls := 0
+	r := idempotencyRouter(store, func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"id": calls})
+	})
+
+	postItem(r, "key-1", `{"amount": 500}`)
+	conflict := postItem(r, "key-1", `{"amount": 700}`)
+	if conflict.Code != http.StatusConflict {
+		t.Errorf("same key with other body = %d, want 409", conflict.Code)
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+	// Сохраненный ответ не затерт конфликтом
+	if replay := postItem(r, "key-1", `{"amount": 500}`); replay.Code != http.StatusCreated {
+		t.Errorf("replay after conflict = %d", replay.Code)
+	}
+}
+
+func TestIdempotencyServerErrorNotStored(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	calls := 0
+	r := idempotencyRouter(store, func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create item"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"id": calls})
+	})
+
+	if w := postItem(r, "key-1", `{}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("first response = %d", w.Code)
+	}
+	if record := store.get(1, "key-1"); record != nil {
+		t.Fatalf("key kept after 5xx: %+v", record)
+	}
+	retry := postItem(r, "key-1", `{}`)
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotency-Replayed") != "" || calls != 2 {
+		t.Errorf("retry after 5xx = %d (replayed %q), calls %d", retry.Code, retry.Header().Get("Idempotency-Replayed"), calls)
+	}
+
+	// 4xx сохраняется как обычный ответ
+	calls = 10
+	r = idempotencyRouter(store, func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount is required"})
+	})
+	postItem(r, "key-4xx", `{}`)
+	if replay := postItem(r, "key-4xx", `{}`); replay.Code != http.StatusBadRequest || replay.Header().Get("Idempotency-Replayed") != "true" || calls != 11 {
+		t.Errorf("4xx replay = %d, calls %d", replay.Code, calls)
+	}
+}
+
+func TestIdempotencyHeartbeatKeepsKeyInProgress(t *testing.T) {
+	defer func(interval time.Duration) { idempotencyHeartbeat = interval }(idempotencyHeartbeat)
+	idempotencyHeartbeat = 5 * time.Millisecond
+
+	store := newMemoryIdempotencyStore()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	calls := 0
+	r := idempotencyRouter(store, func(c *gin.Context) {
+		calls++
+		close(started)
+		<-release
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postItem(r, "key-1", `{}`) }()
+	<-started
+
+	// Запрос выполняется дольше IdempotencyLockTimeout, но отмечает ключ
+	store.advance(2 * models.IdempotencyLockTimeout)
+	store.mu.Lock()
+	touched := store.touches
+	store.mu.Unlock()
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mu.Lock()
+		fresh := store.touches > touched
+		store.mu.Unlock()
+		if fresh {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("key was not touched while the request was running")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if retry := postItem(r, "key-1", `{}`); retry.Code != http.StatusConflict {
+		t.Errorf("retry while in progress = %d, want 409", retry.Code)
+	}
+	close(release)
+	if first := <-done; first.Code != http.StatusCreated {
+		t.Errorf("first response = %d", first.Code)
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyReclaimsAbandonedKey(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	calls := 0
+	r := idempotencyRouter(store, func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"id": calls})
+	})
+
+	// Ключ занял запрос, чей сервер упал: отметок нет дольше IdempotencyLockTimeout
+	hash := requestHash(httptest.NewRequest(http.MethodPost, "/items", nil), []byte(`{}`))
+	store.put(&models.IdempotencyKey{
+		UserID: 1, Key: "abandoned", Method: http.MethodPost, Path: "/items", RequestHash: hash,
+		Status: models.IdempotencyProcessing, UpdatedAt: store.now.Add(-2 * models.IdempotencyLockTimeout),
+		ExpiresAt: store.now.Add(time.Hour),
+	})
+	// Ключ, который еще отмечается
+	store.put(&models.IdempotencyKey{
+		UserID: 1, Key: "alive", Method: http.MethodPost, Path: "/items", RequestHash: hash,
+		Status: models.IdempotencyProcessing, UpdatedAt: store.now.Add(-models.IdempotencyLockTimeout / 2),
+		ExpiresAt: store.now.Add(time.Hour),
+	})
+
+	if w := postItem(r, "alive", `{}`); w.Code != http.StatusConflict {
+		t.Errorf("retry of a live request = %d, want 409", w.Code)
+	}
+	reclaimed := postItem(r, "abandoned", `{}`)
+	if reclaimed.Code != http.StatusCreated || calls != 1 {
+		t.Fatalf("retry of an abandoned request = %d, calls %d", reclaimed.Code, calls)
+	}
+	if record := store.get(1, "abandoned"); record == nil || record.Status != models.IdempotencyCompleted {
+		t.Errorf("reclaimed key = %+v, want completed", record)
+	}
+	if replay := postItem(r, "abandoned", `{}`); replay.Header().Get("Idempotency-Replayed") != "true" || calls != 1 {
+		t.Errorf("replay after reclaim: replayed %q, calls %d", replay.Header().Get("Idempotency-Replayed"), calls)
+	}
+}
+
+func TestIdempotencyBodyLimit(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	r := idempotencyRouter(store, func(c *gin.Context) { c.Status(http.StatusCreated) })
+	if w := postItem(r, "big", strings.Repeat("x", 1<<20+1)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body = %d, want 413", w.Code)
+	}
+	if w := postItem(r, strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("long key = %d, want 400", w.Code)
+	}
+}
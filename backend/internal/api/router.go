@@ -3,13 +3,14 @@ package api
 import (
 	"clarity/internal/api/handlers"
 	"clarity/internal/api/middleware"
+	"clarity/internal/config"
 	"clarity/internal/repository"
 	"clarity/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(repo *repository.Repository, jwtSecret, mlServiceURL string, yandexGPT *service.YandexGPTClient) *gin.Engine {
+func NewRouter(repo *repository.Repository, cfg *config.Config, yandexGPT *service.YandexGPTClient, categorizer service.Categorizer, recategorizer *service.RecategorizationService, attachments *service.AttachmentService, fxService *service.FXService) *gin.Engine {
 	r := gin.Default()
 
 	// Health check
@@ -17,8 +18,20 @@ func NewRouter(repo *repository.Repository, jwtSecret, mlServiceURL string, yand
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Состояние предохранителей внешних зависимостей (ML сервис, YandexGPT, курсы валют)
+	r.GET("/health/dependencies", func(c *gin.Context) {
+		dependencies := service.DependencyStatuses()
+		status := "ok"
+		for _, dependency := range dependencies {
+			if dependency.State != service.BreakerClosed {
+				status = "degraded"
+			}
+		}
+		c.JSON(200, gin.H{"status": status, "dependencies": dependencies})
+	})
+
 	// Auth endpoints
-	authHandler := handlers.NewAuthHandler(repo, jwtSecret)
+	authHandler := handlers.NewAuthHandler(repo, cfg.JWTSecret)
 	api := r.Group("/api")
 	{
 		api.POST("/register", authHandler.Register)
@@ -26,41 +39,154 @@ func NewRouter(repo *repository.Repository, jwtSecret, mlServiceURL string, yand
 	}
 
 	// Protected routes
-	mlClient := service.NewMLClient(mlServiceURL)
-	forecastClient := service.NewForecastClient(mlServiceURL)
-	anomalyDetector := service.NewAnomalyDetector(repo.DB())
-	txHandler := handlers.NewTransactionHandler(repo, mlClient, anomalyDetector)
-	analyticsHandler := handlers.NewAnalyticsHandler(repo)
-	healthScoreHandler := handlers.NewHealthScoreHandler(repo)
-	investmentHandler := handlers.NewInvestmentHandler(repo)
-	depositHandler := handlers.NewDepositHandler(repo)
-	chatHandler := handlers.NewChatHandler(repo, yandexGPT)
+	forecastClient := service.NewForecastClient(cfg.MLServiceURL)
+	anomalyDetector := service.NewAnomalyDetector(repo.DB(), fxService)
+	ruleEngine := service.NewRuleEngine(repo.DB())
+	txHandler := handlers.NewTransactionHandler(repo, categorizer, anomalyDetector, fxService, ruleEngine, service.NewBulkService(repo.DB(), fxService))
+	analyticsHandler := handlers.NewAnalyticsHandler(repo, fxService)
+	healthScoreHandler := handlers.NewHealthScoreHandler(repo, fxService)
+	investmentHandler := handlers.NewInvestmentHandler(repo, fxService)
+	depositHandler := handlers.NewDepositHandler(repo, fxService)
+	chatHandler := handlers.NewChatHandler(repo, yandexGPT, fxService)
 	forecastHandler := handlers.NewForecastHandler(repo, forecastClient)
 	notificationHandler := handlers.NewNotificationHandler(repo)
+	accountHandler := handlers.NewAccountHandler(repo, fxService)
+	fxRateHandler := handlers.NewFXRateHandler(repo, fxService, service.NewFXRatesClient(cfg.FXRatesURL))
+	profileHandler := handlers.NewProfileHandler(repo, fxService)
+	recurringHandler := handlers.NewRecurringHandler(repo, service.NewRecurringService(repo.DB(), fxService))
+	subscriptionHandler := handlers.NewSubscriptionHandler(repo, service.NewSubscriptionDetector(repo.DB()))
+	categoryHandler := handlers.NewCategoryHandler(repo)
+	ruleHandler := handlers.NewRuleHandler(repo, ruleEngine)
+	tagHandler := handlers.NewTagHandler(repo)
+	correctionHandler := handlers.NewCorrectionHandler(repo)
+	merchantHandler := handlers.NewMerchantHandler(repo)
+	recategorizationHandler := handlers.NewRecategorizationHandler(repo, recategorizer)
+	attachmentHandler := handlers.NewAttachmentHandler(repo, attachments)
+	trashHandler := handlers.NewTrashHandler(repo, attachments, cfg.TrashRetention)
+	auditHandler := handlers.NewAuditHandler(repo)
+	// Создание записей можно безопасно повторять с заголовком Idempotency-Key
+	idempotent := middleware.Idempotency(repo, cfg.IdempotencyTTL, cfg.IdempotencyMaxBody)
+	// Загрузка вложения с ключом: тело вмещает файл предельного размера
+	idempotentUpload := middleware.Idempotency(repo, cfg.IdempotencyTTL, max(cfg.IdempotencyMaxBody, attachments.MaxSize()+handlers.MultipartOverhead))
 	protected := api.Group("")
-	protected.Use(middleware.AuthMiddleware(jwtSecret))
+	protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
 	{
-		protected.POST("/transactions", txHandler.Create)
+		protected.GET("/profile", profileHandler.Get)
+		protected.PATCH("/profile", profileHandler.Update)
+
+		protected.POST("/transactions", idempotent, txHandler.Create)
 		protected.GET("/transactions", txHandler.List)
 		protected.PATCH("/transactions/:id", txHandler.Update)
 		protected.DELETE("/transactions/:id", txHandler.Delete)
+		protected.GET("/transactions/:id/explain", txHandler.Explain)
+		protected.GET("/transactions/:id/history", auditHandler.TransactionHistory)
+		protected.POST("/transactions/:id/attachments", idempotentUpload, attachmentHandler.UploadToTransaction)
+		protected.GET("/transactions/:id/attachments", attachmentHandler.ListForTransaction)
+		protected.GET("/transactions/search", txHandler.Search)
 		protected.GET("/transactions/export", txHandler.ExportTransactions)
 		protected.GET("/transactions/report", txHandler.ExportReport)
-		protected.POST("/transactions/import", txHandler.ImportTransactions)
+		protected.POST("/transactions/import", idempotent, txHandler.ImportTransactions)
+		protected.GET("/transactions/review", txHandler.ReviewQueue)
+		protected.POST("/transactions/review/confirm", txHandler.ConfirmReview)
+		protected.POST("/transactions/review/reassign", txHandler.ReassignReview)
+		protected.POST("/transactions/bulk", idempotent, txHandler.Bulk)
+
+		protected.POST("/recurring", idempotent, recurringHandler.Create)
+		protected.GET("/recurring", recurringHandler.List)
+		protected.GET("/recurring/upcoming", recurringHandler.Upcoming)
+		protected.GET("/recurring/:id", recurringHandler.Get)
+		protected.PATCH("/recurring/:id", recurringHandler.Update)
+		protected.DELETE("/recurring/:id", recurringHandler.Delete)
+
+		protected.GET("/subscriptions", subscriptionHandler.List)
+
+		protected.POST("/categories", categoryHandler.Create)
+		protected.GET("/categories", categoryHandler.List)
+		protected.PATCH("/categories/:id", categoryHandler.Update)
+		protected.DELETE("/categories/:id", categoryHandler.Delete)
+		protected.POST("/categories/:id/rename", categoryHandler.Rename)
+		protected.POST("/categories/:id/merge", categoryHandler.Merge)
+
+		protected.POST("/rules", ruleHandler.Create)
+		protected.GET("/rules", ruleHandler.List)
+		protected.POST("/rules/preview", ruleHandler.Preview)
+		protected.POST("/rules/apply", ruleHandler.Apply)
+		protected.PATCH("/rules/:id", ruleHandler.Update)
+		protected.DELETE("/rules/:id", ruleHandler.Delete)
+		protected.GET("/rules/:id/preview", ruleHandler.PreviewSaved)
+
+		protected.GET("/tags", tagHandler.List)
+		protected.POST("/tags", tagHandler.Create)
+		protected.PATCH("/tags/:id", tagHandler.Update)
+		protected.DELETE("/tags/:id", tagHandler.Delete)
+
+		protected.GET("/corrections", correctionHandler.List)
+		protected.GET("/corrections/stats", correctionHandler.Stats)
+		protected.GET("/corrections/training-data", correctionHandler.ExportTrainingData)
+		protected.GET("/merchant-categories", correctionHandler.ListMerchants)
+		protected.DELETE("/merchant-categories/:id", correctionHandler.DeleteMerchant)
 
-		protected.POST("/investments", investmentHandler.Create)
+		protected.GET("/merchants", merchantHandler.List)
+		protected.POST("/merchants", merchantHandler.Create)
+		protected.PATCH("/merchants/:id", merchantHandler.Update)
+		protected.DELETE("/merchants/:id", merchantHandler.Delete)
+
+		protected.POST("/recategorization-jobs", idempotent, recategorizationHandler.Create)
+		protected.GET("/recategorization-jobs", recategorizationHandler.List)
+		protected.GET("/recategorization-jobs/:id", recategorizationHandler.Get)
+		protected.GET("/recategorization-jobs/:id/changes", recategorizationHandler.Changes)
+		protected.POST("/recategorization-jobs/:id/cancel", recategorizationHandler.Cancel)
+		protected.POST("/recategorization-jobs/:id/resume", recategorizationHandler.Resume)
+
+		protected.POST("/accounts", idempotent, accountHandler.Create)
+		protected.GET("/accounts", accountHandler.List)
+		protected.PATCH("/accounts/:id", accountHandler.Update)
+		protected.DELETE("/accounts/:id", accountHandler.Delete)
+
+		protected.POST("/transfers", idempotent, accountHandler.CreateTransfer)
+		protected.DELETE("/transfers/:id", accountHandler.DeleteTransfer)
+
+		protected.GET("/fx-rates", fxRateHandler.List)
+		protected.POST("/fx-rates/import", idempotent, fxRateHandler.Import)
+		protected.POST("/fx-rates/sync", fxRateHandler.Sync)
+
+		protected.POST("/investments", idempotent, investmentHandler.Create)
 		protected.GET("/investments", investmentHandler.List)
 		protected.PATCH("/investments/:id", investmentHandler.Update)
 		protected.DELETE("/investments/:id", investmentHandler.Delete)
+		protected.POST("/investments/:id/attachments", idempotentUpload, attachmentHandler.UploadToInvestment)
+		protected.GET("/investments/:id/attachments", attachmentHandler.ListForInvestment)
+		protected.GET("/investments/:id/history", auditHandler.InvestmentHistory)
 
-		protected.POST("/deposits", depositHandler.Create)
+		protected.POST("/deposits", idempotent, depositHandler.Create)
 		protected.GET("/deposits", depositHandler.List)
 		protected.PATCH("/deposits/:id", depositHandler.Update)
 		protected.DELETE("/deposits/:id", depositHandler.Delete)
+		protected.POST("/deposits/:id/attachments", idempotentUpload, attachmentHandler.UploadToDeposit)
+		protected.GET("/deposits/:id/attachments", attachmentHandler.ListForDeposit)
+		protected.GET("/deposits/:id/history", auditHandler.DepositHistory)
+
+		protected.GET("/attachments/usage", attachmentHandler.Usage)
+		protected.GET("/attachments/:id", attachmentHandler.Get)
+		protected.GET("/attachments/:id/download", attachmentHandler.Download)
+		protected.GET("/attachments/:id/thumbnail", attachmentHandler.Thumbnail)
+		protected.DELETE("/attachments/:id", attachmentHandler.Delete)
+
+		protected.GET("/trash", trashHandler.List)
+		protected.DELETE("/trash", trashHandler.Empty)
+		protected.POST("/trash/:type/:id/restore", trashHandler.Restore)
+		protected.DELETE("/trash/:type/:id", trashHandler.Purge)
+
+		protected.GET("/activity", auditHandler.Activity)
 
 		protected.GET("/analytics/summary", analyticsHandler.Summary)
 		protected.GET("/analytics/trends", analyticsHandler.Trends)
 		protected.GET("/analytics/category-distribution", analyticsHandler.CategoryDistribution)
+		protected.GET("/analytics/tag-distribution", analyticsHandler.TagDistribution)
+		protected.GET("/analytics/category-tree", analyticsHandler.CategoryTree)
+		protected.GET("/analytics/merchants", analyticsHandler.Merchants)
+		protected.GET("/analytics/accounts", analyticsHandler.AccountBalances)
+		protected.GET("/analytics/accounts/:id/balance-history", analyticsHandler.AccountBalanceHistory)
 
 		protected.GET("/health-score", healthScoreHandler.GetHealthScore)
 		protected.GET("/health-score/income-details", healthScoreHandler.GetIncomeDetails)
@@ -71,7 +197,7 @@ func NewRouter(repo *repository.Repository, jwtSecret, mlServiceURL string, yand
 		protected.GET("/health-score/deposit-details", healthScoreHandler.GetDepositDetails)
 
 		// AI Chat
-		protected.POST("/chat", chatHandler.SendMessage)
+		protected.POST("/chat", idempotent, chatHandler.SendMessage)
 		protected.GET("/chat/history", chatHandler.GetHistory)
 
 		// Financial Analysis & Forecasting
@@ -1,26 +1,60 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"time"
+)
 
 type Config struct {
-	Port              string
-	DatabaseURL       string
-	MLServiceURL      string
-	JWTSecret         string
-	YandexGPTAPIKey   string
-	YandexGPTFolderID string
-	YandexGPTModelURI string
+	Port               string
+	DatabaseURL        string
+	MLServiceURL       string
+	JWTSecret          string
+	YandexGPTAPIKey    string
+	YandexGPTFolderID  string
+	YandexGPTModelURI  string
+	FXRatesURL         string
+	RecurringInterval  time.Duration // Период запуска планировщика регулярных операций
+	CategorizerChain   string        // Цепочка категоризаторов, например "merchant,rules,ml+bayes"
+	BlobStore          string        // Хранилище вложений: local или s3
+	AttachmentsDir     string        // Каталог вложений для BLOB_STORE=local
+	S3Endpoint         string
+	S3Bucket           string
+	S3Region           string
+	S3AccessKey        string
+	S3SecretKey        string
+	AttachmentMaxSize  int64         // Предельный размер вложения, байт
+	AttachmentQuota    int64         // Объем вложений на пользователя, байт
+	TrashRetention     time.Duration // Срок хранения записей в корзине; 0 - без плановой очистки
+	IdempotencyTTL     time.Duration // Срок хранения ответов по ключам Idempotency-Key
+	IdempotencyMaxBody int64         // Предельный размер тела запроса с Idempotency-Key, байт
 }
 
 func Load() *Config {
 	return &Config{
-		Port:              getEnv("PORT", "8080"),
-		DatabaseURL:       getEnv("DATABASE_URL", "postgres://localhost/clarity?sslmode=disable"),
-		MLServiceURL:      getEnv("ML_SERVICE_URL", "http://localhost:5000"),
-		JWTSecret:         getEnv("JWT_SECRET", "clarity-secret-key-change-in-production"),
-		YandexGPTAPIKey:   getEnv("YANDEX_CLOUD_API_KEY", ""),
-		YandexGPTFolderID: getEnv("YANDEX_CLOUD_FOLDER_ID", ""),
-		YandexGPTModelURI: getEnv("YANDEX_GPT_MODEL_URI", ""),
+		Port:               getEnv("PORT", "8080"),
+		DatabaseURL:        getEnv("DATABASE_URL", "postgres://localhost/clarity?sslmode=disable"),
+		MLServiceURL:       getEnv("ML_SERVICE_URL", "http://localhost:5000"),
+		JWTSecret:          getEnv("JWT_SECRET", "clarity-secret-key-change-in-production"),
+		YandexGPTAPIKey:    getEnv("YANDEX_CLOUD_API_KEY", ""),
+		YandexGPTFolderID:  getEnv("YANDEX_CLOUD_FOLDER_ID", ""),
+		YandexGPTModelURI:  getEnv("YANDEX_GPT_MODEL_URI", ""),
+		FXRatesURL:         getEnv("FX_RATES_URL", ""),
+		RecurringInterval:  getDuration("RECURRING_INTERVAL", time.Hour),
+		CategorizerChain:   getEnv("CATEGORIZER_CHAIN", ""),
+		BlobStore:          getEnv("BLOB_STORE", "local"),
+		AttachmentsDir:     getEnv("ATTACHMENTS_DIR", "./data/attachments"),
+		S3Endpoint:         getEnv("S3_ENDPOINT", ""),
+		S3Bucket:           getEnv("S3_BUCKET", ""),
+		S3Region:           getEnv("S3_REGION", "us-east-1"),
+		S3AccessKey:        getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:        getEnv("S3_SECRET_KEY", ""),
+		AttachmentMaxSize:  getMegabytes("ATTACHMENT_MAX_SIZE_MB", 10),
+		AttachmentQuota:    getMegabytes("ATTACHMENT_QUOTA_MB", 200),
+		TrashRetention:     getDays("TRASH_RETENTION_DAYS", 30),
+		IdempotencyTTL:     getDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		IdempotencyMaxBody: getMegabytes("IDEMPOTENCY_MAX_BODY_MB", 10),
 	}
 }
 
@@ -30,3 +64,27 @@ func getEnv(key, def string) string {
 	}
 	return def
 }
+
+func getDuration(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+// getMegabytes - размер в мегабайтах из переменной окружения, в байтах
+func getMegabytes(key string, def int64) int64 {
+	if mb, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil && mb > 0 {
+		return mb << 20
+	}
+	return def << 20
+}
+
+// getDays - срок в днях из переменной окружения; 0 допустим
+func getDays(key string, def int) time.Duration {
+	days, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || days < 0 {
+		days = def
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
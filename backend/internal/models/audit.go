@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Записи, изменения которых попадают в журнал (AuditEntry.EntityType)
+const (
+	AuditEntityTransaction  = "transaction"
+	AuditEntityDeposit      = "deposit"
+	AuditEntityInvestment   = "investment"
+	AuditEntityNotification = "notification"
+	AuditEntityBulk         = "bulk" // Сводка массовой операции над транзакциями; EntityID = 0, Action - операция
+)
+
+// Действия с записью (AuditEntry.Action)
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"  // Перенос в корзину
+	AuditActionRestore = "restore" // Возврат из корзины
+	AuditActionPurge   = "purge"   // Окончательное удаление из корзины
+)
+
+// Источники изменений (AuditEntry.Source)
+const (
+	AuditSourceAPI              = "api"              // Запрос пользователя к REST API
+	AuditSourceCSVImport        = "csv_import"       // Импорт транзакций из CSV
+	AuditSourceRecurring        = "recurring"        // Планировщик регулярных операций
+	AuditSourceRecategorization = "recategorization" // Фоновая перекатегоризация с применением
+	AuditSourceChat             = "chat"             // Действие по команде в AI чате (сейчас чат записи не меняет)
+	AuditSourceSystem           = "system"           // Автоматические уведомления (аномалии, лимиты, подушка)
+	AuditSourceBulk             = "bulk"             // Массовая операция над транзакциями (POST /transactions/bulk)
+	AuditSourceRules            = "rules"            // Повторное применение правил к истории (POST /rules/apply)
+	AuditSourceRetention        = "retention"        // Плановая очистка корзины по сроку хранения
+)
+
+// AuditEntry - запись журнала изменений. Журнал только дополняется: записи не меняются и не
+// удаляются, в том числе при окончательном удалении самой записи из корзины.
+type AuditEntry struct {
+	ID         uint                   `gorm:"primaryKey;index:idx_audit_user_id,priority:2" json:"id"`
+	UserID     uint                   `gorm:"not null;index:idx_audit_user_id,priority:1" json:"user_id"` // Владелец записи
+	ActorID    *uint                  `json:"actor_id,omitempty"`                                         // Кто внес изменение; nil - фоновая задача или система
+	EntityType string                 `gorm:"size:16;not null;index:idx_audit_entity,priority:1" json:"entity_type"`
+	EntityID   uint                   `gorm:"not null;index:idx_audit_entity,priority:2" json:"entity_id"`
+	Action     string                 `gorm:"size:16;not null" json:"action"`
+	Source     string                 `gorm:"size:24;not null" json:"source"`
+	Changes    map[string]AuditChange `gorm:"type:jsonb;serializer:json" json:"changes,omitempty"` // Изменившиеся поля по именам из JSON записи
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// AuditChange - значение поля до и после изменения; при создании From пусто, при удалении - To
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
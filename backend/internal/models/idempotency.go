@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Состояние запроса с ключом идемпотентности
+const (
+	IdempotencyProcessing = "processing" // Запрос еще выполняется
+	IdempotencyCompleted  = "completed"  // Ответ сохранен и отдается повторно
+)
+
+// IdempotencyLockTimeout - запрос в состоянии processing, чья запись не обновлялась дольше
+// этого времени, считается брошенным (сервер упал посреди запроса): ключ занимается заново.
+// Пока запрос выполняется, запись обновляется каждые IdempotencyLockTimeout/3.
+const IdempotencyLockTimeout = time.Minute
+
+// IdempotencyKey - ключ идемпотентности (заголовок Idempotency-Key) запроса пользователя.
+// Хранит хэш запроса и ответ на него до ExpiresAt: повтор с тем же ключом получает
+// сохраненный ответ, а не выполняется снова.
+type IdempotencyKey struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"not null;uniqueIndex:idx_idempotency_key,priority:1"`
+	Key          string `gorm:"size:255;not null;uniqueIndex:idx_idempotency_key,priority:2"`
+	Method       string `gorm:"size:8;not null"`
+	Path         string `gorm:"not null"`         // Маршрут запроса
+	RequestHash  string `gorm:"size:64;not null"` // SHA-256 метода, пути и тела
+	Status       string `gorm:"size:16;not null"` // IdempotencyProcessing или IdempotencyCompleted
+	ResponseCode int    // HTTP статус сохраненного ответа
+	ContentType  string
+	ResponseBody []byte
+	CreatedAt    time.Time
+	UpdatedAt    time.Time // Пока запрос выполняется - время последней отметки о нем
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+)
+
+// merchantCities - города и страны, которые банк дописывает в конец названия продавца
+// ("YANDEX*TAXI 1234 MOSCOW RUS"). Срезаются только в конце строки.
+var merchantCities = [][]string{
+	{"moscow"}, {"moskva"}, {"msk"}, {"москва"}, {"мск"},
+	{"saint", "petersburg"}, {"st", "petersburg"}, {"sankt", "peterburg"}, {"spb"}, {"санкт", "петербург"}, {"спб"},
+	{"kazan"}, {"казань"}, {"novosibirsk"}, {"новосибирск"},
+	{"ekaterinburg"}, {"yekaterinburg"}, {"екатеринбург"},
+	{"nizhniy", "novgorod"}, {"nizhny", "novgorod"}, {"нижний", "новгород"},
+	{"krasnodar"}, {"краснодар"}, {"sochi"}, {"сочи"}, {"samara"}, {"самара"},
+	{"rostov", "na", "donu"}, {"ростов", "на", "дону"},
+	{"rus"}, {"ru"}, {"russia"}, {"rf"}, {"россия"}, {"рф"},
+}
+
+// merchantNoise - служебные слова платежной строки: терминал, POS, RRN, карта
+var merchantNoise = map[string]bool{
+	"tid": true, "mid": true, "term": true, "terminal": true, "pos": true, "rrn": true, "card": true,
+	"терм": true, "терминал": true, "карта": true,
+}
+
+// merchantLegalForms - организационно-правовая форма в начале названия (ООО, ИП)
+var merchantLegalForms = map[string]bool{
+	"ooo": true, "ооо": true, "ip": true, "ип": true, "llc": true,
+	"ao": true, "ао": true, "pao": true, "пао": true, "zao": true, "зао": true,
+}
+
+// NormalizeMerchant - ключ продавца по сырой банковской строке: нижний регистр, без знаков
+// препинания, номеров (терминалы, заказы, хвосты карт), масок карт (XXXX), служебных слов,
+// формы организации в начале и города/страны в конце.
+// "YANDEX*TAXI 1234 MOSCOW" -> "yandex taxi", "Пятёрочка #1234" -> "пятёрочка".
+func NormalizeMerchant(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var words []string
+	for _, field := range fields {
+		word := merchantWord(field)
+		if word == "" || merchantNoise[word] {
+			continue
+		}
+		if len(words) == 0 && merchantLegalForms[word] {
+			continue
+		}
+		words = append(words, word)
+	}
+
+	// Город и страна в конце, в том числе с сокращением "г." перед городом
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, city := range merchantCities {
+			if len(words) > len(city) && hasSuffix(words, city) {
+				words = words[:len(words)-len(city)]
+				if n := len(words); n > 1 && (words[n-1] == "g" || words[n-1] == "г") {
+					words = words[:n-1]
+				}
+				trimmed = true
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// merchantWord - слово без цифр; номер (цифр не меньше, чем букв) и маска карты - пустая строка
+func merchantWord(field string) string {
+	var letters, digits int
+	var b strings.Builder
+	for _, r := range field {
+		if unicode.IsDigit(r) {
+			digits++
+			continue
+		}
+		letters++
+		b.WriteRune(r)
+	}
+	if digits > 0 && digits >= letters {
+		return ""
+	}
+	word := b.String()
+	if len([]rune(word)) >= 2 && strings.Trim(word, "xх") == "" {
+		return ""
+	}
+	return word
+}
+
+func hasSuffix(words, suffix []string) bool {
+	offset := len(words) - len(suffix)
+	for i, word := range suffix {
+		if words[offset+i] != word {
+			return false
+		}
+	}
+	return true
+}
+
+// MerchantKey - продавец транзакции: нормализованное описание, а если его нет - ref_no
+// (если после нормализации в нем осталось хотя бы 3 буквы)
+func MerchantKey(description, refNo string) string {
+	if name := NormalizeMerchant(description); name != "" {
+		return name
+	}
+	if name := NormalizeMerchant(refNo); len([]rune(name)) >= 3 {
+		return name
+	}
+	return ""
+}
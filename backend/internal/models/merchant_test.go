@@ -0,0 +1,48 @@
+package models
+
+import "testing"
+
+func TestNormalizeMerchant(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"YANDEX*TAXI 1234 MOSCOW", "yandex taxi"},
+		{"Пятёрочка #1234", "пятёрочка"},
+		{"ООО Ромашка", "ромашка"},
+		{"ip Ivanov", "ivanov"},
+		{"Ромашка ООО", "ромашка ооо"},
+		{"SBOL перевод XXXX1234", "sbol перевод"},
+		{"Оплата карта *4455 ХХХХ", "оплата"},
+		{"MAGNIT MM 0234 TID 12345678 RRN 998877", "magnit mm"},
+		{"Кофейня Зерно г. Москва", "кофейня зерно"},
+		{"Lenta SPB RUS", "lenta"},
+		{"AZBUKA VKUSA SAINT PETERSBURG", "azbuka vkusa"},
+		{"Магнит Санкт-Петербург", "магнит"},
+		{"Moscow", "moscow"},
+		{"GIPPO 2000", "gippo"},
+		{"order A123456", "order"},
+		{"   ", ""},
+		{"1234 5678", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeMerchant(tt.in); got != tt.want {
+			t.Errorf("NormalizeMerchant(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMerchantKey(t *testing.T) {
+	tests := []struct {
+		description, refNo, want string
+	}{
+		{"Перекресток 0042", "REF123", "перекресток"},
+		{"", "OZON*ORDER 55", "ozon order"},
+		{"12345", "AB", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if got := MerchantKey(tt.description, tt.refNo); got != tt.want {
+			t.Errorf("MerchantKey(%q, %q) = %q, want %q", tt.description, tt.refNo, got, tt.want)
+		}
+	}
+}
@@ -1,16 +1,24 @@
 package models
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+// DefaultCurrency - валюта по умолчанию для денежных записей и базовой валюты пользователя
+const DefaultCurrency = "RUB"
+
 type User struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Email     string    `gorm:"uniqueIndex;not null" json:"email"`
-	Password  string    `gorm:"not null" json:"-"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	Email           string    `gorm:"uniqueIndex;not null" json:"email"`
+	Password        string    `gorm:"not null" json:"-"`
+	BaseCurrency    string    `gorm:"size:3;not null;default:RUB" json:"base_currency"` // Валюта отчетности
+	ReviewThreshold float64   `gorm:"not null;default:0.6" json:"review_threshold"`     // Транзакции с уверенностью категоризации ниже порога попадают в очередь проверки
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 func (u *User) SetPassword(password string) error {
@@ -26,17 +34,397 @@ func (u *User) CheckPassword(password string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) == nil
 }
 
+// Типы счетов
+const (
+	AccountTypeCard    = "card"
+	AccountTypeCurrent = "current"
+	AccountTypeCash    = "cash"
+	AccountTypeSavings = "savings"
+)
+
+// TransferCategory - категория ног перевода между счетами
+const TransferCategory = "Transfer"
+
+// Account - счет пользователя (карта, текущий счет, наличные, накопительный)
+type Account struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	UserID         uint      `gorm:"not null;index" json:"user_id"`
+	Name           string    `gorm:"not null" json:"name"`
+	Type           string    `gorm:"not null" json:"type"` // card, current, cash, savings
+	Currency       string    `gorm:"size:3;not null;default:RUB" json:"currency"`
+	OpeningBalance Money     `json:"opening_balance"` // Начальный остаток в валюте счета
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Transfer - перевод между счетами пользователя, связывает две транзакции-ноги
+type Transfer struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	UserID        uint           `gorm:"not null;index" json:"user_id"`
+	FromAccountID uint           `gorm:"not null" json:"from_account_id"`
+	ToAccountID   uint           `gorm:"not null" json:"to_account_id"`
+	Amount        Money          `gorm:"not null" json:"amount"` // Списано в валюте счета-источника
+	Currency      string         `gorm:"size:3;not null;default:RUB" json:"currency"`
+	ToAmount      Money          `gorm:"not null;default:0" json:"to_amount"` // Зачислено в валюте счета-получателя
+	ToCurrency    string         `gorm:"size:3;not null;default:RUB" json:"to_currency"`
+	Description   string         `json:"description"`
+	Date          time.Time      `gorm:"index" json:"date"`
+	CreatedAt     time.Time      `json:"created_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"` // Перевод в корзине вместе с обеими ногами (см. /api/trash)
+}
+
 type Transaction struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	UserID          uint           `gorm:"not null;index" json:"user_id"`
+	AccountID       uint           `gorm:"index" json:"account_id"`
+	TransferID      *uint          `gorm:"index" json:"transfer_id,omitempty"`                                                        // Ссылка на перевод (для ног перевода)
+	RecurringRuleID *uint          `gorm:"uniqueIndex:idx_transactions_recurring_date,priority:1" json:"recurring_rule_id,omitempty"` // Правило, создавшее транзакцию; пара (правило, дата) уникальна
+	Amount          Money          `gorm:"not null" json:"amount"`
+	Currency        string         `gorm:"size:3;not null;default:RUB" json:"currency"`
+	BaseAmount      Money          `gorm:"not null;default:0" json:"base_amount"` // Сумма в базовой валюте пользователя по курсу на дату транзакции
+	Description     string         `json:"description"`
+	RefNo           string         `json:"ref_no"` // Референсный номер транзакции (для ML классификации)
+	Category        string         `json:"category"`
+	Date            time.Time      `gorm:"index;uniqueIndex:idx_transactions_recurring_date,priority:2" json:"date"`
+	Type            string         `gorm:"not null" json:"type"` // income/expense
+	IsEssential     bool           `json:"is_essential"`
+	CreatedAt       time.Time      `json:"created_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"` // Удалена в корзину; все запросы gorm ее пропускают, в SQL - условие deleted_at IS NULL
+
+	CategorySource     string  `gorm:"size:16;not null;default:''" json:"category_source"` // Откуда категория (CategorySource*); пусто - транзакция создана до учета источника
+	CategoryConfidence float64 `gorm:"not null;default:0" json:"category_confidence"`      // Уверенность в категории (0-1)
+	NeedsReview        bool    `gorm:"not null;default:false;index" json:"needs_review"`   // Уверенность ниже порога пользователя, категорию нужно проверить
+
+	Merchant   string `gorm:"index" json:"merchant"`              // Нормализованный продавец (см. NormalizeMerchant); пусто - не определен
+	MerchantID *uint  `gorm:"index" json:"merchant_id,omitempty"` // Продавец из справочника, шаблон которого совпал с Merchant
+
+	Splits      []TransactionSplit   `gorm:"foreignKey:TransactionID" json:"splits,omitempty"` // Разбивка по категориям
+	Tags        []Tag                `gorm:"many2many:transaction_tags" json:"tags,omitempty"`
+	Explanation *CategoryExplanation `gorm:"foreignKey:TransactionID" json:"-"` // Как выбрана категория при создании (GET /transactions/:id/explain)
+}
+
+// Источники категории транзакции (Transaction.CategorySource)
+const (
+	CategorySourceManual    = "manual"    // Указана пользователем: в запросе, CSV, разбивке или исправлением
+	CategorySourceMerchant  = "merchant"  // Выучена на исправлениях пользователя для продавца
+	CategorySourceRule      = "rule"      // Правило категоризации пользователя, в том числе правило по умолчанию
+	CategorySourceKeyword   = "keyword"   // Встроенные ключевые слова (не зависят от правил пользователя)
+	CategorySourceML        = "ml"        // ML сервис
+	CategorySourceBayes     = "bayes"     // Локальный наивный байесовский классификатор по истории пользователя
+	CategorySourceEnsemble  = "ensemble"  // Голосование нескольких стратегий
+	CategorySourceRecurring = "recurring" // Регулярная операция
+	CategorySourceDefault   = "default"   // Определить не удалось, DefaultCategory
+)
+
+// SetCategorySource - источник категории и уверенность в ней
+func (t *Transaction) SetCategorySource(source string, confidence float64) {
+	t.CategorySource = source
+	t.CategoryConfidence = confidence
+}
+
+// CategoryExplanation - как была выбрана категория транзакции при создании
+type CategoryExplanation struct {
+	ID            uint                 `gorm:"primaryKey" json:"-"`
+	TransactionID uint                 `gorm:"not null;uniqueIndex" json:"-"`
+	Category      string               `json:"category"`
+	Source        string               `gorm:"size:16" json:"source"` // CategorySource*
+	Confidence    float64              `json:"confidence"`
+	Explanation   string               `json:"explanation"`       // Почему стратегия выбрала категорию
+	Decision      string               `json:"decision"`          // Как выбран итог среди стратегий
+	RuleID        uint                 `json:"rule_id,omitempty"` // Правило пользователя, задавшее категорию
+	Steps         []CategorizationStep `gorm:"type:jsonb;serializer:json" json:"steps"`
+	CreatedAt     time.Time            `json:"created_at"`
+}
+
+// CategorizationStep - ответ одной стратегии категоризации
+type CategorizationStep struct {
+	Strategy      string  `json:"strategy"`
+	Category      string  `json:"category,omitempty"` // Пусто - стратегия не знает категорию
+	Confidence    float64 `json:"confidence,omitempty"`
+	Explanation   string  `json:"explanation,omitempty"`
+	Error         string  `json:"error,omitempty"`
+	AfterDecision bool    `json:"after_decision,omitempty"` // Опрошена только для объяснения, на итог не повлияла
+}
+
+// TransactionSplit - часть транзакции со своей категорией (например, чек супермаркета:
+// продукты, бытовая химия, аптека). Суммы частей в сумме дают сумму транзакции.
+type TransactionSplit struct {
+	ID            uint   `gorm:"primaryKey" json:"id"`
+	TransactionID uint   `gorm:"not null;index" json:"transaction_id"`
+	Amount        Money  `gorm:"not null" json:"amount"`                // Со знаком родительской транзакции
+	BaseAmount    Money  `gorm:"not null;default:0" json:"base_amount"` // Доля base_amount транзакции
+	Category      string `json:"category"`
+	IsEssential   bool   `json:"is_essential"`
+	Description   string `json:"description"`
+}
+
+// NormalizeSign - каноническое правило знака: доход хранится положительным, расход - отрицательным.
+// Направление определяется только полем Type, знак пришедшей суммы игнорируется.
+func (t *Transaction) NormalizeSign() {
+	t.Amount = SignedByType(t.Type, t.Amount)
+	t.BaseAmount = SignedByType(t.Type, t.BaseAmount)
+	for i := range t.Splits {
+		t.Splits[i].Amount = SignedByType(t.Type, t.Splits[i].Amount)
+	}
+	t.AllocateSplitBase()
+}
+
+// ValidateSplits - проверка разбивки: минимум две части с категорией и ненулевой суммой,
+// суммы частей в сумме дают сумму транзакции
+func (t *Transaction) ValidateSplits() error {
+	if len(t.Splits) == 0 {
+		return nil
+	}
+	if len(t.Splits) < 2 {
+		return fmt.Errorf("split must have at least 2 lines")
+	}
+	var total Money
+	for i, split := range t.Splits {
+		if split.Amount == 0 {
+			return fmt.Errorf("split line %d: amount must not be zero", i+1)
+		}
+		if strings.TrimSpace(split.Category) == "" {
+			return fmt.Errorf("split line %d: category is required", i+1)
+		}
+		total += split.Amount.Abs()
+	}
+	if total != t.Amount.Abs() {
+		return fmt.Errorf("split lines sum to %s, transaction amount is %s", total, t.Amount.Abs())
+	}
+	return nil
+}
+
+// AllocateSplitBase - распределяет base_amount транзакции по частям пропорционально их суммам.
+// Вызывается при каждом пересчете base_amount, чтобы части всегда давали ровно сумму транзакции.
+func (t *Transaction) AllocateSplitBase() {
+	if len(t.Splits) == 0 {
+		return
+	}
+	weights := make([]Money, len(t.Splits))
+	for i, split := range t.Splits {
+		weights[i] = split.Amount
+	}
+	for i, part := range t.BaseAmount.Allocate(weights) {
+		t.Splits[i].BaseAmount = part
+	}
+}
+
+// MainSplitCategory - категория самой крупной части (отображается как категория транзакции)
+func (t *Transaction) MainSplitCategory() string {
+	main := ""
+	var max Money
+	for _, split := range t.Splits {
+		if split.Amount.Abs() > max {
+			max = split.Amount.Abs()
+			main = split.Category
+		}
+	}
+	return main
+}
+
+// Lines - строки транзакции для аналитики по категориям: части разбивки или сама транзакция
+func (t *Transaction) Lines() []TransactionSplit {
+	if len(t.Splits) > 0 {
+		return t.Splits
+	}
+	return []TransactionSplit{{
+		TransactionID: t.ID,
+		Amount:        t.Amount,
+		BaseAmount:    t.BaseAmount,
+		Category:      t.Category,
+		IsEssential:   t.IsEssential,
+		Description:   t.Description,
+	}}
+}
+
+// SignedByType - сумма со знаком по типу транзакции (income - плюс, expense - минус)
+func SignedByType(txType string, amount Money) Money {
+	if txType == "expense" {
+		return -amount.Abs()
+	}
+	return amount.Abs()
+}
+
+// DefaultCategory - категория, если классификация не дала результата
+const DefaultCategory = "Misc"
+
+// LegacyCategoryAliases - старые названия категорий, которые миграция приводит к справочнику
+var LegacyCategoryAliases = map[string]string{
+	"Другое": DefaultCategory,
+}
+
+// Category - категория из справочника пользователя. Транзакции, части разбивки и регулярные
+// операции хранят имя категории, поэтому переименование и слияние переписывают историю.
+// Имя уникально в пределах пользователя и типа (income/expense).
+type Category struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_categories_user_type_name,priority:1" json:"user_id"`
+	Type        string    `gorm:"not null;uniqueIndex:idx_categories_user_type_name,priority:2" json:"type"` // income/expense
+	Name        string    `gorm:"not null;uniqueIndex:idx_categories_user_type_name,priority:3" json:"name"`
+	ParentID    *uint     `gorm:"index" json:"parent_id"` // Родительская категория того же типа (nil - верхний уровень)
+	Icon        string    `json:"icon"`
+	Color       string    `json:"color"`        // #RRGGBB
+	IsEssential bool      `json:"is_essential"` // is_essential по умолчанию для новых транзакций категории
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DefaultCategories - справочник, который создается каждому пользователю
+var DefaultCategories = []Category{
+	{Type: "expense", Name: "Rent", Icon: "🏠", Color: "#8E7CC3", IsEssential: true},
+	{Type: "expense", Name: "Food", Icon: "🍽️", Color: "#F6B26B", IsEssential: true},
+	{Type: "expense", Name: "Transport", Icon: "🚌", Color: "#6FA8DC", IsEssential: true},
+	{Type: "expense", Name: "Health", Icon: "💊", Color: "#E06666", IsEssential: true},
+	{Type: "expense", Name: "Shopping", Icon: "🛍️", Color: "#C27BA0"},
+	{Type: "expense", Name: "Education", Icon: "🎓", Color: "#76A5AF"},
+	{Type: "expense", Name: "Entertainment", Icon: "🎬", Color: "#FFD966"},
+	{Type: "expense", Name: DefaultCategory, Icon: "📦", Color: "#999999"},
+	{Type: "income", Name: "Salary", Icon: "💼", Color: "#93C47D"},
+	{Type: "income", Name: DefaultCategory, Icon: "📦", Color: "#999999"},
+}
+
+// Tag - метка пользователя для транзакций (имя уникально в пределах пользователя)
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_tags_user_name,priority:1" json:"-"`
+	Name      string    `gorm:"not null;uniqueIndex:idx_tags_user_name,priority:2" json:"name"`
+	CreatedAt time.Time `json:"-"`
+}
+
+// CategoryRule - правило автоматической обработки новых транзакций. Правила проверяются по
+// возрастанию priority; заданные условия должны выполниться все. Каждое поле (категорию, признак
+// обязательности, описание) задает первое сработавшее правило, метки всех сработавших правил
+// объединяются. Правило со stop_processing прекращает проверку следующих правил.
+type CategoryRule struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	UserID         uint   `gorm:"not null;index" json:"user_id"`
+	Name           string `json:"name"`
+	Priority       int    `gorm:"not null;default:100" json:"priority"`
+	Enabled        bool   `gorm:"not null;default:true" json:"enabled"`
+	StopProcessing bool   `json:"stop_processing"`
+
+	// Условия (пустое условие не проверяется)
+	DescriptionPattern string `json:"description_pattern,omitempty"` // Регулярное выражение, без учета регистра
+	RefNoPattern       string `json:"ref_no_pattern,omitempty"`      // Регулярное выражение, без учета регистра
+	MinAmount          *Money `json:"min_amount,omitempty"`          // Сумма по модулю в базовой валюте
+	MaxAmount          *Money `json:"max_amount,omitempty"`
+	Type               string `json:"type,omitempty"`                                       // income/expense
+	Weekdays           []int  `gorm:"type:jsonb;serializer:json" json:"weekdays,omitempty"` // 1 - понедельник ... 7 - воскресенье
+	AccountID          *uint  `json:"account_id,omitempty"`
+
+	// Действия
+	SetCategory    string   `json:"set_category,omitempty"`
+	SetEssential   *bool    `json:"set_essential,omitempty"`
+	AddTags        []string `gorm:"type:jsonb;serializer:json" json:"add_tags,omitempty"`
+	SetDescription string   `json:"set_description,omitempty"` // Новое описание; $1, $2 - группы из description_pattern
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DefaultCategoryRules - правила, которые создаются каждому пользователю вместо прежней
+// встроенной классификации по ключевым словам. Их можно менять и удалять как любые другие.
+var DefaultCategoryRules = []CategoryRule{
+	{Name: "Жилье", Priority: 1000, Type: "expense", SetCategory: "Rent",
+		DescriptionPattern: `аренд|квартир|жиль|коммунал|жкх|управляющ|домофон|консьерж|ипотек`},
+	{Name: "Еда", Priority: 1010, Type: "expense", SetCategory: "Food",
+		DescriptionPattern: `еда|еды|обед|ужин|кафе|ресторан|столовая|завтрак|ланч|пицц|суши|бургер|шашлык|кофе|напиток|десерт|морожен|кондитерск|пекарн|доставк|delivery|food|cafe|restaurant|продукт|мясо|рыба|овощ|фрукт|молок|хлеб|бакалея`},
+	{Name: "Транспорт", Priority: 1020, Type: "expense", SetCategory: "Transport",
+		DescriptionPattern: `такси|uber|yandex|ситимобил|gett|транспорт|метро|автобус|троллейбус|трамвай|поезд|электричк|билет|проезд|каршеринг|бензин|заправк|азс|парковк|стоянк|штраф|гибдд|дпс`},
+	{Name: "Здоровье", Priority: 1030, Type: "expense", SetCategory: "Health",
+		DescriptionPattern: `аптек|лекарств|медицин|здоровье|больниц|поликлиник|врач|стоматолог|лечение|анализ|клиник`},
+	{Name: "Образование", Priority: 1040, Type: "expense", SetCategory: "Education",
+		DescriptionPattern: `образован|университет|школ|курс|обучен|репетитор|книг`},
+	{Name: "Развлечения", Priority: 1050, Type: "expense", SetCategory: "Entertainment",
+		DescriptionPattern: `кино|театр|концерт|клуб|развлечен|игра|казино`},
+	{Name: "Покупки", Priority: 1060, Type: "expense", SetCategory: "Shopping",
+		DescriptionPattern: `одежд|шоппинг|магазин|покупк|торгов|молл|бутик|ателье|обув|аксессуар|электроник|телефон|ноутбук|планшет|техник|бытов|мебель|интерьер|косметик|парфюм|спорт|фитнес|тренажер|абонемент|канцтовар|игрушк|подарок`},
+	{Name: "Зарплата", Priority: 1070, Type: "income", SetCategory: "Salary",
+		DescriptionPattern: `зарплат|заработн|аванс|оклад|премия`},
+}
+
+// CategoryCorrection - ручное исправление категории транзакции пользователем
+type CategoryCorrection struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uint      `gorm:"not null;index" json:"user_id"`
+	TransactionID uint      `gorm:"not null;index" json:"transaction_id"`
+	Type          string    `gorm:"not null" json:"type"` // income/expense
+	Merchant      string    `json:"merchant"`             // Нормализованный продавец (пусто - не определен)
+	FromCategory  string    `json:"from_category"`        // Категория до исправления
+	ToCategory    string    `gorm:"not null" json:"to_category"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// MerchantCategory - выученная на исправлениях категория продавца. Для новых транзакций
+// продавца она важнее правил и ML.
+type MerchantCategory struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
-	UserID      uint      `gorm:"not null;index" json:"user_id"`
-	Amount      float64   `gorm:"not null" json:"amount"`
-	Description string    `json:"description"`
-	RefNo       string    `json:"ref_no"` // Референсный номер транзакции (для ML классификации)
-	Category    string    `json:"category"`
-	Date        time.Time `gorm:"index" json:"date"`
-	Type        string    `gorm:"not null" json:"type"` // income/expense
-	IsEssential bool      `json:"is_essential"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_merchant_categories_user_type_merchant,priority:1" json:"user_id"`
+	Type        string    `gorm:"not null;uniqueIndex:idx_merchant_categories_user_type_merchant,priority:2" json:"type"` // income/expense
+	Merchant    string    `gorm:"not null;uniqueIndex:idx_merchant_categories_user_type_merchant,priority:3" json:"merchant"`
+	Category    string    `gorm:"not null" json:"category"`
+	Corrections int       `gorm:"not null;default:1" json:"corrections"` // Сколько раз подряд пользователь выбрал эту категорию
 	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Merchant - продавец из справочника пользователя: каноническое имя, шаблоны, по которым
+// ему принадлежат транзакции, и категория по умолчанию
+type Merchant struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_merchants_user_name,priority:1" json:"user_id"`
+	Name      string    `gorm:"not null;uniqueIndex:idx_merchants_user_name,priority:2" json:"name"`
+	Patterns  []string  `gorm:"type:jsonb;serializer:json" json:"patterns"` // Нормализованные варианты написания (см. NormalizeMerchant)
+	Category  string    `json:"category,omitempty"`                         // Категория расходов по умолчанию
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RecategorizationJob - фоновое задание: повторная категоризация истории транзакций пользователя
+// цепочкой категоризаторов. Транзакции обходятся по возрастанию id, Cursor - последняя
+// обработанная, поэтому прерванное задание продолжается с того же места.
+type RecategorizationJob struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Status     string     `gorm:"size:16;not null;index" json:"status"` // pending/running/completed/cancelled/failed
+	Apply      bool       `gorm:"not null" json:"apply"`                // true - менять категории, false - только отчет о различиях
+	StartDate  *time.Time `json:"start_date,omitempty"`
+	EndDate    *time.Time `json:"end_date,omitempty"`
+	Category   string     `json:"category,omitempty"`                                  // Только транзакции с этой категорией
+	Sources    []string   `gorm:"type:jsonb;serializer:json" json:"sources,omitempty"` // Только с этими источниками категории
+	Total      int        `gorm:"not null;default:0" json:"total"`                     // Транзакций под фильтром
+	Processed  int        `gorm:"not null;default:0" json:"processed"`
+	Changed    int        `gorm:"not null;default:0" json:"changed"` // Новая категория отличается от прежней
+	Cursor     uint       `gorm:"not null;default:0" json:"-"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Состояния задания перекатегоризации
+const (
+	RecategorizationPending   = "pending"
+	RecategorizationRunning   = "running"
+	RecategorizationCompleted = "completed"
+	RecategorizationCancelled = "cancelled"
+	RecategorizationFailed    = "failed"
+)
+
+// RecategorizationChange - различие, найденное заданием: прежняя и новая категория транзакции
+type RecategorizationChange struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	JobID          uint      `gorm:"not null;index" json:"job_id"`
+	TransactionID  uint      `gorm:"not null" json:"transaction_id"`
+	Date           time.Time `json:"date"`
+	Description    string    `json:"description"`
+	FromCategory   string    `json:"from_category"`
+	FromSource     string    `json:"from_source"`
+	FromConfidence float64   `json:"from_confidence"`
+	ToCategory     string    `json:"to_category"`
+	ToSource       string    `json:"to_source"`
+	ToConfidence   float64   `json:"to_confidence"`
+	Applied        bool      `json:"applied"` // Категория транзакции изменена
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 type Prediction struct {
@@ -47,27 +435,67 @@ type Prediction struct {
 
 // Investment - инвестиции пользователя
 type Investment struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	UserID       uint      `gorm:"not null;index" json:"user_id"`
-	Amount       float64   `gorm:"not null" json:"amount"` // Сумма инвестиции
-	Type         string    `json:"type"`                   // Тип: акции, облигации, крипта, фонды и т.д.
-	Description  string    `json:"description"`            // Описание
-	CurrentValue float64   `json:"current_value"`          // Текущая стоимость (опционально)
-	Date         time.Time `gorm:"index" json:"date"`      // Дата покупки/вложения
-	CreatedAt    time.Time `json:"created_at"`
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	UserID           uint           `gorm:"not null;index" json:"user_id"`
+	Amount           Money          `gorm:"not null" json:"amount"` // Сумма инвестиции
+	Currency         string         `gorm:"size:3;not null;default:RUB" json:"currency"`
+	BaseAmount       Money          `gorm:"not null;default:0" json:"base_amount"`        // Сумма в базовой валюте по курсу на дату вложения
+	Type             string         `json:"type"`                                         // Тип: акции, облигации, крипта, фонды и т.д.
+	Description      string         `json:"description"`                                  // Описание
+	CurrentValue     Money          `json:"current_value"`                                // Текущая стоимость (опционально)
+	BaseCurrentValue Money          `gorm:"not null;default:0" json:"base_current_value"` // Текущая стоимость в базовой валюте по текущему курсу
+	Date             time.Time      `gorm:"index" json:"date"`                            // Дата покупки/вложения
+	CreatedAt        time.Time      `json:"created_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"` // Удалена в корзину
+
+	Tags []Tag `gorm:"many2many:investment_tags" json:"tags,omitempty"`
 }
 
 // Deposit - вклады пользователя
 type Deposit struct {
-	ID           uint       `gorm:"primaryKey" json:"id"`
-	UserID       uint       `gorm:"not null;index" json:"user_id"`
-	Amount       float64    `gorm:"not null" json:"amount"` // Сумма вклада
-	InterestRate float64    `json:"interest_rate"`          // Процентная ставка
-	Description  string     `json:"description"`            // Описание (название банка, тип вклада)
-	OpenDate     time.Time  `gorm:"index" json:"open_date"` // Дата открытия
-	CloseDate    *time.Time `json:"close_date,omitempty"`   // Дата закрытия (если закрыт)
-	TermMonths   int        `json:"term_months"`            // Срок в месяцах (0 = до востребования)
-	CreatedAt    time.Time  `json:"created_at"`
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	UserID       uint           `gorm:"not null;index" json:"user_id"`
+	Amount       Money          `gorm:"not null" json:"amount"` // Сумма вклада
+	Currency     string         `gorm:"size:3;not null;default:RUB" json:"currency"`
+	BaseAmount   Money          `gorm:"not null;default:0" json:"base_amount"` // Сумма в базовой валюте по курсу на дату открытия
+	InterestRate float64        `json:"interest_rate"`                         // Процентная ставка
+	Description  string         `json:"description"`                           // Описание (название банка, тип вклада)
+	OpenDate     time.Time      `gorm:"index" json:"open_date"`                // Дата открытия
+	CloseDate    *time.Time     `json:"close_date,omitempty"`                  // Дата закрытия (если закрыт)
+	TermMonths   int            `json:"term_months"`                           // Срок в месяцах (0 = до востребования)
+	CreatedAt    time.Time      `json:"created_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"` // Удален в корзину
+
+	Tags []Tag `gorm:"many2many:deposit_tags" json:"tags,omitempty"`
+}
+
+// Владельцы вложений
+const (
+	AttachmentOwnerTransaction = "transaction"
+	AttachmentOwnerDeposit     = "deposit"
+	AttachmentOwnerInvestment  = "investment"
+)
+
+// Attachment - файл (чек, договор), приложенный к транзакции, вкладу или инвестиции.
+// Содержимое лежит в хранилище (service.BlobStore) по ключу StorageKey.
+type Attachment struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	UserID          uint      `gorm:"not null;index" json:"user_id"`
+	OwnerType       string    `gorm:"size:20;not null;index:idx_attachments_owner" json:"owner_type"` // transaction, deposit, investment
+	OwnerID         uint      `gorm:"not null;index:idx_attachments_owner" json:"owner_id"`
+	FileName        string    `gorm:"not null" json:"file_name"`
+	ContentType     string    `gorm:"not null" json:"content_type"` // Определен по содержимому файла
+	Size            int64     `gorm:"not null" json:"size"`         // Байт
+	SHA256          string    `gorm:"size:64" json:"sha256"`
+	StorageKey      string    `gorm:"not null" json:"-"`
+	Width           int       `json:"width,omitempty"` // Размер изображения в пикселях
+	Height          int       `json:"height,omitempty"`
+	HasThumbnail    bool      `gorm:"not null;default:false" json:"has_thumbnail"`
+	ThumbnailKey    string    `json:"-"`
+	ThumbnailSize   int64     `gorm:"not null;default:0" json:"-"`
+	ThumbnailWidth  int       `json:"thumbnail_width,omitempty"`
+	ThumbnailHeight int       `json:"thumbnail_height,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 // ChatMessage - сообщения в AI-чате
@@ -89,3 +517,50 @@ type Notification struct {
 	IsRead    bool      `gorm:"default:false" json:"is_read"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// FXRate - курс валюты: сколько единиц QuoteCurrency стоит 1 единица Currency на дату
+type FXRate struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uint      `gorm:"not null;uniqueIndex:idx_fx_rate" json:"user_id"`
+	Date          time.Time `gorm:"type:date;not null;uniqueIndex:idx_fx_rate" json:"date"`
+	Currency      string    `gorm:"size:3;not null;uniqueIndex:idx_fx_rate" json:"currency"`
+	QuoteCurrency string    `gorm:"size:3;not null;uniqueIndex:idx_fx_rate" json:"quote_currency"`
+	Rate          float64   `gorm:"not null" json:"rate"`
+	Source        string    `json:"source"` // "csv" или "endpoint"
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Частота повторения (как FREQ в RRULE)
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+	FrequencyYearly  = "yearly"
+)
+
+// RecurringRule - регулярная операция (аренда, зарплата, подписка).
+// Планировщик создает по правилу транзакции на каждую наступившую дату.
+type RecurringRule struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	UserID      uint   `gorm:"not null;index" json:"user_id"`
+	AccountID   uint   `gorm:"not null;index" json:"account_id"`
+	Amount      Money  `gorm:"not null" json:"amount"` // Со знаком по типу, как у транзакций
+	Currency    string `gorm:"size:3;not null;default:RUB" json:"currency"`
+	Type        string `gorm:"not null" json:"type"` // income/expense
+	Category    string `json:"category"`
+	Description string `json:"description"`
+	IsEssential bool   `json:"is_essential"`
+
+	Frequency  string     `gorm:"not null" json:"frequency"`              // daily/weekly/monthly/yearly
+	Interval   int        `gorm:"not null;default:1" json:"interval"`     // Каждые N периодов
+	ByMonthDay int        `gorm:"not null;default:0" json:"by_month_day"` // День месяца (monthly/yearly), -1 = последний; 0 = день start_date
+	StartDate  time.Time  `gorm:"not null" json:"start_date"`             // Первое повторение
+	EndDate    *time.Time `json:"end_date,omitempty"`                     // UNTIL: последняя возможная дата
+	Count      int        `gorm:"not null;default:0" json:"count"`        // COUNT: сколько раз повторить, 0 = без ограничения
+
+	NextDate    *time.Time `gorm:"index" json:"next_date,omitempty"`      // Следующее повторение; nil - правило завершено
+	Occurrences int        `gorm:"not null;default:0" json:"occurrences"` // Сколько транзакций уже создано
+	Active      bool       `gorm:"not null;default:true" json:"active"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
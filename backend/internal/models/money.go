@@ -0,0 +1,298 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Money - денежная сумма в минимальных единицах валюты (копейках, центах).
+// В БД хранится как bigint, в JSON отдается десятичным числом с двумя знаками
+// после запятой, поэтому формат API остается прежним.
+//
+// Правило округления везде одно: до копейки, половина - от нуля (1.005 -> 1.01, -1.005 -> -1.01).
+type Money int64
+
+// moneyScale - количество минимальных единиц в одной единице валюты
+const moneyScale = 100
+
+// maxMoneyUnits - наибольшая сумма в единицах валюты, которая помещается в Money
+const maxMoneyUnits = math.MaxInt64 / moneyScale
+
+// MoneyFromFloat - сумма из float64 с округлением до копейки по правилу ParseMoney: округляется
+// кратчайшая десятичная запись числа, а не его двоичное представление, поэтому 1.005 -> 1.01.
+// Сумма вне диапазона Money ограничивается его пределом, NaN - 0.
+func MoneyFromFloat(f float64) Money {
+	m, err := ParseMoney(strconv.FormatFloat(f, 'f', -1, 64))
+	if err == nil {
+		return m
+	}
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f < 0:
+		return -math.MaxInt64
+	default:
+		return math.MaxInt64
+	}
+}
+
+// ParseMoney - точный разбор десятичной строки ("1234.56", "-0.5", "+10").
+// Лишние знаки после запятой округляются до копейки, экспоненциальная запись разбирается через float.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount: %s", s)
+		}
+		if math.Abs(f) > maxMoneyUnits {
+			return 0, fmt.Errorf("amount is too large: %s", s)
+		}
+		return MoneyFromFloat(f), nil
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("invalid amount")
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+	// Знак допускается только один: "--5" и "-+5" - ошибка
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("invalid amount")
+	}
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || units > maxMoneyUnits {
+		return 0, fmt.Errorf("amount is too large")
+	}
+
+	// Копейки: первые два знака дробной части, третий - для округления
+	frac := fracPart + "000"
+	cents, _ := strconv.ParseInt(frac[:2], 10, 64)
+	if frac[2] >= '5' {
+		cents++
+	}
+	if units > (math.MaxInt64-cents)/moneyScale {
+		return 0, fmt.Errorf("amount is too large")
+	}
+
+	value := units*moneyScale + cents
+	if negative {
+		value = -value
+	}
+	return Money(value), nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Float64 - сумма в единицах валюты (для ML-сервиса, процентов и коэффициентов)
+func (m Money) Float64() float64 {
+	return float64(m) / moneyScale
+}
+
+// Abs - модуль суммы
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// MulRate - умножение на коэффициент (курс, процент) с округлением до копейки
+func (m Money) MulRate(rate float64) Money {
+	return Money(math.Round(float64(m) * rate))
+}
+
+// Ratio - доля суммы от total (0, если total = 0)
+func (m Money) Ratio(total Money) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(m) / float64(total)
+}
+
+// PercentShares - доли частей в процентах с точностью 0.01%, в сумме ровно 100.
+// Остаток от округления распределяется методом наибольших остатков,
+// поэтому подгонять самую крупную категорию не нужно.
+func PercentShares(parts []Money) []float64 {
+	shares := make([]float64, len(parts))
+	var total Money
+	for _, p := range parts {
+		total += p.Abs()
+	}
+	if total == 0 {
+		return shares
+	}
+
+	const scale = 10000 // 100% = 10000 сотых процента
+	basis := make([]int64, len(parts))
+	remainders := make([]int64, len(parts))
+	var assigned int64
+	for i, p := range parts {
+		product := int64(p.Abs()) * scale
+		basis[i] = product / int64(total)
+		remainders[i] = product % int64(total)
+		assigned += basis[i]
+	}
+
+	order := make([]int, len(parts))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for i := 0; assigned < scale && i < len(order); i++ {
+		basis[order[i]]++
+		assigned++
+	}
+
+	for i, b := range basis {
+		shares[i] = float64(b) / 100
+	}
+	return shares
+}
+
+// Allocate - делит сумму на части пропорционально весам так, что части в сумме дают ровно m.
+// Копейки, оставшиеся после округления, распределяются методом наибольших остатков.
+func (m Money) Allocate(weights []Money) []Money {
+	parts := make([]Money, len(weights))
+	var total Money
+	for _, w := range weights {
+		total += w.Abs()
+	}
+	if total == 0 {
+		return parts
+	}
+
+	sign := Money(1)
+	amount := int64(m)
+	if amount < 0 {
+		sign, amount = -1, -amount
+	}
+	remainders := make([]int64, len(weights))
+	var assigned int64
+	for i, w := range weights {
+		product := int64(w.Abs()) * amount
+		parts[i] = Money(product / int64(total))
+		remainders[i] = product % int64(total)
+		assigned += int64(parts[i])
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for i := 0; assigned < amount && i < len(order); i++ {
+		parts[order[i]]++
+		assigned++
+	}
+
+	for i := range parts {
+		parts[i] *= sign
+	}
+	return parts
+}
+
+// String - десятичная запись с двумя знаками после запятой: "1234.56", "-0.50"
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/moneyScale, v%moneyScale)
+}
+
+// MarshalJSON - сумма отдается числом, как и раньше при float64
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON - принимает число или строку с числом
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	if s == "null" || s == "" {
+		*m = 0
+		return nil
+	}
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// GormDataType - тип колонки в БД
+func (Money) GormDataType() string {
+	return "bigint"
+}
+
+// Value - запись в БД в минимальных единицах
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
+
+// Scan - чтение из БД. Агрегаты (SUM, AVG) приходят как numeric, дробная часть округляется.
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = Money(v)
+	case float64:
+		*m = Money(math.Round(v))
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+func (m *Money) scanString(s string) error {
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*m = Money(v)
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("cannot scan %q into Money", s)
+	}
+	*m = Money(math.Round(f))
+	return nil
+}
@@ -0,0 +1,246 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "1234.56", want: 123456},
+		{in: " 10 ", want: 1000},
+		{in: "+10", want: 1000},
+		{in: "-0.5", want: -50},
+		{in: ".5", want: 50},
+		{in: "-.5", want: -50},
+		{in: "5.", want: 500},
+		{in: "1.005", want: 101},
+		{in: "-1.005", want: -101},
+		{in: "1.004", want: 100},
+		{in: "0.995", want: 100},
+		{in: "2.999", want: 300},
+		{in: "1.5e2", want: 15000},
+		{in: "1.005e0", want: 101},
+		{in: "-1e-3", want: 0},
+		{in: "92233720368547758.07", want: math.MaxInt64},
+		{in: "-92233720368547758.07", want: -math.MaxInt64},
+
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "+", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "--5", wantErr: true},
+		{in: "-+5", wantErr: true},
+		{in: "+-5", wantErr: true},
+		{in: "--5.5", wantErr: true},
+		{in: "5-", wantErr: true},
+		{in: "1 000", wantErr: true},
+		{in: "1,5", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "0x10", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "1e", wantErr: true},
+		{in: "92233720368547758.08", wantErr: true},
+		{in: "92233720368547758.075", wantErr: true},
+		{in: "92233720368547759", wantErr: true},
+		{in: "99999999999999999999999", wantErr: true},
+		{in: "1e18", wantErr: true},
+		{in: "-1e300", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q) = %d, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-50, "-0.50"},
+		{123456, "1234.56"},
+		{-123456, "-1234.56"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", tt.in, got, tt.want)
+		}
+		// Запись и разбор взаимно обратны
+		if parsed, err := ParseMoney(tt.want); err != nil || parsed != tt.in {
+			t.Errorf("ParseMoney(%q) = %d, %v, want %d", tt.want, parsed, err, tt.in)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var v struct {
+		Amount Money `json:"amount"`
+	}
+	for in, want := range map[string]Money{
+		`{"amount": 10.5}`:    1050,
+		`{"amount": "-0.01"}`: -1,
+		`{"amount": null}`:    0,
+	} {
+		if err := json.Unmarshal([]byte(in), &v); err != nil || v.Amount != want {
+			t.Errorf("Unmarshal(%s) = %d, %v, want %d", in, v.Amount, err, want)
+		}
+	}
+	for _, in := range []string{`"--1"`, `"5`, `5"`, `"`, `"5""`} {
+		if err := v.Amount.UnmarshalJSON([]byte(in)); err == nil {
+			t.Errorf("UnmarshalJSON(%s) succeeded, want error", in)
+		}
+	}
+
+	data, err := json.Marshal(struct {
+		Amount Money `json:"amount"`
+	}{-1050})
+	if err != nil || string(data) != `{"amount":-10.50}` {
+		t.Errorf("Marshal = %s, %v", data, err)
+	}
+}
+
+func TestMoneyFromFloat(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want Money
+	}{
+		{0, 0},
+		{1.005, 101},
+		{-1.005, -101},
+		{1.004, 100},
+		{0.1 + 0.2, 30},
+		{1234.56, 123456},
+		{50000, 5000000},
+		{1e300, math.MaxInt64},
+		{math.Inf(-1), -math.MaxInt64},
+		{math.NaN(), 0},
+	}
+	for _, tt := range tests {
+		if got := MoneyFromFloat(tt.in); got != tt.want {
+			t.Errorf("MoneyFromFloat(%v) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyAllocate(t *testing.T) {
+	tests := []struct {
+		amount  Money
+		weights []Money
+		want    []Money
+	}{
+		{100, []Money{1, 1, 1}, []Money{34, 33, 33}},
+		{-100, []Money{1, 1, 1}, []Money{-34, -33, -33}},
+		{1000, []Money{300, 700}, []Money{300, 700}},
+		{1, []Money{1, 1}, []Money{1, 0}},
+		{200, []Money{1, 2, 3}, []Money{33, 67, 100}},
+		{100, []Money{-1, 3}, []Money{25, 75}},
+		{100, []Money{0, 0}, []Money{0, 0}},
+		{100, nil, []Money{}},
+	}
+	for _, tt := range tests {
+		got := tt.amount.Allocate(tt.weights)
+		if !equalMoney(got, tt.want) {
+			t.Errorf("Money(%d).Allocate(%v) = %v, want %v", tt.amount, tt.weights, got, tt.want)
+		}
+		var sum Money
+		for _, part := range got {
+			sum += part
+		}
+		if len(got) > 0 && sum != tt.amount && !allZero(tt.weights) {
+			t.Errorf("Money(%d).Allocate(%v) sums to %d", tt.amount, tt.weights, sum)
+		}
+	}
+}
+
+func TestPercentShares(t *testing.T) {
+	tests := []struct {
+		parts []Money
+		want  []float64
+	}{
+		{[]Money{1, 1, 1}, []float64{33.34, 33.33, 33.33}},
+		{[]Money{100, 300}, []float64{25, 75}},
+		{[]Money{-100, 300}, []float64{25, 75}},
+		{[]Money{1, 2, 3}, []float64{16.67, 33.33, 50}},
+		{[]Money{0, 0}, []float64{0, 0}},
+		{[]Money{}, []float64{}},
+	}
+	for _, tt := range tests {
+		got := PercentShares(tt.parts)
+		if len(got) != len(tt.want) {
+			t.Errorf("PercentShares(%v) = %v, want %v", tt.parts, got, tt.want)
+			continue
+		}
+		var total float64
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+				t.Errorf("PercentShares(%v) = %v, want %v", tt.parts, got, tt.want)
+				break
+			}
+			total += got[i]
+		}
+		if !allZero(tt.parts) && strconv.FormatFloat(total, 'f', 2, 64) != "100.00" {
+			t.Errorf("PercentShares(%v) sums to %v", tt.parts, total)
+		}
+	}
+}
+
+func TestMoneyMulRate(t *testing.T) {
+	tests := []struct {
+		amount Money
+		rate   float64
+		want   Money
+	}{
+		{10000, 92.5, 925000},
+		{101, 0.5, 51},
+		{-101, 0.5, -51},
+		{333, 1.0 / 3, 111},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.MulRate(tt.rate); got != tt.want {
+			t.Errorf("Money(%d).MulRate(%v) = %d, want %d", tt.amount, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func equalMoney(a, b []Money) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func allZero(values []Money) bool {
+	for _, v := range values {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
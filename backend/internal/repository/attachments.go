@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"clarity/internal/models"
+)
+
+// attachmentOwnerNotTrashed - условие: запись-владелец вложения не в корзине
+const attachmentOwnerNotTrashed = "NOT (" +
+	"(owner_type = ? AND EXISTS (SELECT 1 FROM transactions WHERE transactions.id = attachments.owner_id AND transactions.deleted_at IS NOT NULL)) OR " +
+	"(owner_type = ? AND EXISTS (SELECT 1 FROM deposits WHERE deposits.id = attachments.owner_id AND deposits.deleted_at IS NOT NULL)) OR " +
+	"(owner_type = ? AND EXISTS (SELECT 1 FROM investments WHERE investments.id = attachments.owner_id AND investments.deleted_at IS NOT NULL)))"
+
+// GetAttachments - вложения записи ownerType/ownerID пользователя, новые первыми
+func (r *Repository) GetAttachments(userID uint, ownerType string, ownerID uint) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := r.db.Where("user_id = ? AND owner_type = ? AND owner_id = ?", userID, ownerType, ownerID).
+		Order("created_at desc, id desc").
+		Find(&attachments).Error
+	return attachments, err
+}
+
+// GetAttachmentByID - вложение пользователя; вложения записей в корзине недоступны до восстановления
+func (r *Repository) GetAttachmentByID(id, userID uint) (*models.Attachment, error) {
+	var attachment models.Attachment
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).
+		Where(attachmentOwnerNotTrashed, models.AttachmentOwnerTransaction, models.AttachmentOwnerDeposit, models.AttachmentOwnerInvestment).
+		First(&attachment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"clarity/internal/models"
+)
+
+// ActivityFilter - фильтры ленты изменений пользователя
+type ActivityFilter struct {
+	EntityType string
+	Action     string
+	Source     string
+	BeforeID   uint // Только записи старше этой (страница после последней полученной)
+	Limit      int
+}
+
+// GetAuditHistory - история изменений записи, новые первыми
+func (r *Repository) GetAuditHistory(userID uint, entityType string, entityID uint) ([]models.AuditEntry, error) {
+	var entries []models.AuditEntry
+	err := r.db.Where("user_id = ? AND entity_type = ? AND entity_id = ?", userID, entityType, entityID).
+		Order("id desc").
+		Find(&entries).Error
+	return entries, err
+}
+
+// GetActivity - лента изменений всех записей пользователя, новые первыми
+func (r *Repository) GetActivity(userID uint, filter ActivityFilter) ([]models.AuditEntry, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.BeforeID > 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+
+	var entries []models.AuditEntry
+	err := query.Order("id desc").Limit(filter.Limit).Find(&entries).Error
+	return entries, err
+}
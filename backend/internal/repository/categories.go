@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"clarity/internal/models"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrCategoryExists - категория с таким именем и типом уже есть у пользователя
+	ErrCategoryExists = errors.New("category already exists")
+	// ErrCategoryInUse - категорию нельзя удалить, пока она используется или у нее есть подкатегории
+	ErrCategoryInUse = errors.New("category is in use")
+	// ErrInvalidParent - родитель не найден, другого типа или образует цикл
+	ErrInvalidParent = errors.New("invalid parent category")
+)
+
+// seedDefaultCategories - создает пользователю справочник по умолчанию (существующие категории не меняются)
+func seedDefaultCategories(db *gorm.DB, userID uint) error {
+	categories := make([]models.Category, len(models.DefaultCategories))
+	copy(categories, models.DefaultCategories)
+	for i := range categories {
+		categories[i].UserID = userID
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&categories).Error
+}
+
+// Category CRUD
+func (r *Repository) CreateCategory(cat *models.Category) error {
+	if err := checkCategoryNameFree(r.db, cat.UserID, cat.Type, cat.Name); err != nil {
+		return err
+	}
+	if err := r.checkParent(cat); err != nil {
+		return err
+	}
+	return r.db.Create(cat).Error
+}
+
+func (r *Repository) GetCategories(userID uint, categoryType string) ([]models.Category, error) {
+	var categories []models.Category
+	query := r.db.Where("user_id = ?", userID)
+	if categoryType != "" {
+		query = query.Where("type = ?", categoryType)
+	}
+	err := query.Order("type asc, name asc").Find(&categories).Error
+	return categories, err
+}
+
+func (r *Repository) GetCategoryByID(id, userID uint) (*models.Category, error) {
+	var cat models.Category
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&cat).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cat, nil
+}
+
+// UpdateCategory - сохраняет иконку, цвет, признак обязательности и родителя. Имя меняется через RenameCategory.
+func (r *Repository) UpdateCategory(cat *models.Category) error {
+	if err := r.checkParent(cat); err != nil {
+		return err
+	}
+	return r.db.Model(cat).Select("parent_id", "icon", "color", "is_essential").Updates(cat).Error
+}
+
+// DeleteCategory - удаляет категорию, только если она нигде не используется и не имеет подкатегорий
+func (r *Repository) DeleteCategory(id, userID uint) error {
+	cat, err := r.GetCategoryByID(id, userID)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	if err := r.db.Model(&models.Category{}).Where("parent_id = ?", cat.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCategoryInUse
+	}
+	if err := categoryUsage(r.db, cat.UserID, cat.Type, cat.Name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCategoryInUse
+	}
+	if err := r.db.Model(&models.RecurringRule{}).
+		Where("user_id = ? AND type = ? AND category = ?", cat.UserID, cat.Type, cat.Name).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCategoryInUse
+	}
+	return r.db.Delete(cat).Error
+}
+
+// EnsureCategory - категория пользователя по типу и имени; если ее нет, создается на верхнем уровне
+func (r *Repository) EnsureCategory(userID uint, categoryType, name string) (*models.Category, error) {
+	cat := models.Category{UserID: userID, Type: categoryType, Name: name}
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&cat).Error; err != nil {
+		return nil, err
+	}
+	err := r.db.Where("user_id = ? AND type = ? AND name = ?", userID, categoryType, name).First(&cat).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cat, nil
+}
+
+// RenameCategory - переименовывает категорию и переписывает ее имя в истории транзакций,
+// частях разбивки и регулярных операциях
+func (r *Repository) RenameCategory(cat *models.Category, name string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkCategoryNameFree(tx, cat.UserID, cat.Type, name); err != nil {
+			return err
+		}
+		if err := rewriteCategory(tx, cat.UserID, cat.Type, cat.Name, name); err != nil {
+			return err
+		}
+		cat.Name = name
+		return tx.Model(cat).Update("name", name).Error
+	})
+}
+
+// MergeCategory - переносит историю категории source в target, подкатегории source
+// становятся подкатегориями target, сама source удаляется
+func (r *Repository) MergeCategory(source, target *models.Category) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := rewriteCategory(tx, source.UserID, source.Type, source.Name, target.Name); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Category{}).
+			Where("parent_id = ?", source.ID).
+			Update("parent_id", target.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(source).Error
+	})
+}
+
+// IsDescendant - является ли категория id потомком ancestorID
+func (r *Repository) IsDescendant(id, ancestorID uint) (bool, error) {
+	for steps := 0; id != 0 && steps < 100; steps++ {
+		var cat models.Category
+		if err := r.db.Select("id", "parent_id").First(&cat, id).Error; err != nil {
+			return false, err
+		}
+		if cat.ParentID == nil {
+			return false, nil
+		}
+		if *cat.ParentID == ancestorID {
+			return true, nil
+		}
+		id = *cat.ParentID
+	}
+	return false, nil
+}
+
+// checkCategoryNameFree - имя категории не занято другой категорией того же типа
+func checkCategoryNameFree(db *gorm.DB, userID uint, categoryType, name string) error {
+	var count int64
+	if err := db.Model(&models.Category{}).
+		Where("user_id = ? AND type = ? AND name = ?", userID, categoryType, name).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCategoryExists
+	}
+	return nil
+}
+
+// checkParent - родитель должен принадлежать пользователю, иметь тот же тип и не быть потомком категории
+func (r *Repository) checkParent(cat *models.Category) error {
+	if cat.ParentID == nil {
+		return nil
+	}
+	if cat.ID != 0 && *cat.ParentID == cat.ID {
+		return ErrInvalidParent
+	}
+	parent, err := r.GetCategoryByID(*cat.ParentID, cat.UserID)
+	if err != nil || parent.Type != cat.Type {
+		return ErrInvalidParent
+	}
+	if cat.ID != 0 {
+		cycle, err := r.IsDescendant(parent.ID, cat.ID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrInvalidParent
+		}
+	}
+	return nil
+}
+
+// CategoryTransactionIDs - транзакции пользователя (и в корзине), которые переписывают
+// RenameCategory и MergeCategory: категория у самой транзакции или у части разбивки
+func (r *Repository) CategoryTransactionIDs(userID uint, categoryType, name string) ([]uint, error) {
+	var ids []uint
+	err := categoryUsage(r.db, userID, categoryType, name).Order("id asc").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// categoryUsage - транзакции пользователя, у которых категория встречается в самой транзакции или в части разбивки.
+// Транзакции в корзине учитываются: после восстановления им нужна существующая категория.
+func categoryUsage(db *gorm.DB, userID uint, categoryType, name string) *gorm.DB {
+	return db.Unscoped().Model(&models.Transaction{}).
+		Where("user_id = ? AND type = ? AND transfer_id IS NULL", userID, categoryType).
+		Where("category = ? OR id IN (?)", name,
+			db.Model(&models.TransactionSplit{}).Select("transaction_id").Where("category = ?", name))
+}
+
+// rewriteCategory - замена имени категории в транзакциях, частях разбивки, регулярных операциях,
+// действиях правил категоризации (правила без условия по типу тоже переписываются)
+// и выученных категориях продавцов. Транзакции в корзине переписываются тоже.
+func rewriteCategory(db *gorm.DB, userID uint, categoryType, from, to string) error {
+	if err := db.Unscoped().Model(&models.Transaction{}).
+		Where("user_id = ? AND type = ? AND transfer_id IS NULL AND category = ?", userID, categoryType, from).
+		Update("category", to).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&models.TransactionSplit{}).
+		Where("category = ? AND transaction_id IN (?)", from,
+			db.Unscoped().Model(&models.Transaction{}).Select("id").Where("user_id = ? AND type = ?", userID, categoryType)).
+		Update("category", to).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&models.RecurringRule{}).
+		Where("user_id = ? AND type = ? AND category = ?", userID, categoryType, from).
+		Update("category", to).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&models.CategoryRule{}).
+		Where("user_id = ? AND (type = ? OR type = '') AND set_category = ?", userID, categoryType, from).
+		Update("set_category", to).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.MerchantCategory{}).
+		Where("user_id = ? AND type = ? AND category = ?", userID, categoryType, from).
+		Update("category", to).Error
+}
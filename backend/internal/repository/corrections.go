@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"clarity/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxTopCorrections - сколько строк возвращают топы в статистике исправлений
+const maxTopCorrections = 10
+
+// RecordCategoryCorrection - сохраняет исправление категории и запоминает категорию продавца
+// (если продавец определен). Повторное исправление на ту же категорию увеличивает счетчик,
+// на другую - заменяет категорию и сбрасывает счетчик.
+func (r *Repository) RecordCategoryCorrection(correction *models.CategoryCorrection) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(correction).Error; err != nil {
+			return err
+		}
+		if correction.Merchant == "" {
+			return nil
+		}
+		memory := models.MerchantCategory{
+			UserID:   correction.UserID,
+			Type:     correction.Type,
+			Merchant: correction.Merchant,
+			Category: correction.ToCategory,
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}, {Name: "type"}, {Name: "merchant"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"corrections": gorm.Expr("CASE WHEN merchant_categories.category = excluded.category THEN merchant_categories.corrections + 1 ELSE 1 END"),
+				"category":    gorm.Expr("excluded.category"),
+				"updated_at":  gorm.Expr("excluded.updated_at"),
+			}),
+		}).Create(&memory).Error
+	})
+}
+
+// GetCategoryCorrections - последние исправления пользователя
+func (r *Repository) GetCategoryCorrections(userID uint, limit int) ([]models.CategoryCorrection, error) {
+	var corrections []models.CategoryCorrection
+	err := r.db.Where("user_id = ?", userID).Order("created_at desc, id desc").Limit(limit).Find(&corrections).Error
+	return corrections, err
+}
+
+// CorrectionPair - сколько раз категорию from исправляли на to
+type CorrectionPair struct {
+	FromCategory string `json:"from_category"`
+	ToCategory   string `json:"to_category"`
+	Count        int    `json:"count"`
+}
+
+// CorrectedCategory - сколько раз исправляли транзакции с этой категорией
+type CorrectedCategory struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+// CorrectionStats - статистика ручных исправлений категорий
+type CorrectionStats struct {
+	Total            int64               `json:"total"`
+	Last30Days       int64               `json:"last_30_days"`
+	Transactions     int64               `json:"transactions"`      // Разных исправленных транзакций
+	MerchantsLearned int64               `json:"merchants_learned"` // Продавцов в памяти категорий
+	TopChanges       []CorrectionPair    `json:"top_changes"`
+	MostCorrected    []CorrectedCategory `json:"most_corrected"` // Категории, которые чаще всего оказывались неверными
+}
+
+func (r *Repository) GetCorrectionStats(userID uint, now time.Time) (*CorrectionStats, error) {
+	stats := CorrectionStats{TopChanges: []CorrectionPair{}, MostCorrected: []CorrectedCategory{}}
+	corrections := func() *gorm.DB {
+		return r.db.Model(&models.CategoryCorrection{}).Where("user_id = ?", userID)
+	}
+
+	if err := corrections().Count(&stats.Total).Error; err != nil {
+		return nil, err
+	}
+	if err := corrections().Where("created_at >= ?", now.AddDate(0, 0, -30)).Count(&stats.Last30Days).Error; err != nil {
+		return nil, err
+	}
+	if err := corrections().Distinct("transaction_id").Count(&stats.Transactions).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(&models.MerchantCategory{}).Where("user_id = ?", userID).Count(&stats.MerchantsLearned).Error; err != nil {
+		return nil, err
+	}
+	if err := corrections().
+		Select("from_category, to_category, COUNT(*) AS count").
+		Group("from_category, to_category").
+		Order("count desc, from_category asc, to_category asc").
+		Limit(maxTopCorrections).
+		Scan(&stats.TopChanges).Error; err != nil {
+		return nil, err
+	}
+	if err := corrections().
+		Select("from_category AS category, COUNT(*) AS count").
+		Group("from_category").
+		Order("count desc, from_category asc").
+		Limit(maxTopCorrections).
+		Scan(&stats.MostCorrected).Error; err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (r *Repository) GetMerchantCategories(userID uint) ([]models.MerchantCategory, error) {
+	var memory []models.MerchantCategory
+	err := r.db.Where("user_id = ?", userID).Order("merchant asc, type asc").Find(&memory).Error
+	return memory, err
+}
+
+// DeleteMerchantCategory - забыть категорию продавца (история исправлений сохраняется)
+func (r *Repository) DeleteMerchantCategory(id, userID uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.MerchantCategory{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetTrainingTransactions - транзакции для обучения классификатора: по умолчанию только
+// исправленные пользователем, с all - все. Ноги переводов и разбитые транзакции не входят.
+func (r *Repository) GetTrainingTransactions(userID uint, all bool) ([]models.Transaction, error) {
+	var txs []models.Transaction
+	query := r.db.Where("user_id = ? AND transfer_id IS NULL", userID).
+		Where("id NOT IN (?)", r.db.Model(&models.TransactionSplit{}).Select("transaction_id"))
+	if !all {
+		query = query.Where("id IN (?)",
+			r.db.Model(&models.CategoryCorrection{}).Select("transaction_id").Where("user_id = ?", userID))
+	}
+	err := query.Order("date asc, id asc").Find(&txs).Error
+	return txs, err
+}
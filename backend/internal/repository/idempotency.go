@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"clarity/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReserveIdempotencyKey - занимает ключ идемпотентности под выполняемый запрос. Если ключ
+// уже занят, возвращает его запись (reserved = false). Истекший ключ и ключ брошенного
+// запроса (processing без отметок дольше IdempotencyLockTimeout) занимаются заново.
+func (r *Repository) ReserveIdempotencyKey(key *models.IdempotencyKey) (existing *models.IdempotencyKey, reserved bool, err error) {
+	now := time.Now()
+	err = r.db.Transaction(func(db *gorm.DB) error {
+		if err := db.Where("user_id = ? AND key = ?", key.UserID, key.Key).
+			Where("expires_at <= ? OR (status = ? AND updated_at < ?)", now, models.IdempotencyProcessing, now.Add(-models.IdempotencyLockTimeout)).
+			Delete(&models.IdempotencyKey{}).Error; err != nil {
+			return err
+		}
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			reserved = true
+			return nil
+		}
+		existing = &models.IdempotencyKey{}
+		return db.Where("user_id = ? AND key = ?", key.UserID, key.Key).First(existing).Error
+	})
+	return existing, reserved, err
+}
+
+// TouchIdempotencyKey - отмечает, что запрос с ключом еще выполняется, чтобы ключ не заняли заново
+func (r *Repository) TouchIdempotencyKey(id uint) error {
+	return r.db.Model(&models.IdempotencyKey{}).
+		Where("id = ? AND status = ?", id, models.IdempotencyProcessing).
+		Update("updated_at", time.Now()).Error
+}
+
+// CompleteIdempotencyKey - сохраняет ответ на запрос с ключом для повторов
+func (r *Repository) CompleteIdempotencyKey(key *models.IdempotencyKey) error {
+	key.Status = models.IdempotencyCompleted
+	return r.db.Model(key).Select("status", "response_code", "content_type", "response_body", "updated_at").Updates(key).Error
+}
+
+// ReleaseIdempotencyKey - освобождает ключ запроса, который не выполнился: повтор выполнится заново
+func (r *Repository) ReleaseIdempotencyKey(id uint) error {
+	return r.db.Delete(&models.IdempotencyKey{}, id).Error
+}
+
+// PurgeExpiredIdempotencyKeys - удаляет истекшие ключи идемпотентности
+func (r *Repository) PurgeExpiredIdempotencyKeys(now time.Time) (int64, error) {
+	result := r.db.Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
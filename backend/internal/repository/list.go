@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"clarity/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnsupportedFilter - фильтр или порядок, которого у списка нет (например, категория у вкладов)
+var ErrUnsupportedFilter = errors.New("filter is not supported by this list")
+
+// ListFilter - фильтры, порядок и страница списков транзакций, инвестиций, вкладов и
+// уведомлений. Пустые поля не фильтруют.
+type ListFilter struct {
+	Type        string
+	Category    string // Категория транзакции или одной из частей разбивки
+	IsEssential *bool
+	Tags        []string      // Нужны все метки
+	MinAmount   *models.Money // Модуль суммы в базовой валюте
+	MaxAmount   *models.Money
+	StartDate   string // YYYY-MM-DD, включительно
+	EndDate     string
+	Sort        string // date_desc (по умолчанию), date_asc, amount_desc, amount_asc
+	Limit       int
+	Cursor      string // next_cursor предыдущей страницы
+	WithTotal   bool   // Посчитать записи под фильтрами
+}
+
+// ListPage - страница списка
+type ListPage[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"` // Пусто - последняя страница
+	Total      *int64 `json:"total,omitempty"`       // Всего под фильтрами, если запрошено
+}
+
+// listSource - колонки списка для фильтров и порядка; пустое поле - такого фильтра у списка нет
+type listSource struct {
+	table     string
+	date      string // Дата записи: порядок по дате и период
+	amount    string // Модуль суммы в базовой валюте: порядок по сумме и границы суммы
+	typ       string
+	essential string
+	category  func(query *gorm.DB, category string) *gorm.DB
+	tagJoin   string // Таблица связи с метками и ее колонка записи
+	tagColumn string
+}
+
+var (
+	transactionList = listSource{table: "transactions", date: "date", amount: "ABS(base_amount)", typ: "type",
+		essential: "is_essential", category: whereTransactionCategory, tagJoin: "transaction_tags", tagColumn: "transaction_id"}
+	investmentList = listSource{table: "investments", date: "date", amount: "ABS(base_amount)", typ: "type",
+		tagJoin: "investment_tags", tagColumn: "investment_id"}
+	depositList      = listSource{table: "deposits", date: "open_date", amount: "ABS(base_amount)", tagJoin: "deposit_tags", tagColumn: "deposit_id"}
+	notificationList = listSource{table: "notifications", date: "created_at", typ: "type"}
+)
+
+// listKey - значения ключей порядка записи для курсора
+type listKey struct {
+	Date   time.Time
+	Amount models.Money
+	ID     uint
+}
+
+// whereTransactionCategory - транзакции с категорией category у самой транзакции или у одной из частей
+func whereTransactionCategory(query *gorm.DB, category string) *gorm.DB {
+	return query.Where("(category = ? OR EXISTS (SELECT 1 FROM transaction_splits WHERE transaction_splits.transaction_id = transactions.id AND transaction_splits.category = ?))",
+		category, category)
+}
+
+// ListTransactions - транзакции пользователя с разбивкой и метками
+func (r *Repository) ListTransactions(userID uint, filter ListFilter) (ListPage[models.Transaction], error) {
+	return listPage(r.db.Where("user_id = ?", userID), transactionList, filter,
+		func(tx *models.Transaction) listKey { return listKey{tx.Date, tx.BaseAmount.Abs(), tx.ID} }, "Splits", "Tags")
+}
+
+// ListInvestments - инвестиции пользователя с метками
+func (r *Repository) ListInvestments(userID uint, filter ListFilter) (ListPage[models.Investment], error) {
+	return listPage(r.db.Where("user_id = ?", userID), investmentList, filter,
+		func(inv *models.Investment) listKey { return listKey{inv.Date, inv.BaseAmount.Abs(), inv.ID} }, "Tags")
+}
+
+// ListDeposits - вклады пользователя с метками; activeOnly - только незакрытые
+func (r *Repository) ListDeposits(userID uint, activeOnly bool, filter ListFilter) (ListPage[models.Deposit], error) {
+	query := r.db.Where("user_id = ?", userID)
+	if activeOnly {
+		query = query.Where("close_date IS NULL")
+	}
+	return listPage(query, depositList, filter,
+		func(dep *models.Deposit) listKey { return listKey{dep.OpenDate, dep.BaseAmount.Abs(), dep.ID} }, "Tags")
+}
+
+// ListNotifications - уведомления пользователя; unreadOnly - только непрочитанные
+func (r *Repository) ListNotifications(userID uint, unreadOnly bool, filter ListFilter) (ListPage[models.Notification], error) {
+	query := r.db.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("is_read = ?", false)
+	}
+	return listPage(query, notificationList, filter,
+		func(n *models.Notification) listKey { return listKey{Date: n.CreatedAt, ID: n.ID} })
+}
+
+// listPage - страница списка: фильтры src, порядок по дате или сумме и страницы по курсору
+// (keyset по ключу порядка и id). query - записи пользователя с условиями самого списка.
+func listPage[T any](query *gorm.DB, src listSource, filter ListFilter, key func(*T) listKey, preload ...string) (ListPage[T], error) {
+	page := ListPage[T]{Items: []T{}}
+	if filter.Sort == "" {
+		filter.Sort = SearchSortDateDesc
+	}
+	sort, ok := searchSorts[filter.Sort]
+	if !ok || filter.Sort == SearchSortRelevance {
+		return page, ErrInvalidSort
+	}
+	sortKey := src.date
+	if sort.key == "amount_key" {
+		sortKey = src.amount
+	}
+
+	query, err := src.filter(query.Model(new(T)), filter)
+	if err != nil {
+		return page, err
+	}
+	if sortKey == "" {
+		return page, fmt.Errorf("%w: sort %s", ErrUnsupportedFilter, filter.Sort)
+	}
+	query = query.Session(&gorm.Session{}) // Общие условия для подсчета и страницы
+
+	if filter.WithTotal {
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			return page, err
+		}
+		page.Total = &total
+	}
+
+	direction := " asc"
+	op := ">"
+	if sort.desc {
+		direction, op = " desc", "<"
+	}
+	rows := query
+	if filter.Cursor != "" {
+		cursor, err := decodeSearchCursor(filter.Cursor)
+		if err != nil || cursor.Sort != filter.Sort {
+			return page, ErrInvalidCursor
+		}
+		var value interface{}
+		switch {
+		case sort.key == "date" && cursor.Date != nil:
+			value = *cursor.Date
+		case sort.key == "amount_key" && cursor.Amount != nil:
+			value = *cursor.Amount
+		default:
+			return page, ErrInvalidCursor
+		}
+		rows = rows.Where("("+sortKey+", "+src.table+".id) "+op+" (?, ?)", value, cursor.ID)
+	}
+	for _, association := range preload {
+		rows = rows.Preload(association)
+	}
+	err = rows.Order(sortKey + direction + ", " + src.table + ".id" + direction).Limit(filter.Limit + 1).Find(&page.Items).Error
+	if err != nil {
+		return page, err
+	}
+
+	if len(page.Items) > filter.Limit {
+		page.Items = page.Items[:filter.Limit]
+		last := key(&page.Items[len(page.Items)-1])
+		cursor := searchCursor{Sort: filter.Sort, ID: last.ID}
+		if sort.key == "date" {
+			cursor.Date = &last.Date
+		} else {
+			amount := int64(last.Amount)
+			cursor.Amount = &amount
+		}
+		page.NextCursor = encodeSearchCursor(cursor)
+	}
+	return page, nil
+}
+
+// filter - добавляет к запросу фильтры списка; фильтр, которого у списка нет, - ErrUnsupportedFilter
+func (src listSource) filter(query *gorm.DB, filter ListFilter) (*gorm.DB, error) {
+	unsupported := func(name string) error { return fmt.Errorf("%w: %s", ErrUnsupportedFilter, name) }
+
+	if len(filter.Tags) > 0 {
+		if src.tagJoin == "" {
+			return nil, unsupported("tag")
+		}
+		query = withTags(query, src.tagJoin, src.tagColumn, src.table, filter.Tags)
+	}
+	if filter.Type != "" {
+		if src.typ == "" {
+			return nil, unsupported("type")
+		}
+		query = query.Where(src.typ+" = ?", filter.Type)
+	}
+	if filter.Category != "" {
+		if src.category == nil {
+			return nil, unsupported("category")
+		}
+		query = src.category(query, filter.Category)
+	}
+	if filter.IsEssential != nil {
+		if src.essential == "" {
+			return nil, unsupported("is_essential")
+		}
+		query = query.Where(src.essential+" = ?", *filter.IsEssential)
+	}
+	if filter.MinAmount != nil || filter.MaxAmount != nil {
+		if src.amount == "" {
+			return nil, unsupported("amount")
+		}
+		if filter.MinAmount != nil {
+			query = query.Where(src.amount+" >= ?", int64(*filter.MinAmount))
+		}
+		if filter.MaxAmount != nil {
+			query = query.Where(src.amount+" <= ?", int64(*filter.MaxAmount))
+		}
+	}
+	if filter.StartDate != "" {
+		query = query.Where(src.date+" >= ?", filter.StartDate)
+	}
+	if filter.EndDate != "" {
+		query = query.Where(src.date+" < (?::date + 1)", filter.EndDate)
+	}
+	return query, nil
+}
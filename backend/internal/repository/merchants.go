@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"clarity/internal/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrMerchantExists - продавец с таким именем уже есть у пользователя
+var ErrMerchantExists = errors.New("merchant already exists")
+
+// MerchantUsage - продавец справочника и число его транзакций
+type MerchantUsage struct {
+	models.Merchant
+	Transactions int `json:"transactions"`
+}
+
+func (r *Repository) GetMerchants(userID uint) ([]MerchantUsage, error) {
+	var merchants []MerchantUsage
+	err := r.db.Model(&models.Merchant{}).
+		Select("merchants.*, COUNT(transactions.id) AS transactions").
+		Joins("LEFT JOIN transactions ON transactions.merchant_id = merchants.id AND transactions.deleted_at IS NULL").
+		Where("merchants.user_id = ?", userID).
+		Group("merchants.id").
+		Order("merchants.name asc").
+		Scan(&merchants).Error
+	return merchants, err
+}
+
+func (r *Repository) GetMerchantByID(id, userID uint) (*models.Merchant, error) {
+	var merchant models.Merchant
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&merchant).Error
+	return &merchant, err
+}
+
+// SaveMerchant - создает или обновляет продавца; имя уникально в справочнике пользователя
+func (r *Repository) SaveMerchant(merchant *models.Merchant) error {
+	var count int64
+	if err := r.db.Model(&models.Merchant{}).
+		Where("user_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", merchant.UserID, merchant.Name, merchant.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrMerchantExists
+	}
+	return r.db.Save(merchant).Error
+}
+
+// DeleteMerchant - удаляет продавца; транзакции сохраняют нормализованный ключ продавца
+func (r *Repository) DeleteMerchant(id, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var merchant models.Merchant
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&merchant).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Transaction{}).Where("merchant_id = ?", merchant.ID).
+			Update("merchant_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&merchant).Error
+	})
+}
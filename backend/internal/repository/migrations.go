@@ -0,0 +1,354 @@
+package repository
+
+import (
+	"clarity/internal/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultAccountName - имя счета, который создается каждому пользователю по умолчанию
+const DefaultAccountName = "Основной счёт"
+
+// moneyColumns - денежные колонки, которые хранятся в копейках (bigint, см. models.Money)
+var moneyColumns = []struct {
+	Table   string
+	Columns []string
+}{
+	{"accounts", []string{"opening_balance"}},
+	{"transfers", []string{"amount", "to_amount"}},
+	{"transactions", []string{"amount", "base_amount"}},
+	{"investments", []string{"amount", "base_amount", "current_value", "base_current_value"}},
+	{"deposits", []string{"amount", "base_amount"}},
+}
+
+// convertMoneyColumns - перевод денежных колонок из дробных рублей в целые копейки.
+// Выполняется до AutoMigrate: сам gorm сменил бы тип простым приведением и потерял копейки.
+// Уже сконвертированные колонки (bigint) и еще не созданные таблицы пропускаются.
+func convertMoneyColumns(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range moneyColumns {
+			for _, column := range item.Columns {
+				var dataType string
+				if err := tx.Raw(
+					"SELECT data_type FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?",
+					item.Table, column,
+				).Scan(&dataType).Error; err != nil {
+					return err
+				}
+				if dataType != "double precision" && dataType != "real" && dataType != "numeric" {
+					continue
+				}
+				if err := tx.Exec(fmt.Sprintf(
+					"ALTER TABLE %s ALTER COLUMN %s TYPE bigint USING ROUND(%s::numeric * 100)::bigint",
+					item.Table, column, column,
+				)).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// runDataMigrations - миграции данных, которые AutoMigrate не умеет делать сам.
+// Каждая миграция идемпотентна и безопасна для повторного запуска при старте.
+func runDataMigrations(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := backfillDefaultAccounts(tx); err != nil {
+			return err
+		}
+		if err := backfillBaseAmounts(tx); err != nil {
+			return err
+		}
+		if err := normalizeTransactionSigns(tx); err != nil {
+			return err
+		}
+		if err := backfillCategories(tx); err != nil {
+			return err
+		}
+		if err := runOnce(tx, "seed_default_category_rules", seedDefaultRulesForExistingUsers); err != nil {
+			return err
+		}
+		if err := runOnce(tx, "backfill_category_confidence", backfillCategoryConfidence); err != nil {
+			return err
+		}
+		return runOnce(tx, "normalize_merchants", normalizeMerchants)
+	})
+}
+
+// ensureTransactionSearch - схема поиска транзакций (см. SearchTransactions): вычисляемая
+// колонка search_vector с GIN индексом и триграммный индекс по тексту транзакции. Расширение
+// pg_trgm может быть недоступно (нет прав на CREATE EXTENSION) - тогда поиск по подстроке
+// идет без индекса и без учета опечаток. Выражение колонки меняется только пересозданием.
+func ensureTransactionSearch(db *gorm.DB) error {
+	if err := db.Exec("ALTER TABLE transactions ADD COLUMN IF NOT EXISTS search_vector tsvector " +
+		"GENERATED ALWAYS AS (" + searchVectorExpr + ") STORED").Error; err != nil {
+		return err
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_transactions_search_vector ON transactions USING GIN (search_vector)").Error; err != nil {
+		return err
+	}
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		return nil
+	}
+	return db.Exec("CREATE INDEX IF NOT EXISTS idx_transactions_search_text ON transactions USING GIN (" + searchTextExpr + " gin_trgm_ops)").Error
+}
+
+// ensureAuditAppendOnly - журнал изменений только дополняется: UPDATE и DELETE строк
+// audit_entries отклоняет триггер
+func ensureAuditAppendOnly(db *gorm.DB) error {
+	if err := db.Exec(`CREATE OR REPLACE FUNCTION audit_entries_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_entries is append-only';
+END
+$$ LANGUAGE plpgsql`).Error; err != nil {
+		return err
+	}
+	if err := db.Exec("DROP TRIGGER IF EXISTS audit_entries_append_only ON audit_entries").Error; err != nil {
+		return err
+	}
+	return db.Exec("CREATE TRIGGER audit_entries_append_only BEFORE UPDATE OR DELETE ON audit_entries " +
+		"FOR EACH ROW EXECUTE FUNCTION audit_entries_append_only()").Error
+}
+
+// dataMigration - миграция данных, которая выполняется один раз (см. runOnce)
+type dataMigration struct {
+	Name      string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+// runOnce - выполняет миграцию, если она еще не записана в data_migrations. Нужна для
+// миграций, повторный запуск которых вернул бы данные, удаленные пользователем.
+func runOnce(db *gorm.DB, name string, migrate func(*gorm.DB) error) error {
+	var count int64
+	if err := db.Model(&dataMigration{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	if err := migrate(db); err != nil {
+		return err
+	}
+	return db.Create(&dataMigration{Name: name, AppliedAt: time.Now()}).Error
+}
+
+// seedDefaultRulesForExistingUsers - правила по умолчанию для пользователей, зарегистрированных
+// до появления правил (они заменяют прежнюю встроенную классификацию по ключевым словам)
+func seedDefaultRulesForExistingUsers(db *gorm.DB) error {
+	var userIDs []uint
+	if err := db.Model(&models.User{}).
+		Where("NOT EXISTS (SELECT 1 FROM category_rules WHERE category_rules.user_id = users.id)").
+		Pluck("id", &userIDs).Error; err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if err := seedDefaultRules(db, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeTransactionSigns - приводит старые записи к правилу знака:
+// доход положительный, расход отрицательный (см. models.Transaction.NormalizeSign)
+func normalizeTransactionSigns(db *gorm.DB) error {
+	if err := db.Model(&models.Transaction{}).
+		Where("type = 'expense' AND (amount > 0 OR base_amount > 0)").
+		Updates(map[string]interface{}{"amount": gorm.Expr("-ABS(amount)"), "base_amount": gorm.Expr("-ABS(base_amount)")}).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.Transaction{}).
+		Where("type = 'income' AND (amount < 0 OR base_amount < 0)").
+		Updates(map[string]interface{}{"amount": gorm.Expr("ABS(amount)"), "base_amount": gorm.Expr("ABS(base_amount)")}).Error
+}
+
+// backfillBaseAmounts - записи, созданные до появления валют, считались в рублях,
+// поэтому сумма в базовой валюте совпадает с исходной
+func backfillBaseAmounts(db *gorm.DB) error {
+	if err := db.Model(&models.Transaction{}).
+		Where("base_amount = 0 AND amount <> 0").
+		Update("base_amount", gorm.Expr("amount")).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&models.Investment{}).
+		Where("base_amount = 0 AND amount <> 0").
+		Updates(map[string]interface{}{"base_amount": gorm.Expr("amount"), "base_current_value": gorm.Expr("current_value")}).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&models.Deposit{}).
+		Where("base_amount = 0 AND amount <> 0").
+		Update("base_amount", gorm.Expr("amount")).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.Transfer{}).
+		Where("to_amount = 0 AND amount <> 0").
+		Updates(map[string]interface{}{"to_amount": gorm.Expr("amount"), "to_currency": gorm.Expr("currency")}).Error
+}
+
+// backfillDefaultAccounts - транзакции, созданные до появления счетов, переносятся
+// на счет по умолчанию их владельца
+func backfillDefaultAccounts(db *gorm.DB) error {
+	var userIDs []uint
+	if err := db.Model(&models.Transaction{}).
+		Where("account_id IS NULL OR account_id = 0").
+		Distinct("user_id").
+		Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		account, err := ensureDefaultAccount(db, userID)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&models.Transaction{}).
+			Where("user_id = ? AND (account_id IS NULL OR account_id = 0)", userID).
+			Update("account_id", account.ID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureDefaultAccount - возвращает счет по умолчанию, создавая его при необходимости
+func ensureDefaultAccount(db *gorm.DB, userID uint) (*models.Account, error) {
+	var account models.Account
+	err := db.Where("user_id = ?", userID).Order("id asc").First(&account).Error
+	if err == nil {
+		return &account, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	account = models.Account{
+		UserID: userID,
+		Name:   DefaultAccountName,
+		Type:   models.AccountTypeCard,
+	}
+	if err := db.Create(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// backfillCategories - переносит категории-строки в справочник. Пользователь без справочника
+// получает категории по умолчанию, старые названия (см. models.LegacyCategoryAliases) приводятся
+// к ним. Затем каждая встречающаяся в истории пара (тип, категория) добавляется в справочник;
+// is_essential по умолчанию - как у большинства транзакций категории.
+func backfillCategories(db *gorm.DB) error {
+	var userIDs []uint
+	if err := db.Model(&models.User{}).
+		Where("NOT EXISTS (SELECT 1 FROM categories WHERE categories.user_id = users.id)").
+		Pluck("id", &userIDs).Error; err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		for legacy, name := range models.LegacyCategoryAliases {
+			for _, categoryType := range []string{"income", "expense"} {
+				if err := rewriteCategory(db, userID, categoryType, legacy, name); err != nil {
+					return err
+				}
+			}
+		}
+		if err := seedDefaultCategories(db, userID); err != nil {
+			return err
+		}
+	}
+
+	sources := []string{
+		`SELECT user_id, type, category, is_essential FROM transactions WHERE transfer_id IS NULL`,
+		`SELECT t.user_id, t.type, s.category, s.is_essential FROM transaction_splits s JOIN transactions t ON t.id = s.transaction_id`,
+		`SELECT user_id, type, category, is_essential FROM recurring_rules`,
+	}
+	for _, source := range sources {
+		if err := db.Exec(`INSERT INTO categories (user_id, type, name, is_essential, created_at)
+			SELECT user_id, type, category, AVG(CASE WHEN is_essential THEN 1 ELSE 0 END) >= 0.5, NOW()
+			FROM (` + source + `) AS used
+			WHERE category <> '' AND type IN ('income', 'expense')
+			GROUP BY user_id, type, category
+			ON CONFLICT (user_id, type, name) DO NOTHING`).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillCategoryConfidence - транзакции, созданные до учета источника категории, считаются
+// проверенными: уверенность 1, в очередь проверки они не попадают
+func backfillCategoryConfidence(db *gorm.DB) error {
+	return db.Model(&models.Transaction{}).
+		Where("category_source = ''").
+		Update("category_confidence", 1).Error
+}
+
+// normalizeMerchants - продавец (models.MerchantKey) для транзакций, созданных до его учета, и
+// ключи исправлений и памяти категорий продавцов по новой нормализации (без городов, терминалов
+// и масок карт). Если два ключа памяти совпали после нормализации, остается более свежий.
+func normalizeMerchants(db *gorm.DB) error {
+	var txs []models.Transaction
+	err := db.Model(&models.Transaction{}).Select("id", "description", "ref_no").
+		Where("transfer_id IS NULL").
+		FindInBatches(&txs, 1000, func(batch *gorm.DB, _ int) error {
+			byKey := make(map[string][]uint)
+			for _, tx := range txs {
+				if key := models.MerchantKey(tx.Description, tx.RefNo); key != "" {
+					byKey[key] = append(byKey[key], tx.ID)
+				}
+			}
+			for key, ids := range byKey {
+				if err := db.Model(&models.Transaction{}).Where("id IN ?", ids).Update("merchant", key).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	var corrections []models.CategoryCorrection
+	if err := db.Where("merchant <> ''").Find(&corrections).Error; err != nil {
+		return err
+	}
+	for _, correction := range corrections {
+		if key := models.NormalizeMerchant(correction.Merchant); key != correction.Merchant {
+			if err := db.Model(&correction).Update("merchant", key).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	var memory []models.MerchantCategory
+	if err := db.Order("updated_at desc, id desc").Find(&memory).Error; err != nil {
+		return err
+	}
+	// Сначала удаляются дубли, потом переименовываются оставшиеся: иначе новый ключ мог бы
+	// совпасть с еще не удаленной записью
+	kept := make(map[string]bool)
+	var renamed []models.MerchantCategory
+	for _, item := range memory {
+		key := models.NormalizeMerchant(item.Merchant)
+		id := fmt.Sprintf("%d:%s:%s", item.UserID, item.Type, key)
+		if key == "" || kept[id] {
+			if err := db.Delete(&item).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		kept[id] = true
+		if key != item.Merchant {
+			item.Merchant = key
+			renamed = append(renamed, item)
+		}
+	}
+	for _, item := range renamed {
+		if err := db.Model(&item).Update("merchant", item.Merchant).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"clarity/internal/models"
+)
+
+// maxRecategorizationJobs - сколько последних заданий возвращает список
+const maxRecategorizationJobs = 50
+
+func (r *Repository) CreateRecategorizationJob(job *models.RecategorizationJob) error {
+	return r.db.Create(job).Error
+}
+
+// GetRecategorizationJobs - последние задания пользователя, новые первыми
+func (r *Repository) GetRecategorizationJobs(userID uint) ([]models.RecategorizationJob, error) {
+	var jobs []models.RecategorizationJob
+	err := r.db.Where("user_id = ?", userID).Order("id desc").Limit(maxRecategorizationJobs).Find(&jobs).Error
+	return jobs, err
+}
+
+func (r *Repository) GetRecategorizationJob(id, userID uint) (*models.RecategorizationJob, error) {
+	var job models.RecategorizationJob
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// HasActiveRecategorizationJob - есть ли у пользователя ожидающее или выполняемое задание
+func (r *Repository) HasActiveRecategorizationJob(userID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RecategorizationJob{}).
+		Where("user_id = ? AND status IN ?", userID, []string{models.RecategorizationPending, models.RecategorizationRunning}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ResetRecategorizationJob - возвращает отмененное или упавшее задание в очередь; обработанные
+// транзакции повторно не обходятся
+func (r *Repository) ResetRecategorizationJob(job *models.RecategorizationJob) error {
+	job.Status = models.RecategorizationPending
+	job.Error = ""
+	job.FinishedAt = nil
+	return r.db.Model(job).Select("status", "error", "finished_at").Updates(job).Error
+}
+
+// GetRecategorizationChanges - различия, найденные заданием, и их общее число
+func (r *Repository) GetRecategorizationChanges(jobID uint, limit, offset int) ([]models.RecategorizationChange, int64, error) {
+	query := r.db.Model(&models.RecategorizationChange{}).Where("job_id = ?", jobID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var changes []models.RecategorizationChange
+	err := query.Order("id asc").Limit(limit).Offset(offset).Find(&changes).Error
+	return changes, total, err
+}
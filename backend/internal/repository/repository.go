@@ -2,11 +2,15 @@ package repository
 
 import (
 	"clarity/internal/models"
+	"errors"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// ErrAccountInUse - счет нельзя удалить, пока по нему есть транзакции или регулярные операции
+var ErrAccountInUse = errors.New("account has transactions")
+
 type Repository struct {
 	db *gorm.DB
 }
@@ -15,12 +19,32 @@ func (r *Repository) DB() *gorm.DB {
 	return r.db
 }
 
+// Transaction - выполняет fn с репозиторием поверх одной транзакции БД: изменения записей и
+// журнал изменений сохраняются вместе или не сохраняются вовсе. Ошибка fn откатывает все.
+func (r *Repository) Transaction(fn func(repo *Repository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&Repository{db: tx})
+	})
+}
+
 func NewDB(dsn string) (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
-	return db, db.AutoMigrate(&models.User{}, &models.Transaction{}, &models.Investment{}, &models.Deposit{}, &models.ChatMessage{}, &models.Notification{})
+	if err := convertMoneyColumns(db); err != nil {
+		return nil, err
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Account{}, &models.Transfer{}, &models.Transaction{}, &models.TransactionSplit{}, &models.Investment{}, &models.Deposit{}, &models.ChatMessage{}, &models.Notification{}, &models.FXRate{}, &models.RecurringRule{}, &models.Category{}, &models.Tag{}, &models.CategoryRule{}, &models.CategoryCorrection{}, &models.MerchantCategory{}, &models.Merchant{}, &models.CategoryExplanation{}, &models.RecategorizationJob{}, &models.RecategorizationChange{}, &models.Attachment{}, &models.AuditEntry{}, &models.IdempotencyKey{}, &dataMigration{}); err != nil {
+		return nil, err
+	}
+	if err := runDataMigrations(db); err != nil {
+		return nil, err
+	}
+	if err := ensureAuditAppendOnly(db); err != nil {
+		return nil, err
+	}
+	return db, ensureTransactionSearch(db)
 }
 
 func New(db *gorm.DB) *Repository {
@@ -31,9 +55,10 @@ func (r *Repository) CreateTransaction(t *models.Transaction) error {
 	return r.db.Create(t).Error
 }
 
-func (r *Repository) GetTransactions(userID uint, limit, offset int, month, startDate, endDate string) ([]models.Transaction, error) {
+// GetTransactions - транзакции пользователя за месяц или период; tags - только с каждой из меток
+func (r *Repository) GetTransactions(userID uint, limit, offset int, month, startDate, endDate string, tags []string) ([]models.Transaction, error) {
 	var txs []models.Transaction
-	query := r.db.Where("user_id = ?", userID)
+	query := withTags(r.db.Where("user_id = ?", userID), "transaction_tags", "transaction_id", "transactions", tags)
 
 	if month != "" {
 		query = query.Where("DATE_TRUNC('month', date) = ?", month)
@@ -45,29 +70,79 @@ func (r *Repository) GetTransactions(userID uint, limit, offset int, month, star
 		query = query.Where("date <= ?", endDate)
 	}
 
-	err := query.Order("date desc").Limit(limit).Offset(offset).Find(&txs).Error
+	err := query.Preload("Splits").Preload("Tags").Order("date desc").Limit(limit).Offset(offset).Find(&txs).Error
 	return txs, err
 }
 
 func (r *Repository) GetTransactionByID(id, userID uint) (*models.Transaction, error) {
 	var tx models.Transaction
-	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&tx).Error
+	err := r.db.Preload("Splits").Preload("Tags").Where("id = ? AND user_id = ?", id, userID).First(&tx).Error
 	if err != nil {
 		return nil, err
 	}
 	return &tx, nil
 }
 
+// UpdateTransaction - сохраняет транзакцию вместе с разбивкой и метками: части, которых нет
+// в tx.Splits, удаляются, метки заменяются на tx.Tags
 func (r *Repository) UpdateTransaction(tx *models.Transaction) error {
-	return r.db.Save(tx).Error
+	return r.db.Transaction(func(db *gorm.DB) error {
+		if err := db.Omit("Splits", "Tags").Save(tx).Error; err != nil {
+			return err
+		}
+		if err := db.Model(tx).Association("Tags").Replace(tx.Tags); err != nil {
+			return err
+		}
+		keep := make([]uint, 0, len(tx.Splits))
+		for _, split := range tx.Splits {
+			if split.ID != 0 {
+				keep = append(keep, split.ID)
+			}
+		}
+		stale := db.Where("transaction_id = ?", tx.ID)
+		if len(keep) > 0 {
+			stale = stale.Where("id NOT IN ?", keep)
+		}
+		if err := stale.Delete(&models.TransactionSplit{}).Error; err != nil {
+			return err
+		}
+		for i := range tx.Splits {
+			tx.Splits[i].TransactionID = tx.ID
+			if err := db.Save(&tx.Splits[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
+// DeleteTransaction - переносит транзакцию в корзину (разбивка, метки и объяснение
+// остаются до окончательного удаления, см. PurgeTrashItem). Нога перевода уносит весь перевод.
 func (r *Repository) DeleteTransaction(id, userID uint) error {
+	tx, err := r.GetTransactionByID(id, userID)
+	if err != nil {
+		return err
+	}
+	if tx.TransferID != nil {
+		return r.DeleteTransfer(*tx.TransferID, userID)
+	}
 	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Transaction{}).Error
 }
 
+// CreateUser - создает пользователя вместе со счетом, справочником категорий и правилами по умолчанию
 func (r *Repository) CreateUser(user *models.User) error {
-	return r.db.Create(user).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if _, err := ensureDefaultAccount(tx, user.ID); err != nil {
+			return err
+		}
+		if err := seedDefaultCategories(tx, user.ID); err != nil {
+			return err
+		}
+		return seedDefaultRules(tx, user.ID)
+	})
 }
 
 func (r *Repository) GetUserByEmail(email string) (*models.User, error) {
@@ -88,34 +163,166 @@ func (r *Repository) GetUserByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
+// Account CRUD
+func (r *Repository) CreateAccount(acc *models.Account) error {
+	return r.db.Create(acc).Error
+}
+
+func (r *Repository) GetAccounts(userID uint) ([]models.Account, error) {
+	var accounts []models.Account
+	err := r.db.Where("user_id = ?", userID).Order("id asc").Find(&accounts).Error
+	return accounts, err
+}
+
+// DefaultAccount - счет пользователя по умолчанию (первый созданный); создается, если счетов нет
+func (r *Repository) DefaultAccount(userID uint) (*models.Account, error) {
+	return ensureDefaultAccount(r.db, userID)
+}
+
+func (r *Repository) GetAccountByID(id, userID uint) (*models.Account, error) {
+	var acc models.Account
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&acc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+func (r *Repository) UpdateAccount(acc *models.Account) error {
+	return r.db.Save(acc).Error
+}
+
+// DeleteAccount - удаляет счет, только если по нему нет транзакций
+func (r *Repository) DeleteAccount(id, userID uint) error {
+	var count int64
+	// Транзакции в корзине тоже держат счет: после восстановления им нужен счет
+	if err := r.db.Unscoped().Model(&models.Transaction{}).
+		Where("account_id = ? AND user_id = ?", id, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrAccountInUse
+	}
+	if err := r.db.Model(&models.RecurringRule{}).
+		Where("account_id = ? AND user_id = ?", id, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrAccountInUse
+	}
+	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Account{}).Error
+}
+
+// Recurring rule CRUD
+func (r *Repository) CreateRecurringRule(rule *models.RecurringRule) error {
+	return r.db.Create(rule).Error
+}
+
+func (r *Repository) GetRecurringRules(userID uint) ([]models.RecurringRule, error) {
+	var rules []models.RecurringRule
+	err := r.db.Where("user_id = ?", userID).Order("next_date asc NULLS LAST, id asc").Find(&rules).Error
+	return rules, err
+}
+
+func (r *Repository) GetRecurringRuleByID(id, userID uint) (*models.RecurringRule, error) {
+	var rule models.RecurringRule
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *Repository) UpdateRecurringRule(rule *models.RecurringRule) error {
+	return r.db.Save(rule).Error
+}
+
+// DeleteRecurringRule - удаляет правило; созданные по нему транзакции остаются, но теряют связь с правилом
+func (r *Repository) DeleteRecurringRule(id, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", id, userID).Delete(&models.RecurringRule{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Unscoped().Model(&models.Transaction{}).
+			Where("recurring_rule_id = ? AND user_id = ?", id, userID).
+			Update("recurring_rule_id", nil).Error
+	})
+}
+
+// Transfer methods
+
+// CreateTransfer - создает перевод и две связанные транзакции-ноги в одной транзакции БД
+func (r *Repository) CreateTransfer(tr *models.Transfer, outLeg, inLeg *models.Transaction) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(tr).Error; err != nil {
+			return err
+		}
+		outLeg.TransferID = &tr.ID
+		inLeg.TransferID = &tr.ID
+		if err := tx.Create(outLeg).Error; err != nil {
+			return err
+		}
+		return tx.Create(inLeg).Error
+	})
+}
+
+func (r *Repository) GetTransferByID(id, userID uint) (*models.Transfer, error) {
+	var tr models.Transfer
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&tr).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tr, nil
+}
+
+// GetTransferLegs - обе ноги перевода
+func (r *Repository) GetTransferLegs(transferID, userID uint) ([]models.Transaction, error) {
+	var legs []models.Transaction
+	err := r.db.Preload("Tags").Where("transfer_id = ? AND user_id = ?", transferID, userID).Order("id asc").Find(&legs).Error
+	return legs, err
+}
+
+// DeleteTransfer - переносит перевод в корзину вместе с обеими ногами
+func (r *Repository) DeleteTransfer(id, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("transfer_id = ? AND user_id = ?", id, userID).Delete(&models.Transaction{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Transfer{}).Error
+	})
+}
+
 // Investment CRUD
 func (r *Repository) CreateInvestment(inv *models.Investment) error {
 	return r.db.Create(inv).Error
 }
 
-func (r *Repository) GetInvestments(userID uint, limit, offset int) ([]models.Investment, error) {
-	var invs []models.Investment
-	err := r.db.Where("user_id = ?", userID).
-		Order("date desc").
-		Limit(limit).
-		Offset(offset).
-		Find(&invs).Error
-	return invs, err
-}
-
 func (r *Repository) GetInvestmentByID(id, userID uint) (*models.Investment, error) {
 	var inv models.Investment
-	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&inv).Error
+	err := r.db.Preload("Tags").Where("id = ? AND user_id = ?", id, userID).First(&inv).Error
 	if err != nil {
 		return nil, err
 	}
 	return &inv, nil
 }
 
+// UpdateInvestment - сохраняет инвестицию, метки заменяются на inv.Tags
 func (r *Repository) UpdateInvestment(inv *models.Investment) error {
-	return r.db.Save(inv).Error
+	return r.db.Transaction(func(db *gorm.DB) error {
+		if err := db.Omit("Tags").Save(inv).Error; err != nil {
+			return err
+		}
+		return db.Model(inv).Association("Tags").Replace(inv.Tags)
+	})
 }
 
+// DeleteInvestment - переносит инвестицию в корзину
 func (r *Repository) DeleteInvestment(id, userID uint) error {
 	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Investment{}).Error
 }
@@ -125,32 +332,26 @@ func (r *Repository) CreateDeposit(dep *models.Deposit) error {
 	return r.db.Create(dep).Error
 }
 
-func (r *Repository) GetDeposits(userID uint, limit, offset int, activeOnly bool) ([]models.Deposit, error) {
-	var deps []models.Deposit
-	query := r.db.Where("user_id = ?", userID)
-	if activeOnly {
-		query = query.Where("close_date IS NULL")
-	}
-	err := query.Order("open_date desc").
-		Limit(limit).
-		Offset(offset).
-		Find(&deps).Error
-	return deps, err
-}
-
 func (r *Repository) GetDepositByID(id, userID uint) (*models.Deposit, error) {
 	var dep models.Deposit
-	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&dep).Error
+	err := r.db.Preload("Tags").Where("id = ? AND user_id = ?", id, userID).First(&dep).Error
 	if err != nil {
 		return nil, err
 	}
 	return &dep, nil
 }
 
+// UpdateDeposit - сохраняет вклад, метки заменяются на dep.Tags
 func (r *Repository) UpdateDeposit(dep *models.Deposit) error {
-	return r.db.Save(dep).Error
+	return r.db.Transaction(func(db *gorm.DB) error {
+		if err := db.Omit("Tags").Save(dep).Error; err != nil {
+			return err
+		}
+		return db.Model(dep).Association("Tags").Replace(dep.Tags)
+	})
 }
 
+// DeleteDeposit - переносит вклад в корзину
 func (r *Repository) DeleteDeposit(id, userID uint) error {
 	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Deposit{}).Error
 }
@@ -178,24 +379,27 @@ func (r *Repository) CreateNotification(n *models.Notification) error {
 	return r.db.Create(n).Error
 }
 
-func (r *Repository) GetNotifications(userID uint, limit int, unreadOnly bool) ([]models.Notification, error) {
-	var notifications []models.Notification
-	query := r.db.Where("user_id = ?", userID)
-	if unreadOnly {
-		query = query.Where("is_read = ?", false)
-	}
-	err := query.Order("created_at desc").Limit(limit).Find(&notifications).Error
-	return notifications, err
-}
-
-func (r *Repository) MarkNotificationAsRead(userID uint, notificationID uint) error {
-	return r.db.Model(&models.Notification{}).
-		Where("id = ? AND user_id = ?", notificationID, userID).
-		Update("is_read", true).Error
+// MarkNotificationAsRead - помечает уведомление прочитанным; false - уведомления нет или оно уже прочитано
+func (r *Repository) MarkNotificationAsRead(userID uint, notificationID uint) (bool, error) {
+	result := r.db.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND is_read = ?", notificationID, userID, false).
+		Update("is_read", true)
+	return result.RowsAffected > 0, result.Error
 }
 
-func (r *Repository) MarkAllNotificationsAsRead(userID uint) error {
-	return r.db.Model(&models.Notification{}).
-		Where("user_id = ? AND is_read = ?", userID, false).
-		Update("is_read", true).Error
+// MarkAllNotificationsAsRead - помечает прочитанными все уведомления; возвращает id помеченных
+func (r *Repository) MarkAllNotificationsAsRead(userID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Notification{}).
+			Where("user_id = ? AND is_read = ?", userID, false).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Model(&models.Notification{}).Where("id IN ?", ids).Update("is_read", true).Error
+	})
+	return ids, err
 }
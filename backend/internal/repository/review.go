@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"clarity/internal/models"
+)
+
+// GetReviewQueue - транзакции, категория которых ждет проверки (новые первыми), и их общее число
+func (r *Repository) GetReviewQueue(userID uint, limit, offset int) ([]models.Transaction, int64, error) {
+	query := r.db.Model(&models.Transaction{}).Where("user_id = ? AND needs_review = ?", userID, true)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var txs []models.Transaction
+	err := query.Preload("Tags").Order("date desc, id desc").Limit(limit).Offset(offset).Find(&txs).Error
+	return txs, total, err
+}
+
+// ReviewQueueIDs - транзакции из очереди проверки среди ids (пустой список - вся очередь)
+func (r *Repository) ReviewQueueIDs(userID uint, ids []uint) ([]uint, error) {
+	query := r.db.Model(&models.Transaction{}).Where("user_id = ? AND needs_review = ?", userID, true)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	var queued []uint
+	err := query.Order("id asc").Pluck("id", &queued).Error
+	return queued, err
+}
+
+// ConfirmReview - подтверждает категории транзакций ids из очереди проверки (см. ReviewQueueIDs).
+// Источник и уверенность категоризации сохраняются. Возвращает число подтвержденных транзакций.
+func (r *Repository) ConfirmReview(userID uint, ids []uint) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.Model(&models.Transaction{}).
+		Where("user_id = ? AND needs_review = ? AND id IN ?", userID, true, ids).
+		Update("needs_review", false)
+	return result.RowsAffected, result.Error
+}
+
+// GetTransactionsByIDs - транзакции пользователя из списка ids (чужие и несуществующие пропускаются)
+func (r *Repository) GetTransactionsByIDs(userID uint, ids []uint) ([]models.Transaction, error) {
+	var txs []models.Transaction
+	err := r.db.Preload("Splits").Where("user_id = ? AND id IN ?", userID, ids).Order("id asc").Find(&txs).Error
+	return txs, err
+}
+
+// UpdateTransactionCategory - сохраняет категорию транзакции, признак обязательности и сведения о категоризации
+func (r *Repository) UpdateTransactionCategory(tx *models.Transaction) error {
+	return r.db.Model(tx).
+		Select("category", "is_essential", "category_source", "category_confidence", "needs_review").
+		Updates(tx).Error
+}
+
+// GetCategoryExplanation - объяснение категории, сохраненное при создании транзакции
+// (nil - транзакция создана до сохранения объяснений или без категоризации)
+func (r *Repository) GetCategoryExplanation(transactionID uint) (*models.CategoryExplanation, error) {
+	var explanation models.CategoryExplanation
+	err := r.db.Where("transaction_id = ?", transactionID).Limit(1).Find(&explanation).Error
+	if err != nil || explanation.ID == 0 {
+		return nil, err
+	}
+	return &explanation, nil
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"clarity/internal/models"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// seedDefaultRules - создает пользователю правила по умолчанию (models.DefaultCategoryRules)
+func seedDefaultRules(db *gorm.DB, userID uint) error {
+	rules := make([]models.CategoryRule, len(models.DefaultCategoryRules))
+	copy(rules, models.DefaultCategoryRules)
+	for i := range rules {
+		rules[i].UserID = userID
+		rules[i].Enabled = true
+	}
+	return db.Create(&rules).Error
+}
+
+// Category rule CRUD
+func (r *Repository) CreateCategoryRule(rule *models.CategoryRule) error {
+	return r.db.Create(rule).Error
+}
+
+// GetCategoryRules - правила пользователя в порядке проверки
+func (r *Repository) GetCategoryRules(userID uint) ([]models.CategoryRule, error) {
+	var rules []models.CategoryRule
+	err := r.db.Where("user_id = ?", userID).Order("priority asc, id asc").Find(&rules).Error
+	return rules, err
+}
+
+func (r *Repository) GetCategoryRuleByID(id, userID uint) (*models.CategoryRule, error) {
+	var rule models.CategoryRule
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *Repository) UpdateCategoryRule(rule *models.CategoryRule) error {
+	return r.db.Save(rule).Error
+}
+
+func (r *Repository) DeleteCategoryRule(id, userID uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.CategoryRule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Tags
+
+// ErrTagExists - метка с таким именем уже есть у пользователя
+var ErrTagExists = errors.New("tag already exists")
+
+// TagUsage - метка и число транзакций, инвестиций и вкладов с ней
+type TagUsage struct {
+	models.Tag
+	Transactions int `json:"transactions"`
+	Investments  int `json:"investments"`
+	Deposits     int `json:"deposits"`
+}
+
+func (r *Repository) GetTags(userID uint) ([]TagUsage, error) {
+	var tags []TagUsage
+	err := r.db.Model(&models.Tag{}).
+		Select("tags.*, "+
+			"(SELECT COUNT(*) FROM transaction_tags JOIN transactions ON transactions.id = transaction_tags.transaction_id "+
+			"WHERE transaction_tags.tag_id = tags.id AND transactions.deleted_at IS NULL) AS transactions, "+
+			"(SELECT COUNT(*) FROM investment_tags JOIN investments ON investments.id = investment_tags.investment_id "+
+			"WHERE investment_tags.tag_id = tags.id AND investments.deleted_at IS NULL) AS investments, "+
+			"(SELECT COUNT(*) FROM deposit_tags JOIN deposits ON deposits.id = deposit_tags.deposit_id "+
+			"WHERE deposit_tags.tag_id = tags.id AND deposits.deleted_at IS NULL) AS deposits").
+		Where("tags.user_id = ?", userID).
+		Order("tags.name asc").
+		Scan(&tags).Error
+	return tags, err
+}
+
+func (r *Repository) GetTagByID(id, userID uint) (*models.Tag, error) {
+	var tag models.Tag
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&tag).Error
+	return &tag, err
+}
+
+// SaveTag - создает метку или переименовывает ее; имя уникально без учета регистра
+func (r *Repository) SaveTag(tag *models.Tag) error {
+	var count int64
+	if err := r.db.Model(&models.Tag{}).
+		Where("user_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", tag.UserID, tag.Name, tag.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTagExists
+	}
+	return r.db.Save(tag).Error
+}
+
+// DeleteTag - удаляет метку и снимает ее со всех транзакций, инвестиций и вкладов
+func (r *Repository) DeleteTag(id, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var tag models.Tag
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&tag).Error; err != nil {
+			return err
+		}
+		for _, table := range []string{"transaction_tags", "investment_tags", "deposit_tags"} {
+			if err := tx.Exec("DELETE FROM "+table+" WHERE tag_id = ?", tag.ID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&tag).Error
+	})
+}
+
+// withTags - только записи table, у которых есть каждая из меток (имя без учета регистра);
+// joinTable и column - таблица связи с метками и ее колонка со ссылкой на запись
+func withTags(query *gorm.DB, joinTable, column, table string, tags []string) *gorm.DB {
+	for _, name := range tags {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		query = query.Where("EXISTS (SELECT 1 FROM "+joinTable+" JOIN tags ON tags.id = "+joinTable+".tag_id "+
+			"WHERE "+joinTable+"."+column+" = "+table+".id AND LOWER(tags.name) = LOWER(?))", name)
+	}
+	return query
+}
@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"clarity/internal/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// searchVectorExpr - документ транзакции для полнотекстового поиска. Конфигурация russian
+// стеммит кириллицу русским стеммером, а латиницу - английским; ref_no (коды, номера)
+// индексируется без стемминга. Вес: описание > продавец > категория > ref_no.
+const searchVectorExpr = "setweight(to_tsvector('russian', COALESCE(description, '')), 'A') || " +
+	"setweight(to_tsvector('russian', COALESCE(merchant, '')), 'B') || " +
+	"setweight(to_tsvector('russian', COALESCE(category, '')), 'C') || " +
+	"setweight(to_tsvector('simple', COALESCE(ref_no, '')), 'D')"
+
+// searchTextExpr - текст транзакции для поиска по подстроке и с опечатками (триграммы)
+const searchTextExpr = "(COALESCE(description, '') || ' ' || COALESCE(ref_no, '') || ' ' || " +
+	"COALESCE(merchant, '') || ' ' || COALESCE(category, ''))"
+
+// searchQueryExpr - запрос в синтаксисе веб-поиска: слова, "фраза", -исключение, or
+const searchQueryExpr = "websearch_to_tsquery('russian', ?)"
+
+// Порядок результатов поиска
+const (
+	SearchSortRelevance  = "relevance"
+	SearchSortDateDesc   = "date_desc"
+	SearchSortDateAsc    = "date_asc"
+	SearchSortAmountDesc = "amount_desc"
+	SearchSortAmountAsc  = "amount_asc"
+)
+
+// searchSorts - выражение ключа сортировки и направление
+var searchSorts = map[string]struct {
+	key  string
+	desc bool
+}{
+	SearchSortRelevance:  {"rank", true},
+	SearchSortDateDesc:   {"date", true},
+	SearchSortDateAsc:    {"date", false},
+	SearchSortAmountDesc: {"amount_key", true},
+	SearchSortAmountAsc:  {"amount_key", false},
+}
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")                  // Курсор поврежден или получен для другого порядка
+	ErrInvalidSort   = errors.New("invalid sort")                    // Неизвестный порядок сортировки
+	ErrSortNeedsText = errors.New("relevance sort requires a query") // Сортировка по релевантности без текста
+)
+
+// TransactionSearch - запрос поиска транзакций. Пустые поля не фильтруют.
+type TransactionSearch struct {
+	Query       string // Слова с учетом словоформ и подстрока описания, ref_no, продавца или категории
+	Type        string
+	Category    string // Категория транзакции или одной из частей разбивки
+	IsEssential *bool
+	Tags        []string // Нужны все метки
+	AccountID   uint
+	MinAmount   *models.Money // Модуль суммы в базовой валюте
+	MaxAmount   *models.Money
+	StartDate   string // YYYY-MM-DD
+	EndDate     string
+	Sort        string // По умолчанию relevance при заданном Query, иначе date_desc
+	Limit       int
+	Cursor      string // next_cursor предыдущей страницы
+}
+
+// SearchHit - найденная транзакция с релевантностью и подсветкой совпадений
+type SearchHit struct {
+	models.Transaction
+	Rank       float64           `json:"rank,omitempty"`
+	Highlights map[string]string `json:"highlights,omitempty"` // Поле -> HTML-экранированный текст с <mark>...</mark>
+}
+
+// searchCursor - позиция последней строки страницы в порядке сортировки
+type searchCursor struct {
+	Sort   string     `json:"s"`
+	Date   *time.Time `json:"d,omitempty"`
+	Amount *int64     `json:"a,omitempty"`
+	Rank   *float64   `json:"r,omitempty"`
+	ID     uint       `json:"id"`
+}
+
+// searchRow - строка страницы до загрузки транзакций
+type searchRow struct {
+	ID        uint
+	Date      time.Time
+	AmountKey int64
+	Rank      float64
+}
+
+var (
+	trigramOnce      sync.Once
+	trigramAvailable bool
+)
+
+// hasTrigram - установлено ли расширение pg_trgm (см. ensureTransactionSearch)
+func hasTrigram(db *gorm.DB) bool {
+	trigramOnce.Do(func() {
+		var count int64
+		db.Raw("SELECT COUNT(*) FROM pg_extension WHERE extname = 'pg_trgm'").Scan(&count)
+		trigramAvailable = count > 0
+	})
+	return trigramAvailable
+}
+
+// SearchTransactions - поиск транзакций пользователя: полнотекстовый (tsvector) и по
+// подстроке/с опечатками (pg_trgm) вместе со структурными фильтрами. Страницы - по курсору
+// (keyset по ключу сортировки и id); nextCursor пуст на последней странице.
+func (r *Repository) SearchTransactions(userID uint, search TransactionSearch) ([]SearchHit, string, error) {
+	text := strings.TrimSpace(search.Query)
+	if search.Sort == "" {
+		search.Sort = SearchSortDateDesc
+		if text != "" {
+			search.Sort = SearchSortRelevance
+		}
+	}
+	sort, ok := searchSorts[search.Sort]
+	if !ok {
+		return nil, "", ErrInvalidSort
+	}
+	if search.Sort == SearchSortRelevance && text == "" {
+		return nil, "", ErrSortNeedsText
+	}
+
+	query, rankExpr, rankArgs := r.searchFilter(userID, search, text)
+	query = query.Select("id, date, ABS(base_amount) AS amount_key, "+rankExpr+" AS rank", rankArgs...)
+
+	page := r.db.Table("(?) AS hits", query)
+	if search.Cursor != "" {
+		cursor, err := decodeSearchCursor(search.Cursor)
+		if err != nil || cursor.Sort != search.Sort {
+			return nil, "", ErrInvalidCursor
+		}
+		var value interface{}
+		switch {
+		case sort.key == "date" && cursor.Date != nil:
+			value = *cursor.Date
+		case sort.key == "amount_key" && cursor.Amount != nil:
+			value = *cursor.Amount
+		case sort.key == "rank" && cursor.Rank != nil:
+			value = *cursor.Rank
+		default:
+			return nil, "", ErrInvalidCursor
+		}
+		op := ">"
+		if sort.desc {
+			op = "<"
+		}
+		page = page.Where("("+sort.key+", id) "+op+" (?, ?)", value, cursor.ID)
+	}
+	direction := " asc"
+	if sort.desc {
+		direction = " desc"
+	}
+	var rows []searchRow
+	if err := page.Order(sort.key + direction + ", id" + direction).Limit(search.Limit + 1).Scan(&rows).Error; err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(rows) > search.Limit {
+		rows = rows[:search.Limit]
+		last := rows[len(rows)-1]
+		cursor := searchCursor{Sort: search.Sort, ID: last.ID}
+		switch sort.key {
+		case "date":
+			cursor.Date = &last.Date
+		case "amount_key":
+			cursor.Amount = &last.AmountKey
+		case "rank":
+			cursor.Rank = &last.Rank
+		}
+		nextCursor = encodeSearchCursor(cursor)
+	}
+
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	var txs []models.Transaction
+	if len(ids) > 0 {
+		if err := r.db.Preload("Splits").Preload("Tags").Where("id IN ?", ids).Find(&txs).Error; err != nil {
+			return nil, "", err
+		}
+	}
+	byID := make(map[uint]models.Transaction, len(txs))
+	for _, tx := range txs {
+		byID[tx.ID] = tx
+	}
+	highlights, err := r.searchHighlights(ids, text)
+	if err != nil {
+		return nil, "", err
+	}
+
+	hits := make([]SearchHit, 0, len(rows))
+	for _, row := range rows {
+		tx, ok := byID[row.ID]
+		if !ok {
+			continue
+		}
+		hits = append(hits, SearchHit{Transaction: tx, Rank: row.Rank, Highlights: highlights[row.ID]})
+	}
+	return hits, nextCursor, nil
+}
+
+// MatchTransactionIDs - id транзакций пользователя под фильтрами поиска по возрастанию, не
+// более limit. Sort, Limit и Cursor не учитываются.
+func (r *Repository) MatchTransactionIDs(userID uint, search TransactionSearch, limit int) ([]uint, error) {
+	query, _, _ := r.searchFilter(userID, search, strings.TrimSpace(search.Query))
+	var ids []uint
+	err := query.Order("id asc").Limit(limit).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// searchFilter - запрос транзакций пользователя под фильтрами поиска и выражение
+// релевантности (с аргументами) для текста text
+func (r *Repository) searchFilter(userID uint, search TransactionSearch, text string) (*gorm.DB, string, []interface{}) {
+	query := withTags(r.db.Table("transactions").Where("user_id = ? AND deleted_at IS NULL", userID),
+		"transaction_tags", "transaction_id", "transactions", search.Tags)
+	rankExpr := "0::float8"
+	var rankArgs []interface{}
+	if text != "" {
+		pattern := "%" + escapeLike(text) + "%"
+		if hasTrigram(r.db) {
+			query = query.Where("(search_vector @@ "+searchQueryExpr+" OR "+searchTextExpr+" ILIKE ? OR ? <% "+searchTextExpr+")", text, pattern, text)
+			rankExpr = "(ts_rank(search_vector, " + searchQueryExpr + ") + 0.1 * word_similarity(?, " + searchTextExpr + "))::float8"
+			rankArgs = []interface{}{text, text}
+		} else {
+			query = query.Where("(search_vector @@ "+searchQueryExpr+" OR "+searchTextExpr+" ILIKE ?)", text, pattern)
+			rankExpr = "(ts_rank(search_vector, " + searchQueryExpr + ") + CASE WHEN " + searchTextExpr + " ILIKE ? THEN 0.1 ELSE 0 END)::float8"
+			rankArgs = []interface{}{text, pattern}
+		}
+	}
+	if search.Type != "" {
+		query = query.Where("type = ?", search.Type)
+	}
+	if search.Category != "" {
+		query = whereTransactionCategory(query, search.Category)
+	}
+	if search.IsEssential != nil {
+		query = query.Where("is_essential = ?", *search.IsEssential)
+	}
+	if search.AccountID != 0 {
+		query = query.Where("account_id = ?", search.AccountID)
+	}
+	if search.MinAmount != nil {
+		query = query.Where("ABS(base_amount) >= ?", int64(*search.MinAmount))
+	}
+	if search.MaxAmount != nil {
+		query = query.Where("ABS(base_amount) <= ?", int64(*search.MaxAmount))
+	}
+	if search.StartDate != "" {
+		query = query.Where("date >= ?", search.StartDate)
+	}
+	if search.EndDate != "" {
+		query = query.Where("date < (?::date + 1)", search.EndDate)
+	}
+	return query, rankExpr, rankArgs
+}
+
+// highlightRow - подсветка словоформ (ts_headline) в полях транзакции; пусто - поле не совпало
+type highlightRow struct {
+	ID          uint
+	Description string
+	RefNo       string
+	Merchant    string
+	Category    string
+	RawDesc     string
+	RawRefNo    string
+	RawMerchant string
+	RawCategory string
+}
+
+// searchHighlights - подсветка совпадений в описании, ref_no, продавце и категории. Совпадения
+// по словоформам размечает ts_headline, совпадения только по подстроке - регулярное выражение.
+// Текст экранируется как HTML, совпадения обрамляются <mark></mark>.
+func (r *Repository) searchHighlights(ids []uint, text string) (map[uint]map[string]string, error) {
+	result := make(map[uint]map[string]string)
+	if len(ids) == 0 || text == "" {
+		return result, nil
+	}
+
+	var args []interface{}
+	headline := func(config, column string) string {
+		args = append(args, text, text)
+		escaped := "replace(replace(replace(COALESCE(" + column + ", ''), '&', '&amp;'), '<', '&lt;'), '>', '&gt;')"
+		return "CASE WHEN to_tsvector('" + config + "', COALESCE(" + column + ", '')) @@ websearch_to_tsquery('" + config + "', ?) " +
+			"THEN ts_headline('" + config + "', " + escaped + ", websearch_to_tsquery('" + config + "', ?), " +
+			"'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') ELSE '' END"
+	}
+	selectSQL := "id, " +
+		headline("russian", "description") + " AS description, " +
+		headline("simple", "ref_no") + " AS ref_no, " +
+		headline("russian", "merchant") + " AS merchant, " +
+		headline("russian", "category") + " AS category, " +
+		"description AS raw_desc, ref_no AS raw_ref_no, merchant AS raw_merchant, category AS raw_category"
+	var rows []highlightRow
+	err := r.db.Table("transactions").Select(selectSQL, args...).Where("id IN ?", ids).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	substring := regexp.MustCompile("(?i)" + regexp.QuoteMeta(text))
+	for _, row := range rows {
+		fields := map[string]string{}
+		for _, field := range []struct{ name, headline, raw string }{
+			{"description", row.Description, row.RawDesc},
+			{"ref_no", row.RefNo, row.RawRefNo},
+			{"merchant", row.Merchant, row.RawMerchant},
+			{"category", row.Category, row.RawCategory},
+		} {
+			if field.headline != "" {
+				fields[field.name] = field.headline
+			} else if marked, ok := markSubstring(substring, field.raw); ok {
+				fields[field.name] = marked
+			}
+		}
+		if len(fields) > 0 {
+			result[row.ID] = fields
+		}
+	}
+	return result, nil
+}
+
+// markSubstring - HTML-экранированный text с <mark> вокруг совпадений pattern
+func markSubstring(pattern *regexp.Regexp, text string) (string, bool) {
+	matches := pattern.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		b.WriteString(html.EscapeString(text[last:match[0]]))
+		b.WriteString("<mark>" + html.EscapeString(text[match[0]:match[1]]) + "</mark>")
+		last = match[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String(), true
+}
+
+// escapeLike - экранирует спецсимволы LIKE (\, %, _)
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func encodeSearchCursor(cursor searchCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSearchCursor(s string) (searchCursor, error) {
+	var cursor searchCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
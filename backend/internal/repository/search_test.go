@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearchCursorRoundTrip(t *testing.T) {
+	date := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	amount := int64(-123456)
+	rank := 0.375
+	cursors := []searchCursor{
+		{Sort: "date_desc", Date: &date, ID: 10},
+		{Sort: "amount_asc", Amount: &amount, ID: 11},
+		{Sort: "relevance", Rank: &rank, Date: &date, ID: 12},
+	}
+	for _, cursor := range cursors {
+		encoded := encodeSearchCursor(cursor)
+		decoded, err := decodeSearchCursor(encoded)
+		if err != nil {
+			t.Errorf("decodeSearchCursor(%q): %v", encoded, err)
+			continue
+		}
+		if decoded.Sort != cursor.Sort || decoded.ID != cursor.ID ||
+			(decoded.Date == nil) != (cursor.Date == nil) || decoded.Date != nil && !decoded.Date.Equal(*cursor.Date) ||
+			(decoded.Amount == nil) != (cursor.Amount == nil) || decoded.Amount != nil && *decoded.Amount != *cursor.Amount ||
+			(decoded.Rank == nil) != (cursor.Rank == nil) || decoded.Rank != nil && *decoded.Rank != *cursor.Rank {
+			t.Errorf("round trip %+v = %+v", cursor, decoded)
+		}
+	}
+}
+
+func TestDecodeSearchCursorInvalid(t *testing.T) {
+	for _, value := range []string{"not base64!", "bm90IGpzb24", "e30=="} {
+		if _, err := decodeSearchCursor(value); err == nil {
+			t.Errorf("decodeSearchCursor(%q) succeeded, want error", value)
+		}
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got := escapeLike(`100%_off\`); got != `100\%\_off\\` {
+		t.Errorf("escapeLike = %q", got)
+	}
+}
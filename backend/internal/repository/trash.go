@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"clarity/internal/models"
+	"errors"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Типы записей в корзине
+const (
+	TrashTransaction = "transaction"
+	TrashTransfer    = "transfer" // Перевод вместе с обеими ногами
+	TrashInvestment  = "investment"
+	TrashDeposit     = "deposit"
+)
+
+// ErrUnknownTrashType - неизвестный тип записи корзины
+var ErrUnknownTrashType = errors.New("unknown trash item type")
+
+// TrashItem - удаленная запись в корзине
+type TrashItem struct {
+	Type        string       `json:"type"`
+	ID          uint         `json:"id"`
+	Date        time.Time    `json:"date"`
+	Amount      models.Money `json:"amount"`
+	Currency    string       `json:"currency"`
+	Description string       `json:"description"`
+	Category    string       `json:"category,omitempty"` // Категория транзакции или тип инвестиции
+	DeletedAt   time.Time    `json:"deleted_at"`
+}
+
+// trashSelection - записи корзины для окончательного удаления
+type trashSelection struct {
+	Transactions []uint // Без ног переводов: они удаляются вместе с переводом
+	Transfers    []uint
+	Investments  []uint
+	Deposits     []uint
+}
+
+func (s trashSelection) count() int {
+	return len(s.Transactions) + len(s.Transfers) + len(s.Investments) + len(s.Deposits)
+}
+
+// PurgedRecord - окончательно удаленная запись для журнала изменений: тип записи журнала
+// (models.AuditEntity*) и ее владелец. Перевод представлен своими ногами.
+type PurgedRecord struct {
+	UserID     uint
+	EntityType string
+	ID         uint
+}
+
+// GetTrash - корзина пользователя, недавно удаленные первыми; itemType - только записи этого типа
+func (r *Repository) GetTrash(userID uint, itemType string) ([]TrashItem, error) {
+	if itemType != "" && itemType != TrashTransaction && itemType != TrashTransfer && itemType != TrashInvestment && itemType != TrashDeposit {
+		return nil, ErrUnknownTrashType
+	}
+	trashed := func(model interface{}) *gorm.DB {
+		return r.db.Unscoped().Model(model).Where("user_id = ? AND deleted_at IS NOT NULL", userID)
+	}
+
+	items := []TrashItem{}
+	if itemType == "" || itemType == TrashTransaction {
+		var txs []models.Transaction
+		if err := trashed(&models.Transaction{}).Where("transfer_id IS NULL").Find(&txs).Error; err != nil {
+			return nil, err
+		}
+		for _, tx := range txs {
+			items = append(items, TrashItem{Type: TrashTransaction, ID: tx.ID, Date: tx.Date, Amount: tx.Amount, Currency: tx.Currency,
+				Description: tx.Description, Category: tx.Category, DeletedAt: tx.DeletedAt.Time})
+		}
+	}
+	if itemType == "" || itemType == TrashTransfer {
+		var transfers []models.Transfer
+		if err := trashed(&models.Transfer{}).Find(&transfers).Error; err != nil {
+			return nil, err
+		}
+		for _, tr := range transfers {
+			items = append(items, TrashItem{Type: TrashTransfer, ID: tr.ID, Date: tr.Date, Amount: tr.Amount, Currency: tr.Currency,
+				Description: tr.Description, DeletedAt: tr.DeletedAt.Time})
+		}
+	}
+	if itemType == "" || itemType == TrashInvestment {
+		var invs []models.Investment
+		if err := trashed(&models.Investment{}).Find(&invs).Error; err != nil {
+			return nil, err
+		}
+		for _, inv := range invs {
+			items = append(items, TrashItem{Type: TrashInvestment, ID: inv.ID, Date: inv.Date, Amount: inv.Amount, Currency: inv.Currency,
+				Description: inv.Description, Category: inv.Type, DeletedAt: inv.DeletedAt.Time})
+		}
+	}
+	if itemType == "" || itemType == TrashDeposit {
+		var deps []models.Deposit
+		if err := trashed(&models.Deposit{}).Find(&deps).Error; err != nil {
+			return nil, err
+		}
+		for _, dep := range deps {
+			items = append(items, TrashItem{Type: TrashDeposit, ID: dep.ID, Date: dep.OpenDate, Amount: dep.Amount, Currency: dep.Currency,
+				Description: dep.Description, DeletedAt: dep.DeletedAt.Time})
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].DeletedAt.Equal(items[j].DeletedAt) {
+			return items[i].DeletedAt.After(items[j].DeletedAt)
+		}
+		return items[i].ID > items[j].ID
+	})
+	return items, nil
+}
+
+// RestoreTrashItem - возвращает запись из корзины; перевод восстанавливается с обеими ногами.
+// gorm.ErrRecordNotFound - такой записи в корзине нет.
+func (r *Repository) RestoreTrashItem(userID uint, itemType string, id uint) error {
+	return r.db.Transaction(func(db *gorm.DB) error {
+		restore := func(model interface{}, where string, args ...interface{}) (int64, error) {
+			result := db.Unscoped().Model(model).
+				Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+				Where(where, args...).
+				Update("deleted_at", nil)
+			return result.RowsAffected, result.Error
+		}
+
+		var restored int64
+		var err error
+		switch itemType {
+		case TrashTransaction:
+			restored, err = restore(&models.Transaction{}, "id = ? AND transfer_id IS NULL", id)
+		case TrashTransfer:
+			if restored, err = restore(&models.Transfer{}, "id = ?", id); err == nil && restored > 0 {
+				_, err = restore(&models.Transaction{}, "transfer_id = ?", id)
+			}
+		case TrashInvestment:
+			restored, err = restore(&models.Investment{}, "id = ?", id)
+		case TrashDeposit:
+			restored, err = restore(&models.Deposit{}, "id = ?", id)
+		default:
+			return ErrUnknownTrashType
+		}
+		if err != nil {
+			return err
+		}
+		if restored == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// PurgeTrashItem - окончательно удаляет запись из корзины и возвращает удаленные записи для
+// журнала. gorm.ErrRecordNotFound - такой записи в корзине нет.
+func (r *Repository) PurgeTrashItem(userID uint, itemType string, id uint) ([]PurgedRecord, error) {
+	var model interface{}
+	var selection trashSelection
+	switch itemType {
+	case TrashTransaction:
+		model, selection.Transactions = &models.Transaction{}, []uint{id}
+	case TrashTransfer:
+		model, selection.Transfers = &models.Transfer{}, []uint{id}
+	case TrashInvestment:
+		model, selection.Investments = &models.Investment{}, []uint{id}
+	case TrashDeposit:
+		model, selection.Deposits = &models.Deposit{}, []uint{id}
+	default:
+		return nil, ErrUnknownTrashType
+	}
+
+	query := r.db.Unscoped().Model(model).Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", id, userID)
+	if itemType == TrashTransaction {
+		query = query.Where("transfer_id IS NULL")
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var records []PurgedRecord
+	err := r.db.Transaction(func(db *gorm.DB) error {
+		var err error
+		records, err = purge(db, selection)
+		return err
+	})
+	return records, err
+}
+
+// EmptyTrash - окончательно удаляет всю корзину пользователя; возвращает число записей
+// корзины (перевод - одна запись) и удаленные записи для журнала
+func (r *Repository) EmptyTrash(userID uint) (int, []PurgedRecord, error) {
+	return r.purgeTrash("user_id = ?", userID)
+}
+
+// PurgeTrashBefore - окончательно удаляет записи всех пользователей, удаленные в корзину
+// раньше cutoff (плановая очистка по сроку хранения)
+func (r *Repository) PurgeTrashBefore(cutoff time.Time) (int, []PurgedRecord, error) {
+	return r.purgeTrash("deleted_at < ?", cutoff)
+}
+
+// purgeTrash - окончательно удаляет записи корзины, подходящие под условие where
+func (r *Repository) purgeTrash(where string, args ...interface{}) (int, []PurgedRecord, error) {
+	var selection trashSelection
+	var records []PurgedRecord
+	err := r.db.Transaction(func(db *gorm.DB) error {
+		trashed := func(model interface{}) *gorm.DB {
+			return db.Unscoped().Model(model).Where("deleted_at IS NOT NULL").Where(where, args...)
+		}
+		if err := trashed(&models.Transaction{}).Where("transfer_id IS NULL").Pluck("id", &selection.Transactions).Error; err != nil {
+			return err
+		}
+		if err := trashed(&models.Transfer{}).Pluck("id", &selection.Transfers).Error; err != nil {
+			return err
+		}
+		if err := trashed(&models.Investment{}).Pluck("id", &selection.Investments).Error; err != nil {
+			return err
+		}
+		if err := trashed(&models.Deposit{}).Pluck("id", &selection.Deposits).Error; err != nil {
+			return err
+		}
+		var err error
+		records, err = purge(db, selection)
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return selection.count(), records, nil
+}
+
+// purge - окончательное удаление записей вместе с разбивкой, метками и объяснениями категорий;
+// возвращает удаленные записи для журнала
+func purge(db *gorm.DB, selection trashSelection) ([]PurgedRecord, error) {
+	transactionIDs := selection.Transactions
+	if len(selection.Transfers) > 0 {
+		var legs []uint
+		if err := db.Unscoped().Model(&models.Transaction{}).Where("transfer_id IN ?", selection.Transfers).
+			Pluck("id", &legs).Error; err != nil {
+			return nil, err
+		}
+		transactionIDs = append(transactionIDs, legs...)
+	}
+
+	var records []PurgedRecord
+	for _, group := range []struct {
+		model      interface{}
+		entityType string
+		ids        []uint
+	}{
+		{&models.Transaction{}, models.AuditEntityTransaction, transactionIDs},
+		{&models.Investment{}, models.AuditEntityInvestment, selection.Investments},
+		{&models.Deposit{}, models.AuditEntityDeposit, selection.Deposits},
+	} {
+		if len(group.ids) == 0 {
+			continue
+		}
+		var owned []PurgedRecord
+		if err := db.Unscoped().Model(group.model).Select("id, user_id").Where("id IN ?", group.ids).
+			Order("id asc").Scan(&owned).Error; err != nil {
+			return nil, err
+		}
+		for i := range owned {
+			owned[i].EntityType = group.entityType
+		}
+		records = append(records, owned...)
+	}
+
+	if len(transactionIDs) > 0 {
+		if err := db.Where("transaction_id IN ?", transactionIDs).Delete(&models.TransactionSplit{}).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Exec("DELETE FROM transaction_tags WHERE transaction_id IN ?", transactionIDs).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("transaction_id IN ?", transactionIDs).Delete(&models.CategoryExplanation{}).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Unscoped().Where("id IN ?", transactionIDs).Delete(&models.Transaction{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(selection.Transfers) > 0 {
+		if err := db.Unscoped().Where("id IN ?", selection.Transfers).Delete(&models.Transfer{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(selection.Investments) > 0 {
+		if err := db.Exec("DELETE FROM investment_tags WHERE investment_id IN ?", selection.Investments).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Unscoped().Where("id IN ?", selection.Investments).Delete(&models.Investment{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(selection.Deposits) > 0 {
+		if err := db.Exec("DELETE FROM deposit_tags WHERE deposit_id IN ?", selection.Deposits).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Unscoped().Where("id IN ?", selection.Deposits).Delete(&models.Deposit{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"clarity/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Правило знака (см. models.Transaction.NormalizeSign): доходы хранятся положительными,
+// расходы - отрицательными. Все агрегаты ниже возвращают неотрицательные суммы доходов
+// и расходов в базовой валюте, поэтому ABS() и CASE по типу в запросах не нужны.
+const (
+	incomeSumSQL    = "COALESCE(SUM(base_amount) FILTER (WHERE type = 'income'), 0)"
+	expenseSumSQL   = "COALESCE(-SUM(base_amount) FILTER (WHERE type = 'expense'), 0)"
+	essentialSumSQL = "COALESCE(-SUM(base_amount) FILTER (WHERE type = 'expense' AND is_essential = true), 0)"
+	// Непроверенные: категория транзакции ждет проверки (needs_review)
+	unreviewedSumSQL   = "COALESCE(-SUM(base_amount) FILTER (WHERE type = 'expense' AND needs_review = true), 0)"
+	unreviewedCountSQL = "COUNT(DISTINCT id) FILTER (WHERE needs_review = true)"
+)
+
+// linesSQL - строки для агрегатов: транзакция без разбивки дает одну строку,
+// транзакция с разбивкой - по строке на каждую часть со своей категорией и признаком обязательности.
+// Суммы частей равны сумме транзакции, поэтому итоги доходов и расходов от разбивки не меняются.
+// Транзакции в корзине (deleted_at) в агрегаты не попадают.
+const linesSQL = `(SELECT t.id, t.user_id, t.account_id, t.transfer_id, t.date, t.type, t.needs_review,
+	COALESCE(s.category, t.category) AS category,
+	COALESCE(s.is_essential, t.is_essential) AS is_essential,
+	COALESCE(s.base_amount, t.base_amount) AS base_amount
+FROM transactions t LEFT JOIN transaction_splits s ON s.transaction_id = t.id
+WHERE t.deleted_at IS NULL) AS lines`
+
+// Totals - доходы и расходы за период в базовой валюте
+type Totals struct {
+	Income          models.Money
+	Expense         models.Money
+	Essential       models.Money
+	Unreviewed      models.Money // Расходы, категория которых ждет проверки
+	UnreviewedCount int          // Транзакции (доходы и расходы), ждущие проверки
+}
+
+// NonEssential - необязательные расходы
+func (t Totals) NonEssential() models.Money {
+	return t.Expense - t.Essential
+}
+
+// Net - доходы минус расходы
+func (t Totals) Net() models.Money {
+	return t.Income - t.Expense
+}
+
+// SavingsRate - норма сбережений в процентах (0, если доходов нет)
+func (t Totals) SavingsRate() float64 {
+	if t.Income <= 0 {
+		return 0
+	}
+	return t.Net().Ratio(t.Income) * 100
+}
+
+// CategoryAmount - сумма по категории
+type CategoryAmount struct {
+	Category string
+	Amount   models.Money
+	Count    int // Количество транзакций
+}
+
+// TransactionLines - строки транзакций с учетом разбивки (см. linesSQL)
+func TransactionLines(db *gorm.DB) *gorm.DB {
+	return db.Table(linesSQL)
+}
+
+// CashflowQuery - строки транзакций пользователя без ног переводов между счетами.
+// Запрос можно переиспользовать для нескольких агрегатов.
+func CashflowQuery(db *gorm.DB, userID uint) *gorm.DB {
+	return TransactionLines(db).
+		Where("user_id = ? AND transfer_id IS NULL", userID).
+		Session(&gorm.Session{})
+}
+
+// PeriodQuery - CashflowQuery за период [start, end] (YYYY-MM-DD, пустая граница - без ограничения)
+func PeriodQuery(db *gorm.DB, userID uint, start, end string) *gorm.DB {
+	query := CashflowQuery(db, userID)
+	if start != "" {
+		query = query.Where("date >= ?", start)
+	}
+	if end != "" {
+		query = query.Where("date <= ?", end)
+	}
+	return query.Session(&gorm.Session{})
+}
+
+// SumTotals - доходы, расходы и обязательные расходы по запросу к транзакциям
+func SumTotals(query *gorm.DB) Totals {
+	var totals Totals
+	query.Select(incomeSumSQL + " AS income, " + expenseSumSQL + " AS expense, " + essentialSumSQL + " AS essential, " +
+		unreviewedSumSQL + " AS unreviewed, " + unreviewedCountSQL + " AS unreviewed_count").
+		Scan(&totals)
+	return totals
+}
+
+// PeriodTotals - доходы и расходы пользователя за период
+func PeriodTotals(db *gorm.DB, userID uint, start, end string) Totals {
+	return SumTotals(PeriodQuery(db, userID, start, end))
+}
+
+// ExpenseByCategory - расходы по категориям (по убыванию суммы)
+func ExpenseByCategory(query *gorm.DB) []CategoryAmount {
+	var result []CategoryAmount
+	query.Where("type = 'expense'").
+		Select("category, COALESCE(-SUM(base_amount), 0) AS amount, COUNT(DISTINCT id) AS count").
+		Group("category").
+		Order("amount desc").
+		Scan(&result)
+	return result
+}
+
+// IncomeByCategory - доходы по категориям (по убыванию суммы)
+func IncomeByCategory(query *gorm.DB) []CategoryAmount {
+	var result []CategoryAmount
+	query.Where("type = 'income'").
+		Select("category, COALESCE(SUM(base_amount), 0) AS amount, COUNT(DISTINCT id) AS count").
+		Group("category").
+		Order("amount desc").
+		Scan(&result)
+	return result
+}
+
+// MonthRange - первый и последний день месяца (YYYY-MM) в формате YYYY-MM-DD
+func MonthRange(month string) (string, string) {
+	monthTime, err := time.Parse("2006-01", month)
+	if err != nil {
+		return "", ""
+	}
+	lastDay := monthTime.AddDate(0, 1, 0).AddDate(0, 0, -1)
+	return monthTime.Format("2006-01-02"), lastDay.Format("2006-01-02")
+}
@@ -10,10 +10,11 @@ import (
 
 type AnomalyDetector struct {
 	db *gorm.DB
+	fx *FXService
 }
 
-func NewAnomalyDetector(db *gorm.DB) *AnomalyDetector {
-	return &AnomalyDetector{db: db}
+func NewAnomalyDetector(db *gorm.DB, fx *FXService) *AnomalyDetector {
+	return &AnomalyDetector{db: db, fx: fx}
 }
 
 // AnomalyResult - результат детекции аномалий
@@ -34,54 +35,16 @@ func (a *AnomalyDetector) DetectTransactionAnomaly(userID uint, tx *models.Trans
 		return result
 	}
 
-	// 1. Проверка суммы относительно истории по категории
-	if tx.Category != "" {
-		var categoryTransactions []models.Transaction
-		a.db.Where("user_id = ? AND type = 'expense' AND category = ? AND id != ?", userID, tx.Category, tx.ID).
-			Order("date desc").
-			Limit(30).
-			Find(&categoryTransactions)
-
-		if len(categoryTransactions) >= 3 {
-			// Вычисляем статистики по категории
-			var amounts []float64
-			for _, ct := range categoryTransactions {
-				amounts = append(amounts, math.Abs(ct.Amount))
-			}
-
-			mean := 0.0
-			for _, v := range amounts {
-				mean += v
-			}
-			mean /= float64(len(amounts))
-
-			variance := 0.0
-			for _, v := range amounts {
-				variance += math.Pow(v-mean, 2)
-			}
-			variance /= float64(len(amounts))
-			stdDev := math.Sqrt(variance)
-
-			if stdDev > 0 && mean > 0 {
-				amountAbs := math.Abs(tx.Amount)
-				zScore := math.Abs((amountAbs - mean) / stdDev)
-				result.ZScore = zScore
-				result.CategoryAvg = mean
-
-				// Z-score > 2.5 = аномалия
-				if zScore > 2.5 {
-					result.IsAnomaly = true
-					result.Reason = "Сумма транзакции значительно превышает среднюю для категории"
-					if zScore > 4.0 {
-						result.Severity = "high"
-					} else if zScore > 3.0 {
-						result.Severity = "medium"
-					} else {
-						result.Severity = "low"
-					}
-					return result
-				}
-			}
+	// 1. Проверка суммы относительно истории по категории (для разбитой транзакции - каждая часть отдельно)
+	for _, line := range tx.Lines() {
+		if line.Category == "" {
+			continue
+		}
+		if categoryResult := a.detectCategoryAnomaly(userID, tx.ID, line); categoryResult.IsAnomaly {
+			return categoryResult
+		} else if categoryResult.CategoryAvg > 0 {
+			result.ZScore = categoryResult.ZScore
+			result.CategoryAvg = categoryResult.CategoryAvg
 		}
 	}
 
@@ -91,18 +54,18 @@ func (a *AnomalyDetector) DetectTransactionAnomaly(userID uint, tx *models.Trans
 		// Проверяем, есть ли история ночных транзакций
 		var nightTransactions int64
 		a.db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'expense' AND EXTRACT(HOUR FROM date) >= 0 AND EXTRACT(HOUR FROM date) < 6", userID).
+			Where("user_id = ? AND type = 'expense' AND transfer_id IS NULL AND EXTRACT(HOUR FROM date) >= 0 AND EXTRACT(HOUR FROM date) < 6", userID).
 			Count(&nightTransactions)
 
 		// Если ночных транзакций меньше 5% от всех, это аномалия
 		var totalTransactions int64
 		a.db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'expense'", userID).
+			Where("user_id = ? AND type = 'expense' AND transfer_id IS NULL", userID).
 			Count(&totalTransactions)
 
 		if totalTransactions > 20 && nightTransactions < int64(float64(totalTransactions)*0.05) {
-			amountAbs := math.Abs(tx.Amount)
-			// Если сумма больше 1000₽, это подозрительно
+			amountAbs := tx.BaseAmount.Abs().Float64()
+			// Если сумма больше 1000 в базовой валюте, это подозрительно
 			if amountAbs > 1000 {
 				result.IsAnomaly = true
 				result.Reason = "Необычно большая транзакция в ночное время"
@@ -113,12 +76,12 @@ func (a *AnomalyDetector) DetectTransactionAnomaly(userID uint, tx *models.Trans
 	}
 
 	// 3. Проверка необычно больших сумм (абсолютный порог)
-	amountAbs := math.Abs(tx.Amount)
-	if amountAbs > 50000 {
+	largeThreshold := models.MoneyFromFloat(50000)
+	if tx.BaseAmount.Abs() > largeThreshold {
 		// Проверяем, были ли такие большие транзакции раньше
 		var largeTransactions int64
 		a.db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'expense' AND ABS(amount) > 50000", userID).
+			Where("user_id = ? AND type = 'expense' AND transfer_id IS NULL AND base_amount < ?", userID, -largeThreshold).
 			Count(&largeTransactions)
 
 		if largeTransactions < 3 {
@@ -132,46 +95,102 @@ func (a *AnomalyDetector) DetectTransactionAnomaly(userID uint, tx *models.Trans
 	return result
 }
 
+// detectCategoryAnomaly - сравнение суммы строки транзакции со статистикой последних 30 строк той же категории
+func (a *AnomalyDetector) detectCategoryAnomaly(userID, txID uint, line models.TransactionSplit) *AnomalyResult {
+	result := &AnomalyResult{IsAnomaly: false}
+
+	var history []struct {
+		BaseAmount models.Money
+	}
+	CashflowQuery(a.db, userID).
+		Where("type = 'expense' AND category = ? AND id != ?", line.Category, txID).
+		Select("base_amount").
+		Order("date desc").
+		Limit(30).
+		Scan(&history)
+
+	if len(history) < 3 {
+		return result
+	}
+
+	// Вычисляем статистики по категории
+	var amounts []float64
+	for _, h := range history {
+		amounts = append(amounts, h.BaseAmount.Abs().Float64())
+	}
+
+	mean := 0.0
+	for _, v := range amounts {
+		mean += v
+	}
+	mean /= float64(len(amounts))
+
+	variance := 0.0
+	for _, v := range amounts {
+		variance += math.Pow(v-mean, 2)
+	}
+	variance /= float64(len(amounts))
+	stdDev := math.Sqrt(variance)
+
+	if stdDev > 0 && mean > 0 {
+		amountAbs := line.BaseAmount.Abs().Float64()
+		zScore := math.Abs((amountAbs - mean) / stdDev)
+		result.ZScore = zScore
+		result.CategoryAvg = mean
+
+		// Z-score > 2.5 = аномалия
+		if zScore > 2.5 {
+			result.IsAnomaly = true
+			result.Reason = "Сумма транзакции значительно превышает среднюю для категории"
+			if zScore > 4.0 {
+				result.Severity = "high"
+			} else if zScore > 3.0 {
+				result.Severity = "medium"
+			} else {
+				result.Severity = "low"
+			}
+		}
+	}
+
+	return result
+}
+
 // CheckCategoryLimit - проверка приближения к лимиту по категории
-func (a *AnomalyDetector) CheckCategoryLimit(userID uint, category string, amount float64, month string) (bool, float64, float64) {
+func (a *AnomalyDetector) CheckCategoryLimit(userID uint, category string, amount models.Money, month string) (bool, models.Money, models.Money) {
 	// Получаем расходы по категории за текущий месяц
 	monthTime, _ := time.Parse("2006-01", month)
-	startDate := month + "-01"
-	lastDay := monthTime.AddDate(0, 1, 0).AddDate(0, 0, -1)
-	endDate := lastDay.Format("2006-01-02")
-
-	var monthExpense float64
-	a.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND category = ? AND date >= ? AND date <= ?", userID, category, startDate, endDate).
-		Select("COALESCE(SUM(ABS(amount)), 0)").
-		Scan(&monthExpense)
+	startDate, endDate := MonthRange(month)
+	monthExpense := SumTotals(PeriodQuery(a.db, userID, startDate, endDate).Where("category = ?", category)).Expense
 
 	// Получаем средний месячный расход по категории за последние 3 месяца
-	var totalExpense3Months float64
+	var totalExpense3Months models.Money
 	threeMonthsAgo := monthTime.AddDate(0, -3, 0).Format("2006-01-02")
-	
-	// Получаем все транзакции за последние 3 месяца для подсчета по месяцам
-	var transactions []models.Transaction
-	a.db.Where("user_id = ? AND type = 'expense' AND category = ? AND date >= ? AND date <= ?", userID, category, threeMonthsAgo, endDate).
-		Order("date asc").
-		Find(&transactions)
 
-	// Группируем по месяцам и считаем средний расход
-	monthlyExpenses := make(map[string]float64)
-	for _, tx := range transactions {
-		monthKey := tx.Date.Format("2006-01")
-		monthlyExpenses[monthKey] += math.Abs(tx.Amount)
+	// Получаем расходы категории (с учетом частей разбитых транзакций) по месяцам за последние 3 месяца
+	var monthlyRows []struct {
+		Month  string
+		Amount models.Money
+	}
+	PeriodQuery(a.db, userID, threeMonthsAgo, endDate).
+		Where("type = 'expense' AND category = ?", category).
+		Select("TO_CHAR(date, 'YYYY-MM') AS month, -SUM(base_amount) AS amount").
+		Group("month").
+		Scan(&monthlyRows)
+
+	monthlyExpenses := make(map[string]models.Money)
+	for _, row := range monthlyRows {
+		monthlyExpenses[row.Month] = row.Amount
 	}
 
 	// Вычисляем средний расход
-	var avgExpense float64
+	var avgExpense models.Money
 	monthCount := len(monthlyExpenses)
-	
+
 	if monthCount > 0 {
 		for _, expense := range monthlyExpenses {
 			totalExpense3Months += expense
 		}
-		avgExpense = totalExpense3Months / float64(monthCount)
+		avgExpense = totalExpense3Months.MulRate(1 / float64(monthCount))
 	} else {
 		// Если нет истории, используем текущий месяц как базу (но только если есть транзакции)
 		if monthExpense > 0 {
@@ -183,10 +202,11 @@ func (a *AnomalyDetector) CheckCategoryLimit(userID uint, category string, amoun
 	}
 
 	// Устанавливаем лимит: 250% от среднего (более разумный порог)
-	// Или минимум 10000₽ для категорий с маленькими расходами
-	limit := avgExpense * 2.5
-	if limit < 10000 && avgExpense > 0 {
-		limit = 10000
+	// Или минимум 10000 (в базовой валюте) для категорий с маленькими расходами
+	limit := avgExpense.MulRate(2.5)
+	minLimit := models.MoneyFromFloat(10000)
+	if limit < minLimit && avgExpense > 0 {
+		limit = minLimit
 	}
 
 	if limit == 0 {
@@ -203,21 +223,17 @@ func (a *AnomalyDetector) CheckCategoryLimit(userID uint, category string, amoun
 }
 
 // CheckCushionDecrease - проверка снижения финансовой подушки
-func (a *AnomalyDetector) CheckCushionDecrease(userID uint, currentBalance float64) (bool, float64, float64) {
+func (a *AnomalyDetector) CheckCushionDecrease(userID uint, currentBalance models.Money) (bool, models.Money, models.Money) {
 	// Получаем баланс за предыдущий месяц
 	now := time.Now()
 	lastMonth := now.AddDate(0, -1, 0)
 	lastDay := lastMonth.AddDate(0, 1, 0).AddDate(0, 0, -1)
 	endDate := lastDay.Format("2006-01-02")
 
-	var lastMonthBalance float64
-	a.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND date <= ?", userID, endDate).
-		Select("COALESCE(SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END), 0)").
-		Scan(&lastMonthBalance)
+	lastMonthBalance := TotalBalance(a.db, a.fx, userID, endDate)
 
 	// Если баланс снизился более чем на 20%
-	if lastMonthBalance > 0 && currentBalance < lastMonthBalance*0.8 {
+	if lastMonthBalance > 0 && currentBalance < lastMonthBalance.MulRate(0.8) {
 		return true, currentBalance, lastMonthBalance
 	}
 
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"bytes"
+	"clarity/internal/models"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Декодеры для миниатюр
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	thumbnailMaxSide   = 320        // Большая сторона миниатюры, px
+	thumbnailMaxPixels = 50_000_000 // Изображения больше не декодируются (защита от "бомб")
+	attachmentNameMax  = 255
+)
+
+// attachmentTypes - допустимые типы вложений (по содержимому файла)
+var attachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+var (
+	ErrAttachmentTooLarge = errors.New("attachment is too large")
+	ErrAttachmentType     = errors.New("unsupported attachment type")
+	ErrAttachmentQuota    = errors.New("attachment quota exceeded")
+)
+
+// AttachmentUsage - занятое вложениями место
+type AttachmentUsage struct {
+	Count   int64 `json:"count"`
+	Used    int64 `json:"used"`     // Байт, вместе с миниатюрами
+	Quota   int64 `json:"quota"`    // Байт на пользователя
+	MaxSize int64 `json:"max_size"` // Предельный размер одного файла, байт
+}
+
+// AttachmentService - вложения: проверка файла и квоты, запись в хранилище, миниатюры
+type AttachmentService struct {
+	db      *gorm.DB
+	store   BlobStore
+	maxSize int64
+	quota   int64
+}
+
+// NewAttachmentService - maxSize - предельный размер файла, quota - суммарный объем
+// вложений пользователя (байт)
+func NewAttachmentService(db *gorm.DB, store BlobStore, maxSize, quota int64) *AttachmentService {
+	return &AttachmentService{db: db, store: store, maxSize: maxSize, quota: quota}
+}
+
+// MaxSize - предельный размер одного файла, байт
+func (s *AttachmentService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Usage - сколько места занимают вложения пользователя
+func (s *AttachmentService) Usage(userID uint) (AttachmentUsage, error) {
+	usage := AttachmentUsage{Quota: s.quota, MaxSize: s.maxSize}
+	err := s.db.Model(&models.Attachment{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(size + thumbnail_size), 0) AS used").
+		Where("user_id = ?", userID).
+		Scan(&usage).Error
+	return usage, err
+}
+
+// Upload - сохраняет файл как вложение записи ownerType/ownerID. Тип определяется по
+// содержимому, заявленный клиентом не учитывается. Для JPEG, PNG и GIF сохраняются размеры
+// изображения и миниатюра. Принадлежность записи пользователю проверяет вызывающий.
+func (s *AttachmentService) Upload(ctx context.Context, userID uint, ownerType string, ownerID uint, fileName string, data []byte) (*models.Attachment, error) {
+	if int64(len(data)) > s.maxSize {
+		return nil, ErrAttachmentTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if !attachmentTypes[contentType] {
+		return nil, fmt.Errorf("%w: %s", ErrAttachmentType, contentType)
+	}
+
+	att := &models.Attachment{
+		UserID:      userID,
+		OwnerType:   ownerType,
+		OwnerID:     ownerID,
+		FileName:    cleanFileName(fileName),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		SHA256:      sha256Hex(data),
+		StorageKey:  fmt.Sprintf("users/%d/%s", userID, randomKey()),
+	}
+	thumbnail := s.describeImage(att, data)
+
+	usage, err := s.Usage(userID)
+	if err != nil {
+		return nil, err
+	}
+	if usage.Used+att.Size+int64(len(thumbnail)) > s.quota {
+		return nil, ErrAttachmentQuota
+	}
+
+	if err := s.store.Put(ctx, att.StorageKey, data, contentType); err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+	if thumbnail != nil {
+		if err := s.store.Put(ctx, att.ThumbnailKey, thumbnail, "image/jpeg"); err != nil {
+			// Без миниатюры вложение остается полноценным
+			att.HasThumbnail, att.ThumbnailKey, att.ThumbnailSize, att.ThumbnailWidth, att.ThumbnailHeight = false, "", 0, 0, 0
+		}
+	}
+	if err := s.db.Create(att).Error; err != nil {
+		s.deleteBlobs(ctx, att)
+		return nil, err
+	}
+	return att, nil
+}
+
+// Open - содержимое вложения (thumbnail - миниатюра); закрыть - задача вызывающего
+func (s *AttachmentService) Open(ctx context.Context, att *models.Attachment, thumbnail bool) (io.ReadCloser, error) {
+	if thumbnail {
+		if !att.HasThumbnail {
+			return nil, ErrBlobNotFound
+		}
+		return s.store.Get(ctx, att.ThumbnailKey)
+	}
+	return s.store.Get(ctx, att.StorageKey)
+}
+
+// Delete - удаляет вложение и его содержимое
+func (s *AttachmentService) Delete(ctx context.Context, att *models.Attachment) error {
+	if err := s.db.Delete(&models.Attachment{}, att.ID).Error; err != nil {
+		return err
+	}
+	s.deleteBlobs(ctx, att)
+	return nil
+}
+
+// PurgeOrphans - удаляет вложения пользователя, чьи транзакции, вклады или инвестиции
+// удалены окончательно (записи в корзине держат свои вложения). Вызывается после удаления
+// записей; возвращает число удаленных вложений.
+func (s *AttachmentService) PurgeOrphans(ctx context.Context, userID uint) (int, error) {
+	return s.purgeOrphans(ctx, s.db.Where("user_id = ?", userID))
+}
+
+// PurgeAllOrphans - PurgeOrphans для всех пользователей (после плановой очистки корзины)
+func (s *AttachmentService) PurgeAllOrphans(ctx context.Context) (int, error) {
+	return s.purgeOrphans(ctx, s.db)
+}
+
+func (s *AttachmentService) purgeOrphans(ctx context.Context, query *gorm.DB) (int, error) {
+	var orphans []models.Attachment
+	err := query.
+		Where("(owner_type = ? AND NOT EXISTS (SELECT 1 FROM transactions WHERE transactions.id = attachments.owner_id)) OR "+
+			"(owner_type = ? AND NOT EXISTS (SELECT 1 FROM deposits WHERE deposits.id = attachments.owner_id)) OR "+
+			"(owner_type = ? AND NOT EXISTS (SELECT 1 FROM investments WHERE investments.id = attachments.owner_id))",
+			models.AttachmentOwnerTransaction, models.AttachmentOwnerDeposit, models.AttachmentOwnerInvestment).
+		Find(&orphans).Error
+	if err != nil {
+		return 0, err
+	}
+	for i := range orphans {
+		if err := s.Delete(ctx, &orphans[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(orphans), nil
+}
+
+// deleteBlobs - удаляет содержимое; ошибки хранилища не возвращаются: строки вложения уже нет,
+// а оставшийся объект недоступен через API
+func (s *AttachmentService) deleteBlobs(ctx context.Context, att *models.Attachment) {
+	_ = s.store.Delete(ctx, att.StorageKey)
+	if att.ThumbnailKey != "" {
+		_ = s.store.Delete(ctx, att.ThumbnailKey)
+	}
+}
+
+// describeImage - размеры изображения и миниатюра в JPEG (nil, если формат не декодируется)
+func (s *AttachmentService) describeImage(att *models.Attachment, data []byte) []byte {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	att.Width, att.Height = config.Width, config.Height
+	if config.Width*config.Height > thumbnailMaxPixels {
+		return nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	thumb := scaleDown(img, thumbnailMaxSide)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80}); err != nil {
+		return nil
+	}
+	att.HasThumbnail = true
+	att.ThumbnailKey = att.StorageKey + ".thumb.jpg"
+	att.ThumbnailSize = int64(buf.Len())
+	att.ThumbnailWidth, att.ThumbnailHeight = thumb.Bounds().Dx(), thumb.Bounds().Dy()
+	return buf.Bytes()
+}
+
+// scaleDown - уменьшает изображение до maxSide по большей стороне усреднением пикселей;
+// маленькие изображения только переводятся в RGBA
+func scaleDown(src image.Image, maxSide int) *image.RGBA {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := w, h
+	if w >= h && w > maxSide {
+		tw, th = maxSide, max(1, h*maxSide/w)
+	} else if h > w && h > maxSide {
+		tw, th = max(1, w*maxSide/h), maxSide
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := bounds.Min.Y+y*h/th, bounds.Min.Y+max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := bounds.Min.X+x*w/tw, bounds.Min.X+max((x+1)*w/tw, x*w/tw+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r/n>>8), uint8(g/n>>8), uint8(b/n>>8), uint8(a/n>>8)
+		}
+	}
+	return dst
+}
+
+// cleanFileName - имя файла без пути и управляющих символов, не длиннее attachmentNameMax
+func cleanFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	for len(name) > attachmentNameMax {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "file"
+	}
+	return name
+}
+
+// randomKey - случайная часть ключа хранилища
+func randomKey() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"bytes"
+	"clarity/internal/models"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// auditIgnored - поля JSON записи, которые не попадают в журнал: не меняются или служебные
+var auditIgnored = []string{"id", "user_id", "created_at"}
+
+// AuditActor - кто и откуда меняет записи пользователя
+type AuditActor struct {
+	UserID  uint   // Владелец записей
+	ActorID *uint  // Кто внес изменение; nil - фоновая задача или система
+	Source  string // models.AuditSource*
+}
+
+// UserActor - изменение, которое пользователь внес сам (через API или импорт)
+func UserActor(userID uint, source string) AuditActor {
+	return AuditActor{UserID: userID, ActorID: &userID, Source: source}
+}
+
+// SystemActor - изменение записей пользователя фоновой задачей или системой
+func SystemActor(userID uint, source string) AuditActor {
+	return AuditActor{UserID: userID, Source: source}
+}
+
+// AuditSnapshot - поля записи для журнала по ее JSON-представлению: метки - отсортированные
+// имена, части разбивки - без идентификаторов. Снимок снимается до изменения записи, потому
+// что обработчики меняют ее на месте.
+func AuditSnapshot(record interface{}) map[string]interface{} {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // Суммы сравниваются и хранятся без потери точности
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil
+	}
+	for _, key := range auditIgnored {
+		delete(fields, key)
+	}
+
+	if tags, ok := fields["tags"].([]interface{}); ok {
+		names := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			if tag, ok := tag.(map[string]interface{}); ok {
+				if name, ok := tag["name"].(string); ok {
+					names = append(names, name)
+				}
+			}
+		}
+		sort.Strings(names)
+		fields["tags"] = names
+	}
+	if splits, ok := fields["splits"].([]interface{}); ok {
+		for _, split := range splits {
+			if split, ok := split.(map[string]interface{}); ok {
+				delete(split, "id")
+				delete(split, "transaction_id")
+			}
+		}
+	}
+	return fields
+}
+
+// AuditDiff - поля, различающиеся в снимках. При создании (before = nil) и удалении
+// (after = nil) пустые значения не попадают в изменения.
+func AuditDiff(before, after map[string]interface{}) map[string]models.AuditChange {
+	changes := make(map[string]models.AuditChange)
+	for key, value := range after {
+		old, ok := before[key]
+		if before == nil && auditEmpty(value) || ok && reflect.DeepEqual(old, value) {
+			continue
+		}
+		changes[key] = models.AuditChange{From: old, To: value}
+	}
+	for key, old := range before {
+		if _, ok := after[key]; ok || after == nil && auditEmpty(old) {
+			continue
+		}
+		changes[key] = models.AuditChange{From: old}
+	}
+	return changes
+}
+
+// auditEmpty - пустое значение JSON: null, "", false, 0 или пустой список
+func auditEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case json.Number:
+		f, err := v.Float64()
+		return err == nil && f == 0
+	case []interface{}:
+		return len(v) == 0
+	case []string:
+		return len(v) == 0
+	}
+	return false
+}
+
+// RecordAudit - добавляет запись в журнал изменений. before - снимок записи до изменения
+// (nil при создании), after - после (nil при удалении). Изменение без различий в полях
+// не записывается; возврат из корзины и окончательное удаление записываются без полей.
+func RecordAudit(db *gorm.DB, actor AuditActor, entityType string, entityID uint, action string, before, after map[string]interface{}) error {
+	entry := models.AuditEntry{
+		UserID:     actor.UserID,
+		ActorID:    actor.ActorID,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Source:     actor.Source,
+	}
+	if before != nil || after != nil {
+		entry.Changes = AuditDiff(before, after)
+	}
+	if action == models.AuditActionUpdate && len(entry.Changes) == 0 {
+		return nil
+	}
+	if len(entry.Changes) == 0 {
+		entry.Changes = nil
+	}
+	return db.Create(&entry).Error
+}
+
+// auditLoadBatch - сколько транзакций загружается за раз для снимков журнала
+const auditLoadBatch = 1000
+
+// RecordTransactionUpdates - выполняет change и записывает в журнал изменения транзакций ids
+// (в том числе в корзине). Снимки до и после снимаются в db, поэтому change должен работать в
+// той же транзакции БД: журнал сохраняется вместе с изменением.
+func RecordTransactionUpdates(db *gorm.DB, actor AuditActor, ids []uint, change func() error) error {
+	before, err := auditTransactions(db, ids)
+	if err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	after, err := auditTransactions(db, ids)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := RecordAudit(db, actor, models.AuditEntityTransaction, id, models.AuditActionUpdate, before[id], after[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditTransactions - снимки транзакций ids с разбивкой и метками по id
+func auditTransactions(db *gorm.DB, ids []uint) (map[uint]map[string]interface{}, error) {
+	snapshots := make(map[uint]map[string]interface{}, len(ids))
+	for start := 0; start < len(ids); start += auditLoadBatch {
+		var txs []models.Transaction
+		if err := db.Unscoped().Preload("Splits").Preload("Tags").
+			Where("id IN ?", ids[start:min(start+auditLoadBatch, len(ids))]).
+			Find(&txs).Error; err != nil {
+			return nil, err
+		}
+		for i := range txs {
+			snapshots[txs[i].ID] = AuditSnapshot(&txs[i])
+		}
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"clarity/internal/models"
+)
+
+func TestAuditSnapshot(t *testing.T) {
+	tx := models.Transaction{
+		ID:          42,
+		UserID:      7,
+		Amount:      -123456,
+		Description: "Кафе",
+		Category:    "Food",
+		Type:        "expense",
+		Splits: []models.TransactionSplit{
+			{ID: 1, TransactionID: 42, Amount: -100000, Category: "Food"},
+			{ID: 2, TransactionID: 42, Amount: -23456, Category: "Entertainment"},
+		},
+		Tags: []models.Tag{{ID: 3, Name: "work"}, {ID: 1, Name: "trip"}},
+	}
+	snapshot := AuditSnapshot(&tx)
+
+	for _, key := range []string{"id", "user_id", "created_at"} {
+		if _, ok := snapshot[key]; ok {
+			t.Errorf("snapshot contains ignored field %q", key)
+		}
+	}
+	// Суммы хранятся как json.Number без потери точности
+	if amount, ok := snapshot["amount"].(json.Number); !ok || amount.String() != "-1234.56" {
+		t.Errorf("amount = %#v, want json.Number -1234.56", snapshot["amount"])
+	}
+	if tags := snapshot["tags"]; !reflect.DeepEqual(tags, []string{"trip", "work"}) {
+		t.Errorf("tags = %#v, want sorted names", tags)
+	}
+	splits, _ := snapshot["splits"].([]interface{})
+	if len(splits) != 2 {
+		t.Fatalf("splits = %#v", snapshot["splits"])
+	}
+	for _, split := range splits {
+		fields := split.(map[string]interface{})
+		if _, ok := fields["id"]; ok {
+			t.Errorf("split keeps id: %v", fields)
+		}
+		if _, ok := fields["transaction_id"]; ok {
+			t.Errorf("split keeps transaction_id: %v", fields)
+		}
+	}
+
+	// Тот же набор меток в другом порядке и другие id частей - снимки равны
+	same := tx
+	same.Tags = []models.Tag{{ID: 1, Name: "trip"}, {ID: 3, Name: "work"}}
+	same.Splits = []models.TransactionSplit{
+		{ID: 10, TransactionID: 42, Amount: -100000, Category: "Food"},
+		{ID: 11, TransactionID: 42, Amount: -23456, Category: "Entertainment"},
+	}
+	if diff := AuditDiff(snapshot, AuditSnapshot(&same)); len(diff) != 0 {
+		t.Errorf("AuditDiff of equal records = %v", diff)
+	}
+}
+
+func TestAuditDiff(t *testing.T) {
+	before := AuditSnapshot(&models.Transaction{Amount: -1000, Category: "Food", Description: "обед", Type: "expense"})
+	after := AuditSnapshot(&models.Transaction{Amount: -1500, Category: "Food", Description: "обед", Type: "expense", NeedsReview: true})
+
+	diff := AuditDiff(before, after)
+	if len(diff) != 2 {
+		t.Fatalf("AuditDiff = %v, want amount and needs_review", diff)
+	}
+	if change := diff["amount"]; change.From.(json.Number).String() != "-10.00" || change.To.(json.Number).String() != "-15.00" {
+		t.Errorf("amount change = %+v", change)
+	}
+	if change := diff["needs_review"]; change.From != false || change.To != true {
+		t.Errorf("needs_review change = %+v", change)
+	}
+
+	// При создании пустые поля не попадают в изменения
+	created := AuditDiff(nil, after)
+	if _, ok := created["ref_no"]; ok {
+		t.Errorf("create diff contains empty ref_no: %v", created)
+	}
+	if change, ok := created["category"]; !ok || change.From != nil || change.To != "Food" {
+		t.Errorf("create diff category = %+v", change)
+	}
+
+	// При удалении - тоже
+	deleted := AuditDiff(before, nil)
+	if _, ok := deleted["is_essential"]; ok {
+		t.Errorf("delete diff contains empty is_essential: %v", deleted)
+	}
+	if change, ok := deleted["description"]; !ok || change.From != "обед" || change.To != nil {
+		t.Errorf("delete diff description = %+v", change)
+	}
+
+	// Поле, пропавшее из снимка, записывается как удаленное
+	trimmed := map[string]interface{}{"category": "Food"}
+	if diff := AuditDiff(map[string]interface{}{"category": "Food", "merchant": "кафе"}, trimmed); len(diff) != 1 || diff["merchant"].From != "кафе" {
+		t.Errorf("AuditDiff with removed field = %v", diff)
+	}
+}
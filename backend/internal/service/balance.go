@@ -0,0 +1,113 @@
+package service
+
+import (
+	"clarity/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AccountBalance - остаток по счету в валюте счета и в базовой валюте пользователя
+type AccountBalance struct {
+	AccountID      uint         `json:"account_id"`
+	Name           string       `json:"name"`
+	Type           string       `json:"type"`
+	Currency       string       `json:"currency"`
+	OpeningBalance models.Money `json:"opening_balance"`
+	Balance        models.Money `json:"balance"`
+	BaseBalance    models.Money `json:"base_balance"`
+	RateMissing    bool         `json:"rate_missing,omitempty"` // Нет курса для перевода в базовую валюту
+}
+
+// BalancePoint - остаток по счету на конец месяца
+type BalancePoint struct {
+	Month   string       `json:"month"`
+	Balance models.Money `json:"balance"`
+}
+
+// AccountBalances - остатки по всем счетам пользователя на дату asOf (YYYY-MM-DD, пустая строка = на текущий момент).
+// Суммы транзакций хранятся со знаком (расход отрицательный), поэтому движение по счету - это SUM(amount).
+// Переводы между счетами учитываются: на общий баланс они не влияют, т.к. ноги взаимно гасятся.
+// Остаток в базовой валюте считается по курсу на дату asOf.
+func AccountBalances(db *gorm.DB, fx *FXService, userID uint, asOf string) []AccountBalance {
+	rateDate := time.Now()
+	if asOf != "" {
+		if parsed, err := time.Parse("2006-01-02", asOf); err == nil {
+			rateDate = parsed
+		}
+	}
+	base := BaseCurrency(db, userID)
+
+	var accounts []models.Account
+	db.Where("user_id = ?", userID).Order("id asc").Find(&accounts)
+
+	var flows []struct {
+		AccountID uint
+		Amount    models.Money
+	}
+	query := db.Model(&models.Transaction{}).Where("user_id = ?", userID)
+	if asOf != "" {
+		query = query.Where("date <= ?", asOf)
+	}
+	query.Select("account_id, COALESCE(SUM(amount), 0) as amount").
+		Group("account_id").
+		Scan(&flows)
+
+	flowByAccount := make(map[uint]models.Money, len(flows))
+	for _, f := range flows {
+		flowByAccount[f.AccountID] = f.Amount
+	}
+
+	balances := make([]AccountBalance, 0, len(accounts))
+	for _, acc := range accounts {
+		balance := acc.OpeningBalance + flowByAccount[acc.ID]
+		item := AccountBalance{
+			AccountID:      acc.ID,
+			Name:           acc.Name,
+			Type:           acc.Type,
+			Currency:       acc.Currency,
+			OpeningBalance: acc.OpeningBalance,
+			Balance:        balance,
+		}
+		if converted, err := fx.Convert(userID, balance, acc.Currency, base, rateDate); err == nil {
+			item.BaseBalance = converted
+		} else {
+			item.RateMissing = true
+		}
+		balances = append(balances, item)
+	}
+	return balances
+}
+
+// TotalBalance - суммарный остаток по всем счетам пользователя на дату asOf в базовой валюте.
+// Счета без курса в сумму не попадают.
+func TotalBalance(db *gorm.DB, fx *FXService, userID uint, asOf string) models.Money {
+	var total models.Money
+	for _, b := range AccountBalances(db, fx, userID, asOf) {
+		total += b.BaseBalance
+	}
+	return total
+}
+
+// AccountBalanceHistory - остатки по счету на конец каждого из последних months месяцев
+func AccountBalanceHistory(db *gorm.DB, account *models.Account, months int) []BalancePoint {
+	now := time.Now()
+	points := make([]BalancePoint, 0, months)
+	for i := months - 1; i >= 0; i-- {
+		// Месяц считается от первого числа: AddDate от 31-го перескочил бы короткий месяц
+		monthStart := time.Date(now.Year(), now.Month()-time.Month(i), 1, 0, 0, 0, 0, now.Location())
+		lastDay := monthStart.AddDate(0, 1, 0).AddDate(0, 0, -1)
+
+		var flow models.Money
+		db.Model(&models.Transaction{}).
+			Where("user_id = ? AND account_id = ? AND date <= ?", account.UserID, account.ID, lastDay.Format("2006-01-02")).
+			Select("COALESCE(SUM(amount), 0)").
+			Scan(&flow)
+
+		points = append(points, BalancePoint{
+			Month:   monthStart.Format("2006-01"),
+			Balance: account.OpeningBalance + flow,
+		})
+	}
+	return points
+}
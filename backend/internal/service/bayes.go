@@ -0,0 +1,182 @@
+package service
+
+import (
+	"clarity/internal/models"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	bayesModelTTL      = 10 * time.Minute // Модель пользователя переобучается не чаще
+	bayesTrainingLimit = 5000             // Последних транзакций в обучающей выборке
+	bayesMinDocuments  = 20               // Меньше - модель не отвечает
+)
+
+// BayesCategorizer - локальный наивный байесовский классификатор по словам описания и ref_no.
+// Обучается в памяти на истории пользователя: транзакции без разбивки с проверенной категорией
+// (кроме DefaultCategory). Модель кэшируется на bayesModelTTL.
+type BayesCategorizer struct {
+	db *gorm.DB
+
+	mu     sync.Mutex
+	models map[uint]*bayesModel
+}
+
+// bayesModel - классификаторы пользователя по типу транзакции
+type bayesModel struct {
+	trainedAt time.Time
+	byType    map[string]*bayesClassifier
+}
+
+// bayesClassifier - частоты слов по категориям
+type bayesClassifier struct {
+	documents    int
+	categoryDocs map[string]int
+	wordCounts   map[string]map[string]int // Категория -> слово -> число употреблений
+	categoryLen  map[string]int            // Категория -> всего слов
+	vocabulary   map[string]bool
+}
+
+func NewBayesCategorizer(db *gorm.DB) *BayesCategorizer {
+	return &BayesCategorizer{db: db, models: make(map[uint]*bayesModel)}
+}
+
+func (b *BayesCategorizer) Name() string { return "bayes" }
+
+func (b *BayesCategorizer) Categorize(ctx context.Context, tx *models.Transaction) (*Categorization, error) {
+	model, err := b.model(ctx, tx.UserID)
+	if err != nil {
+		return nil, err
+	}
+	classifier := model.byType[tx.Type]
+	if classifier == nil || classifier.documents < bayesMinDocuments || len(classifier.categoryDocs) < 2 {
+		return nil, nil
+	}
+
+	category, probability, known := classifier.predict(bayesTokens(tx.Description, tx.RefNo))
+	if known == 0 {
+		return nil, nil
+	}
+	return &Categorization{
+		Category:    category,
+		Confidence:  probability,
+		Source:      models.CategorySourceBayes,
+		Explanation: fmt.Sprintf("наивный Байес по %d транзакциям, знакомых слов: %d", classifier.documents, known),
+	}, nil
+}
+
+// model - модель пользователя из кэша или заново обученная
+func (b *BayesCategorizer) model(ctx context.Context, userID uint) (*bayesModel, error) {
+	b.mu.Lock()
+	model := b.models[userID]
+	b.mu.Unlock()
+	if model != nil && time.Since(model.trainedAt) < bayesModelTTL {
+		return model, nil
+	}
+
+	var txs []models.Transaction
+	err := b.db.WithContext(ctx).
+		Select("description", "ref_no", "category", "type").
+		Where("user_id = ? AND transfer_id IS NULL AND needs_review = ? AND category <> ?", userID, false, models.DefaultCategory).
+		Where("id NOT IN (?)", b.db.Model(&models.TransactionSplit{}).Select("transaction_id")).
+		Order("date desc, id desc").
+		Limit(bayesTrainingLimit).
+		Find(&txs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	model = &bayesModel{trainedAt: time.Now(), byType: make(map[string]*bayesClassifier)}
+	for _, tx := range txs {
+		classifier := model.byType[tx.Type]
+		if classifier == nil {
+			classifier = &bayesClassifier{
+				categoryDocs: make(map[string]int),
+				wordCounts:   make(map[string]map[string]int),
+				categoryLen:  make(map[string]int),
+				vocabulary:   make(map[string]bool),
+			}
+			model.byType[tx.Type] = classifier
+		}
+		classifier.train(tx.Category, bayesTokens(tx.Description, tx.RefNo))
+	}
+
+	b.mu.Lock()
+	b.models[userID] = model
+	b.mu.Unlock()
+	return model, nil
+}
+
+// bayesTokens - слова описания и ref_no (см. models.NormalizeMerchant) не короче 2 букв
+func bayesTokens(description, refNo string) []string {
+	var tokens []string
+	for _, word := range strings.Fields(models.NormalizeMerchant(description + " " + refNo)) {
+		if len([]rune(word)) >= 2 {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+func (c *bayesClassifier) train(category string, tokens []string) {
+	if len(tokens) == 0 {
+		return
+	}
+	c.documents++
+	c.categoryDocs[category]++
+	words := c.wordCounts[category]
+	if words == nil {
+		words = make(map[string]int)
+		c.wordCounts[category] = words
+	}
+	for _, token := range tokens {
+		words[token]++
+		c.categoryLen[category]++
+		c.vocabulary[token] = true
+	}
+}
+
+// predict - самая вероятная категория, ее апостериорная вероятность и число знакомых модели слов.
+// Сглаживание Лапласа; незнакомые слова не учитываются.
+func (c *bayesClassifier) predict(tokens []string) (string, float64, int) {
+	known := 0
+	for _, token := range tokens {
+		if c.vocabulary[token] {
+			known++
+		}
+	}
+	if known == 0 {
+		return "", 0, 0
+	}
+
+	vocabulary := float64(len(c.vocabulary))
+	scores := make(map[string]float64, len(c.categoryDocs))
+	best, bestScore := "", math.Inf(-1)
+	for category, docs := range c.categoryDocs {
+		score := math.Log(float64(docs) / float64(c.documents))
+		for _, token := range tokens {
+			if !c.vocabulary[token] {
+				continue
+			}
+			count := float64(c.wordCounts[category][token])
+			score += math.Log((count + 1) / (float64(c.categoryLen[category]) + vocabulary))
+		}
+		scores[category] = score
+		if score > bestScore || (score == bestScore && category < best) {
+			best, bestScore = category, score
+		}
+	}
+
+	// Вероятность лучшей категории: exp(score) / сумма exp(score) без переполнения
+	var total float64
+	for _, score := range scores {
+		total += math.Exp(score - bestScore)
+	}
+	return best, 1 / total, known
+}
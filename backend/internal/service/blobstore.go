@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrBlobNotFound - в хранилище нет объекта с таким ключом
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore - хранилище содержимого вложений. Ключ - относительный путь из латиницы,
+// цифр, '-', '_', '.' и '/', его выдает вызывающий.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error) // ErrBlobNotFound, если объекта нет
+	Delete(ctx context.Context, key string) error               // Отсутствующий объект - не ошибка
+}
+
+// NewBlobStore - хранилище по имени: "local" (по умолчанию) или "s3"
+func NewBlobStore(kind, localDir string, s3 S3Config) (BlobStore, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "", "local":
+		return NewLocalBlobStore(localDir), nil
+	case "s3":
+		return NewS3BlobStore(s3)
+	}
+	return nil, fmt.Errorf("unknown blob store %q", kind)
+}
+
+// validBlobKey - ключ без выхода за пределы хранилища и лишних символов
+func validBlobKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./", r)) {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	return nil
+}
+
+// LocalBlobStore - хранилище в каталоге локальной файловой системы
+type LocalBlobStore struct {
+	root string
+}
+
+func NewLocalBlobStore(root string) *LocalBlobStore {
+	return &LocalBlobStore{root: root}
+}
+
+func (s *LocalBlobStore) path(key string) (string, error) {
+	if err := validBlobKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put - пишет во временный файл и переименовывает, чтобы читатели не видели файл наполовину
+func (s *LocalBlobStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return file, err
+}
+
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// S3Config - подключение к S3-совместимому хранилищу (AWS S3, MinIO, Yandex Object Storage)
+type S3Config struct {
+	Endpoint  string // Например "https://storage.yandexcloud.net" или "http://localhost:9000"
+	Bucket    string
+	Region    string // По умолчанию us-east-1
+	AccessKey string
+	SecretKey string
+}
+
+// S3BlobStore - хранилище в бакете S3-совместимого сервиса. Запросы в path-style
+// ({endpoint}/{bucket}/{key}) с подписью AWS Signature V4, через общий OutboundClient.
+type S3BlobStore struct {
+	cfg    S3Config
+	client *OutboundClient
+	now    func() time.Time
+}
+
+func NewS3BlobStore(cfg S3Config) (*S3BlobStore, error) {
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 endpoint and bucket are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &S3BlobStore{
+		cfg:    cfg,
+		client: NewOutboundClient("blob-store", 30*time.Second, 2),
+		now:    time.Now,
+	}, nil
+}
+
+func (s *S3BlobStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *S3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrBlobNotFound
+	}
+	defer resp.Body.Close()
+	return nil, s3Error(resp)
+}
+
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("blob store error (status %d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// do - подписанный запрос к объекту key; повторяется при сбоях (все запросы идемпотентны)
+func (s *S3BlobStore) do(ctx context.Context, method, key string, body []byte, contentType string) (*http.Response, error) {
+	if err := validBlobKey(key); err != nil {
+		return nil, err
+	}
+	path := "/" + s.cfg.Bucket + "/" + key
+	header := s.sign(method, path, body, s.now().UTC())
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if body == nil && method == http.MethodPut {
+		body = []byte{}
+	}
+	return s.client.Do(ctx, OutboundRequest{
+		Method:     method,
+		URL:        s.cfg.Endpoint + path,
+		Body:       body,
+		Header:     header,
+		Idempotent: true,
+	})
+}
+
+// sign - заголовки AWS Signature V4 для запроса без query-параметров. Подписываются host,
+// x-amz-content-sha256 и x-amz-date.
+func (s *S3BlobStore) sign(method, path string, body []byte, now time.Time) http.Header {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	host := s.cfg.Endpoint
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		method,
+		s3EscapePath(path),
+		"",
+		"host:" + host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	header := http.Header{}
+	header.Set("X-Amz-Date", amzDate)
+	header.Set("X-Amz-Content-Sha256", payloadHash)
+	header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.cfg.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return header
+}
+
+// s3EscapePath - URI-кодирование пути по правилам SigV4: все, кроме A-Za-z0-9-_.~ и '/'
+func s3EscapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testS3AccessKey = "AKIAEXAMPLE"
+	testS3SecretKey = "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+)
+
+// Подпись посчитана независимой реализацией SigV4 (Python hmac/hashlib) для тех же входных данных
+func TestS3BlobStoreSignKnownVector(t *testing.T) {
+	store, err := NewS3BlobStore(S3Config{
+		Endpoint:  "http://localhost:9000/",
+		Bucket:    "attachments",
+		AccessKey: testS3AccessKey,
+		SecretKey: testS3SecretKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 12, 6, 10, 20, 30, 0, time.UTC)
+	header := store.sign(http.MethodPut, "/attachments/1/2025/receipt_01.pdf", []byte("hello"), now)
+
+	if got := header.Get("X-Amz-Date"); got != "20251206T102030Z" {
+		t.Errorf("X-Amz-Date = %q", got)
+	}
+	if got := header.Get("X-Amz-Content-Sha256"); got != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("X-Amz-Content-Sha256 = %q", got)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIAEXAMPLE/20251206/us-east-1/s3/aws4_request, " +
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date, " +
+		"Signature=52fa5a8be206d4d1e7c80aead6691b9e427095166fa8904a1c18548a528cbb34"
+	if got := header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q\nwant %q", got, want)
+	}
+}
+
+func TestS3EscapePath(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/bucket/1/2025/receipt.pdf", "/bucket/1/2025/receipt.pdf"},
+		{"/bucket/a-b_c.d~e", "/bucket/a-b_c.d~e"},
+		{"/bucket/a b", "/bucket/a%20b"},
+		{"/bucket/a+b=c&d", "/bucket/a%2Bb%3Dc%26d"},
+		{"/bucket/чек.pdf", "/bucket/%D1%87%D0%B5%D0%BA.pdf"},
+		{"/bucket/100%", "/bucket/100%25"},
+	}
+	for _, tt := range tests {
+		if got := s3EscapePath(tt.in); got != tt.want {
+			t.Errorf("s3EscapePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidBlobKey(t *testing.T) {
+	for _, key := range []string{"1/2025/12/receipt.pdf", "a_b-c.d"} {
+		if err := validBlobKey(key); err != nil {
+			t.Errorf("validBlobKey(%q) = %v", key, err)
+		}
+	}
+	for _, key := range []string{"", "/abs", "../up", "a/../b", "a b", "a?b", "ф"} {
+		if err := validBlobKey(key); err == nil {
+			t.Errorf("validBlobKey(%q) = nil, want error", key)
+		}
+	}
+}
+
+// fakeS3 - S3-совместимый сервер в памяти. Как настоящий S3, пересчитывает подпись SigV4
+// по полученному запросу и отвечает 403, если она не сходится.
+type fakeS3 struct {
+	t         *testing.T
+	accessKey string
+	secretKey string
+	region    string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{
+		t:         t,
+		accessKey: testS3AccessKey,
+		secretKey: testS3SecretKey,
+		region:    "us-east-1",
+		objects:   make(map[string][]byte),
+		types:     make(map[string]string),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := f.verify(r, body); err != nil {
+		f.t.Logf("signature check failed: %v", err)
+		http.Error(w, "SignatureDoesNotMatch: "+err.Error(), http.StatusForbidden)
+		return
+	}
+
+	path := r.URL.Path
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[path] = body
+		f.types[path] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, ok := f.objects[path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[path])
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// verify - проверка подписи запроса по правилам SigV4
+func (f *fakeS3) verify(r *http.Request, body []byte) error {
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ")
+	fields := make(map[string]string)
+	for _, part := range strings.Split(auth, ", ") {
+		name, value, _ := strings.Cut(part, "=")
+		fields[name] = value
+	}
+	credential := strings.SplitN(fields["Credential"], "/", 2)
+	if len(credential) != 2 || credential[0] != f.accessKey {
+		return errors.New("unknown access key")
+	}
+	scope := credential[1]
+	day, _, _ := strings.Cut(scope, "/")
+	if scope != day+"/"+f.region+"/s3/aws4_request" {
+		return errors.New("bad credential scope " + scope)
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != sha256Hex(body) {
+		return errors.New("payload hash does not match body")
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, day) {
+		return errors.New("x-amz-date does not match scope")
+	}
+
+	var canonicalHeaders strings.Builder
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	for _, name := range signed {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		fields["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+f.secretKey), day)
+	key = hmacSHA256(key, f.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	if hex.EncodeToString(hmacSHA256(key, stringToSign)) != fields["Signature"] {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+func TestS3BlobStoreRoundTrip(t *testing.T) {
+	fake, server := newFakeS3(t)
+	store, err := NewS3BlobStore(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "attachments",
+		AccessKey: testS3AccessKey,
+		SecretKey: testS3SecretKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	key := "1/2025/12/receipt_01.pdf"
+	data := []byte("%PDF-1.4 receipt")
+
+	if err := store.Put(ctx, key, data, "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := fake.types["/attachments/"+key]; got != "application/pdf" {
+		t.Errorf("stored Content-Type = %q", got)
+	}
+
+	reader, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get = %q, %v, want %q", got, err, data)
+	}
+
+	// Пустой объект тоже подписывается (хэш пустого тела)
+	if err := store.Put(ctx, "1/empty.txt", nil, ""); err != nil {
+		t.Fatalf("Put empty: %v", err)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrBlobNotFound", err)
+	}
+	// Удаление отсутствующего объекта - не ошибка
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("second Delete: %v", err)
+	}
+
+	if _, err := store.Get(ctx, "../secret"); err == nil {
+		t.Fatal("Get with invalid key succeeded")
+	}
+}
+
+func TestS3BlobStoreWrongSecret(t *testing.T) {
+	_, server := newFakeS3(t)
+	store, err := NewS3BlobStore(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "attachments",
+		AccessKey: testS3AccessKey,
+		SecretKey: "wrong-secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Put(context.Background(), "1/a.txt", []byte("x"), "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Put with wrong secret = %v, want 403 error", err)
+	}
+}
+
+func TestLocalBlobStoreRoundTrip(t *testing.T) {
+	store := NewLocalBlobStore(t.TempDir())
+	ctx := context.Background()
+	key := "1/2025/12/receipt.pdf"
+
+	if err := store.Put(ctx, key, []byte("data"), "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	reader, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(got) != "data" {
+		t.Fatalf("Get = %q", got)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrBlobNotFound", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("second Delete: %v", err)
+	}
+}
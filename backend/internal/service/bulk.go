@@ -0,0 +1,321 @@
+package service
+
+import (
+	"clarity/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxBulkTransactions - сколько транзакций может затронуть одна массовая операция
+const MaxBulkTransactions = 5000
+
+// Массовые операции над транзакциями
+const (
+	BulkSetCategory  = "set_category"
+	BulkSetEssential = "set_essential"
+	BulkAddTag       = "add_tag"
+	BulkRemoveTag    = "remove_tag"
+	BulkDelete       = "delete"
+	BulkShiftDate    = "shift_date"
+)
+
+var ErrUnknownBulkOperation = errors.New("operation must be set_category, set_essential, add_tag, remove_tag, delete or shift_date")
+
+// errBulkDryRun - откатывает транзакцию БД пробного запуска
+var errBulkDryRun = errors.New("bulk dry run")
+
+// BulkOperation - операция и ее параметр
+type BulkOperation struct {
+	Operation   string
+	Category    string // set_category
+	IsEssential *bool  // set_essential; для set_category nil - признак категории из справочника
+	Tag         string // add_tag, remove_tag
+	Days        int    // shift_date: сдвиг даты в днях, может быть отрицательным
+}
+
+// Validate - известна ли операция и задан ли ее параметр
+func (op *BulkOperation) Validate() error {
+	op.Category = strings.TrimSpace(op.Category)
+	op.Tag = strings.TrimSpace(op.Tag)
+	switch op.Operation {
+	case BulkSetCategory:
+		if op.Category == "" {
+			return fmt.Errorf("category is required for %s", op.Operation)
+		}
+	case BulkSetEssential:
+		if op.IsEssential == nil {
+			return fmt.Errorf("is_essential is required for %s", op.Operation)
+		}
+	case BulkAddTag, BulkRemoveTag:
+		if op.Tag == "" {
+			return fmt.Errorf("tag is required for %s", op.Operation)
+		}
+	case BulkShiftDate:
+		if op.Days == 0 {
+			return fmt.Errorf("days must not be zero for %s", op.Operation)
+		}
+	case BulkDelete:
+	default:
+		return ErrUnknownBulkOperation
+	}
+	return nil
+}
+
+// BulkResult - итог массовой операции
+type BulkResult struct {
+	DryRun   bool   `json:"dry_run"`
+	Matched  int    `json:"matched"`  // Выбрано транзакций
+	Affected int    `json:"affected"` // Изменились (при удалении - вместе со вторыми ногами переводов)
+	Skipped  []uint `json:"skipped"`  // Не найдены или операция к ним неприменима
+
+	// Corrections - ручные смены категории для памяти продавцов; записываются после операции
+	Corrections []models.CategoryCorrection `json:"-"`
+}
+
+// BulkService - одна операция над множеством транзакций пользователя
+type BulkService struct {
+	db *gorm.DB
+	fx *FXService
+}
+
+func NewBulkService(db *gorm.DB, fx *FXService) *BulkService {
+	return &BulkService{db: db, fx: fx}
+}
+
+// Apply - применяет операцию к транзакциям ids одной транзакцией БД. Каждое изменение
+// записывается в журнал с источником bulk, вся операция - одной сводной записью. Пробный
+// запуск (dryRun) выполняет операцию целиком и откатывает ее, поэтому счетчики точные.
+func (s *BulkService) Apply(userID uint, ids []uint, op BulkOperation, dryRun bool) (BulkResult, error) {
+	result := BulkResult{DryRun: dryRun, Skipped: []uint{}}
+	if err := op.Validate(); err != nil {
+		return result, err
+	}
+
+	err := s.db.Transaction(func(db *gorm.DB) error {
+		var txs []models.Transaction
+		err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Splits").Preload("Tags").
+			Where("user_id = ? AND id IN ?", userID, ids).Order("id asc").Find(&txs).Error
+		if err != nil {
+			return err
+		}
+		found := make(map[uint]bool, len(txs))
+		for _, tx := range txs {
+			found[tx.ID] = true
+		}
+		for _, id := range ids {
+			if !found[id] {
+				result.Skipped = append(result.Skipped, id)
+				found[id] = true // Повторы id учитываются один раз
+			}
+		}
+		result.Matched = len(txs)
+
+		apply := bulkOperation{service: s, db: db, actor: UserActor(userID, models.AuditSourceBulk), op: op, result: &result}
+		if op.Operation == BulkAddTag {
+			if apply.tags, err = EnsureTags(db, userID, []string{op.Tag}); err != nil {
+				return err
+			}
+		}
+		affected := make([]uint, 0, len(txs))
+		for i := range txs {
+			changed, err := apply.transaction(&txs[i])
+			if err != nil {
+				return err
+			}
+			affected = append(affected, changed...)
+		}
+		result.Affected = len(affected)
+
+		if len(affected) > 0 {
+			summary := map[string]interface{}{
+				"transaction_ids": affected,
+				"affected":        len(affected),
+				"category":        op.Category,
+				"tag":             op.Tag,
+				"days":            op.Days,
+			}
+			if op.IsEssential != nil {
+				summary["is_essential"] = *op.IsEssential
+			}
+			if err := RecordAudit(db, apply.actor, models.AuditEntityBulk, 0, op.Operation, nil, summary); err != nil {
+				return err
+			}
+		}
+		if dryRun {
+			return errBulkDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errBulkDryRun) {
+		result.Corrections = nil
+		return result, nil
+	}
+	return result, err
+}
+
+// bulkOperation - выполнение операции над транзакциями внутри транзакции БД
+type bulkOperation struct {
+	service *BulkService
+	db      *gorm.DB
+	actor   AuditActor
+	op      BulkOperation
+	result  *BulkResult
+	tags    []models.Tag  // add_tag: добавляемая метка
+	deleted map[uint]bool // delete: ноги переводов, уже удаленные вместе со второй ногой
+}
+
+// transaction - применяет операцию к транзакции; возвращает id измененных транзакций
+// (при удалении ноги перевода - обеих ног)
+func (b *bulkOperation) transaction(tx *models.Transaction) ([]uint, error) {
+	if b.op.Operation == BulkDelete {
+		return b.delete(tx)
+	}
+	// Категорию, признак обязательности и дату ноги перевода отдельно не меняют; у транзакции
+	// с разбивкой категория и признак заданы в частях
+	isLeg, isSplit := tx.TransferID != nil, len(tx.Splits) > 0
+	switch b.op.Operation {
+	case BulkSetCategory, BulkSetEssential:
+		if isLeg || isSplit {
+			b.result.Skipped = append(b.result.Skipped, tx.ID)
+			return nil, nil
+		}
+	case BulkShiftDate:
+		if isLeg {
+			b.result.Skipped = append(b.result.Skipped, tx.ID)
+			return nil, nil
+		}
+	}
+
+	before := AuditSnapshot(tx)
+	previousCategory := tx.Category
+	var err error
+	switch b.op.Operation {
+	case BulkSetCategory:
+		err = b.setCategory(tx)
+	case BulkSetEssential:
+		tx.IsEssential = *b.op.IsEssential
+		err = b.db.Model(tx).Update("is_essential", tx.IsEssential).Error
+	case BulkAddTag:
+		if !hasTag(tx.Tags, b.op.Tag) {
+			err = b.db.Model(tx).Association("Tags").Append(b.tags)
+		}
+	case BulkRemoveTag:
+		for _, tag := range tx.Tags {
+			if strings.EqualFold(tag.Name, b.op.Tag) {
+				err = b.db.Model(tx).Association("Tags").Delete(&tag)
+				break
+			}
+		}
+	case BulkShiftDate:
+		err = b.shiftDate(tx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	after := AuditSnapshot(tx)
+	if len(AuditDiff(before, after)) == 0 {
+		return nil, nil
+	}
+	if err := RecordAudit(b.db, b.actor, models.AuditEntityTransaction, tx.ID, models.AuditActionUpdate, before, after); err != nil {
+		return nil, err
+	}
+	if b.op.Operation == BulkSetCategory && tx.Category != previousCategory {
+		b.result.Corrections = append(b.result.Corrections, models.CategoryCorrection{
+			UserID:        tx.UserID,
+			TransactionID: tx.ID,
+			Type:          tx.Type,
+			Merchant:      models.MerchantKey(tx.Description, tx.RefNo),
+			FromCategory:  previousCategory,
+			ToCategory:    tx.Category,
+		})
+	}
+	return []uint{tx.ID}, nil
+}
+
+// setCategory - категория, заданная пользователем, считается проверенной; без явного
+// is_essential берется признак категории из справочника
+func (b *bulkOperation) setCategory(tx *models.Transaction) error {
+	tx.Category = b.op.Category
+	tx.SetCategorySource(models.CategorySourceManual, 1)
+	tx.NeedsReview = false
+	isEssential, err := categoryEssential(b.db, tx.UserID, tx.Type, tx.Category)
+	if err != nil {
+		return err
+	}
+	tx.IsEssential = isEssential
+	if b.op.IsEssential != nil {
+		tx.IsEssential = *b.op.IsEssential
+	}
+	return b.db.Model(tx).Select("category", "is_essential", "category_source", "category_confidence", "needs_review").Updates(tx).Error
+}
+
+// shiftDate - сдвигает дату и пересчитывает сумму в базовой валюте по курсу новой даты
+func (b *bulkOperation) shiftDate(tx *models.Transaction) error {
+	tx.Date = tx.Date.AddDate(0, 0, b.op.Days)
+	baseAmount, err := b.service.fx.ToBase(tx.UserID, tx.Amount, tx.Currency, tx.Date)
+	if err != nil {
+		return fmt.Errorf("transaction %d: %w", tx.ID, err)
+	}
+	tx.BaseAmount = baseAmount
+	tx.NormalizeSign()
+	if err := b.db.Model(tx).Select("date", "base_amount").Updates(tx).Error; err != nil {
+		return err
+	}
+	for i := range tx.Splits {
+		if err := b.db.Model(&tx.Splits[i]).Update("base_amount", tx.Splits[i].BaseAmount).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delete - переносит транзакцию в корзину; нога перевода уходит вместе со второй ногой
+func (b *bulkOperation) delete(tx *models.Transaction) ([]uint, error) {
+	if b.deleted[tx.ID] {
+		return nil, nil
+	}
+	removed := []models.Transaction{*tx}
+	if tx.TransferID != nil {
+		if err := b.db.Preload("Tags").Where("transfer_id = ? AND user_id = ?", *tx.TransferID, tx.UserID).
+			Order("id asc").Find(&removed).Error; err != nil {
+			return nil, err
+		}
+		if err := b.db.Where("id = ? AND user_id = ?", *tx.TransferID, tx.UserID).Delete(&models.Transfer{}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]uint, len(removed))
+	for i := range removed {
+		ids[i] = removed[i].ID
+	}
+	if err := b.db.Where("id IN ? AND user_id = ?", ids, tx.UserID).Delete(&models.Transaction{}).Error; err != nil {
+		return nil, err
+	}
+	if b.deleted == nil {
+		b.deleted = make(map[uint]bool)
+	}
+	for i := range removed {
+		b.deleted[removed[i].ID] = true
+		if err := RecordAudit(b.db, b.actor, models.AuditEntityTransaction, removed[i].ID, models.AuditActionDelete,
+			AuditSnapshot(&removed[i]), nil); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// hasTag - есть ли у записи метка name (без учета регистра)
+func hasTag(tags []models.Tag, name string) bool {
+	for _, tag := range tags {
+		if strings.EqualFold(tag.Name, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"clarity/internal/models"
+)
+
+// fakeBulkDB - драйвер database/sql без БД для BulkService. SELECT по транзакциям, разбивкам,
+// меткам и категориям отвечает строками из полей, остальные запросы только запоминаются; отдельно
+// считаются фиксации и откаты транзакций БД.
+type fakeBulkDB struct {
+	txs         []models.Transaction
+	splits      []models.TransactionSplit
+	isEssential bool // Признак категории из справочника
+
+	mu         sync.Mutex
+	statements []string
+	commits    int
+	rollbacks  int
+}
+
+var fakeTableRe = regexp.MustCompile(`(?:FROM|INTO|UPDATE) "(\w+)"`)
+
+func newFakeBulkDB(t *testing.T, fake *fakeBulkDB) *gorm.DB {
+	sqlDB := sql.OpenDB(fake)
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func (f *fakeBulkDB) Connect(context.Context) (driver.Conn, error) { return fakeBulkConn{f}, nil }
+func (f *fakeBulkDB) Driver() driver.Driver                        { return nil }
+
+// count - сколько выполнено запросов, начинающихся с prefix, к таблице table
+func (f *fakeBulkDB) count(prefix, table string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, statement := range f.statements {
+		if strings.HasPrefix(statement, prefix) && tableOf(statement) == table {
+			n++
+		}
+	}
+	return n
+}
+
+func tableOf(query string) string {
+	if m := fakeTableRe.FindStringSubmatch(query); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+type fakeBulkConn struct{ db *fakeBulkDB }
+
+func (c fakeBulkConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c fakeBulkConn) Close() error                        { return nil }
+func (c fakeBulkConn) Begin() (driver.Tx, error)           { return fakeBulkTx(c), nil }
+
+func (c fakeBulkConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.statements = append(c.db.statements, query)
+	return driver.RowsAffected(1), nil
+}
+
+func (c fakeBulkConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.statements = append(c.db.statements, query)
+	if !strings.HasPrefix(query, "SELECT") {
+		return &fakeBulkRows{}, nil
+	}
+
+	values := make([]int64, len(args))
+	for i, arg := range args {
+		values[i], _ = arg.Value.(int64)
+	}
+	switch tableOf(query) {
+	case "transactions":
+		rows := &fakeBulkRows{columns: []string{"id", "user_id", "transfer_id", "type", "category", "description", "amount", "is_essential"}}
+		for _, tx := range c.db.txs {
+			var match bool
+			if strings.Contains(query, "transfer_id =") {
+				match = tx.TransferID != nil && int64(*tx.TransferID) == values[0]
+			} else {
+				match = containsID(values[1:], tx.ID)
+			}
+			if !match {
+				continue
+			}
+			var transferID driver.Value
+			if tx.TransferID != nil {
+				transferID = int64(*tx.TransferID)
+			}
+			rows.rows = append(rows.rows, []driver.Value{int64(tx.ID), int64(tx.UserID), transferID, tx.Type,
+				tx.Category, tx.Description, int64(tx.Amount), tx.IsEssential})
+		}
+		return rows, nil
+	case "transaction_splits":
+		rows := &fakeBulkRows{columns: []string{"id", "transaction_id", "category", "amount"}}
+		for _, split := range c.db.splits {
+			if containsID(values, split.TransactionID) {
+				rows.rows = append(rows.rows, []driver.Value{int64(split.ID), int64(split.TransactionID), split.Category, int64(split.Amount)})
+			}
+		}
+		return rows, nil
+	case "tags":
+		rows := &fakeBulkRows{columns: []string{"id", "user_id", "name"}}
+		for i, arg := range args[1:] {
+			rows.rows = append(rows.rows, []driver.Value{int64(i + 1), values[0], arg.Value})
+		}
+		return rows, nil
+	case "categories":
+		return &fakeBulkRows{columns: []string{"id", "is_essential"}, rows: [][]driver.Value{{int64(1), c.db.isEssential}}}, nil
+	}
+	return &fakeBulkRows{}, nil
+}
+
+func containsID(values []int64, id uint) bool {
+	for _, value := range values {
+		if value == int64(id) {
+			return true
+		}
+	}
+	return false
+}
+
+type fakeBulkTx fakeBulkConn
+
+func (t fakeBulkTx) Commit() error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	t.db.commits++
+	return nil
+}
+
+func (t fakeBulkTx) Rollback() error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	t.db.rollbacks++
+	return nil
+}
+
+type fakeBulkRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeBulkRows) Columns() []string { return r.columns }
+func (r *fakeBulkRows) Close() error      { return nil }
+
+func (r *fakeBulkRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func uintPtr(v uint) *uint { return &v }
+
+// bulkFixture - обычная транзакция 1, ноги 2 и 3 перевода 7 и транзакция 4 с разбивкой
+func bulkFixture() *fakeBulkDB {
+	return &fakeBulkDB{
+		txs: []models.Transaction{
+			{ID: 1, UserID: 5, Type: "expense", Category: "Food", Description: "cafe", Amount: -1000},
+			{ID: 2, UserID: 5, TransferID: uintPtr(7), Type: "expense", Category: "Transfer", Amount: -5000},
+			{ID: 3, UserID: 5, TransferID: uintPtr(7), Type: "income", Category: "Transfer", Amount: 5000},
+			{ID: 4, UserID: 5, Type: "expense", Category: "Food", Description: "market", Amount: -3000},
+		},
+		splits: []models.TransactionSplit{
+			{ID: 10, TransactionID: 4, Category: "Food", Amount: -2000},
+			{ID: 11, TransactionID: 4, Category: "Health", Amount: -1000},
+		},
+		isEssential: true,
+	}
+}
+
+func TestBulkApplySkipsTransferLegsAndSplits(t *testing.T) {
+	essential := true
+	ops := []BulkOperation{
+		{Operation: BulkSetCategory, Category: "Health"},
+		{Operation: BulkSetEssential, IsEssential: &essential},
+	}
+	for _, op := range ops {
+		fake := bulkFixture()
+		service := NewBulkService(newFakeBulkDB(t, fake), nil)
+
+		result, err := service.Apply(5, []uint{1, 2, 3, 4, 99, 1}, op, false)
+		if err != nil {
+			t.Fatalf("%s: %v", op.Operation, err)
+		}
+		// Отсутствующая 99 (повтор id 1 учитывается один раз), затем ноги перевода и разбивка
+		if result.Matched != 4 || result.Affected != 1 || !reflect.DeepEqual(result.Skipped, []uint{99, 2, 3, 4}) {
+			t.Errorf("%s: result = %+v, want matched 4, affected 1, skipped [99 2 3 4]", op.Operation, result)
+		}
+		if fake.count("UPDATE", "transactions") != 1 {
+			t.Errorf("%s: %d transaction updates, want 1", op.Operation, fake.count("UPDATE", "transactions"))
+		}
+		// Запись об изменении транзакции 1 и сводная запись операции
+		if got := fake.count("INSERT", "audit_entries"); got != 2 {
+			t.Errorf("%s: %d audit entries, want 2", op.Operation, got)
+		}
+		if fake.commits != 1 || fake.rollbacks != 0 {
+			t.Errorf("%s: commits %d, rollbacks %d, want 1 commit", op.Operation, fake.commits, fake.rollbacks)
+		}
+	}
+}
+
+func TestBulkApplySetCategoryCorrections(t *testing.T) {
+	fake := bulkFixture()
+	service := NewBulkService(newFakeBulkDB(t, fake), nil)
+
+	result, err := service.Apply(5, []uint{1}, BulkOperation{Operation: BulkSetCategory, Category: " Health "}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Corrections) != 1 {
+		t.Fatalf("Corrections = %+v, want one", result.Corrections)
+	}
+	correction := result.Corrections[0]
+	if correction.TransactionID != 1 || correction.FromCategory != "Food" || correction.ToCategory != "Health" {
+		t.Errorf("Corrections[0] = %+v", correction)
+	}
+}
+
+func TestBulkApplyDryRun(t *testing.T) {
+	ops := []BulkOperation{
+		{Operation: BulkSetCategory, Category: "Health"},
+		{Operation: BulkAddTag, Tag: "trip"},
+		{Operation: BulkDelete},
+	}
+	for _, op := range ops {
+		applied := bulkFixture()
+		want, err := NewBulkService(newFakeBulkDB(t, applied), nil).Apply(5, []uint{1, 2, 4, 99}, op, false)
+		if err != nil {
+			t.Fatalf("%s: %v", op.Operation, err)
+		}
+
+		fake := bulkFixture()
+		got, err := NewBulkService(newFakeBulkDB(t, fake), nil).Apply(5, []uint{1, 2, 4, 99}, op, true)
+		if err != nil {
+			t.Fatalf("%s dry run: %v", op.Operation, err)
+		}
+		// Пробный запуск выполняет операцию целиком, поэтому счетчики совпадают с настоящим
+		if !got.DryRun || got.Matched != want.Matched || got.Affected != want.Affected || !reflect.DeepEqual(got.Skipped, want.Skipped) {
+			t.Errorf("%s: dry run = %+v, want counts of %+v", op.Operation, got, want)
+		}
+		if got.Affected == 0 {
+			t.Errorf("%s: dry run affected nothing", op.Operation)
+		}
+		if got.Corrections != nil {
+			t.Errorf("%s: dry run Corrections = %+v, want nil", op.Operation, got.Corrections)
+		}
+		// ... и откатывает транзакцию БД вместе с записями журнала
+		if fake.commits != 0 || fake.rollbacks != 1 {
+			t.Errorf("%s: commits %d, rollbacks %d, want one rollback", op.Operation, fake.commits, fake.rollbacks)
+		}
+		if fake.count("INSERT", "audit_entries") == 0 {
+			t.Errorf("%s: dry run wrote no audit entries before the rollback", op.Operation)
+		}
+	}
+}
+
+func TestBulkApplyDeletesWholeTransfer(t *testing.T) {
+	fake := bulkFixture()
+	service := NewBulkService(newFakeBulkDB(t, fake), nil)
+
+	// Обе ноги выбраны: перевод удаляется один раз, вторая нога не считается повторно
+	result, err := service.Apply(5, []uint{2, 3, 1}, BulkOperation{Operation: BulkDelete}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Matched != 3 || result.Affected != 3 || len(result.Skipped) != 0 {
+		t.Errorf("result = %+v, want matched 3, affected 3, nothing skipped", result)
+	}
+	if got := fake.count("UPDATE", "transfers"); got != 1 {
+		t.Errorf("%d transfer deletes, want 1", got)
+	}
+	if got := fake.count("UPDATE", "transactions"); got != 2 {
+		t.Errorf("%d transaction deletes, want 2 (transfer legs, then transaction 1)", got)
+	}
+	// Три записи об удалении и сводная запись
+	if got := fake.count("INSERT", "audit_entries"); got != 4 {
+		t.Errorf("%d audit entries, want 4", got)
+	}
+
+	// Выбрана одна нога: вторая удаляется вместе с ней
+	fake = bulkFixture()
+	result, err = NewBulkService(newFakeBulkDB(t, fake), nil).Apply(5, []uint{3}, BulkOperation{Operation: BulkDelete}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Matched != 1 || result.Affected != 2 {
+		t.Errorf("result = %+v, want matched 1, affected 2", result)
+	}
+	if got := fake.count("INSERT", "audit_entries"); got != 3 {
+		t.Errorf("%d audit entries, want 3", got)
+	}
+}
+
+func TestBulkOperationValidate(t *testing.T) {
+	essential := false
+	valid := []BulkOperation{
+		{Operation: BulkSetCategory, Category: "Food"},
+		{Operation: BulkSetEssential, IsEssential: &essential},
+		{Operation: BulkAddTag, Tag: "trip"},
+		{Operation: BulkRemoveTag, Tag: "trip"},
+		{Operation: BulkShiftDate, Days: -3},
+		{Operation: BulkDelete},
+	}
+	for _, op := range valid {
+		if err := op.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", op, err)
+		}
+	}
+	invalid := []BulkOperation{
+		{Operation: "rename"},
+		{Operation: BulkSetCategory, Category: "  "},
+		{Operation: BulkSetEssential},
+		{Operation: BulkAddTag},
+		{Operation: BulkShiftDate},
+	}
+	for _, op := range invalid {
+		if err := op.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want error", op)
+		}
+	}
+}
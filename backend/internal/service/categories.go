@@ -0,0 +1,135 @@
+package service
+
+import (
+	"clarity/internal/models"
+	"sort"
+)
+
+// CategoryNode - категория с суммами за период: amount - по самой категории,
+// total - вместе со всеми подкатегориями
+type CategoryNode struct {
+	ID       uint            `json:"id,omitempty"` // 0 - категории нет в справочнике
+	Name     string          `json:"name"`
+	Icon     string          `json:"icon,omitempty"`
+	Color    string          `json:"color,omitempty"`
+	Amount   models.Money    `json:"amount"`
+	Total    models.Money    `json:"total"`
+	Count    int             `json:"count"` // Количество транзакций самой категории
+	Children []*CategoryNode `json:"children,omitempty"`
+}
+
+// categoryName - имя категории для агрегатов (пустая категория считается категорией по умолчанию)
+func categoryName(name string) string {
+	if name == "" {
+		return models.DefaultCategory
+	}
+	return name
+}
+
+// RootCategories - имя категории верхнего уровня для каждой категории справочника.
+// Категории должны быть одного типа: имена уникальны только в пределах типа.
+func RootCategories(categories []models.Category) map[string]string {
+	byID := make(map[uint]models.Category, len(categories))
+	for _, cat := range categories {
+		byID[cat.ID] = cat
+	}
+
+	roots := make(map[string]string, len(categories))
+	for _, cat := range categories {
+		root := cat
+		// Глубина ограничена числом категорий на случай поврежденной иерархии
+		for steps := 0; root.ParentID != nil && steps < len(categories); steps++ {
+			parent, ok := byID[*root.ParentID]
+			if !ok {
+				break
+			}
+			root = parent
+		}
+		roots[cat.Name] = root.Name
+	}
+	return roots
+}
+
+// RollupByRoot - суммы по категориям верхнего уровня: суммы подкатегорий прибавляются к корню.
+// Категории, которых нет в справочнике, остаются как есть. Результат - по убыванию суммы.
+func RollupByRoot(categories []models.Category, amounts []CategoryAmount) []CategoryAmount {
+	roots := RootCategories(categories)
+	index := make(map[string]int)
+	var result []CategoryAmount
+	for _, item := range amounts {
+		name := categoryName(item.Category)
+		if root, ok := roots[name]; ok {
+			name = root
+		}
+		if i, ok := index[name]; ok {
+			result[i].Amount += item.Amount
+			result[i].Count += item.Count
+			continue
+		}
+		index[name] = len(result)
+		result = append(result, CategoryAmount{Category: name, Amount: item.Amount, Count: item.Count})
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Amount > result[j].Amount
+	})
+	return result
+}
+
+// CategoryTree - дерево категорий с суммами за период. В дерево попадают только категории
+// с транзакциями и их предки; категории, которых нет в справочнике, становятся корнями.
+func CategoryTree(categories []models.Category, amounts []CategoryAmount) []*CategoryNode {
+	byID := make(map[uint]*CategoryNode, len(categories))
+	byName := make(map[string]*CategoryNode, len(categories))
+	for _, cat := range categories {
+		node := &CategoryNode{ID: cat.ID, Name: cat.Name, Icon: cat.Icon, Color: cat.Color}
+		byID[cat.ID] = node
+		byName[cat.Name] = node
+	}
+
+	var roots []*CategoryNode
+	for _, cat := range categories {
+		node := byID[cat.ID]
+		if cat.ParentID != nil {
+			if parent, ok := byID[*cat.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	for _, item := range amounts {
+		name := categoryName(item.Category)
+		node, ok := byName[name]
+		if !ok {
+			node = &CategoryNode{Name: name}
+			byName[name] = node
+			roots = append(roots, node)
+		}
+		node.Amount += item.Amount
+		node.Count += item.Count
+	}
+
+	return pruneCategoryNodes(roots)
+}
+
+// pruneCategoryNodes - считает total, убирает ветки без транзакций и сортирует по убыванию total
+func pruneCategoryNodes(nodes []*CategoryNode) []*CategoryNode {
+	result := make([]*CategoryNode, 0, len(nodes))
+	for _, node := range nodes {
+		node.Children = pruneCategoryNodes(node.Children)
+		node.Total = node.Amount
+		for _, child := range node.Children {
+			node.Total += child.Total
+		}
+		if node.Total != 0 || node.Count > 0 || len(node.Children) > 0 {
+			result = append(result, node)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Total > result[j].Total
+	})
+	return result
+}
@@ -0,0 +1,535 @@
+package service
+
+import (
+	"clarity/internal/models"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// DefaultCategorizerChain - цепочка категоризаторов по умолчанию (см. NewCategorizer)
+const DefaultCategorizerChain = "merchant,rules,ml"
+
+// keywordConfidence - уверенность встроенных ключевых слов: совпадение слова надежнее ML,
+// но в отличие от правил пользователя не подтверждено им самим
+const keywordConfidence = 0.9
+
+// Categorization - результат категоризации транзакции
+type Categorization struct {
+	Category    string                      `json:"category"`
+	Confidence  float64                     `json:"confidence"`        // 0-1
+	Source      string                      `json:"source"`            // models.CategorySource*
+	Explanation string                      `json:"explanation"`       // Почему стратегия выбрала категорию
+	Decision    string                      `json:"decision"`          // Как выбран итог среди стратегий
+	Steps       []models.CategorizationStep `json:"steps,omitempty"`   // Опрошенные стратегии по порядку
+	RuleID      uint                        `json:"rule_id,omitempty"` // Правило пользователя, задавшее категорию
+}
+
+// Categorizer - стратегия категоризации транзакции. Ответ nil без ошибки означает, что
+// стратегия категорию не знает; ошибка - что стратегия недоступна (цепочка идет дальше).
+type Categorizer interface {
+	Name() string
+	Categorize(ctx context.Context, tx *models.Transaction) (*Categorization, error)
+}
+
+// BatchCategorizer - стратегия, которая категоризирует пакет транзакций за один проход
+// (например, одним запросом к внешнему сервису). Результаты и ошибки - по индексу транзакции.
+type BatchCategorizer interface {
+	Categorizer
+	CategorizeBatch(ctx context.Context, txs []*models.Transaction) ([]*Categorization, []error)
+}
+
+// Explainer - категоризатор с пробным прогоном для объяснения категории
+type Explainer interface {
+	Explain(ctx context.Context, tx *models.Transaction) (*Categorization, error)
+}
+
+// CategorizeAll - категоризация пакета транзакций: одним проходом, если стратегия это умеет
+// (BatchCategorizer), иначе по одной
+func CategorizeAll(ctx context.Context, c Categorizer, txs []*models.Transaction) ([]*Categorization, []error) {
+	if batch, ok := c.(BatchCategorizer); ok {
+		return batch.CategorizeBatch(ctx, txs)
+	}
+	results := make([]*Categorization, len(txs))
+	errs := make([]error, len(txs))
+	for i, tx := range txs {
+		results[i], errs[i] = c.Categorize(ctx, tx)
+	}
+	return results, errs
+}
+
+// CategorizerDeps - зависимости стратегий, из которых собирается цепочка
+type CategorizerDeps struct {
+	DB    *gorm.DB
+	ML    *MLClient
+	Rules *RuleEngine
+}
+
+// NewCategorizer - цепочка категоризаторов по описанию spec: имена стратегий через запятую
+// в порядке опроса (merchant, rules, keyword, ml, bayes). Стратегии через "+" образуют ансамбль,
+// например "merchant,rules,ml+bayes". Пустой spec - DefaultCategorizerChain.
+func NewCategorizer(spec string, deps CategorizerDeps) (Categorizer, error) {
+	if strings.TrimSpace(spec) == "" {
+		spec = DefaultCategorizerChain
+	}
+
+	var bayes *BayesCategorizer
+	strategy := func(name string) (Categorizer, error) {
+		switch name {
+		case "merchant":
+			return NewMerchantCategorizer(deps.DB), nil
+		case "rules":
+			return NewRuleCategorizer(deps.Rules), nil
+		case "keyword":
+			return NewKeywordCategorizer(), nil
+		case "ml":
+			return NewMLCategorizer(deps.ML), nil
+		case "bayes":
+			if bayes == nil {
+				bayes = NewBayesCategorizer(deps.DB)
+			}
+			return bayes, nil
+		}
+		return nil, fmt.Errorf("unknown categorizer %q", name)
+	}
+
+	var steps []Categorizer
+	for _, part := range strings.Split(spec, ",") {
+		var members []Categorizer
+		for _, name := range strings.Split(part, "+") {
+			member, err := strategy(strings.ToLower(strings.TrimSpace(name)))
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, member)
+		}
+		if len(members) == 1 {
+			steps = append(steps, members[0])
+		} else {
+			steps = append(steps, NewEnsembleCategorizer(members...))
+		}
+	}
+	return NewChainCategorizer(steps...), nil
+}
+
+// ChainCategorizer - опрашивает стратегии по порядку, итог - ответ первой ответившей
+type ChainCategorizer struct {
+	steps []Categorizer
+}
+
+func NewChainCategorizer(steps ...Categorizer) *ChainCategorizer {
+	return &ChainCategorizer{steps: steps}
+}
+
+func (c *ChainCategorizer) Name() string {
+	names := make([]string, len(c.steps))
+	for i, step := range c.steps {
+		names[i] = step.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *ChainCategorizer) Categorize(ctx context.Context, tx *models.Transaction) (*Categorization, error) {
+	var steps []models.CategorizationStep
+	for _, step := range c.steps {
+		result, err := step.Categorize(ctx, tx)
+		steps = append(steps, stepOf(step.Name(), result, err))
+		if answered(result, err) {
+			return chainDecision(step, result, steps), nil
+		}
+	}
+	return chainUnanswered(steps), nil
+}
+
+// CategorizeBatch - как Categorize, но каждая стратегия опрашивается один раз на весь пакет
+// (только по транзакциям, на которые не ответили предыдущие)
+func (c *ChainCategorizer) CategorizeBatch(ctx context.Context, txs []*models.Transaction) ([]*Categorization, []error) {
+	results := make([]*Categorization, len(txs))
+	steps := make([][]models.CategorizationStep, len(txs))
+	pending := make([]int, len(txs))
+	for i := range txs {
+		pending[i] = i
+	}
+
+	for _, step := range c.steps {
+		if len(pending) == 0 {
+			break
+		}
+		batch := make([]*models.Transaction, len(pending))
+		for j, i := range pending {
+			batch[j] = txs[i]
+		}
+		answers, errs := CategorizeAll(ctx, step, batch)
+
+		var next []int
+		for j, i := range pending {
+			steps[i] = append(steps[i], stepOf(step.Name(), answers[j], errs[j]))
+			if answered(answers[j], errs[j]) {
+				results[i] = chainDecision(step, answers[j], steps[i])
+			} else {
+				next = append(next, i)
+			}
+		}
+		pending = next
+	}
+	for _, i := range pending {
+		results[i] = chainUnanswered(steps[i])
+	}
+	return results, make([]error, len(txs))
+}
+
+// Explain - пробный прогон для объяснения: итог выбирается как в Categorize, но опрашиваются
+// все стратегии цепочки; ответы стратегий после выбравшей помечены AfterDecision
+func (c *ChainCategorizer) Explain(ctx context.Context, tx *models.Transaction) (*Categorization, error) {
+	var steps []models.CategorizationStep
+	var decided *Categorization
+	var decidedBy Categorizer
+	for _, step := range c.steps {
+		result, err := step.Categorize(ctx, tx)
+		record := stepOf(step.Name(), result, err)
+		if decided != nil {
+			record.AfterDecision = true
+		} else if answered(result, err) {
+			decided, decidedBy = result, step
+		}
+		steps = append(steps, record)
+	}
+	if decided == nil {
+		return chainUnanswered(steps), nil
+	}
+	return chainDecision(decidedBy, decided, steps), nil
+}
+
+// answered - знает ли стратегия категорию
+func answered(result *Categorization, err error) bool {
+	return err == nil && result != nil && result.Category != ""
+}
+
+func chainDecision(step Categorizer, result *Categorization, steps []models.CategorizationStep) *Categorization {
+	result.Steps = append(steps, result.Steps...)
+	result.Decision = fmt.Sprintf("первая ответившая стратегия цепочки: %s", step.Name())
+	return result
+}
+
+func chainUnanswered(steps []models.CategorizationStep) *Categorization {
+	return &Categorization{Steps: steps, Decision: "ни одна стратегия цепочки не ответила"}
+}
+
+// stepOf - запись о результате стратегии для объяснения
+func stepOf(name string, result *Categorization, err error) models.CategorizationStep {
+	step := models.CategorizationStep{Strategy: name}
+	if err != nil {
+		step.Error = err.Error()
+	} else if result != nil {
+		step.Category = result.Category
+		step.Confidence = result.Confidence
+		step.Explanation = result.Explanation
+	}
+	return step
+}
+
+// EnsembleCategorizer - опрашивает все стратегии и выбирает категорию с наибольшей суммой
+// уверенностей. Уверенность итога - эта сумма, деленная на число ответивших стратегий.
+type EnsembleCategorizer struct {
+	members []Categorizer
+}
+
+func NewEnsembleCategorizer(members ...Categorizer) *EnsembleCategorizer {
+	return &EnsembleCategorizer{members: members}
+}
+
+func (e *EnsembleCategorizer) Name() string {
+	names := make([]string, len(e.members))
+	for i, member := range e.members {
+		names[i] = member.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+func (e *EnsembleCategorizer) Categorize(ctx context.Context, tx *models.Transaction) (*Categorization, error) {
+	steps := make([]models.CategorizationStep, len(e.members))
+	answers := make([]*Categorization, len(e.members))
+	for m, member := range e.members {
+		result, err := member.Categorize(ctx, tx)
+		steps[m] = stepOf(member.Name(), result, err)
+		if answered(result, err) {
+			answers[m] = result
+		}
+	}
+	return vote(steps, answers), nil
+}
+
+// CategorizeBatch - как Categorize, но каждая стратегия опрашивается один раз на весь пакет
+func (e *EnsembleCategorizer) CategorizeBatch(ctx context.Context, txs []*models.Transaction) ([]*Categorization, []error) {
+	steps := make([][]models.CategorizationStep, len(txs))
+	answers := make([][]*Categorization, len(txs))
+	for i := range txs {
+		steps[i] = make([]models.CategorizationStep, len(e.members))
+		answers[i] = make([]*Categorization, len(e.members))
+	}
+	for m, member := range e.members {
+		results, errs := CategorizeAll(ctx, member, txs)
+		for i := range txs {
+			steps[i][m] = stepOf(member.Name(), results[i], errs[i])
+			if answered(results[i], errs[i]) {
+				answers[i][m] = results[i]
+			}
+		}
+	}
+
+	results := make([]*Categorization, len(txs))
+	for i := range txs {
+		results[i] = vote(steps[i], answers[i])
+	}
+	return results, make([]error, len(txs))
+}
+
+// vote - итог ансамбля по ответам стратегий (nil - стратегия не ответила); nil, если не ответил никто
+func vote(steps []models.CategorizationStep, answers []*Categorization) *Categorization {
+	votes := make(map[string]float64)
+	var ruleID uint
+	count := 0
+	for _, result := range answers {
+		if result == nil {
+			continue
+		}
+		votes[result.Category] += result.Confidence
+		count++
+		if result.RuleID != 0 && ruleID == 0 {
+			ruleID = result.RuleID
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+
+	categories := make([]string, 0, len(votes))
+	for category := range votes {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if votes[categories[i]] != votes[categories[j]] {
+			return votes[categories[i]] > votes[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	best := categories[0]
+
+	parts := make([]string, len(categories))
+	for i, category := range categories {
+		parts[i] = fmt.Sprintf("%s %.2f", category, votes[category])
+	}
+	return &Categorization{
+		Category:    best,
+		Confidence:  votes[best] / float64(count),
+		Source:      models.CategorySourceEnsemble,
+		Explanation: "голоса стратегий: " + strings.Join(parts, ", "),
+		Decision:    "ансамбль: наибольшая сумма уверенностей",
+		Steps:       steps,
+		RuleID:      ruleID,
+	}
+}
+
+// MerchantCategorizer - категория, выученная на исправлениях пользователя для продавца;
+// если исправлений не было - категория расходов по умолчанию продавца из справочника
+type MerchantCategorizer struct {
+	db *gorm.DB
+}
+
+func NewMerchantCategorizer(db *gorm.DB) *MerchantCategorizer {
+	return &MerchantCategorizer{db: db}
+}
+
+func (m *MerchantCategorizer) Name() string { return "merchant" }
+
+func (m *MerchantCategorizer) Categorize(ctx context.Context, tx *models.Transaction) (*Categorization, error) {
+	merchant := models.MerchantKey(tx.Description, tx.RefNo)
+	if merchant == "" {
+		return nil, nil
+	}
+	var memory models.MerchantCategory
+	err := m.db.WithContext(ctx).
+		Where("user_id = ? AND type = ? AND merchant = ?", tx.UserID, tx.Type, merchant).
+		Limit(1).Find(&memory).Error
+	if err != nil {
+		return nil, err
+	}
+	if memory.ID != 0 {
+		return &Categorization{
+			Category:    memory.Category,
+			Confidence:  1,
+			Source:      models.CategorySourceMerchant,
+			Explanation: fmt.Sprintf("продавца «%s» исправляли на эту категорию (%d раз подряд)", merchant, memory.Corrections),
+		}, nil
+	}
+
+	if tx.Type != "expense" {
+		return nil, nil
+	}
+	merchants, err := LoadMerchants(m.db.WithContext(ctx), tx.UserID)
+	if err != nil {
+		return nil, err
+	}
+	entry := MatchMerchant(merchants, merchant)
+	if entry == nil || entry.Category == "" {
+		return nil, nil
+	}
+	return &Categorization{
+		Category:    entry.Category,
+		Confidence:  1,
+		Source:      models.CategorySourceMerchant,
+		Explanation: fmt.Sprintf("категория по умолчанию продавца «%s»", entry.Name),
+	}, nil
+}
+
+// RuleCategorizer - категория из правил пользователя (остальные действия правил применяются отдельно)
+type RuleCategorizer struct {
+	engine *RuleEngine
+}
+
+func NewRuleCategorizer(engine *RuleEngine) *RuleCategorizer {
+	return &RuleCategorizer{engine: engine}
+}
+
+func (r *RuleCategorizer) Name() string { return "rules" }
+
+func (r *RuleCategorizer) Categorize(ctx context.Context, tx *models.Transaction) (*Categorization, error) {
+	rules, err := r.engine.Rules(tx.UserID)
+	if err != nil {
+		return nil, err
+	}
+	outcome := Evaluate(rules, tx)
+	if outcome.Category == "" {
+		return nil, nil
+	}
+	explanation := fmt.Sprintf("правило #%d", outcome.CategoryRuleID)
+	for _, rule := range rules {
+		if rule.ID == outcome.CategoryRuleID && rule.Name != "" {
+			explanation = fmt.Sprintf("правило #%d «%s»", rule.ID, rule.Name)
+		}
+	}
+	return &Categorization{
+		Category:    outcome.Category,
+		Confidence:  1,
+		Source:      models.CategorySourceRule,
+		Explanation: explanation,
+		RuleID:      outcome.CategoryRuleID,
+	}, nil
+}
+
+// keywordGroup - встроенные ключевые слова категории
+type keywordGroup struct {
+	category string
+	txType   string
+	pattern  *regexp.Regexp
+}
+
+// KeywordCategorizer - встроенные ключевые слова в описании. Слова те же, что в правилах
+// по умолчанию (models.DefaultCategoryRules), но не зависят от правок пользователя.
+type KeywordCategorizer struct {
+	groups []keywordGroup
+}
+
+func NewKeywordCategorizer() *KeywordCategorizer {
+	k := &KeywordCategorizer{}
+	for _, rule := range models.DefaultCategoryRules {
+		pattern, err := compilePattern(rule.DescriptionPattern)
+		if err != nil || pattern == nil {
+			continue
+		}
+		k.groups = append(k.groups, keywordGroup{category: rule.SetCategory, txType: rule.Type, pattern: pattern})
+	}
+	return k
+}
+
+func (k *KeywordCategorizer) Name() string { return "keyword" }
+
+func (k *KeywordCategorizer) Categorize(ctx context.Context, tx *models.Transaction) (*Categorization, error) {
+	for _, group := range k.groups {
+		if group.txType != "" && group.txType != tx.Type {
+			continue
+		}
+		if word := group.pattern.FindString(tx.Description); word != "" {
+			return &Categorization{
+				Category:    group.category,
+				Confidence:  keywordConfidence,
+				Source:      models.CategorySourceKeyword,
+				Explanation: fmt.Sprintf("ключевое слово «%s» в описании", word),
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// MLCategorizer - категория расхода от ML сервиса (по ref_no, если он есть, иначе по описанию)
+type MLCategorizer struct {
+	client *MLClient
+}
+
+func NewMLCategorizer(client *MLClient) *MLCategorizer {
+	return &MLCategorizer{client: client}
+}
+
+func (m *MLCategorizer) Name() string { return "ml" }
+
+func (m *MLCategorizer) Categorize(ctx context.Context, tx *models.Transaction) (*Categorization, error) {
+	if tx.Type != "expense" {
+		return nil, nil
+	}
+	result, err := m.client.CategorizeWithDate(ctx, mlRefNo(tx), tx.Amount, tx.Date)
+	if err != nil {
+		return nil, err
+	}
+	return mlCategorization(result), nil
+}
+
+// CategorizeBatch - расходы пакета одним проходом MLClient.CategorizeBatch
+func (m *MLCategorizer) CategorizeBatch(ctx context.Context, txs []*models.Transaction) ([]*Categorization, []error) {
+	results := make([]*Categorization, len(txs))
+	errs := make([]error, len(txs))
+
+	var indexes []int
+	var items []CategorizeRequest
+	for i, tx := range txs {
+		if tx.Type != "expense" {
+			continue
+		}
+		indexes = append(indexes, i)
+		items = append(items, CategorizeRequest{Amount: tx.Amount, Date: tx.Date.Format("2006-01-02"), RefNo: mlRefNo(tx)})
+	}
+	if len(items) == 0 {
+		return results, errs
+	}
+
+	responses, responseErrs := m.client.CategorizeBatch(ctx, items)
+	for j, i := range indexes {
+		if errs[i] = responseErrs[j]; errs[i] == nil {
+			results[i] = mlCategorization(responses[j])
+		}
+	}
+	return results, errs
+}
+
+// mlRefNo - ref_no для ML сервиса: если его нет, описание
+func mlRefNo(tx *models.Transaction) string {
+	if tx.RefNo == "" {
+		return tx.Description
+	}
+	return tx.RefNo
+}
+
+func mlCategorization(result *CategorizeResponse) *Categorization {
+	if result == nil || result.Category == "" {
+		return nil
+	}
+	return &Categorization{
+		Category:    result.Category,
+		Confidence:  result.Confidence,
+		Source:      models.CategorySourceML,
+		Explanation: "ML сервис по ref_no, сумме и дате",
+	}
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"clarity/internal/models"
+)
+
+// fakeCategorizer - стратегия с заранее заданными ответами по описанию транзакции
+type fakeCategorizer struct {
+	name    string
+	answers map[string]Categorization
+	err     error
+	calls   int
+}
+
+func (f *fakeCategorizer) Name() string { return f.name }
+
+func (f *fakeCategorizer) Categorize(ctx context.Context, tx *models.Transaction) (*Categorization, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	answer, ok := f.answers[tx.Description]
+	if !ok {
+		return nil, nil
+	}
+	return &answer, nil
+}
+
+// fakeBatchCategorizer - fakeCategorizer, запоминающий состав каждого пакета
+type fakeBatchCategorizer struct {
+	fakeCategorizer
+	batches [][]string
+}
+
+func (f *fakeBatchCategorizer) CategorizeBatch(ctx context.Context, txs []*models.Transaction) ([]*Categorization, []error) {
+	batch := make([]string, len(txs))
+	results := make([]*Categorization, len(txs))
+	errs := make([]error, len(txs))
+	for i, tx := range txs {
+		batch[i] = tx.Description
+		results[i], errs[i] = f.Categorize(context.Background(), tx)
+	}
+	f.batches = append(f.batches, batch)
+	return results, errs
+}
+
+func answer(category string, confidence float64) Categorization {
+	return Categorization{Category: category, Confidence: confidence, Source: models.CategorySourceML}
+}
+
+func testTx(description string) *models.Transaction {
+	return &models.Transaction{Description: description, Type: "expense"}
+}
+
+func TestChainCategorizerFirstAnswerWins(t *testing.T) {
+	broken := &fakeCategorizer{name: "broken", err: errors.New("unavailable")}
+	silent := &fakeCategorizer{name: "silent"}
+	first := &fakeCategorizer{name: "first", answers: map[string]Categorization{"taxi": answer("Transport", 0.7)}}
+	second := &fakeCategorizer{name: "second", answers: map[string]Categorization{"taxi": answer("Food", 0.99)}}
+	chain := NewChainCategorizer(broken, silent, first, second)
+
+	if got := chain.Name(); got != "broken,silent,first,second" {
+		t.Errorf("Name() = %q", got)
+	}
+
+	result, err := chain.Categorize(context.Background(), testTx("taxi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Category != "Transport" || result.Confidence != 0.7 {
+		t.Errorf("Categorize = %s %.2f, want Transport 0.70", result.Category, result.Confidence)
+	}
+	if !strings.Contains(result.Decision, "first") {
+		t.Errorf("Decision = %q, want the answering strategy", result.Decision)
+	}
+	if second.calls != 0 {
+		t.Errorf("strategy after the answer was called %d times", second.calls)
+	}
+
+	if len(result.Steps) != 3 {
+		t.Fatalf("Steps = %+v, want 3 steps", result.Steps)
+	}
+	if result.Steps[0].Strategy != "broken" || result.Steps[0].Error != "unavailable" {
+		t.Errorf("Steps[0] = %+v", result.Steps[0])
+	}
+	if result.Steps[1].Strategy != "silent" || result.Steps[1].Category != "" {
+		t.Errorf("Steps[1] = %+v", result.Steps[1])
+	}
+	if result.Steps[2].Strategy != "first" || result.Steps[2].Category != "Transport" {
+		t.Errorf("Steps[2] = %+v", result.Steps[2])
+	}
+}
+
+func TestChainCategorizerUnanswered(t *testing.T) {
+	chain := NewChainCategorizer(
+		&fakeCategorizer{name: "a"},
+		&fakeCategorizer{name: "b", err: errors.New("timeout")},
+	)
+	result, err := chain.Categorize(context.Background(), testTx("unknown"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil || result.Category != "" || len(result.Steps) != 2 {
+		t.Fatalf("Categorize = %+v, want empty category with 2 steps", result)
+	}
+}
+
+func TestChainCategorizerExplain(t *testing.T) {
+	chain := NewChainCategorizer(
+		&fakeCategorizer{name: "a"},
+		&fakeCategorizer{name: "b", answers: map[string]Categorization{"cafe": answer("Food", 0.8)}},
+		&fakeCategorizer{name: "c", answers: map[string]Categorization{"cafe": answer("Entertainment", 0.9)}},
+	)
+	result, err := chain.Explain(context.Background(), testTx("cafe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Category != "Food" {
+		t.Errorf("Explain category = %q, want Food", result.Category)
+	}
+	if len(result.Steps) != 3 {
+		t.Fatalf("Steps = %+v, want all 3 strategies", result.Steps)
+	}
+	for i, after := range []bool{false, false, true} {
+		if result.Steps[i].AfterDecision != after {
+			t.Errorf("Steps[%d].AfterDecision = %v, want %v", i, result.Steps[i].AfterDecision, after)
+		}
+	}
+}
+
+func TestChainCategorizerBatch(t *testing.T) {
+	first := &fakeBatchCategorizer{fakeCategorizer: fakeCategorizer{name: "first", answers: map[string]Categorization{
+		"a": answer("Food", 0.9),
+	}}}
+	second := &fakeBatchCategorizer{fakeCategorizer: fakeCategorizer{name: "second", answers: map[string]Categorization{
+		"a": answer("Rent", 0.9),
+		"b": answer("Transport", 0.6),
+	}}}
+	third := &fakeCategorizer{name: "third"}
+	chain := NewChainCategorizer(first, second, third)
+
+	txs := []*models.Transaction{testTx("a"), testTx("b"), testTx("c")}
+	results, errs := CategorizeAll(context.Background(), chain, txs)
+
+	for i, want := range []string{"Food", "Transport", ""} {
+		if errs[i] != nil {
+			t.Errorf("errs[%d] = %v", i, errs[i])
+		}
+		if results[i] == nil || results[i].Category != want {
+			t.Errorf("results[%d] = %+v, want %q", i, results[i], want)
+		}
+	}
+	// Каждая стратегия получает один пакет только из транзакций без ответа
+	if len(first.batches) != 1 || strings.Join(first.batches[0], ",") != "a,b,c" {
+		t.Errorf("first batches = %v", first.batches)
+	}
+	if len(second.batches) != 1 || strings.Join(second.batches[0], ",") != "b,c" {
+		t.Errorf("second batches = %v", second.batches)
+	}
+	// Стратегия без пакетного режима опрашивается по одной транзакции
+	if third.calls != 1 {
+		t.Errorf("third calls = %d, want 1", third.calls)
+	}
+
+	// Пакетный результат совпадает с поштучным
+	for i, tx := range txs {
+		single, _ := chain.Categorize(context.Background(), tx)
+		if single.Category != results[i].Category || len(single.Steps) != len(results[i].Steps) {
+			t.Errorf("tx %q: single %+v, batch %+v", tx.Description, single, results[i])
+		}
+	}
+}
+
+func TestEnsembleCategorizerVote(t *testing.T) {
+	ruled := answer("Food", 0.5)
+	ruled.RuleID = 7
+	ensemble := NewEnsembleCategorizer(
+		&fakeCategorizer{name: "a", answers: map[string]Categorization{"x": answer("Transport", 0.9), "tie": answer("Rent", 0.5)}},
+		&fakeCategorizer{name: "b", answers: map[string]Categorization{"x": ruled, "tie": answer("Food", 0.5)}},
+		&fakeCategorizer{name: "c", answers: map[string]Categorization{"x": answer("Food", 0.6)}},
+		&fakeCategorizer{name: "d", err: errors.New("down")},
+	)
+	if got := ensemble.Name(); got != "a+b+c+d" {
+		t.Errorf("Name() = %q", got)
+	}
+
+	result, err := ensemble.Categorize(context.Background(), testTx("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Food: 0.5 + 0.6 = 1.1 > Transport 0.9; уверенность - 1.1 / 3 ответивших
+	if result.Category != "Food" {
+		t.Errorf("Category = %q, want Food", result.Category)
+	}
+	if math.Abs(result.Confidence-1.1/3) > 1e-9 {
+		t.Errorf("Confidence = %v, want %v", result.Confidence, 1.1/3)
+	}
+	if result.Source != models.CategorySourceEnsemble || result.RuleID != 7 {
+		t.Errorf("Source = %q, RuleID = %d", result.Source, result.RuleID)
+	}
+	if len(result.Steps) != 4 || result.Steps[3].Error != "down" {
+		t.Errorf("Steps = %+v", result.Steps)
+	}
+
+	// При равенстве голосов побеждает категория, первая по алфавиту
+	tie, _ := ensemble.Categorize(context.Background(), testTx("tie"))
+	if tie.Category != "Food" {
+		t.Errorf("tie Category = %q, want Food", tie.Category)
+	}
+
+	none, err := ensemble.Categorize(context.Background(), testTx("nobody knows"))
+	if err != nil || none != nil {
+		t.Errorf("Categorize without answers = %+v, %v, want nil", none, err)
+	}
+}
+
+func TestEnsembleInChainFallsThrough(t *testing.T) {
+	ensemble := NewEnsembleCategorizer(&fakeCategorizer{name: "a"}, &fakeCategorizer{name: "b"})
+	fallback := &fakeCategorizer{name: "fallback", answers: map[string]Categorization{"x": answer("Health", 0.4)}}
+	chain := NewChainCategorizer(ensemble, fallback)
+
+	result, _ := chain.Categorize(context.Background(), testTx("x"))
+	if result.Category != "Health" {
+		t.Errorf("Category = %q, want Health from the next step", result.Category)
+	}
+
+	batch, _ := CategorizeAll(context.Background(), chain, []*models.Transaction{testTx("x")})
+	if batch[0].Category != "Health" {
+		t.Errorf("batch Category = %q, want Health", batch[0].Category)
+	}
+}
+
+func TestKeywordCategorizer(t *testing.T) {
+	k := NewKeywordCategorizer()
+	result, err := k.Categorize(context.Background(), testTx("Оплата такси до дома"))
+	if err != nil || result == nil || result.Category != "Transport" {
+		t.Fatalf("Categorize = %+v, %v, want Transport", result, err)
+	}
+	if result.Source != models.CategorySourceKeyword || result.Confidence != keywordConfidence {
+		t.Errorf("Source = %q, Confidence = %v", result.Source, result.Confidence)
+	}
+
+	income := testTx("такси")
+	income.Type = "income"
+	if result, _ := k.Categorize(context.Background(), income); result != nil {
+		t.Errorf("expense keyword matched income: %+v", result)
+	}
+	if result, _ := k.Categorize(context.Background(), testTx("перевод другу")); result != nil {
+		t.Errorf("Categorize without keywords = %+v, want nil", result)
+	}
+}
+
+func TestNewCategorizerSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", DefaultCategorizerChain},
+		{"keyword", "keyword"},
+		{" Merchant , rules, ml+bayes ", "merchant,rules,ml+bayes"},
+	}
+	for _, tt := range tests {
+		c, err := NewCategorizer(tt.spec, CategorizerDeps{})
+		if err != nil {
+			t.Errorf("NewCategorizer(%q) error: %v", tt.spec, err)
+			continue
+		}
+		if got := c.Name(); got != tt.want {
+			t.Errorf("NewCategorizer(%q).Name() = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"gpt", "rules,,ml", "ml+unknown"} {
+		if _, err := NewCategorizer(spec, CategorizerDeps{}); err == nil {
+			t.Errorf("NewCategorizer(%q) succeeded, want error", spec)
+		}
+	}
+}
@@ -1,7 +1,8 @@
 package service
 
 import (
-	"bytes"
+	"clarity/internal/models"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,25 +11,23 @@ import (
 )
 
 type ForecastClient struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL string
+	client  *OutboundClient
 }
 
 func NewForecastClient(baseURL string) *ForecastClient {
 	return &ForecastClient{
 		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second, // Prophet может занимать время
-		},
+		client:  NewOutboundClient("ml-analyze", 30*time.Second, 1), // Prophet может занимать время
 	}
 }
 
 // AnalyzeTransaction - транзакция для анализа
 type AnalyzeTransaction struct {
-	Date        string  `json:"date"`
-	Amount      float64 `json:"amount"`
-	Category    string  `json:"category"`
-	IsEssential bool    `json:"is_essential"`
+	Date        string       `json:"date"`
+	Amount      models.Money `json:"amount"`
+	Category    string       `json:"category"`
+	IsEssential bool         `json:"is_essential"`
 }
 
 // AnalyzeResponse - ответ от ML сервиса
@@ -67,18 +66,19 @@ type AnalyzeResponse struct {
 }
 
 // Analyze - полный финансовый анализ с ML-прогнозированием
-func (c *ForecastClient) Analyze(transactions []AnalyzeTransaction) (*AnalyzeResponse, error) {
+func (c *ForecastClient) Analyze(ctx context.Context, transactions []AnalyzeTransaction) (*AnalyzeResponse, error) {
 	// ML сервис ожидает массив транзакций напрямую
 	jsonData, err := json.Marshal(transactions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	resp, err := c.httpClient.Post(
-		fmt.Sprintf("%s/analyze", c.baseURL),
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	resp, err := c.client.Do(ctx, OutboundRequest{
+		Method:     http.MethodPost,
+		URL:        fmt.Sprintf("%s/analyze", c.baseURL),
+		Body:       jsonData,
+		Idempotent: true,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to call ML service: %w", err)
 	}
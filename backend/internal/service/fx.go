@@ -0,0 +1,286 @@
+package service
+
+import (
+	"clarity/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// pivotCurrency - валюта, через которую считается кросс-курс, если прямого курса нет
+const pivotCurrency = "RUB"
+
+// ErrRateNotFound - нет курса на дату транзакции или раньше
+var ErrRateNotFound = errors.New("fx rate not found")
+
+// fxCacheLimit - предельное число курсов в кэше; переполненный кэш сбрасывается целиком
+const fxCacheLimit = 10000
+
+// FXService - конвертация сумм по курсам из таблицы fx_rates
+type FXService struct {
+	db *gorm.DB
+
+	mu    sync.RWMutex
+	cache map[string]float64
+}
+
+func NewFXService(db *gorm.DB) *FXService {
+	return &FXService{db: db, cache: make(map[string]float64)}
+}
+
+// NormalizeCurrency - приводит код валюты к виду ISO 4217 (USD), пустой код = RUB
+func NormalizeCurrency(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return models.DefaultCurrency
+	}
+	return code
+}
+
+// ValidCurrency - проверка формата кода валюты (три латинские буквы)
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// CurrencySymbol - символ валюты для текстов уведомлений и AI-контекста
+func CurrencySymbol(code string) string {
+	switch NormalizeCurrency(code) {
+	case "RUB":
+		return "₽"
+	case "USD":
+		return "$"
+	case "EUR":
+		return "€"
+	case "GBP":
+		return "£"
+	case "CNY", "JPY":
+		return "¥"
+	case "KZT":
+		return "₸"
+	default:
+		return " " + code
+	}
+}
+
+// BaseCurrency - базовая валюта пользователя
+func BaseCurrency(db *gorm.DB, userID uint) string {
+	var user models.User
+	if err := db.Select("base_currency").First(&user, userID).Error; err != nil || user.BaseCurrency == "" {
+		return models.DefaultCurrency
+	}
+	return user.BaseCurrency
+}
+
+// Rate - курс from->to на дату: прямой, обратный или кросс-курс через RUB.
+// Используется последний известный курс на дату date или раньше.
+func (s *FXService) Rate(userID uint, from, to string, date time.Time) (float64, error) {
+	from, to = NormalizeCurrency(from), NormalizeCurrency(to)
+	if from == to {
+		return 1, nil
+	}
+
+	day := date.Format("2006-01-02")
+	key := fmt.Sprintf("%d:%s:%s:%s", userID, from, to, day)
+	s.mu.RLock()
+	rate, ok := s.cache[key]
+	s.mu.RUnlock()
+	if ok {
+		return rate, nil
+	}
+
+	rate, err := s.lookup(userID, from, to, day)
+	if err != nil && from != pivotCurrency && to != pivotCurrency {
+		// Кросс-курс через опорную валюту
+		toPivot, err1 := s.lookup(userID, from, pivotCurrency, day)
+		fromPivot, err2 := s.lookup(userID, pivotCurrency, to, day)
+		if err1 == nil && err2 == nil {
+			rate, err = toPivot*fromPivot, nil
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s->%s on %s", ErrRateNotFound, from, to, day)
+	}
+
+	s.mu.Lock()
+	if len(s.cache) >= fxCacheLimit {
+		s.cache = make(map[string]float64)
+	}
+	s.cache[key] = rate
+	s.mu.Unlock()
+	return rate, nil
+}
+
+// lookup - прямой или обратный курс из таблицы
+func (s *FXService) lookup(userID uint, from, to, day string) (float64, error) {
+	var direct models.FXRate
+	err := s.db.Where("user_id = ? AND currency = ? AND quote_currency = ? AND date <= ?", userID, from, to, day).
+		Order("date desc").
+		First(&direct).Error
+	if err == nil && direct.Rate > 0 {
+		return direct.Rate, nil
+	}
+
+	var inverse models.FXRate
+	err = s.db.Where("user_id = ? AND currency = ? AND quote_currency = ? AND date <= ?", userID, to, from, day).
+		Order("date desc").
+		First(&inverse).Error
+	if err == nil && inverse.Rate > 0 {
+		return 1 / inverse.Rate, nil
+	}
+	return 0, ErrRateNotFound
+}
+
+// Convert - перевод суммы из валюты from в валюту to по курсу на дату (с округлением до копейки)
+func (s *FXService) Convert(userID uint, amount models.Money, from, to string, date time.Time) (models.Money, error) {
+	rate, err := s.Rate(userID, from, to, date)
+	if err != nil {
+		return 0, err
+	}
+	return amount.MulRate(rate), nil
+}
+
+// ToBase - перевод суммы в базовую валюту пользователя
+func (s *FXService) ToBase(userID uint, amount models.Money, currency string, date time.Time) (models.Money, error) {
+	return s.Convert(userID, amount, currency, BaseCurrency(s.db, userID), date)
+}
+
+// SaveRates - сохраняет курсы (с заменой существующих на ту же дату) и сбрасывает кэш
+func (s *FXService) SaveRates(rates []models.FXRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for i := range rates {
+			r := &rates[i]
+			if err := tx.Where("user_id = ? AND date = ? AND currency = ? AND quote_currency = ?",
+				r.UserID, r.Date.Format("2006-01-02"), r.Currency, r.QuoteCurrency).
+				Delete(&models.FXRate{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(r).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	s.mu.Lock()
+	s.cache = make(map[string]float64)
+	s.mu.Unlock()
+	return err
+}
+
+// RecalculateBaseAmounts - пересчитывает суммы в базовой валюте для всех денежных записей пользователя,
+// включая записи в корзине (восстановленная запись не должна остаться со старым курсом), одной
+// транзакцией БД. Вызывается после загрузки курсов и смены базовой валюты. Записи, для которых
+// курса нет, не меняются и возвращаются в счетчике missing; у инвестиции без курса на сегодня
+// пересчитывается только base_amount, и она тоже считается в missing.
+func (s *FXService) RecalculateBaseAmounts(userID uint) (updated int, missing int, err error) {
+	base := BaseCurrency(s.db, userID)
+
+	err = s.db.Transaction(func(db *gorm.DB) error {
+		// Записи в базовой валюте не требуют курса
+		if err := db.Unscoped().Model(&models.Transaction{}).
+			Where("user_id = ? AND currency = ?", userID, base).
+			Update("base_amount", gorm.Expr("amount")).Error; err != nil {
+			return err
+		}
+		if err := db.Model(&models.TransactionSplit{}).
+			Where("transaction_id IN (?)", db.Unscoped().Model(&models.Transaction{}).Select("id").Where("user_id = ? AND currency = ?", userID, base)).
+			Update("base_amount", gorm.Expr("amount")).Error; err != nil {
+			return err
+		}
+		if err := db.Unscoped().Model(&models.Investment{}).
+			Where("user_id = ? AND currency = ?", userID, base).
+			Updates(map[string]interface{}{"base_amount": gorm.Expr("amount"), "base_current_value": gorm.Expr("current_value")}).Error; err != nil {
+			return err
+		}
+		if err := db.Unscoped().Model(&models.Deposit{}).
+			Where("user_id = ? AND currency = ?", userID, base).
+			Update("base_amount", gorm.Expr("amount")).Error; err != nil {
+			return err
+		}
+
+		var txs []models.Transaction
+		if err := db.Unscoped().Preload("Splits").Where("user_id = ? AND currency <> ?", userID, base).Find(&txs).Error; err != nil {
+			return err
+		}
+		for _, tx := range txs {
+			converted, err := s.Convert(userID, tx.Amount, tx.Currency, base, tx.Date)
+			if err != nil {
+				missing++
+				continue
+			}
+			if err := db.Unscoped().Model(&models.Transaction{}).Where("id = ?", tx.ID).Update("base_amount", converted).Error; err != nil {
+				return err
+			}
+			// Части разбивки получают доли нового base_amount
+			tx.BaseAmount = converted
+			tx.AllocateSplitBase()
+			for _, split := range tx.Splits {
+				if err := db.Model(&models.TransactionSplit{}).Where("id = ?", split.ID).Update("base_amount", split.BaseAmount).Error; err != nil {
+					return err
+				}
+			}
+			updated++
+		}
+
+		var invs []models.Investment
+		if err := db.Unscoped().Where("user_id = ? AND currency <> ?", userID, base).Find(&invs).Error; err != nil {
+			return err
+		}
+		for _, inv := range invs {
+			converted, err := s.Convert(userID, inv.Amount, inv.Currency, base, inv.Date)
+			if err != nil {
+				missing++
+				continue
+			}
+			fields := map[string]interface{}{"base_amount": converted}
+			current, currentErr := s.Convert(userID, inv.CurrentValue, inv.Currency, base, time.Now())
+			if currentErr == nil {
+				fields["base_current_value"] = current
+			}
+			if err := db.Unscoped().Model(&models.Investment{}).Where("id = ?", inv.ID).Updates(fields).Error; err != nil {
+				return err
+			}
+			if currentErr != nil {
+				missing++
+				continue
+			}
+			updated++
+		}
+
+		var deps []models.Deposit
+		if err := db.Unscoped().Where("user_id = ? AND currency <> ?", userID, base).Find(&deps).Error; err != nil {
+			return err
+		}
+		for _, dep := range deps {
+			converted, err := s.Convert(userID, dep.Amount, dep.Currency, base, dep.OpenDate)
+			if err != nil {
+				missing++
+				continue
+			}
+			if err := db.Unscoped().Model(&models.Deposit{}).Where("id = ?", dep.ID).Update("base_amount", converted).Error; err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return updated, missing, nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// FXRatesClient - клиент внешнего (или локального) сервиса курсов валют
+type FXRatesClient struct {
+	baseURL string
+	client  *OutboundClient
+}
+
+func NewFXRatesClient(baseURL string) *FXRatesClient {
+	return &FXRatesClient{
+		baseURL: baseURL,
+		client:  NewOutboundClient("fx-rates", 10*time.Second, 2),
+	}
+}
+
+// FXRatesResponse - ответ сервиса курсов: 1 единица Base стоит Rates[code] единиц валюты code
+type FXRatesResponse struct {
+	Base  string             `json:"base"`
+	Date  string             `json:"date"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// Enabled - настроен ли адрес сервиса курсов
+func (c *FXRatesClient) Enabled() bool {
+	return c.baseURL != ""
+}
+
+// Fetch - GET {baseURL}?base=RUB&date=YYYY-MM-DD
+func (c *FXRatesClient) Fetch(ctx context.Context, base string, date time.Time) (*FXRatesResponse, error) {
+	if !c.Enabled() {
+		return nil, fmt.Errorf("FX rates endpoint is not configured")
+	}
+
+	query := url.Values{}
+	query.Set("base", base)
+	query.Set("date", date.Format("2006-01-02"))
+
+	resp, err := c.client.Do(ctx, OutboundRequest{
+		Method:     http.MethodGet,
+		URL:        c.baseURL + "?" + query.Encode(),
+		Idempotent: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call FX rates service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("FX rates service error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var result FXRatesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.Base == "" {
+		result.Base = base
+	}
+	if result.Date == "" {
+		result.Date = date.Format("2006-01-02")
+	}
+	return &result, nil
+}
@@ -11,10 +11,11 @@ import (
 
 type HealthScoreService struct {
 	db *gorm.DB
+	fx *FXService
 }
 
-func NewHealthScoreService(db *gorm.DB) *HealthScoreService {
-	return &HealthScoreService{db: db}
+func NewHealthScoreService(db *gorm.DB, fx *FXService) *HealthScoreService {
+	return &HealthScoreService{db: db, fx: fx}
 }
 
 type HealthScoreResult struct {
@@ -61,51 +62,28 @@ type ComponentScore struct {
 }
 
 type ComponentDetails struct {
-	TotalAmount    float64 `json:"total_amount"`     // Общая сумма
-	Recommendation string  `json:"recommendation"`   // Рекомендация
-	HasMoreDetails bool    `json:"has_more_details"` // Есть ли подробная информация
+	TotalAmount    models.Money `json:"total_amount"`     // Общая сумма
+	Recommendation string       `json:"recommendation"`   // Рекомендация
+	HasMoreDetails bool         `json:"has_more_details"` // Есть ли подробная информация
 }
 
 func (s *HealthScoreService) Calculate(userID uint, month string) (*HealthScoreResult, error) {
 	monthTime, _ := time.Parse("2006-01", month)
-	startDate := month + "-01"
-	lastDay := monthTime.AddDate(0, 1, 0).AddDate(0, 0, -1)
-	endDate := lastDay.Format("2006-01-02")
+	startDate, endDate := MonthRange(month)
 
 	// Получаем данные за месяц
-	var monthIncome, monthExpense, monthEssential float64
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'income' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&monthIncome)
-
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&monthExpense)
-
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND is_essential = true AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&monthEssential)
-
-	// Получаем баланс (сумма всех доходов - расходов)
-	var totalBalance float64
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ?", userID).
-		Select("COALESCE(SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END), 0)").
-		Scan(&totalBalance)
+	totals := PeriodTotals(s.db, userID, startDate, endDate)
+	monthIncome, monthExpense, monthEssential := totals.Income, totals.Expense, totals.Essential
+
+	// Получаем баланс (суммарный остаток по всем счетам)
+	totalBalance := TotalBalance(s.db, s.fx, userID, "")
 
 	// Средние расходы за последние 3 месяца (сумма за месяц)
-	var totalExpense3Months float64
 	threeMonthsAgo := monthTime.AddDate(0, -3, 0).Format("2006-01-02")
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, threeMonthsAgo, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&totalExpense3Months)
+	totalExpense3Months := PeriodTotals(s.db, userID, threeMonthsAgo, endDate).Expense
 
 	// Делим на 3 месяца (или используем текущий месяц если нет данных)
-	avgExpense := totalExpense3Months / 3.0
+	avgExpense := totalExpense3Months.MulRate(1.0 / 3.0)
 	if avgExpense == 0 && monthExpense > 0 {
 		avgExpense = monthExpense // Fallback на текущий месяц
 	}
@@ -113,14 +91,14 @@ func (s *HealthScoreService) Calculate(userID uint, month string) (*HealthScoreR
 	// 1. Savings Rate (30%)
 	savingsRate := 0.0
 	if monthIncome > 0 {
-		savingsRate = ((monthIncome - monthExpense) / monthIncome) * 100
+		savingsRate = (monthIncome - monthExpense).Ratio(monthIncome) * 100
 	}
 	savingsRateScore := normalizeSavingsRate(savingsRate)
 
 	// 2. Emergency Fund (25%)
 	emergencyFundMonths := 0.0
 	if avgExpense > 0 {
-		emergencyFundMonths = totalBalance / avgExpense
+		emergencyFundMonths = totalBalance.Ratio(avgExpense)
 	}
 	emergencyFundScore := normalizeEmergencyFund(emergencyFundMonths)
 
@@ -130,7 +108,7 @@ func (s *HealthScoreService) Calculate(userID uint, month string) (*HealthScoreR
 	// 4. Essential Ratio (20%)
 	essentialRatio := 0.0
 	if monthExpense > 0 {
-		essentialRatio = (monthEssential / monthExpense) * 100
+		essentialRatio = monthEssential.Ratio(monthExpense) * 100
 	}
 	essentialRatioScore := normalizeEssentialRatio(essentialRatio)
 
@@ -196,13 +174,9 @@ func (s *HealthScoreService) calculateSpendingStability(userID uint, monthTime t
 		lastDay := m.AddDate(0, 1, 0).AddDate(0, 0, -1)
 		end := lastDay.Format("2006-01-02")
 
-		var amount float64
-		s.db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, start, end).
-			Select("COALESCE(SUM(amount), 0)").
-			Scan(&amount)
+		amount := PeriodTotals(s.db, userID, start, end).Expense
 		if amount > 0 {
-			expenses = append(expenses, amount)
+			expenses = append(expenses, amount.Float64())
 		}
 	}
 
@@ -344,21 +318,13 @@ func (s *HealthScoreService) analyzeTrend(userID uint, monthTime time.Time, curr
 		lastDay := m.AddDate(0, 1, 0).AddDate(0, 0, -1)
 		end := lastDay.Format("2006-01-02")
 
-		var income, expense float64
-		s.db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'income' AND date >= ? AND date <= ?", userID, start, end).
-			Select("COALESCE(SUM(amount), 0)").
-			Scan(&income)
-
-		s.db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, start, end).
-			Select("COALESCE(SUM(amount), 0)").
-			Scan(&expense)
+		totals := PeriodTotals(s.db, userID, start, end)
+		income, expense := totals.Income, totals.Expense
 
 		// Упрощенный расчет score для тренда
 		savingsRate := 0.0
 		if income > 0 {
-			savingsRate = ((income - expense) / income) * 100
+			savingsRate = (income - expense).Ratio(income) * 100
 		}
 		savingsScore := normalizeSavingsRate(savingsRate)
 
@@ -521,31 +487,19 @@ func (s *HealthScoreService) calculateBenchmarks(userID uint, monthTime time.Tim
 		lastDay := m.AddDate(0, 1, 0).AddDate(0, 0, -1)
 		end := lastDay.Format("2006-01-02")
 
-		var income, expense float64
-		s.db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'income' AND date >= ? AND date <= ?", userID, start, end).
-			Select("COALESCE(SUM(amount), 0)").
-			Scan(&income)
-
-		s.db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, start, end).
-			Select("COALESCE(SUM(amount), 0)").
-			Scan(&expense)
+		totals := PeriodTotals(s.db, userID, start, end)
+		income, expense := totals.Income, totals.Expense
 
 		if income > 0 {
-			savingsRate := ((income - expense) / income) * 100
+			savingsRate := (income - expense).Ratio(income) * 100
 			totalSavingsRate += savingsRate
 		}
 
 		// Упрощенный расчет emergency fund
-		var balance float64
-		s.db.Model(&models.Transaction{}).
-			Where("user_id = ? AND date <= ?", userID, end).
-			Select("COALESCE(SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END), 0)").
-			Scan(&balance)
+		balance := TotalBalance(s.db, s.fx, userID, end)
 
 		if expense > 0 {
-			emergencyMonths := balance / expense
+			emergencyMonths := balance.Ratio(expense)
 			totalEmergencyMonths += emergencyMonths
 		}
 
@@ -579,7 +533,7 @@ func (s *HealthScoreService) calculateBenchmarks(userID uint, monthTime time.Tim
 }
 
 // Детализация компонента: Доходы
-func (s *HealthScoreService) getIncomeDetails(userID uint, totalIncome, savingsRate, score float64) *ComponentDetails {
+func (s *HealthScoreService) getIncomeDetails(userID uint, totalIncome models.Money, savingsRate, score float64) *ComponentDetails {
 	recommendation := ""
 
 	if savingsRate < 10 {
@@ -593,14 +547,14 @@ func (s *HealthScoreService) getIncomeDetails(userID uint, totalIncome, savingsR
 	}
 
 	return &ComponentDetails{
-		TotalAmount:    math.Round(totalIncome*100) / 100,
+		TotalAmount:    totalIncome,
 		Recommendation: recommendation,
 		HasMoreDetails: true,
 	}
 }
 
 // Детализация компонента: Свободные средства (Emergency Fund)
-func (s *HealthScoreService) getSavingsDetails(userID uint, totalBalance, emergencyMonths, score float64) *ComponentDetails {
+func (s *HealthScoreService) getSavingsDetails(userID uint, totalBalance models.Money, emergencyMonths, score float64) *ComponentDetails {
 	recommendation := ""
 
 	if emergencyMonths < 3 {
@@ -612,14 +566,14 @@ func (s *HealthScoreService) getSavingsDetails(userID uint, totalBalance, emerge
 	}
 
 	return &ComponentDetails{
-		TotalAmount:    math.Round(totalBalance*100) / 100,
+		TotalAmount:    totalBalance,
 		Recommendation: recommendation,
 		HasMoreDetails: true,
 	}
 }
 
 // Детализация компонента: Расходы
-func (s *HealthScoreService) getExpenseDetails(userID uint, totalExpense float64, monthTime time.Time, stabilityScore float64) *ComponentDetails {
+func (s *HealthScoreService) getExpenseDetails(userID uint, totalExpense models.Money, monthTime time.Time, stabilityScore float64) *ComponentDetails {
 	recommendation := ""
 
 	if stabilityScore < 50 {
@@ -631,14 +585,14 @@ func (s *HealthScoreService) getExpenseDetails(userID uint, totalExpense float64
 	}
 
 	return &ComponentDetails{
-		TotalAmount:    math.Round(totalExpense*100) / 100,
+		TotalAmount:    totalExpense,
 		Recommendation: recommendation,
 		HasMoreDetails: true,
 	}
 }
 
 // Детализация компонента: Essential Ratio (баланс обязательных/необязательных)
-func (s *HealthScoreService) getEssentialRatioDetails(totalExpense, essentialExpense, essentialRatio, score float64) *ComponentDetails {
+func (s *HealthScoreService) getEssentialRatioDetails(totalExpense, essentialExpense models.Money, essentialRatio, score float64) *ComponentDetails {
 	recommendation := ""
 
 	if essentialRatio < 50 {
@@ -652,7 +606,7 @@ func (s *HealthScoreService) getEssentialRatioDetails(totalExpense, essentialExp
 	}
 
 	return &ComponentDetails{
-		TotalAmount:    math.Round(totalExpense*100) / 100,
+		TotalAmount:    totalExpense,
 		Recommendation: recommendation,
 		HasMoreDetails: true,
 	}
@@ -660,119 +614,84 @@ func (s *HealthScoreService) getEssentialRatioDetails(totalExpense, essentialExp
 
 // Детальные структуры для эндпоинтов
 type IncomeDetailsResponse struct {
-	TotalAmount    float64             `json:"total_amount"`
+	TotalAmount    models.Money        `json:"total_amount"`
 	Recommendation string              `json:"recommendation"`
 	Breakdown      []CategoryBreakdown `json:"breakdown"`
 }
 
 type ExpenseDetailsResponse struct {
-	TotalAmount    float64             `json:"total_amount"`
-	Essential      float64             `json:"essential"`
-	NonEssential   float64             `json:"non_essential"`
+	TotalAmount    models.Money        `json:"total_amount"`
+	Essential      models.Money        `json:"essential"`
+	NonEssential   models.Money        `json:"non_essential"`
 	Recommendation string              `json:"recommendation"`
 	Breakdown      []CategoryBreakdown `json:"breakdown"`
 }
 
 type SavingsDetailsResponse struct {
-	TotalBalance        float64 `json:"total_balance"`
-	EmergencyFundMonths float64 `json:"emergency_fund_months"`
-	TargetAmount        float64 `json:"target_amount"`        // Цель финансовой подушки (6 месяцев расходов)
-	AvgMonthlyExpense   float64 `json:"avg_monthly_expense"`   // Средние расходы за месяц
-	Recommendation      string  `json:"recommendation"`
+	TotalBalance        models.Money `json:"total_balance"`
+	EmergencyFundMonths float64      `json:"emergency_fund_months"`
+	TargetAmount        models.Money `json:"target_amount"`       // Цель финансовой подушки (6 месяцев расходов)
+	AvgMonthlyExpense   models.Money `json:"avg_monthly_expense"` // Средние расходы за месяц
+	Recommendation      string       `json:"recommendation"`
 }
 
 type EssentialRatioDetailsResponse struct {
-	TotalExpense        float64 `json:"total_expense"`
-	EssentialExpense    float64 `json:"essential_expense"`
-	NonEssentialExpense float64 `json:"non_essential_expense"`
-	Ratio               float64 `json:"ratio"`
-	Recommendation      string  `json:"recommendation"`
+	TotalExpense        models.Money `json:"total_expense"`
+	EssentialExpense    models.Money `json:"essential_expense"`
+	NonEssentialExpense models.Money `json:"non_essential_expense"`
+	Ratio               float64      `json:"ratio"`
+	Recommendation      string       `json:"recommendation"`
 }
 
 type InvestmentDetailsResponse struct {
-	TotalAmount    float64               `json:"total_amount"`
-	CurrentValue   float64               `json:"current_value"`  // Текущая стоимость (если указана)
-	Profit         float64               `json:"profit"`         // Прибыль (current_value - total_amount)
+	TotalAmount    models.Money          `json:"total_amount"`
+	CurrentValue   models.Money          `json:"current_value"`  // Текущая стоимость (если указана)
+	Profit         models.Money          `json:"profit"`         // Прибыль (current_value - total_amount)
 	ProfitPercent  float64               `json:"profit_percent"` // Процент прибыли
 	Recommendation string                `json:"recommendation"`
 	Breakdown      []InvestmentBreakdown `json:"breakdown"`
 }
 
 type DepositDetailsResponse struct {
-	TotalAmount     float64            `json:"total_amount"`
-	TotalInterest   float64            `json:"total_interest"` // Общий процентный доход
-	ActiveDeposits  int                `json:"active_deposits"` // Количество активных вкладов
-	Recommendation  string             `json:"recommendation"`
-	Breakdown       []DepositBreakdown `json:"breakdown"`
+	TotalAmount    models.Money       `json:"total_amount"`
+	TotalInterest  models.Money       `json:"total_interest"`  // Общий процентный доход
+	ActiveDeposits int                `json:"active_deposits"` // Количество активных вкладов
+	Recommendation string             `json:"recommendation"`
+	Breakdown      []DepositBreakdown `json:"breakdown"`
 }
 
 type InvestmentBreakdown struct {
-	Type    string  `json:"type"`
-	Amount  float64 `json:"amount"`
-	Percent float64 `json:"percent"`
+	Type    string       `json:"type"`
+	Amount  models.Money `json:"amount"`
+	Percent float64      `json:"percent"`
 }
 
 type DepositBreakdown struct {
-	Description  string  `json:"description"`
-	Amount       float64 `json:"amount"`
-	InterestRate float64 `json:"interest_rate"`
-	Percent      float64 `json:"percent"`
+	Description  string       `json:"description"`
+	Amount       models.Money `json:"amount"`
+	InterestRate float64      `json:"interest_rate"`
+	Percent      float64      `json:"percent"`
 }
 
 type CategoryBreakdown struct {
-	Category string  `json:"category"`
-	Amount   float64 `json:"amount"`
-	Percent  float64 `json:"percent"`
+	Category string       `json:"category"`
+	Amount   models.Money `json:"amount"`
+	Percent  float64      `json:"percent"`
 }
 
 // GetIncomeDetails - детальная информация о доходах
 func (s *HealthScoreService) GetIncomeDetails(userID uint, month string) (*IncomeDetailsResponse, error) {
-	monthTime, _ := time.Parse("2006-01", month)
-	startDate := month + "-01"
-	lastDay := monthTime.AddDate(0, 1, 0).AddDate(0, 0, -1)
-	endDate := lastDay.Format("2006-01-02")
-
-	var totalIncome float64
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'income' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&totalIncome)
-
-	var breakdown []CategoryBreakdown
-	var categoryStats []struct {
-		Category string
-		Amount   float64
-	}
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'income' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("category, COALESCE(SUM(amount), 0) as amount").
-		Group("category").
-		Scan(&categoryStats)
-
-	for _, stat := range categoryStats {
-		if stat.Category == "" {
-			stat.Category = "Без категории"
-		}
-		percent := 0.0
-		if totalIncome > 0 {
-			percent = (stat.Amount / totalIncome) * 100
-		}
-		breakdown = append(breakdown, CategoryBreakdown{
-			Category: stat.Category,
-			Amount:   math.Round(stat.Amount*100) / 100,
-			Percent:  math.Round(percent*100) / 100,
-		})
-	}
+	startDate, endDate := MonthRange(month)
+	period := PeriodQuery(s.db, userID, startDate, endDate)
 
-	var totalExpense float64
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&totalExpense)
+	totals := SumTotals(period)
+	totalIncome, totalExpense := totals.Income, totals.Expense
+
+	breakdown := categoryBreakdown(IncomeByCategory(period))
 
 	savingsRate := 0.0
 	if totalIncome > 0 {
-		savingsRate = ((totalIncome - totalExpense) / totalIncome) * 100
+		savingsRate = (totalIncome - totalExpense).Ratio(totalIncome) * 100
 	}
 
 	recommendation := ""
@@ -785,7 +704,7 @@ func (s *HealthScoreService) GetIncomeDetails(userID uint, month string) (*Incom
 	}
 
 	return &IncomeDetailsResponse{
-		TotalAmount:    math.Round(totalIncome*100) / 100,
+		TotalAmount:    totalIncome,
 		Recommendation: recommendation,
 		Breakdown:      breakdown,
 	}, nil
@@ -793,54 +712,19 @@ func (s *HealthScoreService) GetIncomeDetails(userID uint, month string) (*Incom
 
 // GetExpenseDetails - детальная информация о расходах
 func (s *HealthScoreService) GetExpenseDetails(userID uint, month string) (*ExpenseDetailsResponse, error) {
-	monthTime, _ := time.Parse("2006-01", month)
-	startDate := month + "-01"
-	lastDay := monthTime.AddDate(0, 1, 0).AddDate(0, 0, -1)
-	endDate := lastDay.Format("2006-01-02")
-
-	var totalExpense, essentialExpense float64
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&totalExpense)
+	startDate, endDate := MonthRange(month)
+	period := PeriodQuery(s.db, userID, startDate, endDate)
 
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND is_essential = true AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&essentialExpense)
+	totals := SumTotals(period)
+	totalExpense, essentialExpense := totals.Expense, totals.Essential
+	nonEssentialExpense := totals.NonEssential()
 
-	nonEssentialExpense := totalExpense - essentialExpense
-
-	var breakdown []CategoryBreakdown
-	var categoryStats []struct {
-		Category string
-		Amount   float64
-	}
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("category, COALESCE(SUM(amount), 0) as amount").
-		Group("category").
-		Scan(&categoryStats)
-
-	for _, stat := range categoryStats {
-		if stat.Category == "" {
-			stat.Category = "Без категории"
-		}
-		percent := 0.0
-		if totalExpense > 0 {
-			percent = (stat.Amount / totalExpense) * 100
-		}
-		breakdown = append(breakdown, CategoryBreakdown{
-			Category: stat.Category,
-			Amount:   math.Round(stat.Amount*100) / 100,
-			Percent:  math.Round(percent*100) / 100,
-		})
-	}
+	breakdown := categoryBreakdown(ExpenseByCategory(period))
 
 	recommendation := "Ваши общие расходы составляют " + formatMoney(totalExpense) + " рублей. "
 	essentialRatio := 0.0
 	if totalExpense > 0 {
-		essentialRatio = (essentialExpense / totalExpense) * 100
+		essentialRatio = essentialExpense.Ratio(totalExpense) * 100
 	}
 
 	if essentialRatio < 50 {
@@ -852,9 +736,9 @@ func (s *HealthScoreService) GetExpenseDetails(userID uint, month string) (*Expe
 	}
 
 	return &ExpenseDetailsResponse{
-		TotalAmount:    math.Round(totalExpense*100) / 100,
-		Essential:      math.Round(essentialExpense*100) / 100,
-		NonEssential:   math.Round(nonEssentialExpense*100) / 100,
+		TotalAmount:    totalExpense,
+		Essential:      essentialExpense,
+		NonEssential:   nonEssentialExpense,
 		Recommendation: recommendation,
 		Breakdown:      breakdown,
 	}, nil
@@ -862,16 +746,12 @@ func (s *HealthScoreService) GetExpenseDetails(userID uint, month string) (*Expe
 
 // GetSavingsDetails - детальная информация о накоплениях
 func (s *HealthScoreService) GetSavingsDetails(userID uint) (*SavingsDetailsResponse, error) {
-	var totalBalance float64
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ?", userID).
-		Select("COALESCE(SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END), 0)").
-		Scan(&totalBalance)
+	totalBalance := TotalBalance(s.db, s.fx, userID, "")
 
 	// Финансовая подушка рассчитывается на основе ВСЕХ расходов за последние 3 месяца
 	// Это гарантирует, что цель не будет расти при каждом новом доходе
 	now := time.Now()
-	var totalExpense float64
+	var totalExpense models.Money
 	monthCount := 0
 
 	// Считаем все расходы за последние 3 месяца
@@ -881,11 +761,7 @@ func (s *HealthScoreService) GetSavingsDetails(userID uint) (*SavingsDetailsResp
 		lastDay := monthDate.AddDate(0, 1, 0).AddDate(0, 0, -1)
 		endDate := lastDay.Format("2006-01-02")
 
-		var monthExpense float64
-		s.db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, startDate, endDate).
-			Select("COALESCE(SUM(ABS(amount)), 0)").
-			Scan(&monthExpense)
+		monthExpense := PeriodTotals(s.db, userID, startDate, endDate).Expense
 
 		if monthExpense > 0 {
 			totalExpense += monthExpense
@@ -900,7 +776,7 @@ func (s *HealthScoreService) GetSavingsDetails(userID uint) (*SavingsDetailsResp
 			Month string
 		}
 		s.db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'expense'", userID).
+			Where("user_id = ? AND type = 'expense' AND transfer_id IS NULL", userID).
 			Select("DISTINCT DATE_TRUNC('month', date)::text as month").
 			Order("month").
 			Scan(&monthsWithTransactions)
@@ -911,15 +787,12 @@ func (s *HealthScoreService) GetSavingsDetails(userID uint) (*SavingsDetailsResp
 		}
 
 		// Суммируем все расходы
-		s.db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'expense'", userID).
-			Select("COALESCE(SUM(ABS(amount)), 0)").
-			Scan(&totalExpense)
+		totalExpense = PeriodTotals(s.db, userID, "", "").Expense
 	}
 
-	avgExpense := 0.0
+	var avgExpense models.Money
 	if monthCount > 0 {
-		avgExpense = totalExpense / float64(monthCount)
+		avgExpense = totalExpense.MulRate(1 / float64(monthCount))
 	}
 
 	// Определяем количество месяцев на основе стабильности дохода
@@ -931,14 +804,10 @@ func (s *HealthScoreService) GetSavingsDetails(userID uint) (*SavingsDetailsResp
 		lastDay := monthDate.AddDate(0, 1, 0).AddDate(0, 0, -1)
 		endDate := lastDay.Format("2006-01-02")
 
-		var monthIncome float64
-		s.db.Model(&models.Transaction{}).
-			Where("user_id = ? AND type = 'income' AND date >= ? AND date <= ?", userID, startDate, endDate).
-			Select("COALESCE(SUM(amount), 0)").
-			Scan(&monthIncome)
+		monthIncome := PeriodTotals(s.db, userID, startDate, endDate).Income
 
 		if monthIncome > 0 {
-			incomeValues = append(incomeValues, monthIncome)
+			incomeValues = append(incomeValues, monthIncome.Float64())
 		}
 	}
 
@@ -955,7 +824,7 @@ func (s *HealthScoreService) GetSavingsDetails(userID uint) (*SavingsDetailsResp
 			variance := (sumSq / float64(len(incomeValues))) - (mean * mean)
 			stdDev := math.Sqrt(variance)
 			cv := stdDev / mean // Коэффициент вариации
-			
+
 			// Если доход стабильный (CV < 0.2), достаточно 3 месяцев
 			// Если нестабильный (CV >= 0.2), нужно 6 месяцев
 			if cv < 0.2 {
@@ -965,12 +834,12 @@ func (s *HealthScoreService) GetSavingsDetails(userID uint) (*SavingsDetailsResp
 	}
 
 	// Цель финансовой подушки = средние расходы × количество месяцев
-	targetAmount := avgExpense * targetMonths
+	targetAmount := avgExpense.MulRate(targetMonths)
 
 	// Текущая подушка = сколько месяцев расходов покрывает текущий баланс
 	emergencyFundMonths := 0.0
 	if avgExpense > 0 {
-		emergencyFundMonths = totalBalance / avgExpense
+		emergencyFundMonths = totalBalance.Ratio(avgExpense)
 	}
 
 	recommendation := "Ваши свободные средства составляют " + formatMoney(totalBalance) + " рублей. "
@@ -979,7 +848,7 @@ func (s *HealthScoreService) GetSavingsDetails(userID uint) (*SavingsDetailsResp
 	} else {
 		monthsText := fmt.Sprintf("%.0f", targetMonths)
 		if emergencyFundMonths < 3 {
-			recommendation += "Финансовая подушка критически мала. Рекомендуется накопить минимум 3 месяца расходов (примерно " + formatMoney(avgExpense*3) + " рублей). Оптимальная цель: " + formatMoney(targetAmount) + " рублей (" + monthsText + " месяцев расходов, рассчитано на основе средних расходов за последние 3 месяца)."
+			recommendation += "Финансовая подушка критически мала. Рекомендуется накопить минимум 3 месяца расходов (примерно " + formatMoney(avgExpense.MulRate(3)) + " рублей). Оптимальная цель: " + formatMoney(targetAmount) + " рублей (" + monthsText + " месяцев расходов, рассчитано на основе средних расходов за последние 3 месяца)."
 		} else if emergencyFundMonths >= targetMonths {
 			recommendation += "Отличная финансовая подушка! У вас достаточно средств на " + formatMonths(emergencyFundMonths) + " расходов."
 		} else {
@@ -988,36 +857,24 @@ func (s *HealthScoreService) GetSavingsDetails(userID uint) (*SavingsDetailsResp
 	}
 
 	return &SavingsDetailsResponse{
-		TotalBalance:        math.Round(totalBalance*100) / 100,
+		TotalBalance:        totalBalance,
 		EmergencyFundMonths: math.Round(emergencyFundMonths*100) / 100,
-		TargetAmount:        math.Round(targetAmount*100) / 100,
-		AvgMonthlyExpense:   math.Round(avgExpense*100) / 100, // Средние расходы за месяц
+		TargetAmount:        targetAmount,
+		AvgMonthlyExpense:   avgExpense, // Средние расходы за месяц
 		Recommendation:      recommendation,
 	}, nil
 }
 
 // GetEssentialRatioDetails - детальная информация о балансе обязательных/необязательных расходов
 func (s *HealthScoreService) GetEssentialRatioDetails(userID uint, month string) (*EssentialRatioDetailsResponse, error) {
-	monthTime, _ := time.Parse("2006-01", month)
-	startDate := month + "-01"
-	lastDay := monthTime.AddDate(0, 1, 0).AddDate(0, 0, -1)
-	endDate := lastDay.Format("2006-01-02")
-
-	var totalExpense, essentialExpense float64
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&totalExpense)
-
-	s.db.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = 'expense' AND is_essential = true AND date >= ? AND date <= ?", userID, startDate, endDate).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&essentialExpense)
+	startDate, endDate := MonthRange(month)
 
+	totals := PeriodTotals(s.db, userID, startDate, endDate)
+	totalExpense, essentialExpense := totals.Expense, totals.Essential
 	nonEssentialExpense := totalExpense - essentialExpense
 	ratio := 0.0
 	if totalExpense > 0 {
-		ratio = (essentialExpense / totalExpense) * 100
+		ratio = essentialExpense.Ratio(totalExpense) * 100
 	}
 
 	recommendation := ""
@@ -1030,17 +887,40 @@ func (s *HealthScoreService) GetEssentialRatioDetails(userID uint, month string)
 	}
 
 	return &EssentialRatioDetailsResponse{
-		TotalExpense:        math.Round(totalExpense*100) / 100,
-		EssentialExpense:    math.Round(essentialExpense*100) / 100,
-		NonEssentialExpense: math.Round(nonEssentialExpense*100) / 100,
+		TotalExpense:        totalExpense,
+		EssentialExpense:    essentialExpense,
+		NonEssentialExpense: nonEssentialExpense,
 		Ratio:               math.Round(ratio*100) / 100,
 		Recommendation:      recommendation,
 	}, nil
 }
 
 // Вспомогательные функции для форматирования
-func formatMoney(amount float64) string {
-	return fmt.Sprintf("%.2f", amount)
+func formatMoney(amount models.Money) string {
+	return amount.String()
+}
+
+// categoryBreakdown - разбивка по категориям с долями, которые в сумме дают 100%
+func categoryBreakdown(stats []CategoryAmount) []CategoryBreakdown {
+	amounts := make([]models.Money, len(stats))
+	for i, stat := range stats {
+		amounts[i] = stat.Amount
+	}
+	shares := models.PercentShares(amounts)
+
+	breakdown := make([]CategoryBreakdown, 0, len(stats))
+	for i, stat := range stats {
+		category := stat.Category
+		if category == "" {
+			category = "Без категории"
+		}
+		breakdown = append(breakdown, CategoryBreakdown{
+			Category: category,
+			Amount:   stat.Amount,
+			Percent:  shares[i],
+		})
+	}
+	return breakdown
 }
 
 func formatMonths(months float64) string {
@@ -1056,47 +936,49 @@ func formatMonths(months float64) string {
 
 // GetInvestmentDetails - детальная информация об инвестициях
 func (s *HealthScoreService) GetInvestmentDetails(userID uint) (*InvestmentDetailsResponse, error) {
-	var totalAmount, currentValue float64
+	var totalAmount, currentValue models.Money
 
 	// Сумма всех инвестиций
 	s.db.Model(&models.Investment{}).
 		Where("user_id = ?", userID).
-		Select("COALESCE(SUM(amount), 0)").
+		Select("COALESCE(SUM(base_amount), 0)").
 		Scan(&totalAmount)
 
 	// Текущая стоимость (если указана)
 	s.db.Model(&models.Investment{}).
-		Where("user_id = ? AND current_value > 0", userID).
-		Select("COALESCE(SUM(current_value), 0)").
+		Where("user_id = ? AND base_current_value > 0", userID).
+		Select("COALESCE(SUM(base_current_value), 0)").
 		Scan(&currentValue)
 
 	profit := currentValue - totalAmount
 	profitPercent := 0.0
 	if totalAmount > 0 {
-		profitPercent = (profit / totalAmount) * 100
+		profitPercent = profit.Ratio(totalAmount) * 100
 	}
 
 	// Разбивка по типам
 	var typeStats []struct {
 		Type   string
-		Amount float64
+		Amount models.Money
 	}
 	s.db.Model(&models.Investment{}).
 		Where("user_id = ?", userID).
-		Select("COALESCE(type, 'Не указано') as type, COALESCE(SUM(amount), 0) as amount").
+		Select("COALESCE(type, 'Не указано') as type, COALESCE(SUM(base_amount), 0) as amount").
 		Group("type").
 		Find(&typeStats)
 
+	typeAmounts := make([]models.Money, len(typeStats))
+	for i, stat := range typeStats {
+		typeAmounts[i] = stat.Amount
+	}
+	typeShares := models.PercentShares(typeAmounts)
+
 	breakdown := []InvestmentBreakdown{}
-	for _, stat := range typeStats {
-		percent := 0.0
-		if totalAmount > 0 {
-			percent = (stat.Amount / totalAmount) * 100
-		}
+	for i, stat := range typeStats {
 		breakdown = append(breakdown, InvestmentBreakdown{
 			Type:    stat.Type,
-			Amount:  math.Round(stat.Amount*100) / 100,
-			Percent: math.Round(percent*100) / 100,
+			Amount:  stat.Amount,
+			Percent: typeShares[i],
 		})
 	}
 
@@ -1112,9 +994,9 @@ func (s *HealthScoreService) GetInvestmentDetails(userID uint) (*InvestmentDetai
 	}
 
 	return &InvestmentDetailsResponse{
-		TotalAmount:    math.Round(totalAmount*100) / 100,
-		CurrentValue:   math.Round(currentValue*100) / 100,
-		Profit:         math.Round(profit*100) / 100,
+		TotalAmount:    totalAmount,
+		CurrentValue:   currentValue,
+		Profit:         profit,
 		ProfitPercent:  math.Round(profitPercent*100) / 100,
 		Recommendation: recommendation,
 		Breakdown:      breakdown,
@@ -1123,51 +1005,52 @@ func (s *HealthScoreService) GetInvestmentDetails(userID uint) (*InvestmentDetai
 
 // GetDepositDetails - детальная информация о вкладах
 func (s *HealthScoreService) GetDepositDetails(userID uint) (*DepositDetailsResponse, error) {
-	var totalAmount float64
+	var totalAmount models.Money
 
 	// Сумма всех активных вкладов (не закрытых)
 	s.db.Model(&models.Deposit{}).
 		Where("user_id = ? AND close_date IS NULL", userID).
-		Select("COALESCE(SUM(amount), 0)").
+		Select("COALESCE(SUM(base_amount), 0)").
 		Scan(&totalAmount)
 
 	// Расчет процентного дохода (упрощенный: сумма * процент / 100)
 	var deposits []models.Deposit
 	s.db.Where("user_id = ? AND close_date IS NULL", userID).Find(&deposits)
 
-	totalInterest := 0.0
+	var totalInterest models.Money
 	for _, deposit := range deposits {
 		if deposit.InterestRate > 0 && deposit.TermMonths > 0 {
 			// Простой расчет: сумма * процент * срок в годах
 			years := float64(deposit.TermMonths) / 12.0
-			interest := deposit.Amount * (deposit.InterestRate / 100.0) * years
-			totalInterest += interest
+			totalInterest += deposit.BaseAmount.MulRate(deposit.InterestRate / 100.0 * years)
 		}
 	}
 
 	// Разбивка по вкладам
 	var depositStats []models.Deposit
 	s.db.Where("user_id = ? AND close_date IS NULL", userID).
-		Order("amount desc").
+		Order("base_amount desc").
 		Find(&depositStats)
 
 	activeDepositsCount := len(depositStats)
 
+	depositAmounts := make([]models.Money, len(depositStats))
+	for i, deposit := range depositStats {
+		depositAmounts[i] = deposit.BaseAmount
+	}
+	depositShares := models.PercentShares(depositAmounts)
+
 	breakdown := []DepositBreakdown{}
-	for _, deposit := range depositStats {
-		percent := 0.0
-		if totalAmount > 0 {
-			percent = (deposit.Amount / totalAmount) * 100
-		}
+	for i, deposit := range depositStats {
 		desc := deposit.Description
 		if desc == "" {
 			desc = "Вклад"
 		}
 		breakdown = append(breakdown, DepositBreakdown{
 			Description:  desc,
-			Amount:       math.Round(deposit.Amount*100) / 100,
+			Amount:       deposit.BaseAmount,
 			InterestRate: math.Round(deposit.InterestRate*100) / 100,
-			Percent:      math.Round(percent*100) / 100,
+			Percent:      depositShares[i],
 		})
 	}
 
@@ -1182,8 +1065,8 @@ func (s *HealthScoreService) GetDepositDetails(userID uint) (*DepositDetailsResp
 	}
 
 	return &DepositDetailsResponse{
-		TotalAmount:    math.Round(totalAmount*100) / 100,
-		TotalInterest:  math.Round(totalInterest*100) / 100,
+		TotalAmount:    totalAmount,
+		TotalInterest:  totalInterest,
 		ActiveDeposits: activeDepositsCount,
 		Recommendation: recommendation,
 		Breakdown:      breakdown,
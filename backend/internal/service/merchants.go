@@ -0,0 +1,185 @@
+package service
+
+import (
+	"clarity/internal/models"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// NormalizePatterns - шаблоны продавца в виде ключей NormalizeMerchant, без пустых и повторов
+func NormalizePatterns(patterns []string) []string {
+	result := []string{}
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		key := models.NormalizeMerchant(pattern)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, key)
+	}
+	return result
+}
+
+// MatchMerchant - продавец справочника для ключа транзакции. Шаблон совпадает, если он
+// входит в ключ целыми словами ("yandex taxi" совпадает с "yandex taxi comfort");
+// каноническое имя тоже считается шаблоном. При нескольких совпадениях побеждает самый
+// длинный шаблон.
+func MatchMerchant(merchants []models.Merchant, key string) *models.Merchant {
+	if key == "" {
+		return nil
+	}
+	padded := " " + key + " "
+	var best *models.Merchant
+	bestLength := 0
+	for i := range merchants {
+		patterns := append([]string{models.NormalizeMerchant(merchants[i].Name)}, merchants[i].Patterns...)
+		for _, pattern := range patterns {
+			if pattern == "" || len(pattern) <= bestLength || !strings.Contains(padded, " "+pattern+" ") {
+				continue
+			}
+			best, bestLength = &merchants[i], len(pattern)
+		}
+	}
+	return best
+}
+
+// LoadMerchants - справочник продавцов пользователя
+func LoadMerchants(db *gorm.DB, userID uint) ([]models.Merchant, error) {
+	var merchants []models.Merchant
+	err := db.Where("user_id = ?", userID).Order("id asc").Find(&merchants).Error
+	return merchants, err
+}
+
+// AssignMerchant - продавец транзакции по описанию (или ref_no) и справочнику пользователя
+func AssignMerchant(tx *models.Transaction, merchants []models.Merchant) {
+	tx.Merchant = models.MerchantKey(tx.Description, tx.RefNo)
+	tx.MerchantID = nil
+	if merchant := MatchMerchant(merchants, tx.Merchant); merchant != nil {
+		id := merchant.ID
+		tx.MerchantID = &id
+	}
+}
+
+// RelinkMerchants - заново связывает транзакции пользователя со справочником после изменения
+// продавцов: ключи транзакций не меняются, меняется только merchant_id. Транзакции в корзине
+// связываются тоже, чтобы после восстановления не ссылаться на удаленного продавца.
+func RelinkMerchants(db *gorm.DB, userID uint) error {
+	merchants, err := LoadMerchants(db, userID)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	if err := db.Unscoped().Model(&models.Transaction{}).
+		Where("user_id = ? AND merchant <> ''", userID).
+		Distinct("merchant").Pluck("merchant", &keys).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Transaction{}).Where("user_id = ? AND merchant_id IS NOT NULL", userID).
+			Update("merchant_id", nil).Error; err != nil {
+			return err
+		}
+		byMerchant := make(map[uint][]string)
+		for _, key := range keys {
+			if merchant := MatchMerchant(merchants, key); merchant != nil {
+				byMerchant[merchant.ID] = append(byMerchant[merchant.ID], key)
+			}
+		}
+		for id, keys := range byMerchant {
+			if err := tx.Unscoped().Model(&models.Transaction{}).Where("user_id = ? AND merchant IN ?", userID, keys).
+				Update("merchant_id", id).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MerchantStat - траты у продавца за период
+type MerchantStat struct {
+	MerchantID     *uint        `json:"merchant_id,omitempty"` // Продавец из справочника (пусто - только нормализованный ключ)
+	Merchant       string       `json:"merchant"`              // Каноническое имя или нормализованный ключ
+	Category       string       `json:"category,omitempty"`    // Категория продавца по умолчанию
+	Amount         models.Money `json:"amount"`
+	Share          float64      `json:"share"`            // Доля в сумме по всем продавцам, %
+	Visits         int          `json:"visits"`           // Количество транзакций
+	VisitsPerMonth float64      `json:"visits_per_month"` // Частота посещений в среднем за 30 дней периода
+	AverageTicket  models.Money `json:"average_ticket"`   // Средний чек
+	FirstDate      string       `json:"first_date"`
+	LastDate       string       `json:"last_date"`
+}
+
+// merchantRow - строка агрегата по продавцам
+type merchantRow struct {
+	MerchantID *uint
+	Merchant   string
+	Category   string
+	Amount     models.Money
+	Visits     int
+	FirstDate  string
+	LastDate   string
+}
+
+// TopMerchants - продавцы по убыванию суммы за период [start, end] (YYYY-MM-DD) в базовой
+// валюте. Транзакции одного продавца справочника объединяются, остальные группируются по
+// нормализованному ключу. days - длина периода для частоты посещений. Ноги переводов и
+// транзакции без продавца не учитываются.
+func TopMerchants(db *gorm.DB, userID uint, txType, start, end string, days int, limit int) ([]MerchantStat, models.Money, error) {
+	sign := "-"
+	if txType == "income" {
+		sign = ""
+	}
+	query := db.Table("transactions t").
+		Joins("LEFT JOIN merchants m ON m.id = t.merchant_id").
+		Where("t.user_id = ? AND t.deleted_at IS NULL AND t.transfer_id IS NULL AND t.type = ? AND t.merchant <> ''", userID, txType)
+	if start != "" {
+		query = query.Where("t.date >= ?", start)
+	}
+	if end != "" {
+		query = query.Where("t.date <= ?", end)
+	}
+
+	var total models.Money
+	if err := query.Session(&gorm.Session{}).
+		Select("COALESCE(" + sign + "SUM(t.base_amount), 0)").Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []merchantRow
+	err := query.
+		Select("t.merchant_id, COALESCE(MAX(m.name), MAX(t.merchant)) AS merchant, COALESCE(MAX(m.category), '') AS category, " +
+			"COALESCE(" + sign + "SUM(t.base_amount), 0) AS amount, COUNT(*) AS visits, " +
+			"TO_CHAR(MIN(t.date), 'YYYY-MM-DD') AS first_date, TO_CHAR(MAX(t.date), 'YYYY-MM-DD') AS last_date").
+		Group("t.merchant_id, CASE WHEN t.merchant_id IS NULL THEN t.merchant END").
+		Order("amount desc, visits desc").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stats := make([]MerchantStat, len(rows))
+	for i, row := range rows {
+		stats[i] = MerchantStat{
+			MerchantID: row.MerchantID,
+			Merchant:   row.Merchant,
+			Category:   row.Category,
+			Amount:     row.Amount,
+			Share:      math.Round(row.Amount.Ratio(total)*10000) / 100,
+			Visits:     row.Visits,
+			FirstDate:  row.FirstDate,
+			LastDate:   row.LastDate,
+		}
+		if row.Visits > 0 {
+			stats[i].AverageTicket = models.MoneyFromFloat(row.Amount.Float64() / float64(row.Visits))
+		}
+		if days > 0 {
+			stats[i].VisitsPerMonth = math.Round(float64(row.Visits)*30/float64(days)*100) / 100
+		}
+	}
+	return stats, total, nil
+}
@@ -1,32 +1,45 @@
 package service
 
 import (
-	"bytes"
+	"clarity/internal/models"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 )
 
+const (
+	// mlTimeout - таймаут категоризации одной транзакции. Она выполняется при создании
+	// транзакции, поэтому запрос не повторяется: при сбое цепочка сразу идет дальше.
+	mlTimeout          = 2 * time.Second
+	mlBatchSize        = 200 // Транзакций в одном запросе /batch_predict (сервис принимает до 1000)
+	mlBatchConcurrency = 4   // Одновременных запросов к ML сервису при пакетной категоризации
+	mlBatchTimeout     = 30 * time.Second
+)
+
 type MLClient struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL string
+	client  *OutboundClient // Одна транзакция: короткий таймаут, без повторов
+	batch   *OutboundClient // Пакет (импорт, перекатегоризация): тот же предохранитель, больше таймаут, с повтором
 }
 
 func NewMLClient(baseURL string) *MLClient {
 	return &MLClient{
 		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 5 * time.Second,
-		},
+		client:  NewOutboundClient("ml", mlTimeout, 0),
+		batch:   NewOutboundClient("ml", mlBatchTimeout, 1),
 	}
 }
 
 type CategorizeRequest struct {
-	Amount float64 `json:"amount" binding:"required"`
-	Date   string  `json:"date" binding:"required"`
-	RefNo  string  `json:"ref_no" binding:"required"`
+	Amount models.Money `json:"amount" binding:"required"`
+	Date   string       `json:"date" binding:"required"`
+	RefNo  string       `json:"ref_no" binding:"required"`
 }
 
 type CategorizeResponse struct {
@@ -34,13 +47,8 @@ type CategorizeResponse struct {
 	Confidence float64 `json:"confidence"`
 }
 
-// Categorize - устаревший метод, используйте CategorizeWithDate
-func (c *MLClient) Categorize(description string, amount float64) (*CategorizeResponse, error) {
-	return c.CategorizeWithDate(description, amount, time.Now())
-}
-
 // CategorizeWithDate - категоризация с датой и ref_no (новый формат)
-func (c *MLClient) CategorizeWithDate(refNo string, amount float64, date time.Time) (*CategorizeResponse, error) {
+func (c *MLClient) CategorizeWithDate(ctx context.Context, refNo string, amount models.Money, date time.Time) (*CategorizeResponse, error) {
 	reqBody := CategorizeRequest{
 		Amount: amount,
 		Date:   date.Format("2006-01-02"),
@@ -52,11 +60,12 @@ func (c *MLClient) CategorizeWithDate(refNo string, amount float64, date time.Ti
 		return nil, err
 	}
 
-	resp, err := c.httpClient.Post(
-		fmt.Sprintf("%s/categorize", c.baseURL),
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	resp, err := c.client.Do(ctx, OutboundRequest{
+		Method:     http.MethodPost,
+		URL:        fmt.Sprintf("%s/categorize", c.baseURL),
+		Body:       jsonData,
+		Idempotent: true,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -74,3 +83,129 @@ func (c *MLClient) CategorizeWithDate(refNo string, amount float64, date time.Ti
 
 	return &result, nil
 }
+
+// BatchPrediction - предсказание из ответа /batch_predict (PredictionResult в ml/api.py)
+type BatchPrediction struct {
+	Category    string  `json:"category"`
+	Confidence  float64 `json:"confidence"`
+	Method      string  `json:"method"`
+	IsRuleBased bool    `json:"is_rule_based"`
+}
+
+// BatchResult - результат одной транзакции пакета (BatchResult в ml/api.py).
+// Index - позиция транзакции в запросе; при success=false заполнено error.
+type BatchResult struct {
+	Index      int              `json:"index"`
+	Success    bool             `json:"success"`
+	Prediction *BatchPrediction `json:"prediction"`
+	Error      string           `json:"error"`
+}
+
+// BatchPredictResponse - ответ POST /batch_predict. Тело запроса - JSON-массив
+// CategorizeRequest (не больше 1000 элементов), ответ - результат на каждый элемент.
+type BatchPredictResponse struct {
+	Success bool          `json:"success"`
+	Results []BatchResult `json:"results"`
+}
+
+// CategorizeBatch - категоризация пакета транзакций. Запросы бьются на части по mlBatchSize
+// и отправляются не больше чем по mlBatchConcurrency одновременно. Транзакции, по которым
+// сервис не ответил (или весь запрос части вернул ошибку), категоризируются поштучно
+// запросом /categorize; если сервис недоступен (или разомкнут предохранитель), поштучных
+// запросов нет. Результат и
+// ошибка - по индексу запроса.
+func (c *MLClient) CategorizeBatch(ctx context.Context, items []CategorizeRequest) ([]*CategorizeResponse, []error) {
+	results := make([]*CategorizeResponse, len(items))
+	errs := make([]error, len(items))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, mlBatchConcurrency)
+	for start := 0; start < len(items); start += mlBatchSize {
+		end := min(start+mlBatchSize, len(items))
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				for i := start; i < end; i++ {
+					errs[i] = ctx.Err()
+				}
+				return
+			}
+			c.categorizeChunk(ctx, items[start:end], results[start:end], errs[start:end])
+		}(start, end)
+	}
+	wg.Wait()
+	return results, errs
+}
+
+// categorizeChunk - один запрос /batch_predict с поштучным запросом для неудавшихся транзакций
+func (c *MLClient) categorizeChunk(ctx context.Context, items []CategorizeRequest, results []*CategorizeResponse, errs []error) {
+	predictions, err := c.batchPredict(ctx, items)
+	var unavailable *url.Error
+	if errors.As(err, &unavailable) || errors.Is(err, ErrCircuitOpen) {
+		for i := range items {
+			errs[i] = err
+		}
+		return
+	}
+	for i, item := range items {
+		if err == nil && predictions[i] != nil {
+			results[i] = predictions[i]
+			continue
+		}
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			continue
+		}
+		date, parseErr := time.Parse("2006-01-02", item.Date)
+		if parseErr != nil {
+			errs[i] = parseErr
+			continue
+		}
+		results[i], errs[i] = c.CategorizeWithDate(ctx, item.RefNo, item.Amount, date)
+	}
+}
+
+// batchPredict - запрос /batch_predict; nil в ответе - сервис не смог категоризировать транзакцию
+func (c *MLClient) batchPredict(ctx context.Context, items []CategorizeRequest) ([]*CategorizeResponse, error) {
+	jsonData, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.batch.Do(ctx, OutboundRequest{
+		Method:     http.MethodPost,
+		URL:        fmt.Sprintf("%s/batch_predict", c.baseURL),
+		Body:       jsonData,
+		Idempotent: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ML service error: %s", string(body))
+	}
+
+	var batch BatchPredictResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return nil, err
+	}
+
+	predictions := make([]*CategorizeResponse, len(items))
+	for _, result := range batch.Results {
+		if !result.Success || result.Prediction == nil || result.Index < 0 || result.Index >= len(items) {
+			continue
+		}
+		predictions[result.Index] = &CategorizeResponse{
+			Category:   result.Prediction.Category,
+			Confidence: result.Prediction.Confidence,
+		}
+	}
+	return predictions, nil
+}
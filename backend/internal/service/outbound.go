@@ -0,0 +1,278 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	breakerFailureThreshold = 5                      // Сбоев подряд, после которых предохранитель размыкается
+	breakerOpenTimeout      = 30 * time.Second       // Сколько предохранитель разомкнут до пробного запроса
+	retryBaseDelay          = 200 * time.Millisecond // Пауза перед первым повтором, дальше удваивается
+	retryMaxDelay           = 2 * time.Second
+)
+
+// Состояния предохранителя
+const (
+	BreakerClosed   = "closed"    // Запросы идут
+	BreakerOpen     = "open"      // Зависимость считается недоступной, запросы сразу отклоняются
+	BreakerHalfOpen = "half_open" // Идет один пробный запрос
+)
+
+// ErrCircuitOpen - предохранитель зависимости разомкнут, запрос не отправлялся
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker - предохранитель внешней зависимости. Сбой - ошибка соединения, таймаут
+// или ответ 5xx; после breakerFailureThreshold сбоев подряд запросы отклоняются сразу,
+// через breakerOpenTimeout пропускается один пробный запрос.
+type CircuitBreaker struct {
+	name string
+
+	mu                  sync.Mutex
+	state               string
+	consecutiveFailures int
+	openedAt            time.Time
+	probing             bool
+	requests            int64
+	failures            int64
+	lastError           string
+	lastFailureAt       *time.Time
+}
+
+// DependencyStatus - состояние предохранителя для /health/dependencies
+type DependencyStatus struct {
+	Name                string     `json:"name"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Requests            int64      `json:"requests"`
+	Failures            int64      `json:"failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"` // Когда будет пробный запрос (для open)
+}
+
+// allow - можно ли отправить запрос сейчас
+func (b *CircuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < breakerOpenTimeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+func (b *CircuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	b.state = BreakerClosed
+	b.consecutiveFailures = 0
+	b.probing = false
+}
+
+func (b *CircuitBreaker) failure(err string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	b.failures++
+	b.consecutiveFailures++
+	b.lastError = err
+	b.lastFailureAt = &now
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.consecutiveFailures >= breakerFailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = now
+	}
+}
+
+// release - запрос не дошел до зависимости (отменен клиентом): пробу можно повторить
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *CircuitBreaker) Status() DependencyStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := DependencyStatus{
+		Name:                b.name,
+		State:               b.state,
+		ConsecutiveFailures: b.consecutiveFailures,
+		Requests:            b.requests,
+		Failures:            b.failures,
+		LastError:           b.lastError,
+		LastFailureAt:       b.lastFailureAt,
+	}
+	if b.state == BreakerOpen {
+		retryAt := b.openedAt.Add(breakerOpenTimeout)
+		status.RetryAt = &retryAt
+	}
+	return status
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[string]*CircuitBreaker)
+)
+
+// breakerFor - предохранитель зависимости; клиенты одной зависимости делят один предохранитель
+func breakerFor(name string) *CircuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	breaker := breakers[name]
+	if breaker == nil {
+		breaker = &CircuitBreaker{name: name, state: BreakerClosed}
+		breakers[name] = breaker
+	}
+	return breaker
+}
+
+// DependencyStatuses - состояние предохранителей всех внешних зависимостей по имени
+func DependencyStatuses() []DependencyStatus {
+	breakersMu.Lock()
+	list := make([]*CircuitBreaker, 0, len(breakers))
+	for _, breaker := range breakers {
+		list = append(list, breaker)
+	}
+	breakersMu.Unlock()
+
+	statuses := make([]DependencyStatus, len(list))
+	for i, breaker := range list {
+		statuses[i] = breaker.Status()
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// OutboundClient - общий HTTP слой для внешних зависимостей: таймаут попытки, предохранитель
+// зависимости и повторы с джиттером для идемпотентных запросов
+type OutboundClient struct {
+	name       string
+	httpClient *http.Client
+	breaker    *CircuitBreaker
+	retries    int
+}
+
+// NewOutboundClient - клиент зависимости name с таймаутом одной попытки и числом повторов
+func NewOutboundClient(name string, timeout time.Duration, retries int) *OutboundClient {
+	return &OutboundClient{
+		name:       name,
+		httpClient: &http.Client{Timeout: timeout},
+		breaker:    breakerFor(name),
+		retries:    retries,
+	}
+}
+
+// OutboundRequest - запрос к внешней зависимости
+type OutboundRequest struct {
+	Method     string
+	URL        string
+	Body       []byte // JSON тело; nil - без тела
+	Header     http.Header
+	Idempotent bool // Повтор не меняет состояние зависимости: запрос можно повторять при сбое
+}
+
+// Do - отправляет запрос. Ответ 5xx, ошибка соединения и таймаут - сбой зависимости;
+// идемпотентный запрос при сбое (и при 429) повторяется до retries раз с паузой
+// retryBaseDelay*2^n со случайным джиттером. Ответ последней попытки возвращается как есть
+// (в том числе 5xx), закрыть тело - задача вызывающего. Разомкнутый предохранитель -
+// ErrCircuitOpen без запроса.
+func (c *OutboundClient) Do(ctx context.Context, r OutboundRequest) (*http.Response, error) {
+	attempts := 1
+	if r.Idempotent {
+		attempts += c.retries
+	}
+
+	var last *http.Response
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		if !c.breaker.allow(time.Now()) {
+			// Предохранитель разомкнулся между попытками - возвращается ответ предыдущей
+			if attempt > 0 {
+				return last, lastErr
+			}
+			return nil, fmt.Errorf("%s: %w", c.name, ErrCircuitOpen)
+		}
+		if last != nil {
+			last.Body.Close()
+		}
+
+		resp, err := c.send(ctx, r)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			c.breaker.release()
+			return nil, err
+		case err != nil:
+			c.breaker.failure(err.Error(), time.Now())
+		case resp.StatusCode >= http.StatusInternalServerError:
+			c.breaker.failure(fmt.Sprintf("status %d", resp.StatusCode), time.Now())
+		default:
+			c.breaker.success()
+		}
+
+		retryable := err != nil || resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+		if !retryable || attempt+1 >= attempts {
+			return resp, err
+		}
+		last, lastErr = resp, err
+
+		select {
+		case <-ctx.Done():
+			if last != nil {
+				last.Body.Close()
+			}
+			return nil, ctx.Err()
+		case <-time.After(retryDelay(attempt)):
+		}
+	}
+}
+
+func (c *OutboundClient) send(ctx context.Context, r OutboundRequest) (*http.Response, error) {
+	var body io.Reader
+	if r.Body != nil {
+		body = bytes.NewReader(r.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range r.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if r.Body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.httpClient.Do(req)
+}
+
+// retryDelay - пауза перед повтором attempt+1: экспонента, случайно уменьшенная до половины
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << attempt
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
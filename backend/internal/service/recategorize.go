@@ -0,0 +1,341 @@
+package service
+
+import (
+	"clarity/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// recategorizationPageSize - транзакций, которые задание категоризирует и сохраняет за один проход
+const recategorizationPageSize = 200
+
+// RecategorizableSources - источники категории, которые задание перекатегоризации может менять.
+// Категории, заданные пользователем (manual), регулярной операцией и транзакций, созданных
+// до учета источника, задание не трогает.
+var RecategorizableSources = []string{
+	models.CategorySourceMerchant,
+	models.CategorySourceRule,
+	models.CategorySourceKeyword,
+	models.CategorySourceML,
+	models.CategorySourceBayes,
+	models.CategorySourceEnsemble,
+	models.CategorySourceDefault,
+}
+
+// RecategorizationService - фоновые задания перекатегоризации истории транзакций
+type RecategorizationService struct {
+	db          *gorm.DB
+	categorizer Categorizer
+
+	mu      sync.Mutex
+	running map[uint]context.CancelFunc // Задания, выполняемые этим экземпляром
+}
+
+func NewRecategorizationService(db *gorm.DB, categorizer Categorizer) *RecategorizationService {
+	return &RecategorizationService{db: db, categorizer: categorizer, running: make(map[uint]context.CancelFunc)}
+}
+
+// Start - запускает задание в фоне (если оно еще не выполняется)
+func (s *RecategorizationService) Start(jobID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.running[jobID]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.running[jobID] = cancel
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, jobID)
+			s.mu.Unlock()
+			cancel()
+		}()
+		if err := s.run(ctx, jobID); err != nil {
+			log.Printf("Recategorization job %d failed: %v", jobID, err)
+		}
+	}()
+}
+
+// Cancel - останавливает задание после текущей страницы; еще не начатое отменяется сразу
+func (s *RecategorizationService) Cancel(job *models.RecategorizationJob) error {
+	now := time.Now()
+	result := s.db.Model(job).
+		Where("status IN ?", []string{models.RecategorizationPending, models.RecategorizationRunning}).
+		Updates(map[string]interface{}{"status": models.RecategorizationCancelled, "finished_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		job.Status = models.RecategorizationCancelled
+		job.FinishedAt = &now
+	}
+
+	s.mu.Lock()
+	cancel := s.running[job.ID]
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+}
+
+// ResumeInterrupted - продолжает задания, прерванные остановкой сервера
+func (s *RecategorizationService) ResumeInterrupted() (int, error) {
+	var ids []uint
+	err := s.db.Model(&models.RecategorizationJob{}).
+		Where("status IN ?", []string{models.RecategorizationPending, models.RecategorizationRunning}).
+		Order("id asc").Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		s.Start(id)
+	}
+	return len(ids), nil
+}
+
+// run - обходит транзакции задания страницами от Cursor. Изменения страницы, найденные
+// различия и прогресс сохраняются одной транзакцией БД, поэтому после остановки задание
+// продолжается без повторов и пропусков.
+func (s *RecategorizationService) run(ctx context.Context, jobID uint) error {
+	var job models.RecategorizationJob
+	if err := s.db.First(&job, jobID).Error; err != nil {
+		return err
+	}
+	if job.Status != models.RecategorizationPending && job.Status != models.RecategorizationRunning {
+		return nil
+	}
+
+	// Total - уже обработанные и оставшиеся под фильтром
+	var remaining int64
+	if err := s.candidates(&job).Count(&remaining).Error; err != nil {
+		return s.fail(&job, err)
+	}
+	now := time.Now()
+	job.Total = job.Processed + int(remaining)
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+	}
+	if !s.setStatus(&job, models.RecategorizationRunning, "total", "started_at") {
+		return nil
+	}
+
+	threshold := ReviewThreshold(s.db, job.UserID)
+	for {
+		if ctx.Err() != nil {
+			return nil // Отменено (статус уже cancelled) или сервер останавливается
+		}
+
+		var txs []models.Transaction
+		if err := s.candidates(&job).Order("id asc").Limit(recategorizationPageSize).Find(&txs).Error; err != nil {
+			return s.fail(&job, err)
+		}
+		if len(txs) == 0 {
+			break
+		}
+
+		probes := make([]*models.Transaction, len(txs))
+		for i := range txs {
+			probe := txs[i]
+			probe.Category = ""
+			probes[i] = &probe
+		}
+		results, errs := CategorizeAll(ctx, s.categorizer, probes)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		err := s.db.Transaction(func(db *gorm.DB) error {
+			// Отмененное задание страницу не сохраняет
+			var status string
+			if err := db.Model(&models.RecategorizationJob{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ?", job.ID).Pluck("status", &status).Error; err != nil {
+				return err
+			}
+			if status != models.RecategorizationRunning {
+				return errJobStopped
+			}
+
+			for i := range txs {
+				changed, err := s.recategorize(db, &job, &txs[i], results[i], errs[i], threshold)
+				if err != nil {
+					return err
+				}
+				if changed {
+					job.Changed++
+				}
+			}
+			job.Processed += len(txs)
+			job.Cursor = txs[len(txs)-1].ID
+			return db.Model(&job).Select("processed", "changed", "cursor").Updates(&job).Error
+		})
+		if errors.Is(err, errJobStopped) {
+			return nil
+		}
+		if err != nil {
+			return s.fail(&job, err)
+		}
+	}
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+	s.setStatus(&job, models.RecategorizationCompleted, "finished_at")
+	return nil
+}
+
+// errJobStopped - задание отменено между страницами
+var errJobStopped = errors.New("recategorization job stopped")
+
+// candidates - транзакции под фильтром задания после Cursor. Ноги переводов, транзакции
+// с разбивкой и категории, заданные пользователем, не перекатегоризируются.
+func (s *RecategorizationService) candidates(job *models.RecategorizationJob) *gorm.DB {
+	sources := job.Sources
+	if len(sources) == 0 {
+		sources = RecategorizableSources
+	}
+	query := s.db.Model(&models.Transaction{}).
+		Where("user_id = ? AND transfer_id IS NULL AND id > ?", job.UserID, job.Cursor).
+		Where("category_source IN ?", sources).
+		Where("id NOT IN (?)", s.db.Model(&models.TransactionSplit{}).Select("transaction_id"))
+	if job.StartDate != nil {
+		query = query.Where("date >= ?", *job.StartDate)
+	}
+	if job.EndDate != nil {
+		query = query.Where("date <= ?", *job.EndDate)
+	}
+	if job.Category != "" {
+		query = query.Where("category = ?", job.Category)
+	}
+	return query
+}
+
+// recategorize - сравнивает категорию транзакции с новым ответом цепочки; различие
+// записывается в отчет, а в режиме Apply категория транзакции меняется. Если цепочка
+// не ответила из-за недоступной стратегии, транзакция не меняется (а не сбрасывается в
+// DefaultCategory).
+func (s *RecategorizationService) recategorize(db *gorm.DB, job *models.RecategorizationJob, tx *models.Transaction, result *Categorization, err error, threshold float64) (bool, error) {
+	category, source, confidence := models.DefaultCategory, models.CategorySourceDefault, 0.0
+	if answered(result, err) {
+		category, source, confidence = result.Category, result.Source, result.Confidence
+	} else if err != nil || strategyFailed(result) {
+		return false, nil
+	}
+	if category == tx.Category {
+		return false, nil
+	}
+
+	change := models.RecategorizationChange{
+		JobID:          job.ID,
+		TransactionID:  tx.ID,
+		Date:           tx.Date,
+		Description:    tx.Description,
+		FromCategory:   tx.Category,
+		FromSource:     tx.CategorySource,
+		FromConfidence: tx.CategoryConfidence,
+		ToCategory:     category,
+		ToSource:       source,
+		ToConfidence:   confidence,
+		Applied:        job.Apply,
+	}
+	if err := db.Create(&change).Error; err != nil {
+		return false, err
+	}
+	if !job.Apply {
+		return true, nil
+	}
+
+	before := AuditSnapshot(tx)
+	tx.Category = category
+	tx.SetCategorySource(source, confidence)
+	tx.NeedsReview = NeedsReview(tx, threshold)
+	isEssential, err := categoryEssential(db, tx.UserID, tx.Type, category)
+	if err != nil {
+		return false, err
+	}
+	tx.IsEssential = isEssential
+	if err := db.Model(tx).Select("category", "is_essential", "category_source", "category_confidence", "needs_review").Updates(tx).Error; err != nil {
+		return false, err
+	}
+	if err := RecordAudit(db, UserActor(job.UserID, models.AuditSourceRecategorization), models.AuditEntityTransaction, tx.ID,
+		models.AuditActionUpdate, before, AuditSnapshot(tx)); err != nil {
+		return false, err
+	}
+
+	// Объяснение категории - по новому ответу цепочки
+	explanation := models.CategoryExplanation{
+		TransactionID: tx.ID,
+		Category:      category,
+		Source:        source,
+		Confidence:    confidence,
+		Explanation:   "категория по умолчанию",
+		Decision:      fmt.Sprintf("перекатегоризация, задание #%d", job.ID),
+	}
+	if result != nil {
+		if result.Explanation != "" {
+			explanation.Explanation = result.Explanation
+		}
+		if result.Decision != "" {
+			explanation.Decision = fmt.Sprintf("%s (перекатегоризация, задание #%d)", result.Decision, job.ID)
+		}
+		explanation.RuleID = result.RuleID
+		explanation.Steps = result.Steps
+	}
+	return true, db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "transaction_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"category", "source", "confidence", "explanation", "decision", "rule_id", "steps", "created_at"}),
+	}).Create(&explanation).Error
+}
+
+// strategyFailed - была ли среди опрошенных стратегий недоступная
+func strategyFailed(result *Categorization) bool {
+	if result == nil {
+		return false
+	}
+	for _, step := range result.Steps {
+		if step.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// categoryEssential - признак обязательности категории из справочника пользователя;
+// отсутствующая категория добавляется в справочник
+func categoryEssential(db *gorm.DB, userID uint, categoryType, name string) (bool, error) {
+	category := models.Category{UserID: userID, Type: categoryType, Name: name}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&category).Error; err != nil {
+		return false, err
+	}
+	if err := db.Where("user_id = ? AND type = ? AND name = ?", userID, categoryType, name).First(&category).Error; err != nil {
+		return false, err
+	}
+	return category.IsEssential, nil
+}
+
+// setStatus - переводит задание в status, если его не отменили; columns - что еще сохранить
+func (s *RecategorizationService) setStatus(job *models.RecategorizationJob, status string, columns ...string) bool {
+	job.Status = status
+	result := s.db.Model(job).
+		Where("status IN ?", []string{models.RecategorizationPending, models.RecategorizationRunning}).
+		Select(append([]string{"status"}, columns...)).Updates(job)
+	if result.Error != nil {
+		log.Printf("Recategorization job %d: failed to set status %s: %v", job.ID, status, result.Error)
+	}
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+func (s *RecategorizationService) fail(job *models.RecategorizationJob, err error) error {
+	now := time.Now()
+	job.Error = err.Error()
+	job.FinishedAt = &now
+	s.setStatus(job, models.RecategorizationFailed, "error", "finished_at")
+	return err
+}
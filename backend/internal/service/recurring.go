@@ -0,0 +1,385 @@
+package service
+
+import (
+	"clarity/internal/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxUpcomingDays - горизонт просмотра предстоящих платежей
+const maxUpcomingDays = 366
+
+// RecurringService - регулярные операции: расписание повторений и создание транзакций по наступившим датам
+type RecurringService struct {
+	db *gorm.DB
+	fx *FXService
+}
+
+func NewRecurringService(db *gorm.DB, fx *FXService) *RecurringService {
+	return &RecurringService{db: db, fx: fx}
+}
+
+// UpcomingOccurrence - предстоящее (или просроченное, но еще не созданное) повторение правила
+type UpcomingOccurrence struct {
+	RuleID      uint         `json:"rule_id"`
+	Date        time.Time    `json:"date"`
+	Amount      models.Money `json:"amount"`
+	Currency    string       `json:"currency"`
+	BaseAmount  models.Money `json:"base_amount"`
+	RateMissing bool         `json:"rate_missing,omitempty"` // Нет курса, base_amount не посчитан
+	Type        string       `json:"type"`
+	Category    string       `json:"category"`
+	Description string       `json:"description"`
+	IsEssential bool         `json:"is_essential"`
+	Overdue     bool         `json:"overdue,omitempty"` // Дата наступила, но транзакция еще не создана
+}
+
+// dateOf - дата без времени (транзакции хранятся на полночь UTC)
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// monthDay - день повторения в указанном месяце: by_month_day или день start_date,
+// для коротких месяцев и -1 - последний день месяца
+func monthDay(rule *models.RecurringRule, year int, month time.Month) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	day := rule.ByMonthDay
+	if day == 0 {
+		day = rule.StartDate.Day()
+	}
+	if day < 0 || day > last {
+		day = last
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// ValidateRule - проверка параметров расписания
+func ValidateRule(rule *models.RecurringRule) error {
+	switch rule.Frequency {
+	case models.FrequencyDaily, models.FrequencyWeekly, models.FrequencyMonthly, models.FrequencyYearly:
+	default:
+		return fmt.Errorf("frequency must be one of daily, weekly, monthly, yearly")
+	}
+	if rule.Interval < 1 || rule.Interval > 365 {
+		return fmt.Errorf("interval must be between 1 and 365")
+	}
+	if rule.ByMonthDay < -1 || rule.ByMonthDay > 31 {
+		return fmt.Errorf("by_month_day must be between 1 and 31, or -1 for the last day of month")
+	}
+	if rule.Count < 0 {
+		return fmt.Errorf("count must not be negative")
+	}
+	if rule.StartDate.IsZero() {
+		return fmt.Errorf("start_date is required")
+	}
+	if rule.EndDate != nil && rule.EndDate.Before(rule.StartDate) {
+		return fmt.Errorf("end_date must not be before start_date")
+	}
+	if rule.Amount == 0 {
+		return fmt.Errorf("amount must not be zero")
+	}
+	return nil
+}
+
+// FirstOccurrence - первое повторение не раньше start_date
+func FirstOccurrence(rule *models.RecurringRule) time.Time {
+	start := dateOf(rule.StartDate)
+	switch rule.Frequency {
+	case models.FrequencyMonthly:
+		first := monthDay(rule, start.Year(), start.Month())
+		if first.Before(start) {
+			first = monthDay(rule, start.Year(), start.Month()+1)
+		}
+		return first
+	case models.FrequencyYearly:
+		first := monthDay(rule, start.Year(), start.Month())
+		if first.Before(start) {
+			first = monthDay(rule, start.Year()+1, start.Month())
+		}
+		return first
+	default:
+		return start
+	}
+}
+
+// NextOccurrence - повторение, следующее за prev. День месяца всегда берется из правила,
+// поэтому 31-е число после короткого месяца снова становится 31-м, а не "съезжает".
+func NextOccurrence(rule *models.RecurringRule, prev time.Time) time.Time {
+	switch rule.Frequency {
+	case models.FrequencyDaily:
+		return prev.AddDate(0, 0, rule.Interval)
+	case models.FrequencyWeekly:
+		return prev.AddDate(0, 0, 7*rule.Interval)
+	case models.FrequencyMonthly:
+		return monthDay(rule, prev.Year(), prev.Month()+time.Month(rule.Interval))
+	default:
+		return monthDay(rule, prev.Year()+rule.Interval, rule.StartDate.Month())
+	}
+}
+
+// finished - правило исчерпано: достигнут COUNT или следующая дата позже UNTIL
+func finished(rule *models.RecurringRule, next time.Time) bool {
+	if rule.Count > 0 && rule.Occurrences >= rule.Count {
+		return true
+	}
+	return rule.EndDate != nil && next.After(dateOf(*rule.EndDate))
+}
+
+// setNext - записывает следующую дату или nil, если правило исчерпано
+func setNext(rule *models.RecurringRule, next time.Time) {
+	if finished(rule, next) {
+		rule.NextDate = nil
+		return
+	}
+	rule.NextDate = &next
+}
+
+// Reschedule - пересчитывает next_date после создания правила или изменения расписания.
+// Даты, на которые по правилу уже есть транзакции (в том числе в корзине), пропускаются.
+func (s *RecurringService) Reschedule(rule *models.RecurringRule) {
+	next := FirstOccurrence(rule)
+	if rule.ID != 0 {
+		var last *time.Time
+		s.db.Unscoped().Model(&models.Transaction{}).
+			Where("recurring_rule_id = ?", rule.ID).
+			Select("MAX(date)").
+			Scan(&last)
+		for last != nil && !next.After(*last) {
+			next = NextOccurrence(rule, next)
+		}
+	}
+	setNext(rule, next)
+}
+
+// SkipPast - переносит next_date на сегодня или позже (при возобновлении правила
+// пропущенные за время паузы платежи не создаются)
+func SkipPast(rule *models.RecurringRule, now time.Time) {
+	today := dateOf(now)
+	if rule.NextDate == nil {
+		return
+	}
+	next := *rule.NextDate
+	for next.Before(today) {
+		next = NextOccurrence(rule, next)
+	}
+	setNext(rule, next)
+}
+
+// MaterializeDue - создает транзакции по всем наступившим повторениям (включая пропущенные,
+// пока сервис не работал). Повторный запуск безопасен: пара (правило, дата) уникальна,
+// а правило блокируется на время обработки, поэтому несколько экземпляров не создадут дублей.
+func (s *RecurringService) MaterializeDue(now time.Time) (int, error) {
+	today := dateOf(now)
+
+	var ids []uint
+	if err := s.db.Model(&models.RecurringRule{}).
+		Where("active = ? AND next_date IS NOT NULL AND next_date <= ?", true, today).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	created := 0
+	var errs []error
+	for _, id := range ids {
+		n, err := s.materializeRule(id, today)
+		created += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %d: %w", id, err))
+		}
+	}
+	return created, errors.Join(errs...)
+}
+
+func (s *RecurringService) materializeRule(id uint, today time.Time) (int, error) {
+	created := 0
+	var rateErr error
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var rule models.RecurringRule
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ? AND active = ? AND next_date IS NOT NULL AND next_date <= ?", id, true, today).
+			First(&rule).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil // Уже обработано другим экземпляром
+		}
+		if err != nil {
+			return err
+		}
+
+		merchants, err := LoadMerchants(tx, rule.UserID)
+		if err != nil {
+			return err
+		}
+
+		for rule.NextDate != nil && !rule.NextDate.After(today) {
+			date := *rule.NextDate
+			baseAmount, err := s.fx.ToBase(rule.UserID, rule.Amount, rule.Currency, date)
+			if err != nil {
+				// Без курса транзакцию не создаем; правило дождется загрузки курсов
+				rateErr = err
+				break
+			}
+
+			ruleID := rule.ID
+			txn := &models.Transaction{
+				UserID:          rule.UserID,
+				AccountID:       rule.AccountID,
+				RecurringRuleID: &ruleID,
+				Amount:          rule.Amount,
+				Currency:        rule.Currency,
+				BaseAmount:      baseAmount,
+				Description:     rule.Description,
+				Category:        rule.Category,
+				Date:            date,
+				Type:            rule.Type,
+				IsEssential:     rule.IsEssential,
+			}
+			txn.SetCategorySource(models.CategorySourceRecurring, 1)
+			txn.NormalizeSign()
+			AssignMerchant(txn, merchants)
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(txn)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				if err := RecordAudit(tx, SystemActor(rule.UserID, models.AuditSourceRecurring), models.AuditEntityTransaction, txn.ID,
+					models.AuditActionCreate, nil, AuditSnapshot(txn)); err != nil {
+					return err
+				}
+				rule.Occurrences++
+				created++
+			}
+			setNext(&rule, NextOccurrence(&rule, date))
+		}
+
+		return tx.Model(&rule).Select("next_date", "occurrences").Updates(&rule).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return created, rateErr
+}
+
+// Upcoming - повторения активных правил пользователя на ближайшие days дней,
+// включая наступившие, но еще не созданные. Суммы в базовой валюте - по последнему известному курсу.
+func (s *RecurringService) Upcoming(userID uint, days int, now time.Time) []UpcomingOccurrence {
+	if days < 1 {
+		days = 1
+	}
+	if days > maxUpcomingDays {
+		days = maxUpcomingDays
+	}
+	today := dateOf(now)
+	horizon := today.AddDate(0, 0, days)
+
+	var rules []models.RecurringRule
+	s.db.Where("user_id = ? AND active = ? AND next_date IS NOT NULL AND next_date <= ?", userID, true, horizon).
+		Find(&rules)
+
+	items := []UpcomingOccurrence{}
+	for _, rule := range rules {
+		r := rule
+		for r.NextDate != nil && !r.NextDate.After(horizon) {
+			date := *r.NextDate
+			item := UpcomingOccurrence{
+				RuleID:      r.ID,
+				Date:        date,
+				Amount:      r.Amount,
+				Currency:    r.Currency,
+				Type:        r.Type,
+				Category:    r.Category,
+				Description: r.Description,
+				IsEssential: r.IsEssential,
+				Overdue:     !date.After(today),
+			}
+			if base, err := s.fx.ToBase(userID, r.Amount, r.Currency, date); err == nil {
+				item.BaseAmount = base
+			} else {
+				item.RateMissing = true
+			}
+			items = append(items, item)
+
+			r.Occurrences++
+			setNext(&r, NextOccurrence(&r, date))
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Date.Before(items[j].Date)
+	})
+	return items
+}
+
+// ParseRRule - разбор строки в формате RRULE (RFC 5545), например
+// "FREQ=MONTHLY;INTERVAL=1;BYMONTHDAY=5;COUNT=12" или "RRULE:FREQ=WEEKLY;UNTIL=20261231".
+// Поддерживаются FREQ, INTERVAL, BYMONTHDAY, COUNT и UNTIL.
+func ParseRRule(value string, rule *models.RecurringRule) error {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("invalid rrule part: %s", part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Frequency = strings.ToLower(val)
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("invalid INTERVAL: %s", val)
+			}
+			rule.Interval = n
+		case "BYMONTHDAY":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("invalid BYMONTHDAY: %s", val)
+			}
+			rule.ByMonthDay = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("invalid COUNT: %s", val)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := time.Parse("20060102", val)
+			if err != nil {
+				if until, err = time.Parse("20060102T150405Z", val); err != nil {
+					return fmt.Errorf("invalid UNTIL: %s", val)
+				}
+			}
+			until = dateOf(until)
+			rule.EndDate = &until
+		default:
+			return fmt.Errorf("unsupported rrule part: %s", key)
+		}
+	}
+	return nil
+}
+
+// FormatRRule - расписание правила в формате RRULE
+func FormatRRule(rule *models.RecurringRule) string {
+	parts := []string{"FREQ=" + strings.ToUpper(rule.Frequency)}
+	if rule.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", rule.Interval))
+	}
+	if rule.ByMonthDay != 0 {
+		parts = append(parts, fmt.Sprintf("BYMONTHDAY=%d", rule.ByMonthDay))
+	}
+	if rule.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", rule.Count))
+	}
+	if rule.EndDate != nil {
+		parts = append(parts, "UNTIL="+rule.EndDate.Format("20060102"))
+	}
+	return strings.Join(parts, ";")
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"clarity/internal/models"
+)
+
+func mustDate(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseRRule(t *testing.T) {
+	var rule models.RecurringRule
+	if err := ParseRRule("RRULE:FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=-1;COUNT=12;UNTIL=20261231", &rule); err != nil {
+		t.Fatal(err)
+	}
+	if rule.Frequency != models.FrequencyMonthly || rule.Interval != 2 || rule.ByMonthDay != -1 || rule.Count != 12 {
+		t.Errorf("ParseRRule = %+v", rule)
+	}
+	if rule.EndDate == nil || !rule.EndDate.Equal(mustDate("2026-12-31")) {
+		t.Errorf("EndDate = %v, want 2026-12-31", rule.EndDate)
+	}
+
+	// UNTIL с временем приводится к дате, части без учета регистра и с пустыми сегментами
+	rule = models.RecurringRule{}
+	if err := ParseRRule(" freq=weekly;;until=20260301T235959Z ", &rule); err != nil {
+		t.Fatal(err)
+	}
+	if rule.Frequency != models.FrequencyWeekly || rule.EndDate == nil || !rule.EndDate.Equal(mustDate("2026-03-01")) {
+		t.Errorf("ParseRRule = %+v, EndDate %v", rule, rule.EndDate)
+	}
+
+	for _, value := range []string{
+		"FREQ",
+		"FREQ=DAILY;INTERVAL=x",
+		"FREQ=MONTHLY;BYMONTHDAY=last",
+		"FREQ=DAILY;COUNT=1.5",
+		"FREQ=DAILY;UNTIL=2026-12-31",
+		"FREQ=WEEKLY;BYDAY=MO",
+	} {
+		if err := ParseRRule(value, &models.RecurringRule{}); err == nil {
+			t.Errorf("ParseRRule(%q) succeeded, want error", value)
+		}
+	}
+}
+
+func TestFormatRRuleRoundTrip(t *testing.T) {
+	until := mustDate("2027-01-15")
+	rules := []models.RecurringRule{
+		{Frequency: models.FrequencyDaily, Interval: 1},
+		{Frequency: models.FrequencyWeekly, Interval: 2, Count: 10},
+		{Frequency: models.FrequencyMonthly, Interval: 1, ByMonthDay: -1, EndDate: &until},
+		{Frequency: models.FrequencyYearly, Interval: 3, ByMonthDay: 29},
+	}
+	for _, rule := range rules {
+		value := FormatRRule(&rule)
+		parsed := models.RecurringRule{Interval: 1}
+		if err := ParseRRule(value, &parsed); err != nil {
+			t.Errorf("ParseRRule(%q): %v", value, err)
+			continue
+		}
+		if parsed.Frequency != rule.Frequency || parsed.Interval != rule.Interval ||
+			parsed.ByMonthDay != rule.ByMonthDay || parsed.Count != rule.Count ||
+			(parsed.EndDate == nil) != (rule.EndDate == nil) || parsed.EndDate != nil && !parsed.EndDate.Equal(*rule.EndDate) {
+			t.Errorf("round trip %q = %+v, want %+v", value, parsed, rule)
+		}
+	}
+	if got := FormatRRule(&rules[2]); got != "FREQ=MONTHLY;BYMONTHDAY=-1;UNTIL=20270115" {
+		t.Errorf("FormatRRule = %q", got)
+	}
+}
+
+func TestNextOccurrence(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  models.RecurringRule
+		first string
+		want  []string
+	}{
+		{
+			name:  "daily every 3 days",
+			rule:  models.RecurringRule{Frequency: models.FrequencyDaily, Interval: 3, StartDate: mustDate("2026-02-26")},
+			first: "2026-02-26",
+			want:  []string{"2026-03-01", "2026-03-04"},
+		},
+		{
+			name:  "weekly every 2 weeks",
+			rule:  models.RecurringRule{Frequency: models.FrequencyWeekly, Interval: 2, StartDate: mustDate("2026-12-24")},
+			first: "2026-12-24",
+			want:  []string{"2027-01-07", "2027-01-21"},
+		},
+		{
+			name:  "31st does not drift after short month",
+			rule:  models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, StartDate: mustDate("2026-01-31")},
+			first: "2026-01-31",
+			want:  []string{"2026-02-28", "2026-03-31", "2026-04-30", "2026-05-31"},
+		},
+		{
+			name:  "last day of month",
+			rule:  models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByMonthDay: -1, StartDate: mustDate("2028-01-10")},
+			first: "2028-01-31",
+			want:  []string{"2028-02-29", "2028-03-31"},
+		},
+		{
+			name:  "by month day before start moves to next month",
+			rule:  models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 2, ByMonthDay: 5, StartDate: mustDate("2026-11-10")},
+			first: "2026-12-05",
+			want:  []string{"2027-02-05", "2027-04-05"},
+		},
+		{
+			name:  "yearly from leap day",
+			rule:  models.RecurringRule{Frequency: models.FrequencyYearly, Interval: 1, StartDate: mustDate("2024-02-29")},
+			first: "2024-02-29",
+			want:  []string{"2025-02-28", "2026-02-28", "2027-02-28", "2028-02-29"},
+		},
+		{
+			name:  "yearly by month day before start",
+			rule:  models.RecurringRule{Frequency: models.FrequencyYearly, Interval: 1, ByMonthDay: 5, StartDate: mustDate("2026-03-10")},
+			first: "2027-03-05",
+			want:  []string{"2028-03-05"},
+		},
+	}
+	for _, tt := range tests {
+		next := FirstOccurrence(&tt.rule)
+		if !next.Equal(mustDate(tt.first)) {
+			t.Errorf("%s: FirstOccurrence = %s, want %s", tt.name, next.Format("2006-01-02"), tt.first)
+			continue
+		}
+		for _, want := range tt.want {
+			next = NextOccurrence(&tt.rule, next)
+			if !next.Equal(mustDate(want)) {
+				t.Errorf("%s: NextOccurrence = %s, want %s", tt.name, next.Format("2006-01-02"), want)
+				break
+			}
+		}
+	}
+}
+
+func TestSkipPastAndLimits(t *testing.T) {
+	next := mustDate("2026-01-05")
+	rule := models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, StartDate: next, NextDate: &next}
+	SkipPast(&rule, mustDate("2026-04-05").Add(15*time.Hour))
+	if rule.NextDate == nil || !rule.NextDate.Equal(mustDate("2026-04-05")) {
+		t.Errorf("SkipPast NextDate = %v, want 2026-04-05", rule.NextDate)
+	}
+
+	// UNTIL раньше следующей даты завершает правило
+	until := mustDate("2026-05-01")
+	rule.EndDate = &until
+	SkipPast(&rule, mustDate("2026-04-20"))
+	if rule.NextDate != nil {
+		t.Errorf("NextDate after UNTIL = %v, want nil", rule.NextDate)
+	}
+
+	// COUNT исчерпан
+	counted := models.RecurringRule{Frequency: models.FrequencyDaily, Interval: 1, Count: 3, Occurrences: 3, StartDate: next}
+	setNext(&counted, next)
+	if counted.NextDate != nil {
+		t.Errorf("NextDate after COUNT = %v, want nil", counted.NextDate)
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	valid := func() models.RecurringRule {
+		return models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, StartDate: mustDate("2026-01-01"), Amount: -100}
+	}
+	rule := valid()
+	if err := ValidateRule(&rule); err != nil {
+		t.Fatalf("ValidateRule(valid) = %v", err)
+	}
+
+	before := mustDate("2025-12-31")
+	broken := []func(*models.RecurringRule){
+		func(r *models.RecurringRule) { r.Frequency = "hourly" },
+		func(r *models.RecurringRule) { r.Interval = 0 },
+		func(r *models.RecurringRule) { r.Interval = 366 },
+		func(r *models.RecurringRule) { r.ByMonthDay = 32 },
+		func(r *models.RecurringRule) { r.ByMonthDay = -2 },
+		func(r *models.RecurringRule) { r.Count = -1 },
+		func(r *models.RecurringRule) { r.StartDate = time.Time{} },
+		func(r *models.RecurringRule) { r.EndDate = &before },
+		func(r *models.RecurringRule) { r.Amount = 0 },
+	}
+	for i, breakRule := range broken {
+		rule := valid()
+		breakRule(&rule)
+		if err := ValidateRule(&rule); err == nil {
+			t.Errorf("case %d: ValidateRule(%+v) = nil, want error", i, rule)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"clarity/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultReviewThreshold - порог уверенности категоризации, если у пользователя он не прочитан
+const DefaultReviewThreshold = 0.6
+
+// ReviewThreshold - порог уверенности категоризации пользователя (0 - очередь проверки отключена)
+func ReviewThreshold(db *gorm.DB, userID uint) float64 {
+	var user models.User
+	if err := db.Select("review_threshold").First(&user, userID).Error; err != nil {
+		return DefaultReviewThreshold
+	}
+	return user.ReviewThreshold
+}
+
+// NeedsReview - попадает ли категория транзакции в очередь проверки при пороге threshold
+func NeedsReview(tx *models.Transaction, threshold float64) bool {
+	return tx.TransferID == nil && tx.CategoryConfidence < threshold
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"clarity/internal/models"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// maxRulePreview - сколько совпавших транзакций показывает предпросмотр правила
+const maxRulePreview = 100
+
+// RuleEngine - применение пользовательских правил к транзакциям
+type RuleEngine struct {
+	db *gorm.DB
+}
+
+func NewRuleEngine(db *gorm.DB) *RuleEngine {
+	return &RuleEngine{db: db}
+}
+
+// CompiledRule - правило с разобранными регулярными выражениями
+type CompiledRule struct {
+	models.CategoryRule
+	description *regexp.Regexp
+	refNo       *regexp.Regexp
+}
+
+// RuleOutcome - результат применения правил к транзакции. Пустые поля правила не задавали.
+type RuleOutcome struct {
+	RuleIDs        []uint   `json:"rule_ids"` // Сработавшие правила в порядке проверки
+	Category       string   `json:"category,omitempty"`
+	CategoryRuleID uint     `json:"category_rule_id,omitempty"` // Правило, задавшее категорию
+	IsEssential    *bool    `json:"is_essential,omitempty"`
+	Description    *string  `json:"description,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+}
+
+// Matched - сработало ли хотя бы одно правило
+func (o RuleOutcome) Matched() bool {
+	return len(o.RuleIDs) > 0
+}
+
+// compilePattern - регулярное выражение условия (без учета регистра)
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// CompileRule - проверка и разбор правила
+func CompileRule(rule models.CategoryRule) (CompiledRule, error) {
+	compiled := CompiledRule{CategoryRule: rule}
+	var err error
+	if compiled.description, err = compilePattern(rule.DescriptionPattern); err != nil {
+		return compiled, fmt.Errorf("invalid description_pattern: %v", err)
+	}
+	if compiled.refNo, err = compilePattern(rule.RefNoPattern); err != nil {
+		return compiled, fmt.Errorf("invalid ref_no_pattern: %v", err)
+	}
+	return compiled, nil
+}
+
+// ValidateCategoryRule - правило должно иметь хотя бы одно условие и одно действие
+func ValidateCategoryRule(rule models.CategoryRule) error {
+	if _, err := CompileRule(rule); err != nil {
+		return err
+	}
+	if rule.Type != "" && rule.Type != "income" && rule.Type != "expense" {
+		return fmt.Errorf("type must be income or expense")
+	}
+	for _, day := range rule.Weekdays {
+		if day < 1 || day > 7 {
+			return fmt.Errorf("weekdays must be between 1 (Monday) and 7 (Sunday)")
+		}
+	}
+	if rule.MinAmount != nil && rule.MaxAmount != nil && *rule.MinAmount > *rule.MaxAmount {
+		return fmt.Errorf("min_amount must not exceed max_amount")
+	}
+	if rule.DescriptionPattern == "" && rule.RefNoPattern == "" && rule.MinAmount == nil && rule.MaxAmount == nil &&
+		rule.Type == "" && len(rule.Weekdays) == 0 && rule.AccountID == nil {
+		return fmt.Errorf("rule must have at least one condition")
+	}
+	if rule.SetCategory == "" && rule.SetEssential == nil && len(rule.AddTags) == 0 && rule.SetDescription == "" {
+		return fmt.Errorf("rule must have at least one action")
+	}
+	return nil
+}
+
+// Rules - включенные правила пользователя в порядке проверки. Правила с ошибкой
+// в регулярном выражении пропускаются (при сохранении они не проходят проверку).
+func (e *RuleEngine) Rules(userID uint) ([]CompiledRule, error) {
+	var rules []models.CategoryRule
+	if err := e.db.Where("user_id = ? AND enabled = ?", userID, true).
+		Order("priority asc, id asc").
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	compiled := make([]CompiledRule, 0, len(rules))
+	for _, rule := range rules {
+		if c, err := CompileRule(rule); err == nil {
+			compiled = append(compiled, c)
+		}
+	}
+	return compiled, nil
+}
+
+// Matches - выполняются ли все условия правила для транзакции
+func (r CompiledRule) Matches(tx *models.Transaction) bool {
+	if r.Type != "" && r.Type != tx.Type {
+		return false
+	}
+	if r.AccountID != nil && *r.AccountID != tx.AccountID {
+		return false
+	}
+	if len(r.Weekdays) > 0 {
+		weekday := int(tx.Date.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		found := false
+		for _, day := range r.Weekdays {
+			if day == weekday {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	amount := tx.BaseAmount.Abs()
+	if r.MinAmount != nil && amount < *r.MinAmount {
+		return false
+	}
+	if r.MaxAmount != nil && amount > *r.MaxAmount {
+		return false
+	}
+	if r.description != nil && !r.description.MatchString(tx.Description) {
+		return false
+	}
+	if r.refNo != nil && !r.refNo.MatchString(tx.RefNo) {
+		return false
+	}
+	return true
+}
+
+// rewriteDescription - новое описание: шаблон set_description, в котором $1, $2 и ${name}
+// заменяются группами из description_pattern
+func (r CompiledRule) rewriteDescription(description string) string {
+	if r.description == nil || !strings.Contains(r.SetDescription, "$") {
+		return r.SetDescription
+	}
+	match := r.description.FindStringSubmatchIndex(description)
+	if match == nil {
+		return r.SetDescription
+	}
+	return string(r.description.ExpandString(nil, r.SetDescription, description, match))
+}
+
+// Evaluate - применение правил к транзакции (сама транзакция не меняется). Каждое поле задает
+// первое сработавшее правило, метки объединяются.
+func Evaluate(rules []CompiledRule, tx *models.Transaction) RuleOutcome {
+	outcome := RuleOutcome{RuleIDs: []uint{}}
+	for _, rule := range rules {
+		if !rule.Matches(tx) {
+			continue
+		}
+		outcome.RuleIDs = append(outcome.RuleIDs, rule.ID)
+		if outcome.Category == "" && rule.SetCategory != "" {
+			outcome.Category = rule.SetCategory
+			outcome.CategoryRuleID = rule.ID
+		}
+		if outcome.IsEssential == nil && rule.SetEssential != nil {
+			essential := *rule.SetEssential
+			outcome.IsEssential = &essential
+		}
+		if outcome.Description == nil && rule.SetDescription != "" {
+			description := rule.rewriteDescription(tx.Description)
+			outcome.Description = &description
+		}
+		outcome.Tags = NormalizeTags(append(outcome.Tags, rule.AddTags...))
+		if rule.StopProcessing {
+			break
+		}
+	}
+	return outcome
+}
+
+// RulePreviewItem - транзакция, которая совпала бы с правилом, и что бы в ней изменилось
+type RulePreviewItem struct {
+	Transaction models.Transaction `json:"transaction"`
+	Outcome     RuleOutcome        `json:"outcome"`
+}
+
+// Preview - прошлые транзакции, подходящие под правило (самые новые первыми, не более 100),
+// и общее число совпадений. Ноги переводов правилами не обрабатываются.
+func (e *RuleEngine) Preview(userID uint, rule CompiledRule) ([]RulePreviewItem, int, error) {
+	var txs []models.Transaction
+	query := e.db.Preload("Tags").Where("user_id = ? AND transfer_id IS NULL", userID)
+	if rule.Type != "" {
+		query = query.Where("type = ?", rule.Type)
+	}
+	if rule.AccountID != nil {
+		query = query.Where("account_id = ?", *rule.AccountID)
+	}
+	if err := query.Order("date desc, id desc").Find(&txs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	items := []RulePreviewItem{}
+	total := 0
+	for i := range txs {
+		if !rule.Matches(&txs[i]) {
+			continue
+		}
+		total++
+		if len(items) < maxRulePreview {
+			items = append(items, RulePreviewItem{Transaction: txs[i], Outcome: Evaluate([]CompiledRule{rule}, &txs[i])})
+		}
+	}
+	return items, total, nil
+}
+
+// ApplyOutcome - записывает в транзакцию результат правил. Поля из keep не меняются
+// (их значение задано явно). У транзакции с разбивкой категорию и признак обязательности
+// задают части, поэтому правила их не меняют. Категория от правила считается проверенной.
+// Возвращает true, если что-то изменилось.
+func ApplyOutcome(tx *models.Transaction, outcome RuleOutcome, keepCategory, keepEssential bool) bool {
+	changed := false
+	split := len(tx.Splits) > 0
+	if outcome.Category != "" && !keepCategory && !split && tx.Category != outcome.Category {
+		tx.Category = outcome.Category
+		tx.SetCategorySource(models.CategorySourceRule, 1)
+		tx.NeedsReview = false
+		changed = true
+	}
+	if outcome.IsEssential != nil && !keepEssential && !split && tx.IsEssential != *outcome.IsEssential {
+		tx.IsEssential = *outcome.IsEssential
+		changed = true
+	}
+	if outcome.Description != nil && tx.Description != *outcome.Description {
+		tx.Description = *outcome.Description
+		changed = true
+	}
+	return changed
+}
+
+// ApplyToHistoryResult - итог повторного применения правил
+type ApplyToHistoryResult struct {
+	Checked int `json:"checked"` // Проверено транзакций
+	Matched int `json:"matched"` // Подошло хотя бы одно правило
+	Updated int `json:"updated"` // Транзакция изменилась
+}
+
+// ApplyToHistory - повторно применяет включенные правила (или только ruleIDs) к транзакциям
+// пользователя за период [start, end] (YYYY-MM-DD, пустая граница - без ограничения).
+// Правила перезаписывают категорию, признак обязательности и описание; метки добавляются.
+// Категория и признак обязательности, заданные вручную (CategorySourceManual), сохраняются.
+// Каждое изменение записывается в журнал в той же транзакции БД.
+func (e *RuleEngine) ApplyToHistory(userID uint, ruleIDs []uint, start, end string) (ApplyToHistoryResult, error) {
+	var result ApplyToHistoryResult
+
+	rules, err := e.Rules(userID)
+	if err != nil {
+		return result, err
+	}
+	if len(ruleIDs) > 0 {
+		selected := make(map[uint]bool, len(ruleIDs))
+		for _, id := range ruleIDs {
+			selected[id] = true
+		}
+		filtered := rules[:0]
+		for _, rule := range rules {
+			if selected[rule.ID] {
+				filtered = append(filtered, rule)
+			}
+		}
+		rules = filtered
+	}
+	if len(rules) == 0 {
+		return result, nil
+	}
+
+	var txs []models.Transaction
+	query := e.db.Preload("Splits").Preload("Tags").Where("user_id = ? AND transfer_id IS NULL", userID)
+	if start != "" {
+		query = query.Where("date >= ?", start)
+	}
+	if end != "" {
+		query = query.Where("date <= ?", end)
+	}
+	if err := query.Order("date asc, id asc").Find(&txs).Error; err != nil {
+		return result, err
+	}
+
+	actor := UserActor(userID, models.AuditSourceRules)
+	err = e.db.Transaction(func(db *gorm.DB) error {
+		for i := range txs {
+			tx := &txs[i]
+			result.Checked++
+			outcome := Evaluate(rules, tx)
+			if !outcome.Matched() {
+				continue
+			}
+			result.Matched++
+
+			before := AuditSnapshot(tx)
+			// Категорию, выбранную пользователем, правила не перезаписывают
+			manual := tx.CategorySource == models.CategorySourceManual
+			changed := ApplyOutcome(tx, outcome, manual, manual)
+			if changed {
+				if err := db.Model(tx).Select("category", "is_essential", "description", "category_source", "category_confidence", "needs_review").Updates(tx).Error; err != nil {
+					return err
+				}
+			}
+
+			newTags := missingTags(tx.Tags, outcome.Tags)
+			if len(newTags) > 0 {
+				tags, err := EnsureTags(db, userID, newTags)
+				if err != nil {
+					return err
+				}
+				if err := db.Model(tx).Association("Tags").Append(tags); err != nil {
+					return err
+				}
+				changed = true
+			}
+			if changed {
+				if err := RecordAudit(db, actor, models.AuditEntityTransaction, tx.ID, models.AuditActionUpdate, before, AuditSnapshot(tx)); err != nil {
+					return err
+				}
+				result.Updated++
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// missingTags - метки из names, которых еще нет у транзакции
+func missingTags(existing []models.Tag, names []string) []string {
+	has := make(map[string]bool, len(existing))
+	for _, tag := range existing {
+		has[strings.ToLower(tag.Name)] = true
+	}
+	var missing []string
+	for _, name := range names {
+		if !has[strings.ToLower(name)] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"clarity/internal/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Периодичность найденной подписки
+const (
+	CadenceWeekly  = "weekly"
+	CadenceMonthly = "monthly"
+	CadenceYearly  = "yearly"
+)
+
+const (
+	// subscriptionLookback - глубина истории для поиска подписок (годовым нужно минимум два списания)
+	subscriptionLookback = 25 // месяцев
+	// subscriptionAmountTolerance - допустимое отличие суммы от предыдущего списания той же подписки
+	subscriptionAmountTolerance = 0.2
+	// subscriptionRegularShare - доля интервалов, которые должны соответствовать периодичности
+	subscriptionRegularShare = 0.75
+)
+
+// cadenceSpec - допустимый интервал между списаниями и минимальное число списаний
+type cadenceSpec struct {
+	name           string
+	minDays        int
+	maxDays        int
+	minOccurrences int
+	perYear        float64
+	graceDays      int // Через сколько дней после ожидаемой даты подписка считается отмененной
+}
+
+var cadences = []cadenceSpec{
+	{name: CadenceWeekly, minDays: 5, maxDays: 9, minOccurrences: 4, perYear: 52, graceDays: 7},
+	{name: CadenceMonthly, minDays: 26, maxDays: 35, minOccurrences: 3, perYear: 12, graceDays: 15},
+	{name: CadenceYearly, minDays: 350, maxDays: 380, minOccurrences: 2, perYear: 1, graceDays: 45},
+}
+
+// SubscriptionDetector - поиск подписок и регулярных платежей по истории расходов,
+// в том числе тех, для которых пользователь не завел регулярную операцию
+type SubscriptionDetector struct {
+	db *gorm.DB
+}
+
+func NewSubscriptionDetector(db *gorm.DB) *SubscriptionDetector {
+	return &SubscriptionDetector{db: db}
+}
+
+// PriceChange - изменение суммы списания
+type PriceChange struct {
+	Date          time.Time    `json:"date"` // Первое списание по новой цене
+	OldAmount     models.Money `json:"old_amount"`
+	NewAmount     models.Money `json:"new_amount"`
+	ChangePercent float64      `json:"change_percent"`
+}
+
+// Subscription - найденная подписка. Суммы положительные: amount - в валюте списаний,
+// base_amount и annual_cost - в базовой валюте по последнему списанию.
+type Subscription struct {
+	Name             string        `json:"name"`
+	Category         string        `json:"category"`
+	Cadence          string        `json:"cadence"`
+	Amount           models.Money  `json:"amount"`
+	Currency         string        `json:"currency"`
+	BaseAmount       models.Money  `json:"base_amount"`
+	AnnualCost       models.Money  `json:"annual_cost"`
+	AverageInterval  float64       `json:"average_interval_days"`
+	Occurrences      int           `json:"occurrences"`
+	FirstDate        time.Time     `json:"first_date"`
+	LastDate         time.Time     `json:"last_date"`
+	NextExpectedDate time.Time     `json:"next_expected_date"`
+	Active           bool          `json:"active"`                      // false - ожидаемое списание давно не пришло
+	RecurringRuleID  *uint         `json:"recurring_rule_id,omitempty"` // Последнее списание создано регулярной операцией
+	PriceChanges     []PriceChange `json:"price_changes"`
+	TransactionIDs   []uint        `json:"transaction_ids"`
+}
+
+// Detect - подписки пользователя на дату now, по убыванию годовой стоимости
+func (d *SubscriptionDetector) Detect(userID uint, now time.Time) []Subscription {
+	var txs []models.Transaction
+	d.db.Where("user_id = ? AND type = 'expense' AND transfer_id IS NULL AND date >= ?",
+		userID, now.AddDate(0, -subscriptionLookback, 0).Format("2006-01-02")).
+		Order("date asc, id asc").
+		Find(&txs)
+
+	// Группы по описанию (или ref_no, если описания нет) и валюте
+	groups := make(map[string][]models.Transaction)
+	var keys []string
+	for _, tx := range txs {
+		name := models.MerchantKey(tx.Description, tx.RefNo)
+		if name == "" {
+			continue
+		}
+		key := tx.Currency + ":" + name
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], tx)
+	}
+
+	result := []Subscription{}
+	for _, key := range keys {
+		for _, series := range splitByAmount(groups[key]) {
+			if sub, ok := detectSeries(series, now); ok {
+				result = append(result, sub)
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].AnnualCost > result[j].AnnualCost
+	})
+	return result
+}
+
+// splitByAmount - делит списания одного получателя на серии с близкими суммами.
+// Сумма сравнивается с последним списанием серии, поэтому постепенное подорожание
+// остается в той же серии, а разные тарифы одного сервиса - в разных.
+func splitByAmount(txs []models.Transaction) [][]models.Transaction {
+	var series [][]models.Transaction
+	for _, tx := range txs {
+		placed := false
+		for i := range series {
+			last := series[i][len(series[i])-1].Amount.Abs()
+			if last > 0 && (tx.Amount.Abs()-last).Abs().Ratio(last) <= subscriptionAmountTolerance {
+				series[i] = append(series[i], tx)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			series = append(series, []models.Transaction{tx})
+		}
+	}
+	return series
+}
+
+// detectSeries - проверка периодичности серии списаний
+func detectSeries(series []models.Transaction, now time.Time) (Subscription, bool) {
+	if len(series) < 2 {
+		return Subscription{}, false
+	}
+
+	intervals := make([]int, 0, len(series)-1)
+	for i := 1; i < len(series); i++ {
+		intervals = append(intervals, int(dateOf(series[i].Date).Sub(dateOf(series[i-1].Date)).Hours()/24))
+	}
+	sorted := append([]int(nil), intervals...)
+	sort.Ints(sorted)
+	median := sorted[len(sorted)/2]
+
+	for _, spec := range cadences {
+		if median < spec.minDays || median > spec.maxDays || len(series) < spec.minOccurrences {
+			continue
+		}
+		regular, total := 0, 0
+		for _, interval := range intervals {
+			total += interval
+			if interval >= spec.minDays && interval <= spec.maxDays {
+				regular++
+			}
+		}
+		if float64(regular) < subscriptionRegularShare*float64(len(intervals)) {
+			return Subscription{}, false
+		}
+		return buildSubscription(series, spec, float64(total)/float64(len(intervals)), now), true
+	}
+	return Subscription{}, false
+}
+
+func buildSubscription(series []models.Transaction, spec cadenceSpec, avgInterval float64, now time.Time) Subscription {
+	first, last := series[0], series[len(series)-1]
+	sub := Subscription{
+		Name:            last.Description,
+		Category:        last.Category,
+		Cadence:         spec.name,
+		Amount:          last.Amount.Abs(),
+		Currency:        last.Currency,
+		BaseAmount:      last.BaseAmount.Abs(),
+		AnnualCost:      last.BaseAmount.Abs().MulRate(spec.perYear),
+		AverageInterval: avgInterval,
+		Occurrences:     len(series),
+		FirstDate:       first.Date,
+		LastDate:        last.Date,
+		RecurringRuleID: last.RecurringRuleID,
+		PriceChanges:    []PriceChange{},
+	}
+	if sub.Name == "" {
+		sub.Name = last.RefNo
+	}
+
+	for i, tx := range series {
+		sub.TransactionIDs = append(sub.TransactionIDs, tx.ID)
+		if i == 0 {
+			continue
+		}
+		prev, cur := series[i-1].Amount.Abs(), tx.Amount.Abs()
+		if cur != prev {
+			sub.PriceChanges = append(sub.PriceChanges, PriceChange{
+				Date:          tx.Date,
+				OldAmount:     prev,
+				NewAmount:     cur,
+				ChangePercent: (cur - prev).Ratio(prev) * 100,
+			})
+		}
+	}
+
+	// День месяца берется из первого списания, чтобы 31-е после короткого месяца снова стало 31-м
+	lastDate, anchor := dateOf(last.Date), dateOf(first.Date)
+	switch spec.name {
+	case CadenceWeekly:
+		sub.NextExpectedDate = lastDate.AddDate(0, 0, 7)
+	case CadenceMonthly:
+		sub.NextExpectedDate = sameDayIn(anchor, lastDate.Year(), lastDate.Month()+1)
+	default:
+		sub.NextExpectedDate = sameDayIn(anchor, lastDate.Year()+1, lastDate.Month())
+	}
+	sub.Active = !dateOf(now).After(sub.NextExpectedDate.AddDate(0, 0, spec.graceDays))
+	return sub
+}
+
+// sameDayIn - день date в указанном месяце (для коротких месяцев - последний день)
+func sameDayIn(date time.Time, year int, month time.Month) time.Time {
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	day := date.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
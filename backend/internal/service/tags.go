@@ -0,0 +1,141 @@
+package service
+
+import (
+	"clarity/internal/models"
+	"math"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NormalizeTags - метки без пробелов по краям, пустых значений и повторов (порядок сохраняется)
+func NormalizeTags(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		result = append(result, name)
+	}
+	return result
+}
+
+// EnsureTags - метки пользователя по именам; отсутствующие создаются
+func EnsureTags(db *gorm.DB, userID uint, names []string) ([]models.Tag, error) {
+	names = NormalizeTags(names)
+	if len(names) == 0 {
+		return []models.Tag{}, nil
+	}
+
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, models.Tag{UserID: userID, Name: name})
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	var result []models.Tag
+	err := db.Where("user_id = ? AND name IN ?", userID, names).Order("name asc").Find(&result).Error
+	return result, err
+}
+
+// TagNames - имена меток
+func TagNames(tags []models.Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+// TagAmount - суммы по метке за период в базовой валюте
+type TagAmount struct {
+	Tag         string       `json:"tag"`
+	Expense     models.Money `json:"expense"`
+	Income      models.Money `json:"income"`
+	Count       int          `json:"count"` // Транзакции с меткой
+	Share       float64      `json:"share"` // Доля расходов метки во всех расходах периода, %
+	Investments models.Money `json:"investments"`
+	Deposits    models.Money `json:"deposits"`
+}
+
+// TagBreakdown - расходы и доходы по меткам за период [start, end] (YYYY-MM-DD), а также
+// инвестиции (по дате вложения) и вклады (по дате открытия) с меткой. totalExpense - все
+// расходы периода для доли. Транзакция с несколькими метками учитывается в каждой, поэтому
+// доли в сумме могут превышать 100. Ноги переводов не учитываются.
+func TagBreakdown(db *gorm.DB, userID uint, start, end string, totalExpense models.Money) ([]TagAmount, error) {
+	var cashflows []TagAmount
+	if err := db.Table("tags").
+		Select("tags.name AS tag, "+
+			"COALESCE(-SUM(t.base_amount) FILTER (WHERE t.type = 'expense'), 0) AS expense, "+
+			"COALESCE(SUM(t.base_amount) FILTER (WHERE t.type = 'income'), 0) AS income, "+
+			"COUNT(t.id) AS count").
+		Joins("JOIN transaction_tags tt ON tt.tag_id = tags.id").
+		Joins("JOIN transactions t ON t.id = tt.transaction_id").
+		Where("tags.user_id = ? AND t.deleted_at IS NULL AND t.transfer_id IS NULL AND t.date >= ? AND t.date <= ?", userID, start, end).
+		Group("tags.name").
+		Scan(&cashflows).Error; err != nil {
+		return nil, err
+	}
+
+	var investments, deposits []TagAmount
+	if err := db.Table("tags").
+		Select("tags.name AS tag, COALESCE(SUM(i.base_amount), 0) AS investments").
+		Joins("JOIN investment_tags it ON it.tag_id = tags.id").
+		Joins("JOIN investments i ON i.id = it.investment_id").
+		Where("tags.user_id = ? AND i.deleted_at IS NULL AND i.date >= ? AND i.date <= ?", userID, start, end).
+		Group("tags.name").
+		Scan(&investments).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Table("tags").
+		Select("tags.name AS tag, COALESCE(SUM(d.base_amount), 0) AS deposits").
+		Joins("JOIN deposit_tags dt ON dt.tag_id = tags.id").
+		Joins("JOIN deposits d ON d.id = dt.deposit_id").
+		Where("tags.user_id = ? AND d.deleted_at IS NULL AND d.open_date >= ? AND d.open_date <= ?", userID, start, end).
+		Group("tags.name").
+		Scan(&deposits).Error; err != nil {
+		return nil, err
+	}
+
+	byTag := make(map[string]*TagAmount)
+	var result []*TagAmount
+	get := func(tag string) *TagAmount {
+		if item, ok := byTag[tag]; ok {
+			return item
+		}
+		item := &TagAmount{Tag: tag}
+		byTag[tag] = item
+		result = append(result, item)
+		return item
+	}
+	for _, item := range cashflows {
+		amount := get(item.Tag)
+		amount.Expense, amount.Income, amount.Count = item.Expense, item.Income, item.Count
+		amount.Share = math.Round(item.Expense.Ratio(totalExpense)*10000) / 100
+	}
+	for _, item := range investments {
+		get(item.Tag).Investments = item.Investments
+	}
+	for _, item := range deposits {
+		get(item.Tag).Deposits = item.Deposits
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Expense != result[j].Expense {
+			return result[i].Expense > result[j].Expense
+		}
+		return result[i].Tag < result[j].Tag
+	})
+	amounts := make([]TagAmount, len(result))
+	for i, item := range result {
+		amounts[i] = *item
+	}
+	return amounts, nil
+}
@@ -1,7 +1,7 @@
 package service
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,7 +14,7 @@ type YandexGPTClient struct {
 	folderID string
 	modelURI string
 	baseURL  string
-	client   *http.Client
+	client   *OutboundClient
 }
 
 func NewYandexGPTClient(apiKey, folderID, modelURI string) *YandexGPTClient {
@@ -23,9 +23,8 @@ func NewYandexGPTClient(apiKey, folderID, modelURI string) *YandexGPTClient {
 		folderID: folderID,
 		modelURI: modelURI,
 		baseURL:  "https://llm.api.cloud.yandex.net/foundationModels/v1/completion",
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		// Ответ модели платный и не детерминирован: без повторов
+		client: NewOutboundClient("yandexgpt", 30*time.Second, 0),
 	}
 }
 
@@ -60,7 +59,7 @@ type YandexGPTResponse struct {
 	} `json:"result"`
 }
 
-func (c *YandexGPTClient) Chat(messages []YandexGPTMessage) (string, error) {
+func (c *YandexGPTClient) Chat(ctx context.Context, messages []YandexGPTMessage) (string, error) {
 	if c.apiKey == "" || c.modelURI == "" {
 		return "", fmt.Errorf("YandexGPT не настроен: проверьте API ключ и model URI")
 	}
@@ -91,15 +90,15 @@ func (c *YandexGPTClient) Chat(messages []YandexGPTMessage) (string, error) {
 		return "", fmt.Errorf("ошибка маршалинга запроса: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", c.baseURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("ошибка создания запроса: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Api-Key %s", c.apiKey))
+	header := http.Header{}
+	header.Set("Authorization", fmt.Sprintf("Api-Key %s", c.apiKey))
 
-	resp, err := c.client.Do(req)
+	resp, err := c.client.Do(ctx, OutboundRequest{
+		Method: http.MethodPost,
+		URL:    c.baseURL,
+		Body:   jsonData,
+		Header: header,
+	})
 	if err != nil {
 		return "", fmt.Errorf("ошибка HTTP запроса: %w", err)
 	}
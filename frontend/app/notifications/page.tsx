@@ -84,7 +84,7 @@ export default function NotificationsPage() {
         }
 
         const data = await response.json()
-        setNotifications(data)
+        setNotifications(data.items)
       } catch (err) {
         setError(err instanceof Error ? err.message : "Произошла ошибка")
         console.error("Error fetching notifications:", err)
@@ -45,7 +45,7 @@ export function Anomalies() {
           throw new Error("Не удалось загрузить уведомления")
         }
 
-        const data: Notification[] = await response.json()
+        const { items: data }: { items: Notification[] } = await response.json()
         // Фильтруем ТОЛЬКО аномалии (не превышения лимитов)
         const filtered = data.filter(n => n.type === "anomaly")
         setNotifications(filtered)
@@ -90,7 +90,7 @@ export function DepositsList() {
         }
 
         const data = await response.json()
-        setDeposits(data)
+        setDeposits(data.items)
       } catch (err) {
         setError(err instanceof Error ? err.message : "Произошла ошибка")
         console.error("Error fetching deposits:", err)
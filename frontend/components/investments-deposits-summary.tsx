@@ -61,8 +61,8 @@ export function InvestmentsDepositsSummary() {
         const investmentsData = await investmentsRes.json()
         const depositsData = await depositsRes.json()
 
-        setInvestments(investmentsData)
-        setDeposits(depositsData)
+        setInvestments(investmentsData.items)
+        setDeposits(depositsData.items)
       } catch (err) {
         setError(err instanceof Error ? err.message : "Произошла ошибка")
         console.error("Error fetching investments/deposits:", err)
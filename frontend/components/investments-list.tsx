@@ -103,7 +103,7 @@ export function InvestmentsList() {
         }
 
         const data = await response.json()
-        setInvestments(data)
+        setInvestments(data.items)
       } catch (err) {
         setError(err instanceof Error ? err.message : "Произошла ошибка")
         console.error("Error fetching investments:", err)
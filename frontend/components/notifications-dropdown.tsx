@@ -81,7 +81,7 @@ export function NotificationsDropdown() {
         }
 
         const data = await response.json()
-        setNotifications(data)
+        setNotifications(data.items)
       } catch (err) {
         console.error("Error fetching notifications:", err)
       } finally {
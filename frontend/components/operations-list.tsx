@@ -166,7 +166,7 @@ export function OperationsList({ activeFilter, searchQuery, dateRange }: Operati
   const [operations, setOperations] = useState<Operation[]>([])
   const [isLoading, setIsLoading] = useState(true)
   const [error, setError] = useState<string | null>(null)
-  const [offset, setOffset] = useState(0)
+  const [cursor, setCursor] = useState<string | null>(null)
   const [hasMore, setHasMore] = useState(true)
   const [selectedTransaction, setSelectedTransaction] = useState<Transaction | null>(null)
   const [isEditing, setIsEditing] = useState(false)
@@ -194,9 +194,10 @@ export function OperationsList({ activeFilter, searchQuery, dateRange }: Operati
         setError(null)
       }
 
-      const currentOffset = reset ? 0 : offset
+      const currentCursor = reset ? null : cursor
       
-      let url = `${apiUrl("/transactions")}?limit=${limit}&offset=${currentOffset}`
+      let url = `${apiUrl("/transactions")}?limit=${limit}`
+      if (currentCursor) url += `&cursor=${encodeURIComponent(currentCursor)}`
       
       if (activeFilter === "expense") url += "&type=expense"
       else if (activeFilter === "income") url += "&type=income"
@@ -220,7 +221,8 @@ export function OperationsList({ activeFilter, searchQuery, dateRange }: Operati
         throw new Error("Не удалось загрузить транзакции")
       }
 
-      const data: Transaction[] = await response.json()
+      const page: { items: Transaction[]; next_cursor?: string } = await response.json()
+      const data = page.items
 
       // Клиентская фильтрация
       let filteredData = data
@@ -243,26 +245,25 @@ export function OperationsList({ activeFilter, searchQuery, dateRange }: Operati
 
       if (reset) {
         setOperations(newOperations)
-        setOffset(limit)
       } else {
         setOperations((prev) => [...prev, ...newOperations])
-        setOffset((prev) => prev + limit)
       }
 
-      setHasMore(data.length === limit)
+      setCursor(page.next_cursor ?? null)
+      setHasMore(Boolean(page.next_cursor))
 
     } catch (err) {
       setError(err instanceof Error ? err.message : "Произошла ошибка")
     } finally {
       setIsLoading(false)
     }
-  }, [token, activeFilter, offset, searchQuery, dateRange]) 
+  }, [token, activeFilter, cursor, searchQuery, dateRange]) 
 
   // Эффект обновления
   useEffect(() => {
     const timer = setTimeout(() => {
       setOperations([])
-      setOffset(0)
+      setCursor(null)
       fetchTransactions(true) 
     }, 300)
 
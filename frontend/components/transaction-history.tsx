@@ -146,7 +146,7 @@ export function TransactionHistory() {
           throw new Error("Не удалось загрузить транзакции")
         }
 
-        const data: Transaction[] = await response.json()
+        const { items: data }: { items: Transaction[] } = await response.json()
         
         // 3. Сортируем: Новые (с большим ID или датой) должны быть сверху
         // Если данные приходят в порядке [Старая -> Новая], мы их разворачиваем.
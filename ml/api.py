@@ -5,7 +5,7 @@ from datetime import datetime
 from fastapi import FastAPI, HTTPException
 from fastapi.middleware.cors import CORSMiddleware
 from pydantic import BaseModel, Field
-from typing import List, Optional
+from typing import Dict, List, Optional
 import os
 import joblib
 import logging
@@ -286,6 +286,11 @@ class AnalyzeResponse(BaseModel):
     mandatory_expenses: MandatoryExpenses
     optimization_plan: List[OptimizationAdvice]
 
+class FXRatesResponse(BaseModel):
+    base: str
+    date: str
+    rates: Dict[str, float]
+
 # --- Класс для финансового анализа ---
 class FinancialBrain:
     """Класс для анализа финансов и прогнозирования"""
@@ -624,6 +629,51 @@ async def analyze(transactions: List[AnalyzeTransaction]):
     except Exception as e:
         raise HTTPException(status_code=500, detail=f"Analysis error: {str(e)}")
 
+# Справочные курсы для локальной разработки: сколько рублей стоит 1 единица валюты.
+# Это заглушка внешнего сервиса курсов (FX_RATES_URL), курс не зависит от даты.
+REFERENCE_RUB_RATES = {
+    'RUB': 1.0,
+    'USD': 92.5,
+    'EUR': 100.2,
+    'GBP': 117.4,
+    'CNY': 12.75,
+    'KZT': 0.185,
+    'BYN': 28.3,
+    'TRY': 2.85,
+    'AED': 25.2,
+    'JPY': 0.615,
+}
+
+@app.get("/fx_rates", response_model=FXRatesResponse)
+async def fx_rates(base: str = 'RUB', date: Optional[str] = None):
+    """
+    Курсы валют на дату (заглушка сервиса курсов для FX_RATES_URL)
+
+    Принимает:
+    - base: базовая валюта (ISO 4217), по умолчанию RUB
+    - date: дата курсов (YYYY-MM-DD), по умолчанию сегодня
+
+    Возвращает:
+    - rates: сколько единиц каждой валюты стоит 1 единица base
+    """
+    base = base.strip().upper()
+    if base not in REFERENCE_RUB_RATES:
+        raise HTTPException(status_code=400, detail=f"Unknown base currency: {base}")
+    if date is None:
+        date = datetime.now().strftime('%Y-%m-%d')
+    try:
+        datetime.strptime(date, '%Y-%m-%d')
+    except ValueError:
+        raise HTTPException(status_code=400, detail="Invalid date. Use YYYY-MM-DD")
+
+    base_rub = REFERENCE_RUB_RATES[base]
+    rates = {
+        code: round(base_rub / rub, 6)
+        for code, rub in REFERENCE_RUB_RATES.items()
+        if code != base
+    }
+    return FXRatesResponse(base=base, date=date, rates=rates)
+
 @app.get("/model_info")
 async def model_info():
     """Информация о модели"""
@@ -653,6 +703,7 @@ if __name__ == '__main__':
     print("  POST /predict       - Предсказание (расширенный формат)")
     print("  POST /batch_predict - Пакетное предсказание")
     print("  POST /analyze       - Финансовый анализ с ML-прогнозированием")
+    print("  GET  /fx_rates      - Курсы валют (заглушка для FX_RATES_URL)")
     print("="*50 + "\n")
     
     uvicorn.run(app, host="0.0.0.0", port=5000)